@@ -166,6 +166,10 @@ type Config struct {
 	// modFlag will be used for -modfile in go command invocations.
 	modFlag string
 
+	// driverExtensions, if set, receives the protocol extensions declared
+	// in the response of an external driver.
+	driverExtensions *packagesinternal.DriverExtensions
+
 	// Fset provides source position information for syntax trees and types.
 	// If Fset is nil, Load will use a new fileset, but preserve Fset's value.
 	Fset *token.FileSet
@@ -242,6 +246,21 @@ type driverResponse struct {
 	// (e.g. the go command on the PATH) when selecting .go files.
 	// Zero means unknown.
 	GoVersion int
+
+	// The following fields are optional extensions of the driver protocol,
+	// which are never set by the go list driver.
+
+	// Incremental reports that the driver supports incremental queries:
+	// given "file=" patterns for a set of changed files, it reports only
+	// the packages containing those files (and their dependencies), so
+	// that a client need not reload the entire workspace.
+	Incremental bool `json:",omitempty"`
+
+	// FileOwners maps absolute file names to the IDs of the packages
+	// whose metadata depends on them. Unlike the file lists of each
+	// Package, it may include build inputs such as BUILD files, so that
+	// a client can tell which packages to reload when they change.
+	FileOwners map[string][]string `json:",omitempty"`
 }
 
 // Load loads and returns the Go packages named by the given patterns.
@@ -264,6 +283,10 @@ func Load(cfg *Config, patterns ...string) ([]*Package, error) {
 	if err != nil {
 		return nil, err
 	}
+	if ext := ld.Config.driverExtensions; ext != nil {
+		ext.Incremental = response.Incremental
+		ext.FileOwners = response.FileOwners
+	}
 
 	ld.sizes = types.SizesFor(response.Compiler, response.Arch)
 	if ld.sizes == nil && ld.Config.Mode&(NeedTypes|NeedTypesSizes|NeedTypesInfo) != 0 {
@@ -439,6 +462,9 @@ func init() {
 	packagesinternal.SetModFlag = func(config interface{}, value string) {
 		config.(*Config).modFlag = value
 	}
+	packagesinternal.SetDriverExtensions = func(config interface{}, ext *packagesinternal.DriverExtensions) {
+		config.(*Config).driverExtensions = ext
+	}
 	packagesinternal.TypecheckCgo = int(typecheckCgo)
 	packagesinternal.DepsErrors = int(needInternalDepsErrors)
 	packagesinternal.ForTest = int(needInternalForTest)
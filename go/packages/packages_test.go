@@ -38,6 +38,7 @@ import (
 var testCtx = context.Background()
 
 func TestMain(m *testing.M) {
+	packagestest.RunFakeDriverIfRequested()
 	testenv.ExitIfSmallMachine()
 
 	timeoutFlag := flag.Lookup("test.timeout")
@@ -2741,6 +2742,62 @@ func main() {
 	}
 }
 
+func TestExternal_Extensions(t *testing.T) {
+	testAllOrModulesParallel(t, testExternal_Extensions)
+}
+func testExternal_Extensions(t *testing.T, exporter packagestest.Exporter) {
+	exported := packagestest.Export(t, exporter, []packagestest.Module{{
+		Name: "golang.org/fake",
+		Files: map[string]interface{}{
+			"a/a.go":  `package a; import _ "golang.org/fake/b"`,
+			"a/BUILD": ``,
+			"b/b.go":  `package b`,
+		}}})
+	defer exported.Cleanup()
+
+	driverEnv, err := packagestest.FakeDriverEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	exported.Config.Mode = packages.NeedName | packages.NeedFiles | packages.NeedImports | packages.NeedDeps
+	exported.Config.Env = append(append([]string{}, exported.Config.Env...), driverEnv...)
+
+	// Without extensions requested, Load behaves as usual.
+	initial, err := packages.Load(exported.Config, "golang.org/fake/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(initial) != 1 || initial[0].PkgPath != "golang.org/fake/a" {
+		t.Fatalf("package.Load: want [golang.org/fake/a], got %v", initial)
+	}
+
+	var ext packagesinternal.DriverExtensions
+	packagesinternal.SetDriverExtensions(exported.Config, &ext)
+	initial, err = packages.Load(exported.Config, "file="+exported.File("golang.org/fake", "a/a.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(initial) != 1 || initial[0].PkgPath != "golang.org/fake/a" {
+		t.Fatalf("package.Load: want [golang.org/fake/a], got %v", initial)
+	}
+	if !ext.Incremental {
+		t.Errorf("DriverExtensions.Incremental = false, want true")
+	}
+	build := exported.File("golang.org/fake", "a/BUILD")
+	if got, want := ext.FileOwners, map[string][]string{build: {initial[0].ID}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("DriverExtensions.FileOwners = %v, want %v", got, want)
+	}
+
+	// The go list driver declares no extensions.
+	exported.Config.Env = append(exported.Config.Env, "GOPACKAGESDRIVER=off")
+	if _, err := packages.Load(exported.Config, "golang.org/fake/a"); err != nil {
+		t.Fatal(err)
+	}
+	if ext.Incremental || ext.FileOwners != nil {
+		t.Errorf("go list driver declared extensions: %+v", ext)
+	}
+}
+
 func TestInvalidPackageName(t *testing.T) {
 	testAllOrModulesParallel(t, testInvalidPackageName)
 }
@@ -0,0 +1,134 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package packagestest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// fakeDriverEnv is set in the environment of a test executable that has been
+// started as a GOPACKAGESDRIVER by go/packages.
+const fakeDriverEnv = "PACKAGESTEST_FAKE_GOPACKAGESDRIVER"
+
+// FakeDriverBuildFile is the name of the files that the fake driver reports
+// as owned by the packages in the same directory, in the manner of Bazel's
+// BUILD files.
+const FakeDriverBuildFile = "BUILD"
+
+// FakeDriverEnv returns the environment variables that cause go/packages to
+// use the running test executable as an external GOPACKAGESDRIVER.
+//
+// The fake driver answers queries using the go command, and declares the
+// incremental driver protocol extensions: it supports incremental queries,
+// and reports each FakeDriverBuildFile as owned by the packages in its
+// directory.
+//
+// The TestMain function of the test must call RunFakeDriverIfRequested
+// before doing anything else.
+func FakeDriverEnv() ([]string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	return []string{"GOPACKAGESDRIVER=" + exe, fakeDriverEnv + "=1"}, nil
+}
+
+// RunFakeDriverIfRequested serves a single driver request and exits, if the
+// current process was started as a driver using the environment returned by
+// FakeDriverEnv. Otherwise, it does nothing.
+func RunFakeDriverIfRequested() {
+	if os.Getenv(fakeDriverEnv) == "" {
+		return
+	}
+	if err := runFakeDriver(os.Stdin, os.Stdout, os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "fake driver: %v\n", err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+// fakeDriverRequest mirrors the request sent by go/packages to an external
+// driver.
+type fakeDriverRequest struct {
+	Mode       packages.LoadMode `json:"mode"`
+	Env        []string          `json:"env"`
+	BuildFlags []string          `json:"build_flags"`
+	Tests      bool              `json:"tests"`
+	Overlay    map[string][]byte `json:"overlay"`
+}
+
+// fakeDriverResponse mirrors the response expected by go/packages from an
+// external driver.
+type fakeDriverResponse struct {
+	Compiler    string
+	Arch        string
+	Roots       []string `json:",omitempty"`
+	Packages    []*packages.Package
+	Incremental bool                `json:",omitempty"`
+	FileOwners  map[string][]string `json:",omitempty"`
+}
+
+func runFakeDriver(stdin io.Reader, stdout io.Writer, patterns []string) error {
+	var req fakeDriverRequest
+	if err := json.NewDecoder(stdin).Decode(&req); err != nil {
+		return fmt.Errorf("decoding request: %v", err)
+	}
+	dir, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	cfg := &packages.Config{
+		// A driver reports metadata only: type information is computed by
+		// go/packages in the client.
+		Mode:       req.Mode &^ (packages.NeedTypes | packages.NeedTypesInfo | packages.NeedSyntax),
+		Dir:        dir,
+		Env:        append(append([]string{}, req.Env...), "GOPACKAGESDRIVER=off"),
+		BuildFlags: req.BuildFlags,
+		Tests:      req.Tests,
+		Overlay:    req.Overlay,
+	}
+	roots, err := packages.Load(cfg, patterns...)
+	if err != nil {
+		return err
+	}
+
+	resp := fakeDriverResponse{
+		Compiler:    "gc",
+		Arch:        runtime.GOARCH,
+		Incremental: true,
+		FileOwners:  make(map[string][]string),
+	}
+	for _, kv := range req.Env {
+		if v := strings.TrimPrefix(kv, "GOARCH="); v != kv && v != "" {
+			resp.Arch = v
+		}
+	}
+	for _, root := range roots {
+		resp.Roots = append(resp.Roots, root.ID)
+	}
+	packages.Visit(roots, nil, func(pkg *packages.Package) {
+		resp.Packages = append(resp.Packages, pkg)
+		seen := make(map[string]bool)
+		for _, f := range pkg.GoFiles {
+			build := filepath.Join(filepath.Dir(f), FakeDriverBuildFile)
+			if seen[build] {
+				continue
+			}
+			seen[build] = true
+			if _, err := os.Stat(build); err == nil {
+				resp.FileOwners[build] = append(resp.FileOwners[build], pkg.ID)
+			}
+		}
+	})
+	return json.NewEncoder(stdout).Encode(resp)
+}
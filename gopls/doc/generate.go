@@ -497,6 +497,8 @@ func loadLenses(commands []*settings.CommandJSON) []*settings.LensJSON {
 		}
 		all[k] = struct{}{}
 	}
+	// The go.work lenses are computed by work.CodeLenses.
+	all[command.RunGoWorkCommand] = struct{}{}
 
 	var lenses []*settings.LensJSON
 
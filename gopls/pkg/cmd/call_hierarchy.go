@@ -8,6 +8,7 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"io"
 	"strings"
 
 	"golang.org/x/tools/gopls/pkg/lsp/protocol"
@@ -16,12 +17,14 @@ import (
 
 // callHierarchy implements the callHierarchy verb for gopls.
 type callHierarchy struct {
+	JSON bool `flag:"json" help:"emit the call hierarchy in JSON format"`
+
 	app *Application
 }
 
 func (c *callHierarchy) Name() string      { return "call_hierarchy" }
 func (c *callHierarchy) Parent() string    { return c.app.Name() }
-func (c *callHierarchy) Usage() string     { return "<position>" }
+func (c *callHierarchy) Usage() string     { return "[call_hierarchy-flags] <position>" }
 func (c *callHierarchy) ShortHelp() string { return "display selected identifier's call hierarchy" }
 func (c *callHierarchy) DetailedHelp(f *flag.FlagSet) {
 	fmt.Fprint(f.Output(), `
@@ -30,6 +33,8 @@ Example:
 	$ # 1-indexed location (:line:column or :#offset) of the target identifier
 	$ gopls call_hierarchy helper/helper.go:8:6
 	$ gopls call_hierarchy helper/helper.go:#53
+
+call_hierarchy-flags:
 `)
 	printFlagDefaults(f)
 }
@@ -38,22 +43,22 @@ func (c *callHierarchy) Run(ctx context.Context, args ...string) error {
 	if len(args) != 1 {
 		return tool.CommandLineErrorf("call_hierarchy expects 1 argument (position)")
 	}
+	return c.app.runQuery(ctx, c, c.JSON, args...)
+}
 
-	conn, err := c.app.connect(ctx, nil)
-	if err != nil {
-		return err
+func (c *callHierarchy) query(ctx context.Context, conn *connection, args ...string) (queryResult, error) {
+	if len(args) != 1 {
+		return nil, tool.CommandLineErrorf("call_hierarchy expects 1 argument (position)")
 	}
-	defer conn.terminate(ctx)
-
 	from := parseSpan(args[0])
 	file, err := conn.openFile(ctx, from.URI())
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	loc, err := file.spanLocation(from)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	p := protocol.CallHierarchyPrepareParams{
@@ -62,82 +67,147 @@ func (c *callHierarchy) Run(ctx context.Context, args ...string) error {
 
 	callItems, err := conn.PrepareCallHierarchy(ctx, &p)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if len(callItems) == 0 {
-		return fmt.Errorf("function declaration identifier not found at %v", args[0])
+		return nil, fmt.Errorf("function declaration identifier not found at %v", args[0])
 	}
 
+	results := callHierarchyList{}
 	for _, item := range callItems {
+		identifier, err := newCallHierarchyItem(ctx, conn, item)
+		if err != nil {
+			return nil, err
+		}
+		result := CallHierarchy{
+			Identifier: identifier,
+			Callers:    []Call{},
+			Callees:    []Call{},
+		}
+
 		incomingCalls, err := conn.IncomingCalls(ctx, &protocol.CallHierarchyIncomingCallsParams{Item: item})
 		if err != nil {
-			return err
+			return nil, err
 		}
-		for i, call := range incomingCalls {
+		for _, call := range incomingCalls {
 			// From the spec: CallHierarchyIncomingCall.FromRanges is relative to
 			// the caller denoted by CallHierarchyIncomingCall.from.
-			printString, err := callItemPrintString(ctx, conn, call.From, call.From.URI, call.FromRanges)
+			caller, err := newCall(ctx, conn, call.From, call.From.URI, call.FromRanges)
 			if err != nil {
-				return err
+				return nil, err
 			}
-			fmt.Printf("caller[%d]: %s\n", i, printString)
+			result.Callers = append(result.Callers, caller)
 		}
 
-		printString, err := callItemPrintString(ctx, conn, item, "", nil)
-		if err != nil {
-			return err
-		}
-		fmt.Printf("identifier: %s\n", printString)
-
 		outgoingCalls, err := conn.OutgoingCalls(ctx, &protocol.CallHierarchyOutgoingCallsParams{Item: item})
 		if err != nil {
-			return err
+			return nil, err
 		}
-		for i, call := range outgoingCalls {
+		for _, call := range outgoingCalls {
 			// From the spec: CallHierarchyOutgoingCall.FromRanges is the range
 			// relative to the caller, e.g the item passed to
-			printString, err := callItemPrintString(ctx, conn, call.To, item.URI, call.FromRanges)
+			callee, err := newCall(ctx, conn, call.To, item.URI, call.FromRanges)
 			if err != nil {
-				return err
+				return nil, err
 			}
-			fmt.Printf("callee[%d]: %s\n", i, printString)
+			result.Callees = append(result.Callees, callee)
 		}
+		results = append(results, result)
 	}
+	return results, nil
+}
 
-	return nil
+// A CallHierarchy is a result of a 'call_hierarchy' query.
+type CallHierarchy struct {
+	Identifier CallHierarchyItem `json:"identifier"` // the function at the position
+	Callers    []Call            `json:"callers"`
+	Callees    []Call            `json:"callees"`
 }
 
-// callItemPrintString returns a protocol.CallHierarchyItem object represented as a string.
-// item and call ranges (protocol.Range) are converted to user friendly spans (1-indexed).
-func callItemPrintString(ctx context.Context, conn *connection, item protocol.CallHierarchyItem, callsURI protocol.DocumentURI, calls []protocol.Range) (string, error) {
+// A CallHierarchyItem is a function in a CallHierarchy.
+type CallHierarchyItem struct {
+	Name   string `json:"name"`
+	Kind   string `json:"kind"` // e.g. "Function"
+	Detail string `json:"detail,omitempty"`
+	Span   span   `json:"span"` // span of the declaration of the function
+}
+
+// A Call is a caller or callee of the identifier of a CallHierarchy, and
+// the calls between them.
+type Call struct {
+	Item  CallHierarchyItem `json:"item"`  // the caller or callee
+	Calls []span            `json:"calls"` // spans of the calls, within the caller
+}
+
+// newCallHierarchyItem converts a protocol.CallHierarchyItem, whose
+// ranges are converted to user friendly spans (1-indexed).
+func newCallHierarchyItem(ctx context.Context, conn *connection, item protocol.CallHierarchyItem) (CallHierarchyItem, error) {
 	itemFile, err := conn.openFile(ctx, item.URI)
 	if err != nil {
-		return "", err
+		return CallHierarchyItem{}, err
 	}
 	itemSpan, err := itemFile.rangeSpan(item.Range)
 	if err != nil {
-		return "", err
+		return CallHierarchyItem{}, err
 	}
+	return CallHierarchyItem{
+		Name:   item.Name,
+		Kind:   fmt.Sprint(item.Kind),
+		Detail: item.Detail,
+		Span:   itemSpan,
+	}, nil
+}
 
-	var callRanges []string
-	if callsURI != "" {
-		callsFile, err := conn.openFile(ctx, callsURI)
+// newCall returns the Call of item, whose calls are the given ranges of
+// the file callsURI.
+func newCall(ctx context.Context, conn *connection, item protocol.CallHierarchyItem, callsURI protocol.DocumentURI, calls []protocol.Range) (Call, error) {
+	callItem, err := newCallHierarchyItem(ctx, conn, item)
+	if err != nil {
+		return Call{}, err
+	}
+	call := Call{Item: callItem, Calls: []span{}}
+	callsFile, err := conn.openFile(ctx, callsURI)
+	if err != nil {
+		return Call{}, err
+	}
+	for _, rng := range calls {
+		s, err := callsFile.rangeSpan(rng)
 		if err != nil {
-			return "", err
-		}
-		for _, rng := range calls {
-			call, err := callsFile.rangeSpan(rng)
-			if err != nil {
-				return "", err
-			}
-			callRange := fmt.Sprintf("%d:%d-%d", call.Start().Line(), call.Start().Column(), call.End().Column())
-			callRanges = append(callRanges, callRange)
+			return Call{}, err
 		}
+		call.Calls = append(call.Calls, s)
 	}
+	return call, nil
+}
 
-	printString := fmt.Sprintf("function %s in %v", item.Name, itemSpan)
-	if len(calls) > 0 {
-		printString = fmt.Sprintf("ranges %s in %s from/to %s", strings.Join(callRanges, ", "), callsURI.Path(), printString)
+// String returns the call as a string, for the text output of the
+// 'call_hierarchy' verb.
+func (c *Call) String() string {
+	var callRanges []string
+	for _, s := range c.Calls {
+		callRanges = append(callRanges, fmt.Sprintf("%d:%d-%d", s.Start().Line(), s.Start().Column(), s.End().Column()))
+	}
+	printString := c.Item.String()
+	if len(c.Calls) > 0 {
+		printString = fmt.Sprintf("ranges %s in %s from/to %s", strings.Join(callRanges, ", "), c.Calls[0].URI().Path(), printString)
+	}
+	return printString
+}
+
+func (item *CallHierarchyItem) String() string {
+	return fmt.Sprintf("function %s in %v", item.Name, item.Span)
+}
+
+type callHierarchyList []CallHierarchy
+
+func (l callHierarchyList) printText(w io.Writer) {
+	for _, h := range l {
+		for i := range h.Callers {
+			fmt.Fprintf(w, "caller[%d]: %s\n", i, &h.Callers[i])
+		}
+		fmt.Fprintf(w, "identifier: %s\n", &h.Identifier)
+		for i := range h.Callees {
+			fmt.Fprintf(w, "callee[%d]: %s\n", i, &h.Callees[i])
+		}
 	}
-	return printString, nil
 }
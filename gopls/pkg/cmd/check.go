@@ -6,37 +6,110 @@ package cmd
 
 import (
 	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
+	"os"
+	"sort"
+	"strings"
 
+	"golang.org/x/tools/go/packages"
 	"golang.org/x/tools/gopls/pkg/lsp/protocol"
+	"golang.org/x/tools/pkg/tool"
 )
 
 // check implements the check verb for gopls.
 type check struct {
 	app *Application
+
+	JSON     bool   `flag:"json" help:"emit diagnostics in JSON format"`
+	SARIF    bool   `flag:"sarif" help:"emit diagnostics in SARIF 2.1.0 format"`
+	Severity string `flag:"severity" help:"report only diagnostics at least as severe as this one of error, warning, info or hint"`
 }
 
-func (c *check) Name() string      { return "check" }
-func (c *check) Parent() string    { return c.app.Name() }
-func (c *check) Usage() string     { return "<filename>" }
-func (c *check) ShortHelp() string { return "show diagnostic results for the specified file" }
+func (c *check) Name() string   { return "check" }
+func (c *check) Parent() string { return c.app.Name() }
+func (c *check) Usage() string  { return "[check-flags] <filename or package pattern>..." }
+func (c *check) ShortHelp() string {
+	return "show diagnostic results for the specified files or packages"
+}
 func (c *check) DetailedHelp(f *flag.FlagSet) {
 	fmt.Fprint(f.Output(), `
+Check reports the diagnostics of the given files, or of the files of the
+packages matching the given patterns, including their tests: the same
+diagnostics as an editor shows, from the compiler and the analyzers
+enabled in the settings. It exits with a non-zero status if any are
+reported.
+
 Example: show the diagnostic results of this file:
 
 	$ gopls check internal/cmd/check.go
+
+Example: show the errors and warnings of all packages, in SARIF format:
+
+	$ gopls check -severity=warning -sarif ./...
+
+check-flags:
 `)
 	printFlagDefaults(f)
 }
 
-// Run performs the check on the files specified by args and prints the
-// results to stdout.
+// A Diagnostic is a diagnostic reported by the check verb, in JSON format.
+type Diagnostic struct {
+	Span     span                 `json:"span"`
+	Severity string               `json:"severity"`         // error, warning, info or hint
+	Code     string               `json:"code,omitempty"`   // e.g. the type error code
+	Source   string               `json:"source,omitempty"` // e.g. "compiler" or the analyzer name
+	Message  string               `json:"message"`
+	Related  []RelatedInformation `json:"related,omitempty"`
+
+	rng protocol.Range // for SARIF, in UTF-16
+}
+
+// RelatedInformation is a location related to a Diagnostic.
+type RelatedInformation struct {
+	Span    span   `json:"span"`
+	Message string `json:"message"`
+
+	rng protocol.Range
+}
+
+// severityNames are the names of the severities accepted by the -severity
+// flag, and reported in JSON.
+var severityNames = map[protocol.DiagnosticSeverity]string{
+	protocol.SeverityError:       "error",
+	protocol.SeverityWarning:     "warning",
+	protocol.SeverityInformation: "info",
+	protocol.SeverityHint:        "hint",
+}
+
+// Run performs the check on the files and packages specified by args and
+// prints the results to stdout.
 func (c *check) Run(ctx context.Context, args ...string) error {
+	if c.JSON && c.SARIF {
+		return tool.CommandLineErrorf("-json and -sarif are mutually exclusive")
+	}
+	threshold := protocol.SeverityHint
+	if c.Severity != "" {
+		found := false
+		for severity, name := range severityNames {
+			if name == c.Severity {
+				threshold, found = severity, true
+			}
+		}
+		if !found {
+			return tool.CommandLineErrorf("invalid -severity %q: want error, warning, info or hint", c.Severity)
+		}
+	}
 	if len(args) == 0 {
 		// no files, so no results
 		return nil
 	}
+	filenames, err := c.expand(args)
+	if err != nil {
+		return err
+	}
+
 	checking := map[protocol.DocumentURI]*cmdFile{}
 	var uris []protocol.DocumentURI
 	// now we ready to kick things off
@@ -45,8 +118,8 @@ func (c *check) Run(ctx context.Context, args ...string) error {
 		return err
 	}
 	defer conn.terminate(ctx)
-	for _, arg := range args {
-		uri := protocol.URIFromPath(arg)
+	for _, filename := range filenames {
+		uri := protocol.URIFromPath(filename)
 		uris = append(uris, uri)
 		file, err := conn.openFile(ctx, uri)
 		if err != nil {
@@ -57,17 +130,286 @@ func (c *check) Run(ctx context.Context, args ...string) error {
 	if err := conn.diagnoseFiles(ctx, uris); err != nil {
 		return err
 	}
-	conn.client.filesMu.Lock()
-	defer conn.client.filesMu.Unlock()
 
-	for _, file := range checking {
-		for _, d := range file.diagnostics {
+	var diags []Diagnostic
+	for _, uri := range uris {
+		conn.client.filesMu.Lock()
+		file := checking[uri]
+		fileDiags := append([]protocol.Diagnostic(nil), file.diagnostics...)
+		conn.client.filesMu.Unlock()
+
+		for _, d := range fileDiags {
+			severity := d.Severity
+			if severity == 0 {
+				severity = protocol.SeverityError // as interpreted by most clients
+			}
+			if severity > threshold {
+				continue
+			}
 			spn, err := file.rangeSpan(d.Range)
 			if err != nil {
 				return fmt.Errorf("Could not convert position %v for %q", d.Range, d.Message)
 			}
-			fmt.Printf("%v: %v\n", spn, d.Message)
+			diag := Diagnostic{
+				Span:     spn,
+				Severity: severityNames[severity],
+				Source:   d.Source,
+				Message:  d.Message,
+				rng:      d.Range,
+			}
+			if d.Code != nil {
+				diag.Code = fmt.Sprint(d.Code)
+			}
+			for _, related := range d.RelatedInformation {
+				relatedFile := conn.client.openFile(related.Location.URI)
+				if relatedFile.err != nil {
+					return relatedFile.err
+				}
+				spn, err := relatedFile.locationSpan(related.Location)
+				if err != nil {
+					return err
+				}
+				diag.Related = append(diag.Related, RelatedInformation{Span: spn, Message: related.Message, rng: related.Location.Range})
+			}
+			diags = append(diags, diag)
 		}
 	}
+	sort.SliceStable(diags, func(i, j int) bool {
+		x, y := diags[i].Span, diags[j].Span
+		if x.URI() != y.URI() {
+			return x.URI() < y.URI()
+		}
+		return x.Start().Offset() < y.Start().Offset()
+	})
+
+	switch {
+	case c.JSON:
+		if diags == nil {
+			diags = []Diagnostic{} // print [] rather than null
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "\t")
+		if err := enc.Encode(diags); err != nil {
+			return err
+		}
+	case c.SARIF:
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "\t")
+		if err := enc.Encode(sarifLog(diags)); err != nil {
+			return err
+		}
+	default:
+		for _, d := range diags {
+			fmt.Printf("%v: %v\n", d.Span, d.Message)
+			for _, related := range d.Related {
+				fmt.Printf("\t%v: %v\n", related.Span, related.Message)
+			}
+		}
+	}
+	if len(diags) > 0 {
+		return fmt.Errorf("%d %s", len(diags), pluralize(len(diags), "diagnostic", "diagnostics"))
+	}
 	return nil
 }
+
+// expand returns the files named by args, which are either Go files or
+// package patterns, which are expanded to the files of the matching
+// packages and their tests.
+func (c *check) expand(args []string) ([]string, error) {
+	var filenames, patterns []string
+	for _, arg := range args {
+		if strings.HasSuffix(arg, ".go") {
+			filenames = append(filenames, arg)
+		} else {
+			patterns = append(patterns, arg)
+		}
+	}
+	if len(patterns) == 0 {
+		return filenames, nil
+	}
+	pkgFiles, err := c.app.packageFiles(patterns)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	for _, filename := range filenames {
+		seen[filename] = true
+	}
+	for _, filename := range pkgFiles {
+		if !seen[filename] {
+			seen[filename] = true
+			filenames = append(filenames, filename)
+		}
+	}
+	return filenames, nil
+}
+
+// packageFiles returns the Go files of the packages matching the
+// given patterns, including their tests, without duplicates.
+// Errors listing the packages are reported to stderr.
+func (app *Application) packageFiles(patterns []string) ([]string, error) {
+	cfg := &packages.Config{
+		Mode:  packages.NeedName | packages.NeedFiles,
+		Dir:   app.wd,
+		Env:   append(os.Environ(), app.env...),
+		Tests: true,
+	}
+	pkgs, err := packages.Load(cfg, patterns...)
+	if err != nil {
+		return nil, err
+	}
+	packages.Visit(pkgs, nil, func(pkg *packages.Package) {
+		for _, err := range pkg.Errors {
+			if err.Kind == packages.ListError {
+				fmt.Fprintf(os.Stderr, "%v\n", err)
+			}
+		}
+	})
+	var filenames []string
+	seen := make(map[string]bool)
+	for _, pkg := range pkgs {
+		if strings.HasSuffix(pkg.ID, ".test") {
+			continue // generated test main package
+		}
+		for _, filename := range pkg.GoFiles {
+			if !seen[filename] {
+				seen[filename] = true
+				filenames = append(filenames, filename)
+			}
+		}
+	}
+	return filenames, nil
+}
+
+func pluralize(n int, singular, plural string) string {
+	if n == 1 {
+		return singular
+	}
+	return plural
+}
+
+// -- SARIF --
+
+// The SARIF 2.1.0 format is specified at
+// https://docs.oasis-open.org/sarif/sarif/v2.1.0/sarif-v2.1.0.html.
+// Only the properties needed to report diagnostics are defined here.
+
+type sarifLogJSON struct {
+	Version string         `json:"version"`
+	Schema  string         `json:"$schema"`
+	Runs    []sarifRunJSON `json:"runs"`
+}
+
+type sarifRunJSON struct {
+	Tool    sarifToolJSON     `json:"tool"`
+	Results []sarifResultJSON `json:"results"`
+}
+
+type sarifToolJSON struct {
+	Driver sarifDriverJSON `json:"driver"`
+}
+
+type sarifDriverJSON struct {
+	Name           string          `json:"name"`
+	InformationURI string          `json:"informationUri"`
+	Rules          []sarifRuleJSON `json:"rules,omitempty"`
+}
+
+type sarifRuleJSON struct {
+	ID string `json:"id"`
+}
+
+type sarifResultJSON struct {
+	RuleID           string              `json:"ruleId"`
+	Level            string              `json:"level"`
+	Message          sarifMessageJSON    `json:"message"`
+	Locations        []sarifLocationJSON `json:"locations"`
+	RelatedLocations []sarifLocationJSON `json:"relatedLocations,omitempty"`
+}
+
+type sarifMessageJSON struct {
+	Text string `json:"text"`
+}
+
+type sarifLocationJSON struct {
+	ID               *int                      `json:"id,omitempty"`
+	PhysicalLocation sarifPhysicalLocationJSON `json:"physicalLocation"`
+	Message          *sarifMessageJSON         `json:"message,omitempty"`
+}
+
+type sarifPhysicalLocationJSON struct {
+	ArtifactLocation sarifArtifactLocationJSON `json:"artifactLocation"`
+	Region           sarifRegionJSON           `json:"region"`
+}
+
+type sarifArtifactLocationJSON struct {
+	URI string `json:"uri"`
+}
+
+// A sarifRegionJSON is a range of a file, whose columns are counted in
+// UTF-16 code units, the default column kind of SARIF and that of LSP.
+type sarifRegionJSON struct {
+	StartLine   uint32 `json:"startLine"`
+	StartColumn uint32 `json:"startColumn"`
+	EndLine     uint32 `json:"endLine"`
+	EndColumn   uint32 `json:"endColumn"`
+}
+
+// sarifLog returns the diagnostics as a SARIF log of a single run, whose
+// rules are the sources of the diagnostics.
+func sarifLog(diags []Diagnostic) sarifLogJSON {
+	location := func(uri protocol.DocumentURI, rng protocol.Range) sarifPhysicalLocationJSON {
+		return sarifPhysicalLocationJSON{
+			ArtifactLocation: sarifArtifactLocationJSON{URI: string(uri)},
+			Region: sarifRegionJSON{
+				StartLine:   rng.Start.Line + 1,
+				StartColumn: rng.Start.Character + 1,
+				EndLine:     rng.End.Line + 1,
+				EndColumn:   rng.End.Character + 1,
+			},
+		}
+	}
+	run := sarifRunJSON{
+		Tool: sarifToolJSON{Driver: sarifDriverJSON{
+			Name:           "gopls",
+			InformationURI: "https://pkg.go.dev/golang.org/x/tools/gopls",
+		}},
+		Results: []sarifResultJSON{},
+	}
+	rules := make(map[string]bool)
+	for _, d := range diags {
+		ruleID := d.Source
+		if ruleID == "" {
+			ruleID = "gopls"
+		}
+		if !rules[ruleID] {
+			rules[ruleID] = true
+			run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRuleJSON{ID: ruleID})
+		}
+		level := "note"
+		switch d.Severity {
+		case "error", "warning":
+			level = d.Severity
+		}
+		result := sarifResultJSON{
+			RuleID:    ruleID,
+			Level:     level,
+			Message:   sarifMessageJSON{Text: d.Message},
+			Locations: []sarifLocationJSON{{PhysicalLocation: location(d.Span.URI(), d.rng)}},
+		}
+		for i, related := range d.Related {
+			id := i
+			result.RelatedLocations = append(result.RelatedLocations, sarifLocationJSON{
+				ID:               &id,
+				PhysicalLocation: location(related.Span.URI(), related.rng),
+				Message:          &sarifMessageJSON{Text: related.Message},
+			})
+		}
+		run.Results = append(run.Results, result)
+	}
+	return sarifLogJSON{
+		Version: "2.1.0",
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Runs:    []sarifRunJSON{run},
+	}
+}
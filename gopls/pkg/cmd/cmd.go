@@ -65,6 +65,9 @@ type Application struct {
 	// Support for remote LSP server.
 	Remote string `flag:"remote" help:"forward all commands to a remote lsp specified by this flag. With no special prefix, this is assumed to be a TCP address. If prefixed by 'unix;', the subsequent address is assumed to be a unix domain socket. If 'auto', or prefixed by 'auto;', the remote address is automatically resolved based on the executing environment."`
 
+	// RemoteTokenFile holds the token authenticating with the remote.
+	RemoteTokenFile string `flag:"remote.tokenfile" help:"when used with -remote, the file holding the token with which to authenticate with the daemon, if it was started with -listen.tokens"`
+
 	// Verbose enables verbose logging.
 	Verbose bool `flag:"v,verbose" help:"verbose output"`
 
@@ -295,7 +298,8 @@ func (app *Application) internalCommands() []tool.Application {
 func (app *Application) featureCommands() []tool.Application {
 	return []tool.Application{
 		&callHierarchy{app: app},
-		&check{app: app},
+		&check{app: app, Severity: "hint"},
+		&codeaction{app: app},
 		&codelens{app: app},
 		&definition{app: app},
 		&foldingRanges{app: app},
@@ -307,6 +311,7 @@ func (app *Application) featureCommands() []tool.Application {
 		newRemote(app, "inspect"),
 		&links{app: app},
 		&prepareRename{app: app},
+		&queryStream{app: app},
 		&references{app: app},
 		&rename{app: app},
 		&semtok{app: app},
@@ -314,7 +319,7 @@ func (app *Application) featureCommands() []tool.Application {
 		&stats{app: app},
 		&suggestedFix{app: app},
 		&symbols{app: app},
-
+		newVulndb(app),
 		&workspaceSymbol{app: app},
 	}
 }
@@ -361,6 +366,10 @@ func (app *Application) connect(ctx context.Context, onProgress func(*protocol.P
 }
 
 func (app *Application) connectRemote(ctx context.Context, remote string) (*connection, error) {
+	token, err := app.remoteToken()
+	if err != nil {
+		return nil, err
+	}
 	conn, err := lsprpc.ConnectToRemote(ctx, remote)
 	if err != nil {
 		return nil, err
@@ -374,9 +383,30 @@ func (app *Application) connectRemote(ctx context.Context, remote string) (*conn
 	cc.Go(ctx,
 		protocol.Handlers(
 			protocol.ClientHandler(client, jsonrpc2.MethodNotFound)))
+	if err := lsprpc.Authenticate(ctx, cc, token); err != nil {
+		cc.Close()
+		return nil, fmt.Errorf("authenticating with remote: %w", err)
+	}
 	return connection, connection.initialize(ctx, app.options)
 }
 
+// remoteToken returns the token in the -remote.tokenfile file, or "" if
+// the flag is not set.
+func (app *Application) remoteToken() (string, error) {
+	if app.RemoteTokenFile == "" {
+		return "", nil
+	}
+	data, err := os.ReadFile(app.RemoteTokenFile)
+	if err != nil {
+		return "", fmt.Errorf("reading token: %w", err)
+	}
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return "", fmt.Errorf("reading token: %s is empty", app.RemoteTokenFile)
+	}
+	return token, nil
+}
+
 func (c *connection) initialize(ctx context.Context, options func(*settings.Options)) error {
 	params := &protocol.ParamInitialize{}
 	params.RootURI = protocol.URIFromPath(c.client.app.wd)
@@ -429,6 +459,10 @@ type cmdClient struct {
 
 	filesMu sync.Mutex // guards files map and each cmdFile.diagnostics
 	files   map[protocol.DocumentURI]*cmdFile
+
+	// onApplyEdit, if set, is called instead of applying the edit
+	// of an ApplyEdit downcall. (Used by codeaction to gather edits.)
+	onApplyEdit func(*protocol.WorkspaceEdit) error
 }
 
 type cmdFile struct {
@@ -537,7 +571,11 @@ func (c *cmdClient) Configuration(ctx context.Context, p *protocol.ParamConfigur
 }
 
 func (c *cmdClient) ApplyEdit(ctx context.Context, p *protocol.ApplyWorkspaceEditParams) (*protocol.ApplyWorkspaceEditResult, error) {
-	if err := c.applyWorkspaceEdit(&p.Edit); err != nil {
+	apply := c.applyWorkspaceEdit
+	if c.onApplyEdit != nil {
+		apply = c.onApplyEdit
+	}
+	if err := apply(&p.Edit); err != nil {
 		return &protocol.ApplyWorkspaceEditResult{FailureReason: err.Error()}, nil
 	}
 	return &protocol.ApplyWorkspaceEditResult{Applied: true}, nil
@@ -588,7 +626,12 @@ func applyTextEdits(mapper *protocol.Mapper, edits []protocol.TextEdit, flags *E
 	if err != nil {
 		return err
 	}
+	return writeEdited(mapper, newContent, renameEdits, flags)
+}
 
+// writeEdited writes the new content of the mapper's file, computed
+// by the specified diff edits, using the preferred edit mode.
+func writeEdited(mapper *protocol.Mapper, newContent []byte, renameEdits []diff.Edit, flags *EditFlags) error {
 	filename := mapper.URI.Path()
 
 	if flags.List {
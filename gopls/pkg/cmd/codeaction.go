@@ -0,0 +1,430 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"golang.org/x/tools/gopls/pkg/lsp/protocol"
+	"golang.org/x/tools/pkg/diff"
+	"golang.org/x/tools/pkg/tool"
+)
+
+// codeaction implements the codeaction verb for gopls.
+type codeaction struct {
+	EditFlags
+	Kind  string `flag:"kind" help:"comma-separated list of code action kinds to consider, e.g. quickfix,refactor.rewrite"`
+	Title string `flag:"title" help:"regular expression that the titles of the code actions must match"`
+
+	app *Application
+}
+
+func (c *codeaction) Name() string   { return "codeaction" }
+func (c *codeaction) Parent() string { return c.app.Name() }
+func (c *codeaction) Usage() string {
+	return "[codeaction-flags] <filename[:span]> or <package pattern>..."
+}
+func (c *codeaction) ShortHelp() string { return "list or apply code actions" }
+func (c *codeaction) DetailedHelp(f *flag.FlagSet) {
+	fmt.Fprint(f.Output(), `
+The codeaction command lists, or applies, the code actions available
+for the specified files or ranges within files, or for all the files
+of the packages matching the specified patterns, including their tests.
+The diagnostics of each file are included in the requests, so that the
+quick fixes of all its diagnostics are found.
+
+The -kind flag restricts the code actions to those of the specified
+kinds; kinds are hierarchical, so "refactor" includes
+"refactor.rewrite". The -title flag restricts them to those whose
+title matches the regular expression.
+
+By default the matching code actions are listed. With -w, -d or -l,
+they are applied: the edits of all of them are computed first, and
+written only if they all apply. A code action whose edits conflict
+with those of an earlier one is skipped, and reported. With -w, the
+new contents are written to temporary files, which replace the files
+only once all of them are written; the replacement is not atomic.
+
+Example: list the code actions of this file:
+
+	$ gopls codeaction internal/cmd/check.go
+
+Example: preview the rewrites of the "fill struct" code actions at a
+specific line within this file:
+
+	$ gopls codeaction -kind=refactor.rewrite -title='^Fill' -d internal/cmd/check.go:43
+
+Example: apply the fixes of all "simplifycompositelit" diagnostics of
+the module:
+
+	$ gopls codeaction -kind=quickfix -title='^Remove' -w ./...
+
+codeaction-flags:
+`)
+	printFlagDefaults(f)
+}
+
+// A foundAction is a code action found for a file, or range within it.
+type foundAction struct {
+	file   *cmdFile
+	rng    protocol.Range // the requested range
+	action protocol.CodeAction
+}
+
+// Run lists the code actions for the files or packages specified by
+// args, or, if any of -w, -d or -l is specified, applies them.
+func (c *codeaction) Run(ctx context.Context, args ...string) error {
+	if len(args) == 0 {
+		return tool.CommandLineErrorf("codeaction expects at least 1 argument")
+	}
+	var titleRx *regexp.Regexp
+	if c.Title != "" {
+		rx, err := regexp.Compile(c.Title)
+		if err != nil {
+			return tool.CommandLineErrorf("invalid -title: %v", err)
+		}
+		titleRx = rx
+	}
+	var kinds []protocol.CodeActionKind
+	if c.Kind != "" {
+		for _, kind := range strings.Split(c.Kind, ",") {
+			if kind = strings.TrimSpace(kind); kind != "" {
+				kinds = append(kinds, protocol.CodeActionKind(kind))
+			}
+		}
+	}
+	spans, err := c.expand(args)
+	if err != nil {
+		return err
+	}
+
+	c.app.editFlags = &c.EditFlags
+	conn, err := c.app.connect(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer conn.terminate(ctx)
+
+	// Gather the edits of commands, rather than applying them,
+	// so that all edits are applied together.
+	var (
+		capturedMu sync.Mutex
+		captured   []protocol.WorkspaceEdit
+	)
+	conn.client.onApplyEdit = func(edit *protocol.WorkspaceEdit) error {
+		capturedMu.Lock()
+		defer capturedMu.Unlock()
+		captured = append(captured, *edit)
+		return nil
+	}
+	defer func() { conn.client.onApplyEdit = nil }()
+
+	files := make(map[protocol.DocumentURI]*cmdFile)
+	var uris []protocol.DocumentURI
+	for _, spn := range spans {
+		uri := spn.URI()
+		if files[uri] != nil {
+			continue
+		}
+		file, err := conn.openFile(ctx, uri)
+		if err != nil {
+			return err
+		}
+		files[uri] = file
+		uris = append(uris, uri)
+	}
+	if err := conn.diagnoseFiles(ctx, uris); err != nil {
+		return err
+	}
+
+	var found []foundAction
+	for _, spn := range spans {
+		file := files[spn.URI()]
+		var rng protocol.Range
+		if spn.HasPosition() || spn.HasOffset() {
+			rng, err = file.spanRange(spn)
+			if err != nil {
+				return err
+			}
+		} else {
+			rng, err = file.mapper.OffsetRange(0, len(file.mapper.Content))
+			if err != nil {
+				return err
+			}
+		}
+
+		diagnostics := []protocol.Diagnostic{} // LSP wants non-nil slice
+		conn.client.filesMu.Lock()
+		for _, d := range file.diagnostics {
+			if protocol.Intersect(d.Range, rng) {
+				diagnostics = append(diagnostics, d)
+			}
+		}
+		conn.client.filesMu.Unlock()
+
+		actions, err := conn.CodeAction(ctx, &protocol.CodeActionParams{
+			TextDocument: protocol.TextDocumentIdentifier{URI: file.uri},
+			Range:        rng,
+			Context: protocol.CodeActionContext{
+				Only:        kinds,
+				Diagnostics: diagnostics,
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("%v: %v", spn, err)
+		}
+		for _, a := range actions {
+			if titleRx != nil && !titleRx.MatchString(a.Title) {
+				continue
+			}
+			found = append(found, foundAction{file: file, rng: rng, action: a})
+		}
+	}
+
+	if !(c.Write || c.Diff || c.List) {
+		for _, f := range found {
+			spn, err := f.span()
+			if err != nil {
+				return err
+			}
+			fmt.Printf("%v: %s: %s\n", spn, f.action.Kind, f.action.Title)
+		}
+		return nil
+	}
+
+	// Gather the edits of each code action in turn, skipping those
+	// that conflict with the edits of earlier ones.
+	edits := make(map[protocol.DocumentURI][]protocol.TextEdit)
+	for _, f := range found {
+		edit := f.action.Edit
+		if f.action.Command != nil {
+			// Execute the command, gathering the edits of the
+			// resulting ApplyEdit downcalls. As in the fix verb,
+			// the command is executed instead of applying the
+			// edits, not after them, to avoid duplicate edits.
+			capturedMu.Lock()
+			captured = nil
+			capturedMu.Unlock()
+			if _, err := conn.ExecuteCommand(ctx, &protocol.ExecuteCommandParams{
+				Command:   f.action.Command.Command,
+				Arguments: f.action.Command.Arguments,
+			}); err != nil {
+				return fmt.Errorf("%s: %v", f.action.Title, err)
+			}
+			capturedMu.Lock()
+			edit = &protocol.WorkspaceEdit{}
+			for _, e := range captured {
+				edit.DocumentChanges = append(edit.DocumentChanges, e.DocumentChanges...)
+			}
+			capturedMu.Unlock()
+		}
+		if edit == nil {
+			continue
+		}
+		actionEdits, err := documentEdits(edit)
+		if err != nil {
+			return fmt.Errorf("%s: %v", f.action.Title, err)
+		}
+		if conflictingEdits(edits, actionEdits) {
+			spn, err := f.span()
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(os.Stderr, "%v: skipping %q, whose edits conflict with those of an earlier code action\n", spn, f.action.Title)
+			continue
+		}
+		for uri, uriEdits := range actionEdits {
+		nextEdit:
+			for _, e := range uriEdits {
+				for _, prev := range edits[uri] {
+					if e == prev {
+						continue nextEdit // duplicate
+					}
+				}
+				edits[uri] = append(edits[uri], e)
+			}
+		}
+	}
+
+	// Compute the new content of every file before writing any.
+	type edited struct {
+		mapper      *protocol.Mapper
+		newContent  []byte
+		renameEdits []diff.Edit
+	}
+	var orderedURIs []protocol.DocumentURI
+	for uri := range edits {
+		orderedURIs = append(orderedURIs, uri)
+	}
+	sortSlice(orderedURIs)
+	var results []edited
+	for _, uri := range orderedURIs {
+		file := conn.client.openFile(uri)
+		if file.err != nil {
+			return file.err
+		}
+		newContent, renameEdits, err := protocol.ApplyEdits(file.mapper, edits[uri])
+		if err != nil {
+			return fmt.Errorf("%v: %v", uri.Path(), err)
+		}
+		results = append(results, edited{file.mapper, newContent, renameEdits})
+	}
+
+	// With -w, write the new contents to temporary files first, and replace
+	// the files only once all of them are written, so that a failure to
+	// write leaves every file unchanged.
+	flags := c.EditFlags
+	if flags.Write {
+		files := make([]string, len(results))
+		contents := make([][]byte, len(results))
+		for i, r := range results {
+			files[i], contents[i] = r.mapper.URI.Path(), r.newContent
+		}
+		if err := replaceFiles(files, contents, flags.Preserve); err != nil {
+			return err
+		}
+		if !(flags.Diff || flags.List) {
+			return nil
+		}
+		flags.Write = false // already written
+	}
+	for _, r := range results {
+		if err := writeEdited(r.mapper, r.newContent, r.renameEdits, &flags); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// replaceFiles replaces the contents of the given files, keeping the
+// original of each as file.orig if preserve is set.
+//
+// The new contents are written to temporary files beside the files, which
+// are then renamed over them. Files are changed only if all temporary
+// files are written; a failure to rename may still leave some changed.
+func replaceFiles(files []string, contents [][]byte, preserve bool) (err error) {
+	temps := make([]string, 0, len(files))
+	defer func() {
+		for _, temp := range temps {
+			os.Remove(temp) // ignore error; renamed temps no longer exist
+		}
+	}()
+	for i, file := range files {
+		mode := os.FileMode(0644)
+		if info, err := os.Stat(file); err == nil {
+			mode = info.Mode().Perm()
+		}
+		f, err := os.CreateTemp(filepath.Dir(file), "."+filepath.Base(file)+".*.tmp")
+		if err != nil {
+			return err
+		}
+		temps = append(temps, f.Name())
+		_, err = f.Write(contents[i])
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err == nil {
+			err = os.Chmod(f.Name(), mode)
+		}
+		if err != nil {
+			return fmt.Errorf("writing %s: %v", file, err)
+		}
+	}
+	for i, file := range files {
+		if preserve {
+			if err := os.Rename(file, file+".orig"); err != nil {
+				return err
+			}
+		}
+		if err := os.Rename(temps[i], file); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// span returns the span of the code action: that of its first
+// diagnostic, if any, or else the requested range.
+func (f foundAction) span() (span, error) {
+	rng := f.rng
+	if len(f.action.Diagnostics) > 0 {
+		rng = f.action.Diagnostics[0].Range
+	}
+	return f.file.rangeSpan(rng)
+}
+
+// expand returns the spans named by args, which are either Go files,
+// optionally with a range, or package patterns, which are expanded to
+// the files of the matching packages and their tests.
+func (c *codeaction) expand(args []string) ([]span, error) {
+	var spans []span
+	var patterns []string
+	for _, arg := range args {
+		if spn := parseSpan(arg); strings.HasSuffix(spn.URI().Path(), ".go") {
+			spans = append(spans, spn)
+		} else {
+			patterns = append(patterns, arg)
+		}
+	}
+	if len(patterns) == 0 {
+		return spans, nil
+	}
+	filenames, err := c.app.packageFiles(patterns)
+	if err != nil {
+		return nil, err
+	}
+	for _, filename := range filenames {
+		spans = append(spans, parseSpan(filename))
+	}
+	return spans, nil
+}
+
+// documentEdits returns the text edits of a WorkspaceEdit, by file.
+func documentEdits(edit *protocol.WorkspaceEdit) (map[protocol.DocumentURI][]protocol.TextEdit, error) {
+	edits := make(map[protocol.DocumentURI][]protocol.TextEdit)
+	for _, c := range edit.DocumentChanges {
+		if c.TextDocumentEdit != nil {
+			uri := c.TextDocumentEdit.TextDocument.URI
+			edits[uri] = append(edits[uri], c.TextDocumentEdit.Edits...)
+		}
+		if c.RenameFile != nil {
+			return nil, fmt.Errorf("client does not support file renaming (%s -> %s)",
+				c.RenameFile.OldURI,
+				c.RenameFile.NewURI)
+		}
+	}
+	return edits, nil
+}
+
+// conflictingEdits reports whether any of the added edits overlaps,
+// without being identical to, any of the existing edits to the same
+// file. Distinct insertions at the same position conflict, as their
+// order is undefined.
+func conflictingEdits(existing, added map[protocol.DocumentURI][]protocol.TextEdit) bool {
+	for uri, addedEdits := range added {
+		for _, x := range addedEdits {
+			for _, y := range existing[uri] {
+				if x == y {
+					continue
+				}
+				if protocol.ComparePosition(x.Range.Start, y.Range.End) < 0 &&
+					protocol.ComparePosition(y.Range.Start, x.Range.End) < 0 {
+					return true // overlapping
+				}
+				if x.Range.Start == x.Range.End && x.Range == y.Range {
+					return true // insertions at the same position
+				}
+			}
+		}
+	}
+	return false
+}
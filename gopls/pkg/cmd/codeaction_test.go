@@ -0,0 +1,54 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReplaceFiles(t *testing.T) {
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a.go"), filepath.Join(dir, "b.go")
+	for _, file := range []string{a, b} {
+		if err := os.WriteFile(file, []byte("old"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A file that cannot be written leaves all files unchanged.
+	missing := filepath.Join(dir, "missing", "c.go")
+	if err := replaceFiles([]string{a, b, missing}, [][]byte{[]byte("new"), []byte("new"), []byte("new")}, false); err == nil {
+		t.Fatal("replaceFiles succeeded in a missing directory")
+	}
+	for _, file := range []string{a, b} {
+		if data, _ := os.ReadFile(file); string(data) != "old" {
+			t.Errorf("after failure, %s = %q, want %q", file, data, "old")
+		}
+	}
+
+	if err := replaceFiles([]string{a, b}, [][]byte{[]byte("new a"), []byte("new b")}, true); err != nil {
+		t.Fatal(err)
+	}
+	for file, want := range map[string]string{a: "new a", b: "new b", a + ".orig": "old", b + ".orig": "old"} {
+		if data, _ := os.ReadFile(file); string(data) != want {
+			t.Errorf("%s = %q, want %q", file, data, want)
+		}
+	}
+	if info, err := os.Stat(a); err != nil {
+		t.Error(err)
+	} else if info.Mode().Perm() != 0600 {
+		t.Errorf("mode of %s = %v, want 0600", a, info.Mode().Perm())
+	}
+	// No temporary file is left.
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 4 {
+		t.Errorf("got %d files, want 4", len(entries))
+	}
+}
@@ -130,12 +130,23 @@ func (r *codelens) Run(ctx context.Context, args ...string) error {
 			return nil
 		}
 
-		if title != "" && lens.Command.Title != title {
-			continue // title was specified but does not match
-		}
 		if filespan.HasPosition() && !protocol.Intersect(loc.Range, lens.Range) {
 			continue // position was specified but does not match
 		}
+		// Some lenses, such as reference counts, are resolved lazily.
+		if lens.Command == nil {
+			resolved, err := conn.ResolveCodeLens(ctx, &lens)
+			if err != nil {
+				return err
+			}
+			lens = *resolved
+			if lens.Command == nil {
+				continue
+			}
+		}
+		if title != "" && lens.Command.Title != title {
+			continue // title was specified but does not match
+		}
 
 		// -exec: run the first matching code lens.
 		if r.Exec {
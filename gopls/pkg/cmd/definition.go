@@ -6,10 +6,9 @@ package cmd
 
 import (
 	"context"
-	"encoding/json"
 	"flag"
 	"fmt"
-	"os"
+	"io"
 	"strings"
 
 	"golang.org/x/tools/gopls/pkg/lsp/protocol"
@@ -73,38 +72,40 @@ func (d *definition) Run(ctx context.Context, args ...string) error {
 			o.PreferredContentFormat = protocol.Markdown
 		}
 	}
-	conn, err := d.app.connect(ctx, nil)
-	if err != nil {
-		return err
+	return d.app.runQuery(ctx, d, d.JSON, args...)
+}
+
+func (d *definition) query(ctx context.Context, conn *connection, args ...string) (queryResult, error) {
+	if len(args) != 1 {
+		return nil, tool.CommandLineErrorf("definition expects 1 argument")
 	}
-	defer conn.terminate(ctx)
 	from := parseSpan(args[0])
 	file, err := conn.openFile(ctx, from.URI())
 	if err != nil {
-		return err
+		return nil, err
 	}
 	loc, err := file.spanLocation(from)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	p := protocol.DefinitionParams{
 		TextDocumentPositionParams: protocol.LocationTextDocumentPositionParams(loc),
 	}
 	locs, err := conn.Definition(ctx, &p)
 	if err != nil {
-		return fmt.Errorf("%v: %v", from, err)
+		return nil, fmt.Errorf("%v: %v", from, err)
 	}
 
 	if len(locs) == 0 {
-		return fmt.Errorf("%v: not an identifier", from)
+		return nil, fmt.Errorf("%v: not an identifier", from)
 	}
 	file, err = conn.openFile(ctx, locs[0].URI)
 	if err != nil {
-		return fmt.Errorf("%v: %v", from, err)
+		return nil, fmt.Errorf("%v: %v", from, err)
 	}
 	definition, err := file.locationSpan(locs[0])
 	if err != nil {
-		return fmt.Errorf("%v: %v", from, err)
+		return nil, fmt.Errorf("%v: %v", from, err)
 	}
 
 	q := protocol.HoverParams{
@@ -112,26 +113,23 @@ func (d *definition) Run(ctx context.Context, args ...string) error {
 	}
 	hover, err := conn.Hover(ctx, &q)
 	if err != nil {
-		return fmt.Errorf("%v: %v", from, err)
+		return nil, fmt.Errorf("%v: %v", from, err)
 	}
 	var description string
 	if hover != nil {
 		description = strings.TrimSpace(hover.Contents.Value)
 	}
 
-	result := &Definition{
+	return &Definition{
 		Span:        definition,
 		Description: description,
+	}, nil
+}
+
+func (d *Definition) printText(w io.Writer) {
+	fmt.Fprintf(w, "%v", d.Span)
+	if len(d.Description) > 0 {
+		fmt.Fprintf(w, ": defined here as %s", d.Description)
 	}
-	if d.JSON {
-		enc := json.NewEncoder(os.Stdout)
-		enc.SetIndent("", "\t")
-		return enc.Encode(result)
-	}
-	fmt.Printf("%v", result.Span)
-	if len(result.Description) > 0 {
-		fmt.Printf(": defined here as %s", result.Description)
-	}
-	fmt.Printf("\n")
-	return nil
+	fmt.Fprintf(w, "\n")
 }
@@ -8,6 +8,8 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"io"
+	"sort"
 
 	"golang.org/x/tools/gopls/pkg/lsp/protocol"
 	"golang.org/x/tools/pkg/tool"
@@ -15,12 +17,14 @@ import (
 
 // highlight implements the highlight verb for gopls.
 type highlight struct {
+	JSON bool `flag:"json" help:"emit the highlights in JSON format"`
+
 	app *Application
 }
 
 func (r *highlight) Name() string      { return "highlight" }
 func (r *highlight) Parent() string    { return r.app.Name() }
-func (r *highlight) Usage() string     { return "<position>" }
+func (r *highlight) Usage() string     { return "[highlight-flags] <position>" }
 func (r *highlight) ShortHelp() string { return "display selected identifier's highlights" }
 func (r *highlight) DetailedHelp(f *flag.FlagSet) {
 	fmt.Fprint(f.Output(), `
@@ -29,6 +33,8 @@ Example:
 	$ # 1-indexed location (:line:column or :#offset) of the target identifier
 	$ gopls highlight helper/helper.go:8:6
 	$ gopls highlight helper/helper.go:#53
+
+highlight-flags:
 `)
 	printFlagDefaults(f)
 }
@@ -37,22 +43,22 @@ func (r *highlight) Run(ctx context.Context, args ...string) error {
 	if len(args) != 1 {
 		return tool.CommandLineErrorf("highlight expects 1 argument (position)")
 	}
+	return r.app.runQuery(ctx, r, r.JSON, args...)
+}
 
-	conn, err := r.app.connect(ctx, nil)
-	if err != nil {
-		return err
+func (r *highlight) query(ctx context.Context, conn *connection, args ...string) (queryResult, error) {
+	if len(args) != 1 {
+		return nil, tool.CommandLineErrorf("highlight expects 1 argument (position)")
 	}
-	defer conn.terminate(ctx)
-
 	from := parseSpan(args[0])
 	file, err := conn.openFile(ctx, from.URI())
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	loc, err := file.spanLocation(from)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	p := protocol.DocumentHighlightParams{
@@ -60,22 +66,34 @@ func (r *highlight) Run(ctx context.Context, args ...string) error {
 	}
 	highlights, err := conn.DocumentHighlight(ctx, &p)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	var results []span
+	results := highlightList{}
 	for _, h := range highlights {
 		s, err := file.rangeSpan(h.Range)
 		if err != nil {
-			return err
+			return nil, err
 		}
-		results = append(results, s)
+		results = append(results, Highlight{Span: s, Kind: fmt.Sprint(h.Kind)})
 	}
 	// Sort results to make tests deterministic since DocumentHighlight uses a map.
-	sortSpans(results)
+	sort.SliceStable(results, func(i, j int) bool {
+		return compare(results[i].Span, results[j].Span) < 0
+	})
+	return results, nil
+}
+
+// A Highlight is a result of a 'highlight' query.
+type Highlight struct {
+	Span span   `json:"span"` // span of the reference
+	Kind string `json:"kind"` // "Text", "Read", or "Write"
+}
+
+type highlightList []Highlight
 
-	for _, s := range results {
-		fmt.Println(s)
+func (l highlightList) printText(w io.Writer) {
+	for _, h := range l {
+		fmt.Fprintln(w, h.Span)
 	}
-	return nil
 }
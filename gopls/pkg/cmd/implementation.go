@@ -8,7 +8,6 @@ import (
 	"context"
 	"flag"
 	"fmt"
-	"sort"
 
 	"golang.org/x/tools/gopls/pkg/lsp/protocol"
 	"golang.org/x/tools/pkg/tool"
@@ -16,12 +15,14 @@ import (
 
 // implementation implements the implementation verb for gopls
 type implementation struct {
+	JSON bool `flag:"json" help:"emit the implementations in JSON format"`
+
 	app *Application
 }
 
 func (i *implementation) Name() string      { return "implementation" }
 func (i *implementation) Parent() string    { return i.app.Name() }
-func (i *implementation) Usage() string     { return "<position>" }
+func (i *implementation) Usage() string     { return "[implementation-flags] <position>" }
 func (i *implementation) ShortHelp() string { return "display selected identifier's implementation" }
 func (i *implementation) DetailedHelp(f *flag.FlagSet) {
 	fmt.Fprint(f.Output(), `
@@ -30,6 +31,8 @@ Example:
 	$ # 1-indexed location (:line:column or :#offset) of the target identifier
 	$ gopls implementation helper/helper.go:8:6
 	$ gopls implementation helper/helper.go:#53
+
+implementation-flags:
 `)
 	printFlagDefaults(f)
 }
@@ -38,22 +41,22 @@ func (i *implementation) Run(ctx context.Context, args ...string) error {
 	if len(args) != 1 {
 		return tool.CommandLineErrorf("implementation expects 1 argument (position)")
 	}
+	return i.app.runQuery(ctx, i, i.JSON, args...)
+}
 
-	conn, err := i.app.connect(ctx, nil)
-	if err != nil {
-		return err
+func (i *implementation) query(ctx context.Context, conn *connection, args ...string) (queryResult, error) {
+	if len(args) != 1 {
+		return nil, tool.CommandLineErrorf("implementation expects 1 argument (position)")
 	}
-	defer conn.terminate(ctx)
-
 	from := parseSpan(args[0])
 	file, err := conn.openFile(ctx, from.URI())
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	loc, err := file.spanLocation(from)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	p := protocol.ImplementationParams{
@@ -61,26 +64,21 @@ func (i *implementation) Run(ctx context.Context, args ...string) error {
 	}
 	implementations, err := conn.Implementation(ctx, &p)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	var spans []string
+	spans := spanList{}
 	for _, impl := range implementations {
 		f, err := conn.openFile(ctx, impl.URI)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		span, err := f.locationSpan(impl)
 		if err != nil {
-			return err
+			return nil, err
 		}
-		spans = append(spans, fmt.Sprint(span))
-	}
-	sort.Strings(spans)
-
-	for _, s := range spans {
-		fmt.Println(s)
+		spans = append(spans, span)
 	}
-
-	return nil
+	sortSpans(spans)
+	return spans, nil
 }
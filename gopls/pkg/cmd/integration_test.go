@@ -34,6 +34,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"reflect"
 	"regexp"
 	"strings"
 	"testing"
@@ -43,6 +44,7 @@ import (
 	"golang.org/x/tools/gopls/pkg/hooks"
 	"golang.org/x/tools/gopls/pkg/lsp/debug"
 	"golang.org/x/tools/gopls/pkg/lsp/protocol"
+	"golang.org/x/tools/gopls/pkg/telemetry"
 	"golang.org/x/tools/pkg/testenv"
 	"golang.org/x/tools/pkg/tool"
 	"golang.org/x/tools/txtar"
@@ -95,6 +97,18 @@ var _ = fmt.Sprintf("%s", 123)
 package a
 import "fmt"
 var _ = fmt.Sprintf("%d", "123")
+
+-- c/c.go --
+package c
+
+-- d/d.go --
+package d
+var X int = "x"
+
+-- c/c_test.go --
+package c
+import "fmt"
+var _ = fmt.Sprintf("%d", "123")
 `)
 
 	// no files
@@ -109,17 +123,88 @@ var _ = fmt.Sprintf("%d", "123")
 	// one file
 	{
 		res := gopls(t, tree, "check", "./a.go")
-		res.checkExit(true)
+		res.checkExit(false)
 		res.checkStdout("fmt.Sprintf format %s has arg 123 of wrong type int")
+		res.checkStderr("1 diagnostic")
 	}
 
 	// two files
 	{
 		res := gopls(t, tree, "check", "./a.go", "./b.go")
-		res.checkExit(true)
+		res.checkExit(false)
 		res.checkStdout(`a.go:.* fmt.Sprintf format %s has arg 123 of wrong type int`)
 		res.checkStdout(`b.go:.* fmt.Sprintf format %d has arg "123" of wrong type string`)
 	}
+
+	// package pattern, including tests
+	{
+		res := gopls(t, tree, "check", "./c", "./d")
+		res.checkExit(false)
+		res.checkStdout(`d.go:.* cannot use "x"`)
+		res.checkStdout(`c_test.go:.* fmt.Sprintf format %d has arg "123" of wrong type string`)
+	}
+
+	// severity filtering: the printf analyzer reports warnings
+	{
+		res := gopls(t, tree, "check", "-severity=error", "./...")
+		res.checkExit(false)
+		res.checkStdout(`d.go:.* cannot use "x"`)
+		if strings.Contains(res.stdout, "fmt.Sprintf") {
+			t.Errorf("warnings reported despite -severity=error: %v", res)
+		}
+	}
+	{
+		res := gopls(t, tree, "check", "-severity=error", "./a.go")
+		res.checkExit(true)
+		if res.stdout != "" {
+			t.Errorf("unexpected output: %v", res)
+		}
+	}
+
+	// JSON
+	{
+		res := gopls(t, tree, "check", "-json", "./a.go")
+		res.checkExit(false)
+		var diags []cmd.Diagnostic
+		if res.toJSON(&diags) {
+			if len(diags) != 1 {
+				t.Fatalf("got %d diagnostics, want 1: %v", len(diags), res)
+			}
+			d := diags[0]
+			if d.Severity != "warning" || d.Source != "printf" || !strings.Contains(d.Message, "fmt.Sprintf format %s") {
+				t.Errorf("unexpected diagnostic: %+v", d)
+			}
+		}
+	}
+
+	// SARIF
+	{
+		res := gopls(t, tree, "check", "-sarif", "./a.go")
+		res.checkExit(false)
+		var log struct {
+			Version string
+			Runs    []struct {
+				Results []struct {
+					RuleID    string
+					Level     string
+					Locations []struct {
+						PhysicalLocation struct {
+							Region struct{ StartLine int }
+						}
+					}
+				}
+			}
+		}
+		if res.toJSON(&log) {
+			if log.Version != "2.1.0" || len(log.Runs) != 1 || len(log.Runs[0].Results) != 1 {
+				t.Fatalf("unexpected SARIF log: %v", res)
+			}
+			result := log.Runs[0].Results[0]
+			if result.RuleID != "printf" || result.Level != "warning" || result.Locations[0].PhysicalLocation.Region.StartLine != 3 {
+				t.Errorf("unexpected SARIF result: %+v", result)
+			}
+		}
+	}
 }
 
 // TestCallHierarchy tests the 'call_hierarchy' subcommand (../call_hierarchy.go).
@@ -163,6 +248,28 @@ func h() {
 		res.checkStdout("ranges 4:2-3 in ..a.go from/to function g in ..a.go:3:6-7")
 		res.checkStdout("identifier: function f in ..a.go:2:6-7")
 	}
+	// -json
+	{
+		res := gopls(t, tree, "call_hierarchy", "-json", "a.go:2:6")
+		res.checkExit(true)
+		var hierarchies []cmd.CallHierarchy
+		if res.toJSON(&hierarchies) {
+			if len(hierarchies) != 1 {
+				t.Fatalf("got %d call hierarchies, want 1", len(hierarchies))
+			}
+			h := hierarchies[0]
+			if h.Identifier.Name != "f" || h.Identifier.Kind != "Function" || h.Identifier.Span.Start().Line() != 2 {
+				t.Errorf("unexpected identifier: %+v", h.Identifier)
+			}
+			calls := make(map[string]int)
+			for _, caller := range h.Callers {
+				calls[caller.Item.Name] = len(caller.Calls)
+			}
+			if calls["g"] != 1 || calls["h"] != 2 || len(h.Callees) != 0 {
+				t.Errorf("got callers %v and %d callees, want g (1 call), h (2 calls) and none", calls, len(h.Callees))
+			}
+		}
+	}
 }
 
 // TestCodeLens tests the 'codelens' subcommand (../codelens.go).
@@ -385,6 +492,22 @@ func f() {
 		res.checkStdout("a.go:4:6-13")
 		res.checkStdout("a.go:5:6-13")
 	}
+	// -json
+	{
+		res := gopls(t, tree, "highlight", "-json", "a.go:4:7")
+		res.checkExit(true)
+		var highlights []cmd.Highlight
+		if res.toJSON(&highlights) {
+			if len(highlights) != 2 {
+				t.Fatalf("got %d highlights, want 2", len(highlights))
+			}
+			for i, h := range highlights {
+				if line := h.Span.Start().Line(); line != 4+i || h.Kind == "" {
+					t.Errorf("highlight %d: got line %d and kind %q, want line %d and a kind", i, line, h.Kind, 4+i)
+				}
+			}
+		}
+	}
 }
 
 // TestImplementations tests the 'implementation' subcommand (../implementation.go).
@@ -577,6 +700,17 @@ func f() {
 		res.checkStdout("Println\\(a ...")
 		res.checkStdout("Println formats using the default formats...")
 	}
+	// -json
+	{
+		res := gopls(t, tree, "signature", "-json", "a.go:4:15")
+		res.checkExit(true)
+		var sig cmd.Signature
+		if res.toJSON(&sig) {
+			if !strings.HasPrefix(sig.Label, "Println(a ...") || len(sig.Parameters) != 1 || sig.ActiveParameter != 0 {
+				t.Errorf("unexpected signature: %+v", sig)
+			}
+		}
+	}
 }
 
 // TestPrepareRename tests the 'prepare_rename' subcommand (../prepare_rename.go).
@@ -693,6 +827,22 @@ const c = 0
 		res.checkStdout("v Variable 3:5-3:6")
 		res.checkStdout("c Constant 4:7-4:8")
 	}
+	// -json
+	{
+		res := gopls(t, tree, "symbols", "-json", "a.go")
+		res.checkExit(true)
+		var symbols []cmd.Symbol
+		if res.toJSON(&symbols) {
+			var got []string
+			for _, s := range symbols {
+				got = append(got, fmt.Sprintf("%s %s %d:%d", s.Name, s.Kind, s.Span.Start().Line(), s.Span.Start().Column()))
+			}
+			want := []string{"f Function 2:6", "v Variable 3:5", "c Constant 4:7"}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("got symbols %q, want %q", got, want)
+			}
+		}
+	}
 }
 
 // TestSemtok tests the 'semtok' subcommand (../semantictokens.go).
@@ -846,6 +996,33 @@ package foo
 	}
 }
 
+func TestStatsHistory(t *testing.T) {
+	t.Parallel()
+
+	tree := writeTree(t, `
+-- history.jsonl --
+{"time": "2023-11-01T10:00:00Z", "pid": 1, "start": "2023-11-01T09:00:00Z", "counters": {"gopls/hover/latency:<10ms": 3}}
+{"time": "2023-11-01T12:00:00Z", "pid": 1, "start": "2023-11-01T09:00:00Z", "counters": {"gopls/hover/latency:<10ms": 3, "gopls/hover/latency:<1s": 1}}
+{"time": "2023-11-0
+`)
+	res := gopls(t, tree, "stats", "-history="+filepath.Join(tree, "history.jsonl"))
+	res.checkExit(true)
+	res.checkStderr("skipped 1 invalid lines")
+
+	var summary telemetry.HistorySummary
+	if res.toJSON(&summary) {
+		if len(summary.Days) != 1 || summary.Days[0].Latency["hover"] == nil {
+			t.Fatalf("got %s, want the hover latency of one day", res.stdout)
+		}
+		if got := *summary.Days[0].Latency["hover"]; got.Count != 4 || got.Median != "<10ms" || got.P90 != "<1s" {
+			t.Errorf("hover latency = %+v, want 4 hovers, of median <10ms and 90th percentile <1s", got)
+		}
+	}
+
+	res = gopls(t, tree, "stats", "-history="+filepath.Join(tree, "missing.jsonl"))
+	res.checkExit(false)
+}
+
 // TestFix tests the 'fix' subcommand (../suggested_fix.go).
 func TestFix(t *testing.T) {
 	t.Parallel()
@@ -914,6 +1091,86 @@ func (C) Read(p []byte) (n int, err error) {
 	}
 }
 
+// TestCodeAction tests the 'codeaction' subcommand (../codeaction.go).
+func TestCodeAction(t *testing.T) {
+	t.Parallel()
+
+	tree := writeTree(t, `
+-- go.mod --
+module example.com
+go 1.18
+
+-- a/a.go --
+package a
+type T struct{ X, Y int }
+var _ = []T{T{1, 2}}
+var _ = []T{T{3, 4}}
+
+-- b/b.go --
+package b
+func f() (int, string) { return }
+`)
+
+	// no arguments
+	{
+		res := gopls(t, tree, "codeaction")
+		res.checkExit(false)
+		res.checkStderr("expects at least 1 argument")
+	}
+	// list the quick fixes of all packages
+	{
+		res := gopls(t, tree, "codeaction", "-kind=quickfix", "./...")
+		res.checkExit(true)
+		res.checkStdout(`a.go:3:13-14: quickfix: Remove 'T'`)
+		res.checkStdout(`a.go:4:13-14: quickfix: Remove 'T'`)
+		res.checkStdout(`b.go:2:26-32: quickfix: Fill in return values`)
+	}
+	// -title filters the code actions
+	{
+		res := gopls(t, tree, "codeaction", "-kind=quickfix", "-title=^Fill", "./...")
+		res.checkExit(true)
+		if strings.Contains(res.stdout, "Remove") {
+			t.Errorf("codeaction -title: got <<%s>>, want no 'Remove' actions", res.stdout)
+		}
+	}
+	// preview the edits of all of them, as a diff
+	{
+		res := gopls(t, tree, "codeaction", "-kind=quickfix", "-title=^Remove", "-d", "./...")
+		res.checkExit(true)
+		res.checkStdout(`-var _ = \[\]T\{T\{1, 2\}\}`)
+		res.checkStdout(`\+var _ = \[\]T\{\{1, 2\}\}`)
+		res.checkStdout(`\+var _ = \[\]T\{\{3, 4\}\}`)
+	}
+	// apply them
+	{
+		res := gopls(t, tree, "codeaction", "-kind=quickfix", "-w", "./...")
+		res.checkExit(true)
+		if res.stdout != "" {
+			t.Errorf("codeaction -w: got stdout <<%s>>, want none", res.stdout)
+		}
+		for filename, want := range map[string]string{
+			"a/a.go": `
+package a
+type T struct{ X, Y int }
+var _ = []T{{1, 2}}
+var _ = []T{{3, 4}}
+`[1:],
+			"b/b.go": `
+package b
+func f() (int, string) { return 0, "" }
+`[1:],
+		} {
+			got, err := os.ReadFile(filepath.Join(tree, filename))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != want {
+				t.Errorf("codeaction -w: %s: got <<%s>>, want <<%s>>\nstderr:\n%s", filename, got, want, res.stderr)
+			}
+		}
+	}
+}
+
 // TestWorkspaceSymbol tests the 'workspace_symbol' subcommand (../workspace_symbol.go).
 func TestWorkspaceSymbol(t *testing.T) {
 	t.Parallel()
@@ -939,6 +1196,132 @@ func someFunctionName()
 		res.checkExit(true)
 		res.checkStdout("a.go:2:6-22 someFunctionName Function")
 	}
+	// -json
+	{
+		res := gopls(t, tree, "workspace_symbol", "-json", "meFun")
+		res.checkExit(true)
+		var symbols []cmd.WorkspaceSymbol
+		if res.toJSON(&symbols) {
+			if len(symbols) != 1 || symbols[0].Name != "someFunctionName" || symbols[0].Kind != "Function" {
+				t.Errorf("unexpected symbols: %+v", symbols)
+			}
+		}
+	}
+}
+
+// TestQuery tests the 'query' subcommand (../query.go).
+func TestQuery(t *testing.T) {
+	t.Parallel()
+
+	tree := writeTree(t, `
+-- go.mod --
+module example.com
+go 1.18
+
+-- a.go --
+package a
+func f() {}
+func g() {
+	f()
+	f()
+}
+`)
+	queries := `
+{"id": 1, "verb": "highlight", "args": ["a.go:4:2"]}
+{"id": "two", "verb": "workspace_symbol", "args": ["-json", "g"]}
+{"id": 3, "verb": "references", "args": ["-d", "a.go:2:6"]}
+{"id": 4, "verb": "rename", "args": ["a.go:2:6", "h"]}
+{"id": 5, "verb": "nope"}
+{"id": 6, "verb": "references", "args": []}
+`
+	res := goplsWithInput(t, tree, nil, queries, "query")
+	res.checkExit(true)
+
+	type answer struct {
+		ID     json.RawMessage `json:"id"`
+		Result json.RawMessage `json:"result"`
+		Error  string          `json:"error"`
+	}
+	var answers []answer
+	dec := json.NewDecoder(strings.NewReader(res.stdout))
+	for dec.More() {
+		var a answer
+		if err := dec.Decode(&a); err != nil {
+			t.Fatalf("invalid answer: %v (%s)", err, res)
+		}
+		answers = append(answers, a)
+	}
+	if len(answers) != 6 {
+		t.Fatalf("got %d answers, want 6 (%s)", len(answers), res)
+	}
+	for i, id := range []string{`1`, `"two"`, `3`, `4`, `5`, `6`} {
+		if string(answers[i].ID) != id {
+			t.Errorf("answer %d has id %s, want %s", i, answers[i].ID, id)
+		}
+	}
+
+	var highlights []cmd.Highlight
+	if err := json.Unmarshal(answers[0].Result, &highlights); err != nil || len(highlights) != 3 {
+		t.Errorf("highlight: got %s (error %q), want 3 highlights", answers[0].Result, answers[0].Error)
+	}
+	var symbols []cmd.WorkspaceSymbol
+	if err := json.Unmarshal(answers[1].Result, &symbols); err != nil || len(symbols) == 0 || symbols[0].Name != "g" {
+		t.Errorf("workspace_symbol: got %s (error %q), want g", answers[1].Result, answers[1].Error)
+	}
+	var refs []json.RawMessage
+	if err := json.Unmarshal(answers[2].Result, &refs); err != nil || len(refs) != 3 {
+		t.Errorf("references -d: got %s (error %q), want 3 references", answers[2].Result, answers[2].Error)
+	}
+	for i, want := range map[int]string{3: "does not support queries", 4: "unknown verb", 5: "expects 1 argument"} {
+		if !strings.Contains(answers[i].Error, want) {
+			t.Errorf("answer %d: got error %q, want %q", i, answers[i].Error, want)
+		}
+	}
+}
+
+func TestVulndb(t *testing.T) {
+	t.Parallel()
+
+	tree := writeTree(t, `
+-- osv/GO-2023-0001.json --
+{"id": "GO-2023-0001", "modified": "2023-01-02T00:00:00Z", "affected": [{"package": {"name": "golang.org/amod", "ecosystem": "Go"}, "ranges": [{"type": "SEMVER", "events": [{"introduced": "0"}, {"fixed": "1.0.4"}]}]}]}
+-- osv/GO-2023-0002.json --
+{"id": "GO-2023-0002", "modified": "2023-01-05T00:00:00Z", "affected": [{"package": {"name": "golang.org/bmod", "ecosystem": "Go"}}]}
+`)
+	dir := filepath.Join(tree, "vulndb")
+
+	// no snapshot
+	{
+		res := gopls(t, tree, "vulndb", "status", "-dir="+dir)
+		res.checkExit(true)
+		res.checkStdout("There is no snapshot")
+	}
+	// import
+	{
+		res := gopls(t, tree, "vulndb", "import", "-dir="+dir, filepath.Join(tree, "osv"))
+		res.checkExit(true)
+		res.checkStdout("Imported version 2023-01-05T00:00:00Z")
+		res.checkStderr("last modified on 2023-01-05")
+	}
+	// import, up to date
+	{
+		res := gopls(t, tree, "vulndb", "import", "-dir="+dir, filepath.Join(tree, "osv"))
+		res.checkExit(true)
+		res.checkStdout("is up to date, at version 2023-01-05T00:00:00Z")
+	}
+	// status
+	{
+		res := gopls(t, tree, "vulndb", "status", "-dir="+dir)
+		res.checkExit(true)
+		res.checkStdout("version:  2023-01-05T00:00:00Z")
+		res.checkStderr("last modified on 2023-01-05")
+	}
+	// import, not a database
+	{
+		res := gopls(t, tree, "vulndb", "import", "-dir="+dir, filepath.Join(tree, "vulndb", "index", "db.json"))
+		res.checkExit(false)
+		res.checkStderr("neither a directory nor a zip file")
+	}
 }
 
 // -- test framework --
@@ -993,6 +1376,12 @@ func gopls(t *testing.T, dir string, args ...string) *result {
 }
 
 func goplsWithEnv(t *testing.T, dir string, env []string, args ...string) *result {
+	return goplsWithInput(t, dir, env, "", args...)
+}
+
+// goplsWithInput executes gopls in a child process, with the given
+// standard input.
+func goplsWithInput(t *testing.T, dir string, env []string, stdin string, args ...string) *result {
 	testenv.NeedsTool(t, "go")
 
 	// Catch inadvertent use of dir=".", which would make
@@ -1005,6 +1394,7 @@ func goplsWithEnv(t *testing.T, dir string, env []string, args ...string) *resul
 	goplsCmd.Env = append(os.Environ(), "ENTRYPOINT=goplsMain")
 	goplsCmd.Env = append(goplsCmd.Env, env...)
 	goplsCmd.Dir = dir
+	goplsCmd.Stdin = strings.NewReader(stdin)
 	goplsCmd.Stdout = new(bytes.Buffer)
 	goplsCmd.Stderr = new(bytes.Buffer)
 
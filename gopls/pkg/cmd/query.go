@@ -0,0 +1,206 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"golang.org/x/tools/gopls/pkg/lsp/protocol"
+	"golang.org/x/tools/gopls/pkg/settings"
+	"golang.org/x/tools/pkg/tool"
+)
+
+// A queryVerb is a verb that answers a query, such as 'references',
+// whose result is printed as text, or with -json as JSON. Its queries
+// may also be issued to the 'query' verb, in a single session.
+type queryVerb interface {
+	tool.Application
+
+	// query answers the query for the (non-flag) arguments args on
+	// conn. Its result must marshal to JSON as the result of -json.
+	query(ctx context.Context, conn *connection, args ...string) (queryResult, error)
+}
+
+// A queryResult is the result of a queryVerb.
+type queryResult interface {
+	// printText prints the result in the text format of the verb.
+	printText(w io.Writer)
+}
+
+// A spanList is a queryResult that lists spans, one per line.
+type spanList []span
+
+func (l spanList) printText(w io.Writer) {
+	for _, s := range l {
+		fmt.Fprintln(w, s)
+	}
+}
+
+// runQuery connects to the server, and prints the result of the query
+// of verb for args, as JSON if asJSON is set.
+func (app *Application) runQuery(ctx context.Context, verb queryVerb, asJSON bool, args ...string) error {
+	conn, err := app.connect(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer conn.terminate(ctx)
+
+	result, err := verb.query(ctx, conn, args...)
+	if err != nil {
+		return err
+	}
+	if asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "\t")
+		return enc.Encode(result)
+	}
+	result.printText(os.Stdout)
+	return nil
+}
+
+// queryStream implements the query verb for gopls.
+type queryStream struct {
+	app *Application
+}
+
+func (q *queryStream) Name() string   { return "query" }
+func (q *queryStream) Parent() string { return q.app.Name() }
+func (q *queryStream) Usage() string  { return "" }
+func (q *queryStream) ShortHelp() string {
+	return "answer a stream of JSON queries in a single session"
+}
+func (q *queryStream) DetailedHelp(f *flag.FlagSet) {
+	fmt.Fprint(f.Output(), `
+The query command reads queries, as JSON objects, from its standard input,
+and answers them in order, in a single gopls session, writing one line of
+JSON per answer to its standard output. It ends at the end of its input.
+
+Each query names a verb that supports -json, and its arguments, which may
+include the flags of the verb:
+
+	{"id": 1, "verb": "references", "args": ["-d", "a.go:4:10"]}
+
+Each answer holds the id of its query, and either the result of the
+verb, as printed by -json, or an error:
+
+	{"id":1,"result":[{"uri":"file:///...","start":{...},"end":{...}}]}
+	{"id":2,"error":"..."}
+
+The flags that configure the session, such as -markdown of definition
+and -matcher of workspace_symbol, have no effect on queries.
+
+The verbs that may be queried are: call_hierarchy, definition, highlight,
+implementation, references, signature, symbols, and workspace_symbol.
+
+Example:
+
+	$ echo '{"id": 1, "verb": "highlight", "args": ["a.go:4:7"]}' | gopls query
+`)
+	printFlagDefaults(f)
+}
+
+// A queryRequest is a query read by the query verb.
+type queryRequest struct {
+	ID   json.RawMessage `json:"id,omitempty"`
+	Verb string          `json:"verb"`
+	Args []string        `json:"args"`
+}
+
+// A queryResponse is the answer to a queryRequest.
+type queryResponse struct {
+	ID     json.RawMessage `json:"id,omitempty"`
+	Result queryResult     `json:"result,omitempty"`
+	Error  string          `json:"error,omitempty"`
+}
+
+func (q *queryStream) Run(ctx context.Context, args ...string) error {
+	if len(args) > 0 {
+		return tool.CommandLineErrorf("query expects no arguments: queries are read from standard input")
+	}
+	// Plaintext makes more sense for the command line.
+	opts := q.app.options
+	q.app.options = func(o *settings.Options) {
+		if opts != nil {
+			opts(o)
+		}
+		o.PreferredContentFormat = protocol.PlainText
+	}
+	conn, err := q.app.connect(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer conn.terminate(ctx)
+
+	dec := json.NewDecoder(os.Stdin)
+	enc := json.NewEncoder(os.Stdout)
+	for {
+		var req queryRequest
+		if err := dec.Decode(&req); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("reading query: %v", err)
+		}
+		resp := queryResponse{ID: req.ID}
+		if result, err := q.answer(ctx, conn, &req); err != nil {
+			resp.Error = err.Error()
+		} else {
+			resp.Result = result
+		}
+		if err := enc.Encode(&resp); err != nil {
+			return err
+		}
+	}
+}
+
+// answer answers a single query, on conn.
+func (q *queryStream) answer(ctx context.Context, conn *connection, req *queryRequest) (queryResult, error) {
+	var verb queryVerb
+	for _, cmd := range q.app.featureCommands() { // fresh verbs, with zero flags
+		if cmd.Name() == req.Verb {
+			verb, _ = cmd.(queryVerb)
+			if verb == nil {
+				return nil, fmt.Errorf("verb %q does not support queries", req.Verb)
+			}
+		}
+	}
+	if verb == nil {
+		return nil, fmt.Errorf("unknown verb %q", req.Verb)
+	}
+	call := &queryCall{Verb: verb, conn: conn}
+	fs := flag.NewFlagSet(req.Verb, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	if err := tool.Run(ctx, fs, call, req.Args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil, fmt.Errorf("%s: no help is available in queries", req.Verb)
+		}
+		return nil, err
+	}
+	return call.result, nil
+}
+
+// A queryCall is the tool.Application that answers a query of the query
+// verb, once tool.Run has parsed the flags of its verb.
+type queryCall struct {
+	Verb queryVerb // exported for flag reflection
+
+	conn   *connection
+	result queryResult
+}
+
+func (c *queryCall) Name() string                 { return c.Verb.Name() }
+func (c *queryCall) Usage() string                { return c.Verb.Usage() }
+func (c *queryCall) ShortHelp() string            { return c.Verb.ShortHelp() }
+func (c *queryCall) DetailedHelp(f *flag.FlagSet) { c.Verb.DetailedHelp(f) }
+
+func (c *queryCall) Run(ctx context.Context, args ...string) (err error) {
+	c.result, err = c.Verb.query(ctx, c.conn, args...)
+	return err
+}
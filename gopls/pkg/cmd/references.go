@@ -8,7 +8,6 @@ import (
 	"context"
 	"flag"
 	"fmt"
-	"sort"
 
 	"golang.org/x/tools/gopls/pkg/lsp/protocol"
 	"golang.org/x/tools/pkg/tool"
@@ -17,6 +16,7 @@ import (
 // references implements the references verb for gopls
 type references struct {
 	IncludeDeclaration bool `flag:"d,declaration" help:"include the declaration of the specified identifier in the results"`
+	JSON               bool `flag:"json" help:"emit the references in JSON format"`
 
 	app *Application
 }
@@ -42,21 +42,21 @@ func (r *references) Run(ctx context.Context, args ...string) error {
 	if len(args) != 1 {
 		return tool.CommandLineErrorf("references expects 1 argument (position)")
 	}
+	return r.app.runQuery(ctx, r, r.JSON, args...)
+}
 
-	conn, err := r.app.connect(ctx, nil)
-	if err != nil {
-		return err
+func (r *references) query(ctx context.Context, conn *connection, args ...string) (queryResult, error) {
+	if len(args) != 1 {
+		return nil, tool.CommandLineErrorf("references expects 1 argument (position)")
 	}
-	defer conn.terminate(ctx)
-
 	from := parseSpan(args[0])
 	file, err := conn.openFile(ctx, from.URI())
 	if err != nil {
-		return err
+		return nil, err
 	}
 	loc, err := file.spanLocation(from)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	p := protocol.ReferenceParams{
 		Context: protocol.ReferenceContext{
@@ -66,26 +66,22 @@ func (r *references) Run(ctx context.Context, args ...string) error {
 	}
 	locations, err := conn.References(ctx, &p)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	var spans []string
+	spans := spanList{}
 	for _, l := range locations {
 		f, err := conn.openFile(ctx, l.URI)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		// convert location to span for user-friendly 1-indexed line
 		// and column numbers
 		span, err := f.locationSpan(l)
 		if err != nil {
-			return err
+			return nil, err
 		}
-		spans = append(spans, fmt.Sprint(span))
-	}
-
-	sort.Strings(spans)
-	for _, s := range spans {
-		fmt.Println(s)
+		spans = append(spans, span)
 	}
-	return nil
+	sortSpans(spans)
+	return spans, nil
 }
@@ -35,6 +35,7 @@ func newRemote(app *Application, alias string) *remote {
 		subcommands: subcommands{
 			&listSessions{app: app},
 			&startDebugging{app: app},
+			&evictSessions{app: app},
 		},
 		alias: alias,
 	}
@@ -93,7 +94,11 @@ func (c *listSessions) Run(ctx context.Context, args ...string) error {
 	if remote == "" {
 		remote = "auto"
 	}
-	state, err := lsprpc.QueryServerState(ctx, remote)
+	token, err := c.app.remoteToken()
+	if err != nil {
+		return err
+	}
+	state, err := lsprpc.QueryServerState(ctx, remote, token)
 	if err != nil {
 		return err
 	}
@@ -150,8 +155,12 @@ func (c *startDebugging) Run(ctx context.Context, args ...string) error {
 	debugArgs := command.DebuggingArgs{
 		Addr: debugAddr,
 	}
+	token, err := c.app.remoteToken()
+	if err != nil {
+		return err
+	}
 	var result command.DebuggingResult
-	if err := lsprpc.ExecuteCommand(ctx, remote, command.StartDebugging.ID(), debugArgs, &result); err != nil {
+	if err := lsprpc.ExecuteCommand(ctx, remote, token, command.StartDebugging.ID(), debugArgs, &result); err != nil {
 		return err
 	}
 	if len(result.URLs) == 0 {
@@ -162,3 +171,59 @@ func (c *startDebugging) Run(ctx context.Context, args ...string) error {
 	}
 	return nil
 }
+
+// evictSessions is a remote subcommand to close sessions of the daemon.
+type evictSessions struct {
+	app *Application
+}
+
+func (c *evictSessions) Name() string  { return "evict" }
+func (c *evictSessions) Usage() string { return "<session-id>..." }
+func (c *evictSessions) ShortHelp() string {
+	return "close gopls sessions"
+}
+
+const evictSessionsExamples = `
+The sessions are identified by the IDs printed by 'gopls remote sessions'.
+Their clients are notified, and disconnected. Unless the daemon was
+started with -listen.tokens, and the -remote.tokenfile token is that of
+an administrator, only the sessions of the same tenant may be evicted.
+
+Examples:
+
+1) evict a session from the default daemon:
+
+$ gopls remote evict 3
+
+2) evict sessions from a daemon that authenticates its clients:
+
+$ gopls -remote=localhost:8082 -remote.tokenfile=$HOME/.gopls-token remote evict 3 5
+`
+
+func (c *evictSessions) DetailedHelp(f *flag.FlagSet) {
+	fmt.Fprint(f.Output(), evictSessionsExamples)
+	printFlagDefaults(f)
+}
+
+func (c *evictSessions) Run(ctx context.Context, args ...string) error {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, c.Usage())
+		return errors.New("invalid usage")
+	}
+	remote := c.app.Remote
+	if remote == "" {
+		remote = "auto"
+	}
+	token, err := c.app.remoteToken()
+	if err != nil {
+		return err
+	}
+	evicted, err := lsprpc.EvictSessions(ctx, remote, token, args)
+	if err != nil {
+		return err
+	}
+	for _, id := range evicted {
+		fmt.Printf("evicted session %s\n", id)
+	}
+	return nil
+}
@@ -31,8 +31,14 @@ type Serve struct {
 	Port        int           `flag:"port" help:"port on which to run gopls for debugging purposes"`
 	Address     string        `flag:"listen" help:"address on which to listen for remote connections. If prefixed by 'unix;', the subsequent address is assumed to be a unix domain socket. Otherwise, TCP is used."`
 	IdleTimeout time.Duration `flag:"listen.timeout" help:"when used with -listen, shut down the server when there are no connected clients for this duration"`
+	Tokens      string        `flag:"listen.tokens" help:"when used with -listen, the file of tenant tokens with which clients must authenticate (see -remote.tokenfile)"`
+	MaxViews    int           `flag:"listen.maxviews" help:"when used with -listen, the maximum number of views of the sessions of each tenant"`
+	MaxMemory   int           `flag:"listen.maxmemory" help:"when used with -listen, the maximum share of the heap, in MiB, of the sessions of each tenant, in proportion to their views"`
 	Trace       bool          `flag:"rpc.trace" help:"print the full rpc trace in lsp inspector format"`
+	Slow        time.Duration `flag:"rpc.slow" help:"capture the goroutines of LSP requests that run for longer than this duration, for download from the debug server"`
+	Record      string        `flag:"rpc.record" help:"record the session, with the workspace files, to this txtar archive, for replay by the regtest package"`
 	Debug       string        `flag:"debug" help:"serve debug information on the supplied address"`
+	OTLP        string        `flag:"otlp" help:"export traces and metrics to the OpenTelemetry collector at this OTLP/HTTP address (e.g. http://localhost:4318)"`
 
 	RemoteListenTimeout time.Duration `flag:"remote.listen.timeout" help:"when used with -remote=auto, the -listen.timeout value used to start the daemon"`
 	RemoteDebug         string        `flag:"remote.debug" help:"when used with -remote=auto, the -debug value used to start the daemon"`
@@ -91,16 +97,62 @@ func (s *Serve) Run(ctx context.Context, args ...string) error {
 		defer closeLog()
 		di.ServerAddress = s.Address
 		di.Serve(ctx, s.Debug)
+		di.SetSlowRequestThreshold(s.Slow)
+		if s.OTLP != "" {
+			defer di.ConnectOTLP(s.OTLP)()
+		}
+	}
+	var recorder *lsprpc.Recorder
+	if s.Record != "" {
+		if isDaemon {
+			return tool.CommandLineErrorf("-rpc.record records a single session, so can't be used with -listen or -port")
+		}
+		var err error
+		recorder, err = lsprpc.NewRecorder(s.Record)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if err := recorder.Close(); err != nil {
+				log.Printf("recording session: %v", err)
+			}
+		}()
+	}
+	if !isDaemon && (s.Tokens != "" || s.MaxViews != 0 || s.MaxMemory != 0) {
+		return tool.CommandLineErrorf("-listen.tokens, -listen.maxviews and -listen.maxmemory require -listen or -port")
 	}
 	var ss jsonrpc2.StreamServer
 	if s.app.Remote != "" {
-		var err error
-		ss, err = lsprpc.NewForwarder(s.app.Remote, s.remoteArgs)
+		fwd, err := lsprpc.NewForwarder(s.app.Remote, s.remoteArgs)
 		if err != nil {
 			return fmt.Errorf("creating forwarder: %w", err)
 		}
+		if recorder != nil {
+			// Record the forwarded session, as sent to the daemon.
+			fwd.SetRecorder(recorder)
+		}
+		token, err := s.app.remoteToken()
+		if err != nil {
+			return err
+		}
+		fwd.SetToken(token)
+		ss = fwd
 	} else {
-		ss = lsprpc.NewStreamServer(cache.New(nil), isDaemon, s.app.options)
+		server := lsprpc.NewStreamServer(cache.New(nil), isDaemon, s.app.options)
+		if isDaemon {
+			tenancy := &lsprpc.Tenancy{}
+			if s.Tokens != "" {
+				var err error
+				tenancy, err = lsprpc.ReadTenancy(s.Tokens)
+				if err != nil {
+					return err
+				}
+			}
+			tenancy.MaxViews = s.MaxViews
+			tenancy.MaxMemory = uint64(s.MaxMemory) << 20
+			server.SetTenancy(tenancy)
+		}
+		ss = server
 	}
 
 	var network, addr string
@@ -137,6 +189,9 @@ func (s *Serve) Run(ctx context.Context, args ...string) error {
 	if s.Trace && di != nil {
 		stream = protocol.LoggingStream(stream, di.LogWriter)
 	}
+	if recorder != nil && s.app.Remote == "" {
+		stream = recorder.ClientStream(stream)
+	}
 	conn := jsonrpc2.NewConn(stream)
 	err := ss.ServeStream(ctx, conn)
 	if errors.Is(err, io.EOF) {
@@ -8,6 +8,7 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"io"
 
 	"golang.org/x/tools/gopls/pkg/lsp/protocol"
 	"golang.org/x/tools/pkg/tool"
@@ -15,12 +16,14 @@ import (
 
 // signature implements the signature verb for gopls
 type signature struct {
+	JSON bool `flag:"json" help:"emit the signature in JSON format"`
+
 	app *Application
 }
 
 func (r *signature) Name() string      { return "signature" }
 func (r *signature) Parent() string    { return r.app.Name() }
-func (r *signature) Usage() string     { return "<position>" }
+func (r *signature) Usage() string     { return "[signature-flags] <position>" }
 func (r *signature) ShortHelp() string { return "display selected identifier's signature" }
 func (r *signature) DetailedHelp(f *flag.FlagSet) {
 	fmt.Fprint(f.Output(), `
@@ -29,6 +32,8 @@ Example:
 	$ # 1-indexed location (:line:column or :#offset) of the target identifier
 	$ gopls signature helper/helper.go:8:6
 	$ gopls signature helper/helper.go:#53
+
+signature-flags:
 `)
 	printFlagDefaults(f)
 }
@@ -37,22 +42,22 @@ func (r *signature) Run(ctx context.Context, args ...string) error {
 	if len(args) != 1 {
 		return tool.CommandLineErrorf("signature expects 1 argument (position)")
 	}
+	return r.app.runQuery(ctx, r, r.JSON, args...)
+}
 
-	conn, err := r.app.connect(ctx, nil)
-	if err != nil {
-		return err
+func (r *signature) query(ctx context.Context, conn *connection, args ...string) (queryResult, error) {
+	if len(args) != 1 {
+		return nil, tool.CommandLineErrorf("signature expects 1 argument (position)")
 	}
-	defer conn.terminate(ctx)
-
 	from := parseSpan(args[0])
 	file, err := conn.openFile(ctx, from.URI())
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	loc, err := file.spanLocation(from)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	p := protocol.SignatureHelpParams{
@@ -61,27 +66,63 @@ func (r *signature) Run(ctx context.Context, args ...string) error {
 
 	s, err := conn.SignatureHelp(ctx, &p)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	if s == nil || len(s.Signatures) == 0 {
-		return tool.CommandLineErrorf("%v: not a function", from)
+		return nil, tool.CommandLineErrorf("%v: not a function", from)
 	}
 
 	// there is only ever one possible signature,
 	// see toProtocolSignatureHelp in lsp/signature_help.go
 	signature := s.Signatures[0]
-	fmt.Printf("%s\n", signature.Label)
-	switch x := signature.Documentation.Value.(type) {
+	result := &Signature{
+		Label:           signature.Label,
+		Documentation:   documentation(signature.Documentation),
+		Parameters:      []Parameter{},
+		ActiveParameter: int(s.ActiveParameter),
+	}
+	for _, param := range signature.Parameters {
+		result.Parameters = append(result.Parameters, Parameter{
+			Label:         param.Label,
+			Documentation: param.Documentation,
+		})
+	}
+	return result, nil
+}
+
+// documentation returns the text of the documentation of a signature
+// or parameter, which is either a string or markup.
+func documentation(doc *protocol.Or_SignatureInformation_documentation) string {
+	if doc == nil {
+		return ""
+	}
+	switch x := doc.Value.(type) {
 	case string:
-		if x != "" {
-			fmt.Printf("\n%s\n", x)
-		}
+		return x
 	case protocol.MarkupContent:
-		if x.Value != "" {
-			fmt.Printf("\n%s\n", x.Value)
-		}
+		return x.Value
 	}
+	return ""
+}
+
+// A Signature is the result of a 'signature' query.
+type Signature struct {
+	Label           string      `json:"label"`                   // the signature of the function
+	Documentation   string      `json:"documentation,omitempty"` // the doc comment of the function
+	Parameters      []Parameter `json:"parameters"`
+	ActiveParameter int         `json:"activeParameter"` // index of the parameter at the position
+}
+
+// A Parameter is a parameter of a Signature.
+type Parameter struct {
+	Label         string `json:"label"`
+	Documentation string `json:"documentation,omitempty"`
+}
 
-	return nil
+func (s *Signature) printText(w io.Writer) {
+	fmt.Fprintf(w, "%s\n", s.Label)
+	if s.Documentation != "" {
+		fmt.Fprintf(w, "\n%s\n", s.Documentation)
+	}
 }
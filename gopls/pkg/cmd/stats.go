@@ -26,13 +26,15 @@ import (
 	"golang.org/x/tools/gopls/pkg/lsp/filecache"
 	"golang.org/x/tools/gopls/pkg/lsp/protocol"
 	"golang.org/x/tools/gopls/pkg/settings"
+	"golang.org/x/tools/gopls/pkg/telemetry"
 	"golang.org/x/tools/pkg/event"
 )
 
 type stats struct {
 	app *Application
 
-	Anon bool `flag:"anon" help:"hide any fields that may contain user names, file names, or source code"`
+	Anon    bool   `flag:"anon" help:"hide any fields that may contain user names, file names, or source code"`
+	History string `flag:"history" help:"summarize the snapshots of the telemetry counters in this file, written by the telemetryHistoryFile setting, instead of the workspace"`
 }
 
 func (s *stats) Name() string      { return "stats" }
@@ -52,11 +54,23 @@ code are hidden.
 
 Example:
   $ gopls stats -anon
+
+With the -history flag, this command instead outputs a JSON summary, by day,
+of the snapshots of the telemetry counters that gopls appends to the file set
+by the telemetryHistoryFile setting: the number of gopls processes, the counts
+of the counters, and for each operation, such as completion or hover, the
+histogram of its latency, and its median and 90th percentile.
+
+Example:
+  $ gopls stats -history=$HOME/gopls-history.jsonl
 `)
 	printFlagDefaults(f)
 }
 
 func (s *stats) Run(ctx context.Context, args ...string) error {
+	if s.History != "" {
+		return s.summarizeHistory()
+	}
 	if s.app.Remote != "" {
 		// stats does not work with -remote.
 		// Other sessions on the daemon may interfere with results.
@@ -218,6 +232,29 @@ func (s *stats) Run(ctx context.Context, args ...string) error {
 	return nil
 }
 
+// summarizeHistory prints the summary of the telemetry history file.
+func (s *stats) summarizeHistory() error {
+	f, err := os.Open(s.History)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	snapshots, invalid, err := telemetry.ReadHistory(f)
+	if err != nil {
+		return fmt.Errorf("reading %s: %v", s.History, err)
+	}
+	if invalid > 0 {
+		fmt.Fprintf(os.Stderr, "%s: skipped %d invalid lines\n", s.History, invalid)
+	}
+	data, err := json.MarshalIndent(telemetry.SummarizeHistory(snapshots), "", "  ")
+	if err != nil {
+		return err
+	}
+	os.Stdout.Write(data)
+	fmt.Println()
+	return nil
+}
+
 // GoplsStats holds information extracted from a gopls session in the current
 // workspace.
 //
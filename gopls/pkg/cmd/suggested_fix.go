@@ -60,6 +60,9 @@ offset within this file:
 
 	$ gopls fix -a internal/cmd/check.go:#43 refactor.rewrite
 
+To list code actions, or to apply them across many files or packages,
+use the codeaction command.
+
 fix-flags:
 `)
 	printFlagDefaults(f)
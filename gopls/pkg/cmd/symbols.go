@@ -9,6 +9,7 @@ import (
 	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"sort"
 
 	"golang.org/x/tools/gopls/pkg/lsp/protocol"
@@ -17,17 +18,21 @@ import (
 
 // symbols implements the symbols verb for gopls
 type symbols struct {
+	JSON bool `flag:"json" help:"emit the symbols in JSON format"`
+
 	app *Application
 }
 
 func (r *symbols) Name() string      { return "symbols" }
 func (r *symbols) Parent() string    { return r.app.Name() }
-func (r *symbols) Usage() string     { return "<file>" }
+func (r *symbols) Usage() string     { return "[symbols-flags] <file>" }
 func (r *symbols) ShortHelp() string { return "display selected file's symbols" }
 func (r *symbols) DetailedHelp(f *flag.FlagSet) {
 	fmt.Fprint(f.Output(), `
 Example:
 	$ gopls symbols helper/helper.go
+
+symbols-flags:
 `)
 	printFlagDefaults(f)
 }
@@ -35,14 +40,18 @@ func (r *symbols) Run(ctx context.Context, args ...string) error {
 	if len(args) != 1 {
 		return tool.CommandLineErrorf("symbols expects 1 argument (position)")
 	}
+	return r.app.runQuery(ctx, r, r.JSON, args...)
+}
 
-	conn, err := r.app.connect(ctx, nil)
-	if err != nil {
-		return err
+func (r *symbols) query(ctx context.Context, conn *connection, args ...string) (queryResult, error) {
+	if len(args) != 1 {
+		return nil, tool.CommandLineErrorf("symbols expects 1 argument (position)")
 	}
-	defer conn.terminate(ctx)
-
 	from := parseSpan(args[0])
+	file, err := conn.openFile(ctx, from.URI())
+	if err != nil {
+		return nil, err
+	}
 	p := protocol.DocumentSymbolParams{
 		TextDocument: protocol.TextDocumentIdentifier{
 			URI: from.URI(),
@@ -50,23 +59,34 @@ func (r *symbols) Run(ctx context.Context, args ...string) error {
 	}
 	symbols, err := conn.DocumentSymbol(ctx, &p)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	results := symbolList{}
 	for _, s := range symbols {
 		if m, ok := s.(map[string]interface{}); ok {
 			s, err = mapToSymbol(m)
 			if err != nil {
-				return err
+				return nil, err
 			}
 		}
+		var symbol Symbol
 		switch t := s.(type) {
 		case protocol.DocumentSymbol:
-			printDocumentSymbol(t)
+			symbol, err = newSymbol(file, t)
 		case protocol.SymbolInformation:
-			printSymbolInformation(t)
+			symbol.Name = t.Name
+			symbol.Kind = fmt.Sprint(t.Kind)
+			symbol.Span, err = file.rangeSpan(t.Location.Range)
+			symbol.Range = symbol.Span
+		default:
+			continue
 		}
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, symbol)
 	}
-	return nil
+	return results, nil
 }
 
 func mapToSymbol(m map[string]interface{}) (interface{}, error) {
@@ -90,26 +110,61 @@ func mapToSymbol(m map[string]interface{}) (interface{}, error) {
 	return s, nil
 }
 
-func printDocumentSymbol(s protocol.DocumentSymbol) {
-	fmt.Printf("%s %s %s\n", s.Name, s.Kind, positionToString(s.SelectionRange))
+// A Symbol is a result of a 'symbols' query.
+type Symbol struct {
+	Name     string   `json:"name"`
+	Detail   string   `json:"detail,omitempty"`
+	Kind     string   `json:"kind"`               // e.g. "Function"
+	Span     span     `json:"span"`               // span of the name of the symbol
+	Range    span     `json:"range"`              // span of the declaration of the symbol
+	Children []Symbol `json:"children,omitempty"` // sorted by name
+}
+
+// newSymbol converts a document symbol, and its children, to a Symbol.
+func newSymbol(file *cmdFile, s protocol.DocumentSymbol) (Symbol, error) {
+	symbol := Symbol{
+		Name:   s.Name,
+		Detail: s.Detail,
+		Kind:   fmt.Sprint(s.Kind),
+	}
+	var err error
+	if symbol.Span, err = file.rangeSpan(s.SelectionRange); err != nil {
+		return Symbol{}, err
+	}
+	if symbol.Range, err = file.rangeSpan(s.Range); err != nil {
+		return Symbol{}, err
+	}
 	// Sort children for consistency
 	sort.Slice(s.Children, func(i, j int) bool {
 		return s.Children[i].Name < s.Children[j].Name
 	})
 	for _, c := range s.Children {
-		fmt.Printf("\t%s %s %s\n", c.Name, c.Kind, positionToString(c.SelectionRange))
+		child, err := newSymbol(file, c)
+		if err != nil {
+			return Symbol{}, err
+		}
+		symbol.Children = append(symbol.Children, child)
 	}
+	return symbol, nil
 }
 
-func printSymbolInformation(s protocol.SymbolInformation) {
-	fmt.Printf("%s %s %s\n", s.Name, s.Kind, positionToString(s.Location.Range))
+type symbolList []Symbol
+
+func (l symbolList) printText(w io.Writer) {
+	for _, s := range l {
+		fmt.Fprintf(w, "%s %s %s\n", s.Name, s.Kind, spanRangeString(s.Span))
+		for _, c := range s.Children {
+			fmt.Fprintf(w, "\t%s %s %s\n", c.Name, c.Kind, spanRangeString(c.Span))
+		}
+	}
 }
 
-func positionToString(r protocol.Range) string {
+// spanRangeString returns the line:column-line:column form of a span.
+func spanRangeString(s span) string {
 	return fmt.Sprintf("%v:%v-%v:%v",
-		r.Start.Line+1,
-		r.Start.Character+1,
-		r.End.Line+1,
-		r.End.Character+1,
+		s.Start().Line(),
+		s.Start().Column(),
+		s.End().Line(),
+		s.End().Column(),
 	)
 }
@@ -0,0 +1,165 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	vulnchecklib "golang.org/x/tools/gopls/pkg/vulncheck"
+	"golang.org/x/tools/gopls/pkg/vulncheck/osv"
+	"golang.org/x/tools/gopls/pkg/vulncheck/scan"
+	"golang.org/x/tools/pkg/tool"
+)
+
+// vulndb implements the vulndb command.
+type vulndb struct {
+	app *Application
+	subcommands
+}
+
+func newVulndb(app *Application) *vulndb {
+	return &vulndb{
+		app: app,
+		subcommands: subcommands{
+			&importVulndb{app: app},
+			&vulndbStatus{app: app},
+		},
+	}
+}
+
+func (v *vulndb) Name() string   { return "vulndb" }
+func (v *vulndb) Parent() string { return v.app.Name() }
+func (v *vulndb) ShortHelp() string {
+	return "manage the local snapshot of the vulnerability database"
+}
+
+// snapshotDir returns dir, or if it is empty, the default directory of
+// the vulnerability database snapshot.
+func snapshotDir(dir string) (string, error) {
+	if dir != "" {
+		return dir, nil
+	}
+	return scan.DefaultDBDir()
+}
+
+// importVulndb implements the 'vulndb import' subcommand.
+type importVulndb struct {
+	app *Application
+
+	Dir string `flag:"dir" help:"directory of the snapshot, instead of the one vulncheck uses by default"`
+}
+
+func (c *importVulndb) Name() string   { return "import" }
+func (c *importVulndb) Parent() string { return c.app.Name() }
+func (c *importVulndb) Usage() string  { return "[import-flags] <file>" }
+func (c *importVulndb) ShortHelp() string {
+	return "import or update the snapshot from a file"
+}
+func (c *importVulndb) DetailedHelp(f *flag.FlagSet) {
+	fmt.Fprint(f.Output(), `
+The import command imports a vulnerability database, from a zip file or a
+directory, into the local snapshot that vulncheck uses when GOVULNDB is not
+set, so that it works without a network.
+
+The database is either in the layout of the Go vulnerability database,
+such as https://vuln.go.dev/vulndb.zip, or a tree of OSV entries, one per
+.json file. It replaces the snapshot if it is more recent.
+
+Example:
+
+	$ gopls vulndb import vulndb.zip
+
+With -dir, the snapshot is imported into another directory, which
+vulncheck uses when GOVULNDB is its file URL:
+
+	$ gopls vulndb import -dir=/srv/vulndb vulndb.zip
+	$ export GOVULNDB=file:///srv/vulndb
+
+import-flags:
+`)
+	printFlagDefaults(f)
+}
+
+func (c *importVulndb) Run(ctx context.Context, args ...string) error {
+	if len(args) != 1 {
+		return tool.CommandLineErrorf("import expects 1 argument, the file of the database")
+	}
+	dir, err := snapshotDir(c.Dir)
+	if err != nil {
+		return err
+	}
+	prev, _ := osv.OpenSnapshot(dir) // nil if there is none
+	s, err := osv.ImportSnapshot(dir, args[0])
+	if err != nil {
+		return err
+	}
+	if prev != nil && prev.Modified.Equal(s.Modified) {
+		fmt.Printf("The snapshot in %s is up to date, at version %s.\n", dir, s.Version())
+	} else {
+		fmt.Printf("Imported version %s of the vulnerability database into %s.\n", s.Version(), dir)
+	}
+	if warning := vulnchecklib.StaleDBWarning(s.URI(), s.Modified, time.Now()); warning != "" {
+		fmt.Fprintln(os.Stderr, warning)
+	}
+	return nil
+}
+
+// vulndbStatus implements the 'vulndb status' subcommand.
+type vulndbStatus struct {
+	app *Application
+
+	Dir string `flag:"dir" help:"directory of the snapshot, instead of the one vulncheck uses by default"`
+}
+
+func (c *vulndbStatus) Name() string   { return "status" }
+func (c *vulndbStatus) Parent() string { return c.app.Name() }
+func (c *vulndbStatus) Usage() string  { return "[status-flags]" }
+func (c *vulndbStatus) ShortHelp() string {
+	return "print the version of the snapshot"
+}
+func (c *vulndbStatus) DetailedHelp(f *flag.FlagSet) {
+	fmt.Fprint(f.Output(), `
+The status command prints the directory and the version of the local
+snapshot of the vulnerability database, and warns if it is stale.
+
+Example:
+
+	$ gopls vulndb status
+
+status-flags:
+`)
+	printFlagDefaults(f)
+}
+
+func (c *vulndbStatus) Run(ctx context.Context, args ...string) error {
+	if len(args) != 0 {
+		return tool.CommandLineErrorf("status expects no arguments")
+	}
+	dir, err := snapshotDir(c.Dir)
+	if err != nil {
+		return err
+	}
+	if db := os.Getenv("GOVULNDB"); db != "" && c.Dir == "" {
+		fmt.Printf("GOVULNDB is set: vulncheck uses %s.\n", db)
+	}
+	s, err := osv.OpenSnapshot(dir)
+	if err != nil {
+		if _, statErr := os.Stat(dir); os.IsNotExist(statErr) {
+			fmt.Printf("There is no snapshot in %s.\n", dir)
+			return nil
+		}
+		return err
+	}
+	days := int(time.Since(s.Modified) / (24 * time.Hour))
+	fmt.Printf("snapshot: %s\nversion:  %s (%d days old)\n", s.Dir, s.Version(), days)
+	if warning := vulnchecklib.StaleDBWarning(s.URI(), s.Modified, time.Now()); warning != "" {
+		fmt.Fprintln(os.Stderr, warning)
+	}
+	return nil
+}
@@ -8,6 +8,7 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"io"
 	"strings"
 
 	"golang.org/x/tools/gopls/pkg/lsp/protocol"
@@ -18,6 +19,7 @@ import (
 // workspaceSymbol implements the workspace_symbol verb for gopls.
 type workspaceSymbol struct {
 	Matcher string `flag:"matcher" help:"specifies the type of matcher: fuzzy, fastfuzzy, casesensitive, or caseinsensitive.\nThe default is caseinsensitive."`
+	JSON    bool   `flag:"json" help:"emit the symbols in JSON format"`
 
 	app *Application
 }
@@ -59,31 +61,53 @@ func (r *workspaceSymbol) Run(ctx context.Context, args ...string) error {
 		}
 	}
 
-	conn, err := r.app.connect(ctx, nil)
-	if err != nil {
-		return err
-	}
-	defer conn.terminate(ctx)
+	return r.app.runQuery(ctx, r, r.JSON, args...)
+}
 
+func (r *workspaceSymbol) query(ctx context.Context, conn *connection, args ...string) (queryResult, error) {
+	if len(args) != 1 {
+		return nil, tool.CommandLineErrorf("workspace_symbol expects 1 argument")
+	}
 	p := protocol.WorkspaceSymbolParams{
 		Query: args[0],
 	}
 
 	symbols, err := conn.Symbol(ctx, &p)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	results := workspaceSymbolList{}
 	for _, s := range symbols {
 		f, err := conn.openFile(ctx, s.Location.URI)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		span, err := f.locationSpan(s.Location)
 		if err != nil {
-			return err
+			return nil, err
 		}
-		fmt.Printf("%s %s %s\n", span, s.Name, s.Kind)
+		results = append(results, WorkspaceSymbol{
+			Name:          s.Name,
+			Kind:          fmt.Sprint(s.Kind),
+			ContainerName: s.ContainerName,
+			Span:          span,
+		})
 	}
+	return results, nil
+}
+
+// A WorkspaceSymbol is a result of a 'workspace_symbol' query.
+type WorkspaceSymbol struct {
+	Name          string `json:"name"`
+	Kind          string `json:"kind"` // e.g. "Function"
+	ContainerName string `json:"containerName,omitempty"`
+	Span          span   `json:"span"` // span of the name of the symbol
+}
 
-	return nil
+type workspaceSymbolList []WorkspaceSymbol
+
+func (l workspaceSymbolList) printText(w io.Writer) {
+	for _, s := range l {
+		fmt.Fprintf(w, "%s %s %s\n", s.Span, s.Name, s.Kind)
+	}
 }
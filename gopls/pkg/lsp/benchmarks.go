@@ -0,0 +1,149 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package lsp
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/tools/benchmark/parse"
+	"golang.org/x/tools/gopls/pkg/lsp/command"
+	"golang.org/x/tools/gopls/pkg/lsp/protocol"
+)
+
+// benchmarkResults keeps the results of the benchmarks run by the RunTests
+// command, so that the code lens of each benchmark shows its last result,
+// and how it changed since the previous run, as does benchcmp.
+type benchmarkResults struct {
+	mu      sync.Mutex
+	results map[benchmarkKey][2]*parse.Benchmark // previous and last result
+}
+
+// A benchmarkKey identifies a benchmark by the directory of its package and
+// its name.
+type benchmarkKey struct {
+	dir  string
+	name string
+}
+
+func newBenchmarkKey(uri protocol.DocumentURI, name string) benchmarkKey {
+	return benchmarkKey{dir: filepath.Dir(uri.Path()), name: name}
+}
+
+// record parses the output of go test -bench for the named benchmark of the
+// package of the file, and records its result.
+func (r *benchmarkResults) record(uri protocol.DocumentURI, name string, output io.Reader) {
+	set, err := parse.ParseSet(output)
+	if err != nil {
+		return
+	}
+	var result *parse.Benchmark
+	for benchName, benchmarks := range set {
+		if trimProcs(benchName) == name && len(benchmarks) > 0 {
+			result = benchmarks[len(benchmarks)-1]
+		}
+	}
+	if result == nil {
+		return // e.g. only sub-benchmarks
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.results == nil {
+		r.results = make(map[benchmarkKey][2]*parse.Benchmark)
+	}
+	key := newBenchmarkKey(uri, name)
+	r.results[key] = [2]*parse.Benchmark{r.results[key][1], result}
+}
+
+// summary describes the last result of the named benchmark of the package
+// of the file, and its change since the previous run, or returns "" if it
+// has not been run.
+func (r *benchmarkResults) summary(uri protocol.DocumentURI, name string) string {
+	r.mu.Lock()
+	results := r.results[newBenchmarkKey(uri, name)]
+	r.mu.Unlock()
+
+	before, after := results[0], results[1]
+	if after == nil {
+		return ""
+	}
+	parts := []string{formatNs(after.NsPerOp) + " ns/op"}
+	if before != nil {
+		parts = append(parts, deltaPercent(before.NsPerOp, after.NsPerOp))
+		if before.Measured&after.Measured&parse.AllocsPerOp != 0 {
+			parts = append(parts, deltaPercent(float64(before.AllocsPerOp), float64(after.AllocsPerOp))+" allocs/op")
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// annotateLenses adds the summary of the last results of the
+// benchmarks to the titles of their code lenses.
+func (r *benchmarkResults) annotateLenses(lenses []protocol.CodeLens) {
+	for i, lens := range lenses {
+		if lens.Command == nil || lens.Command.Command != command.Test.ID() || len(lens.Command.Arguments) != 3 {
+			continue
+		}
+		var (
+			uri        protocol.DocumentURI
+			benchmarks []string
+		)
+		if json.Unmarshal(lens.Command.Arguments[0], &uri) != nil ||
+			json.Unmarshal(lens.Command.Arguments[2], &benchmarks) != nil ||
+			len(benchmarks) != 1 {
+			continue
+		}
+		if summary := r.summary(uri, benchmarks[0]); summary != "" {
+			cmd := *lens.Command
+			cmd.Title = fmt.Sprintf("%s (%s)", cmd.Title, summary)
+			lenses[i].Command = &cmd
+		}
+	}
+}
+
+// trimProcs trims the GOMAXPROCS suffix from the name of a benchmark, as
+// in BenchmarkFoo-8.
+func trimProcs(name string) string {
+	if i := strings.LastIndexByte(name, '-'); i >= 0 {
+		if _, err := strconv.Atoi(name[i+1:]); err == nil {
+			return name[:i]
+		}
+	}
+	return name
+}
+
+// formatNs formats nanoseconds as does benchcmp.
+func formatNs(ns float64) string {
+	prec := 0
+	switch {
+	case ns < 10:
+		prec = 2
+	case ns < 100:
+		prec = 1
+	}
+	return strconv.FormatFloat(ns, 'f', prec, 64)
+}
+
+// deltaPercent formats the change from before to after as a percent change,
+// ranging from -100% up, as does benchcmp.
+func deltaPercent(before, after float64) string {
+	var ratio float64
+	switch {
+	case before != 0:
+		ratio = after / before
+	case after == 0:
+		ratio = 1
+	default:
+		ratio = math.Inf(1)
+	}
+	return fmt.Sprintf("%+.2f%%", 100*ratio-100)
+}
@@ -0,0 +1,60 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package lsp
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/gopls/pkg/lsp/command"
+	"golang.org/x/tools/gopls/pkg/lsp/protocol"
+)
+
+func TestBenchmarkResults(t *testing.T) {
+	const (
+		uri  = protocol.DocumentURI("file:///src/p/p_test.go")
+		run1 = `goos: linux
+BenchmarkFoo-8   	 1000000	      1200 ns/op	      64 B/op	       2 allocs/op
+BenchmarkFoo/sub-8   	 1000000	      100 ns/op
+PASS
+`
+		run2 = `BenchmarkFoo-8   	 1000000	      900 ns/op	      32 B/op	       1 allocs/op
+PASS
+`
+	)
+	var r benchmarkResults
+	if got := r.summary(uri, "BenchmarkFoo"); got != "" {
+		t.Errorf("summary before any run = %q, want none", got)
+	}
+
+	r.record(uri, "BenchmarkFoo", strings.NewReader(run1))
+	if got, want := r.summary(uri, "BenchmarkFoo"), "1200 ns/op"; got != want {
+		t.Errorf("summary after one run = %q, want %q", got, want)
+	}
+
+	r.record(uri, "BenchmarkFoo", strings.NewReader(run2))
+	want := "900 ns/op, -25.00%, -50.00% allocs/op"
+	if got := r.summary(uri, "BenchmarkFoo"); got != want {
+		t.Errorf("summary after two runs = %q, want %q", got, want)
+	}
+
+	// Benchmarks of other packages are distinct.
+	if got := r.summary("file:///src/q/q_test.go", "BenchmarkFoo"); got != "" {
+		t.Errorf("summary of another package = %q, want none", got)
+	}
+
+	cmd, err := command.NewTestCommand("run benchmark", uri, nil, []string{"BenchmarkFoo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lenses := []protocol.CodeLens{{Command: &cmd}}
+	r.annotateLenses(lenses)
+	if got, want := lenses[0].Command.Title, "run benchmark (900 ns/op, -25.00%, -50.00% allocs/op)"; got != want {
+		t.Errorf("lens title = %q, want %q", got, want)
+	}
+	if cmd.Title != "run benchmark" {
+		t.Errorf("annotateLenses modified the original command: %q", cmd.Title)
+	}
+}
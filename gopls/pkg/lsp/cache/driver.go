@@ -0,0 +1,123 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/gopls/pkg/file"
+	"golang.org/x/tools/gopls/pkg/lsp/protocol"
+	"golang.org/x/tools/pkg/packagesinternal"
+)
+
+// driverInfo records the protocol extensions declared by an external
+// GOPACKAGESDRIVER (see GoPackagesDriverView) in its responses.
+//
+// A driverInfo is immutable once it has been set on a snapshot.
+type driverInfo struct {
+	// incremental reports whether the driver answers "file=" queries with
+	// only the packages containing those files, so that invalidated packages
+	// may be reloaded individually, as is done for go list.
+	incremental bool
+
+	// owners maps files, including build inputs that are not Go files (such
+	// as Bazel BUILD files), to the packages whose metadata depends on them.
+	owners map[protocol.DocumentURI][]PackageID
+}
+
+// update returns the result of merging the extensions of a new driver
+// response into d, which may be nil.
+//
+// Ownership reported by the new response replaces any previous ownership of
+// the same files. Stale entries for other files are retained, which at worst
+// causes unnecessary invalidation.
+func (d *driverInfo) update(ext *packagesinternal.DriverExtensions) *driverInfo {
+	result := &driverInfo{
+		incremental: ext.Incremental,
+		owners:      make(map[protocol.DocumentURI][]PackageID),
+	}
+	if d != nil {
+		for uri, ids := range d.owners {
+			result.owners[uri] = ids
+		}
+	}
+	for filename, ids := range ext.FileOwners {
+		var pkgIDs []PackageID
+		for _, id := range ids {
+			pkgIDs = append(pkgIDs, PackageID(id))
+		}
+		result.owners[protocol.URIFromPath(filename)] = pkgIDs
+	}
+	return result
+}
+
+// reloadQueries returns the queries to use when reloading the invalidated
+// metadata m, following the changes to the given files. For an incremental
+// driver, this is a "file=" query for one of the package's files that still
+// exists, preferably a changed one; otherwise, or if all of its files were
+// deleted, it is the package path, as for go list.
+func (d *driverInfo) reloadQueries(m *Metadata, changed map[protocol.DocumentURI]file.Handle) []PackagePath {
+	if d != nil && d.incremental {
+		files := m.CompiledGoFiles
+		if len(files) == 0 {
+			files = m.GoFiles
+		}
+		var query protocol.DocumentURI
+		for _, uri := range files {
+			fh, ok := changed[uri]
+			if !ok {
+				if query == "" {
+					query = uri // unchanged, so presumably still present
+				}
+				continue
+			}
+			if fileExists(fh) {
+				query = uri
+				break
+			}
+		}
+		if query != "" {
+			return []PackagePath{PackagePath(fmt.Sprintf("file=%s", query.Path()))}
+		}
+	}
+	needsReload := []PackagePath{m.PkgPath}
+	if m.ForTest != "" && m.ForTest != m.PkgPath {
+		// When reloading test variants, always reload their ForTest package as
+		// well. Otherwise, we may miss test variants in the resulting load.
+		//
+		// TODO(rfindley): is this actually sufficient? Is it possible that
+		// other test variants may be invalidated? Either way, we should
+		// determine exactly what needs to be reloaded here.
+		needsReload = append(needsReload, m.ForTest)
+	}
+	return needsReload
+}
+
+// watchPatterns returns glob patterns matching the owned files that are not
+// already covered by the given file extensions.
+func (d *driverInfo) watchPatterns(extensions []string) []string {
+	if d == nil {
+		return nil
+	}
+	covered := make(map[string]bool)
+	for _, ext := range extensions {
+		covered["."+ext] = true
+	}
+	seen := make(map[string]bool)
+	var patterns []string
+	for uri := range d.owners {
+		base := filepath.Base(uri.Path())
+		if covered[filepath.Ext(base)] || seen[base] || strings.ContainsAny(base, "*?[]{},") {
+			continue
+		}
+		seen[base] = true
+		patterns = append(patterns, "**/"+base)
+	}
+	sort.Strings(patterns)
+	return patterns
+}
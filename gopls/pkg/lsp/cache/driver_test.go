@@ -0,0 +1,48 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"golang.org/x/tools/gopls/pkg/file"
+	"golang.org/x/tools/gopls/pkg/lsp/protocol"
+)
+
+func TestReloadQueries(t *testing.T) {
+	var (
+		a = protocol.URIFromPath("/src/p/a.go")
+		b = protocol.URIFromPath("/src/p/b.go")
+	)
+	m := &Metadata{
+		ID:              "p",
+		PkgPath:         "example.com/p",
+		CompiledGoFiles: []protocol.DocumentURI{a, b},
+	}
+	exists := func(uri protocol.DocumentURI) file.Handle { return &DiskFile{uri: uri} }
+	deleted := func(uri protocol.DocumentURI) file.Handle { return &DiskFile{uri: uri, err: os.ErrNotExist} }
+	fileQuery := func(uri protocol.DocumentURI) []PackagePath { return []PackagePath{PackagePath("file=" + uri.Path())} }
+
+	incremental := &driverInfo{incremental: true}
+	tests := []struct {
+		name    string
+		driver  *driverInfo
+		changed map[protocol.DocumentURI]file.Handle
+		want    []PackagePath
+	}{
+		{"not incremental", nil, map[protocol.DocumentURI]file.Handle{a: exists(a)}, []PackagePath{"example.com/p"}},
+		{"no change", incremental, nil, fileQuery(a)},
+		{"changed file", incremental, map[protocol.DocumentURI]file.Handle{b: exists(b)}, fileQuery(b)},
+		{"deleted file", incremental, map[protocol.DocumentURI]file.Handle{a: deleted(a)}, fileQuery(b)},
+		{"all deleted", incremental, map[protocol.DocumentURI]file.Handle{a: deleted(a), b: deleted(b)}, []PackagePath{"example.com/p"}},
+	}
+	for _, test := range tests {
+		if got := test.driver.reloadQueries(m, test.changed); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: reloadQueries() = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"context"
+
+	"golang.org/x/tools/gopls/pkg/lsp/filecache"
+	"golang.org/x/tools/gopls/pkg/lsp/protocol"
+	"golang.org/x/tools/gopls/pkg/lsp/source"
+	"golang.org/x/tools/pkg/event"
+	"golang.org/x/tools/pkg/event/tag"
+)
+
+// heapEscapesKind is the filecache kind for the escape analysis decisions of
+// the compiler.
+const heapEscapesKind = "escapes"
+
+// HeapEscapes returns the escape analysis decisions of the compiler for the
+// file, as reported by source.HeapEscapes for the narrowest package
+// containing it.
+//
+// As the compiler reads files from disk, nothing is reported for packages
+// with unsaved files. Nor is anything reported in restricted views, as
+// building a package may run cgo and the C compiler. Results are cached
+// in the filecache, keyed by the package.
+func (s *Snapshot) HeapEscapes(ctx context.Context, uri protocol.DocumentURI) ([]*source.Diagnostic, error) {
+	ctx, done := event.Start(ctx, "cache.snapshot.HeapEscapes", tag.URI.Of(uri))
+	defer done()
+
+	if s.view.Restricted() {
+		return nil, nil
+	}
+
+	m, err := source.NarrowestMetadataForFile(ctx, s, uri)
+	if err != nil {
+		return nil, err
+	}
+	if !s.savedToDisk(m) {
+		return nil, nil
+	}
+	handles, err := s.getPackageHandles(ctx, []PackageID{m.ID})
+	if err != nil {
+		return nil, err
+	}
+	ph := handles[m.ID]
+	if ph == nil {
+		return nil, nil
+	}
+
+	var diags []*source.Diagnostic
+	if data, err := filecache.Get(heapEscapesKind, ph.key); err == nil { // hit
+		diags = decodeDiagnostics(data)
+	} else {
+		if err != filecache.ErrNotFound {
+			event.Error(ctx, "reading escape analysis from filecache", err)
+		}
+		reports, err := source.HeapEscapes(ctx, s, m)
+		if err != nil {
+			return nil, err
+		}
+		for _, fileDiags := range reports {
+			diags = append(diags, fileDiags...)
+		}
+		if err := filecache.Set(heapEscapesKind, ph.key, encodeDiagnostics(diags)); err != nil {
+			event.Error(ctx, "storing escape analysis in filecache", err)
+		}
+	}
+
+	var fileDiags []*source.Diagnostic
+	for _, diag := range diags {
+		if diag.URI == uri {
+			fileDiags = append(fileDiags, diag)
+		}
+	}
+	return fileDiags, nil
+}
@@ -131,6 +131,9 @@ func populateProcessEnvFromSnapshot(ctx context.Context, pe *imports.ProcessEnv,
 	pe.Env = map[string]string{}
 	pe.BuildFlags = append([]string{}, snapshot.Options().BuildFlags...)
 	env := append(append(os.Environ(), snapshot.Options().EnvSlice()...), "GO111MODULE="+snapshot.view.GO111MODULE())
+	if snapshot.view.Restricted() {
+		env = append(env, restrictedEnv(snapshot.view.goflags)...)
+	}
 	for _, kv := range env {
 		split := strings.SplitN(kv, "=", 2)
 		if len(split) != 2 {
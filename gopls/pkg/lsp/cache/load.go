@@ -131,6 +131,11 @@ func (s *Snapshot) load(ctx context.Context, allowNetwork bool, scopes ...loadSc
 	defer cancel()
 
 	cfg := s.config(ctx, inv)
+	var driverExt *packagesinternal.DriverExtensions
+	if s.view.ViewType() == GoPackagesDriverView {
+		driverExt = new(packagesinternal.DriverExtensions)
+		packagesinternal.SetDriverExtensions(cfg, driverExt)
+	}
 	pkgs, err := packages.Load(cfg, query...)
 	cleanup()
 
@@ -256,6 +261,9 @@ func (s *Snapshot) load(ctx context.Context, allowNetwork bool, scopes ...loadSc
 	s.meta = meta
 	s.workspacePackages = workspacePackages
 	s.resetActivePackagesLocked()
+	if driverExt != nil {
+		s.driver = s.driver.update(driverExt)
+	}
 
 	s.mu.Unlock()
 
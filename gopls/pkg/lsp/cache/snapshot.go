@@ -132,8 +132,15 @@ type Snapshot struct {
 	//
 	// When we try to load a package, we clear it from the shouldLoad map
 	// regardless of whether the load succeeded, to prevent endless loads.
+	//
+	// For an incremental GOPACKAGESDRIVER, the "paths" are "file=" queries;
+	// see driverInfo.reloadQueries.
 	shouldLoad *persistent.Map[PackageID, []PackagePath]
 
+	// driver holds the protocol extensions declared by an external
+	// GOPACKAGESDRIVER, or nil if there were none.
+	driver *driverInfo
+
 	// unloadableFiles keeps track of files that we've failed to load.
 	unloadableFiles *persistent.Set[protocol.DocumentURI]
 
@@ -177,6 +184,10 @@ type Snapshot struct {
 
 	// vulns maps each go.mod file's URI to its known vulnerabilities.
 	vulns *persistent.Map[protocol.DocumentURI, *vulncheck.Result]
+
+	// memberUsage memoizes the result of MemberUsage; it is created on
+	// first use, guarded by mu.
+	memberUsage *memoize.Promise // *memoize.Promise[memberUsageResult]
 }
 
 var globalSnapshotID uint64
@@ -500,6 +511,9 @@ func (s *Snapshot) goCommandInvocation(ctx context.Context, flags InvocationFlag
 	//
 	// We should refactor to make it clearer that the correct env is being used.
 	inv.Env = append(append(append(os.Environ(), s.Options().EnvSlice()...), inv.Env...), "GO111MODULE="+s.view.GO111MODULE())
+	if s.view.Restricted() {
+		inv.Env = append(inv.Env, restrictedEnv(s.view.goflags)...)
+	}
 	inv.BuildFlags = append([]string{}, s.Options().BuildFlags...)
 	cleanup = func() {} // fallback
 
@@ -904,6 +918,14 @@ func (s *Snapshot) fileWatchingGlobPatterns(ctx context.Context) map[string]stru
 		fmt.Sprintf("**/*.{%s}", extensions): {},
 	}
 
+	// Watch the non-Go build inputs reported by the GOPACKAGESDRIVER.
+	s.mu.Lock()
+	driver := s.driver
+	s.mu.Unlock()
+	for _, pattern := range driver.watchPatterns(strings.Split(extensions, ",")) {
+		patterns[pattern] = struct{}{}
+	}
+
 	// If GOWORK is outside the folder, ensure we are watching it.
 	gowork, _ := s.view.GOWORK()
 	if gowork != "" && !pathutil.InDir(s.view.folder.Dir.Path(), gowork.Path()) {
@@ -1846,7 +1868,8 @@ func (s *Snapshot) clone(ctx, bgCtx context.Context, changed StateChange) (*Snap
 		files:             s.files.Clone(changedFiles),
 		symbolizeHandles:  cloneWithout(s.symbolizeHandles, changedFiles),
 		workspacePackages: s.workspacePackages,
-		shouldLoad:        s.shouldLoad.Clone(),      // not cloneWithout: shouldLoad is cleared on loads
+		shouldLoad:        s.shouldLoad.Clone(), // not cloneWithout: shouldLoad is cleared on loads
+		driver:            s.driver,
 		unloadableFiles:   s.unloadableFiles.Clone(), // not cloneWithout: typing in a file doesn't necessarily make it loadable
 		parseModHandles:   cloneWithout(s.parseModHandles, changedFiles),
 		parseWorkHandles:  cloneWithout(s.parseWorkHandles, changedFiles),
@@ -1971,6 +1994,12 @@ func (s *Snapshot) clone(ctx, bgCtx context.Context, changed StateChange) (*Snap
 		var invalidateMetadata, pkgFileChanged, importDeleted bool
 		if strings.HasSuffix(uri.Path(), ".go") {
 			invalidateMetadata, pkgFileChanged, importDeleted = metadataChanges(ctx, s, oldFH, newFH)
+		} else if s.driver != nil {
+			// Any change to a build input reported by the driver (such as a
+			// BUILD file) invalidates the metadata of the packages that own it.
+			for _, id := range s.driver.owners[uri] {
+				directIDs[id] = true
+			}
 		}
 		if invalidateMetadata {
 			// If this is a metadata-affecting change, perhaps a reload will succeed.
@@ -2113,17 +2142,7 @@ func (s *Snapshot) clone(ctx, bgCtx context.Context, changed StateChange) (*Snap
 		// For metadata that has been newly invalidated, capture package paths
 		// requiring reloading in the shouldLoad map.
 		if invalidateMetadata && !IsCommandLineArguments(v.ID) {
-			needsReload := []PackagePath{v.PkgPath}
-			if v.ForTest != "" && v.ForTest != v.PkgPath {
-				// When reloading test variants, always reload their ForTest package as
-				// well. Otherwise, we may miss test variants in the resulting load.
-				//
-				// TODO(rfindley): is this actually sufficient? Is it possible that
-				// other test variants may be invalidated? Either way, we should
-				// determine exactly what needs to be reloaded here.
-				needsReload = append(needsReload, v.ForTest)
-			}
-			result.shouldLoad.Set(k, needsReload, nil)
+			result.shouldLoad.Set(k, s.driver.reloadQueries(v, changedFiles), nil)
 		}
 
 		// Check whether the metadata should be deleted.
@@ -0,0 +1,182 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/gopls/pkg/lsp/filecache"
+	"golang.org/x/tools/gopls/pkg/lsp/source/xrefs"
+	"golang.org/x/tools/pkg/event"
+	"golang.org/x/tools/pkg/memoize"
+)
+
+// Usage statistics kinds, identifying the statistics about the workspace
+// that are stored in the file cache to rank completion candidates.
+const (
+	memberUsageKind        = "memberusage"
+	acceptedCompletionKind = "completions"
+)
+
+// MemberUsage returns the number of references from the workspace packages
+// to each package-level member of the packages they import, keyed by
+// completion.UsageKey ("path.Name").
+//
+// Only the packages whose cross-reference index is already in the file
+// cache are counted, so as not to type-check the workspace. The totals are
+// themselves cached, keyed by the set of workspace packages, when every
+// package was counted, and memoized by the snapshot, as they are needed by
+// every completion request. The caller must not modify the result.
+func (s *Snapshot) MemberUsage(ctx context.Context) (map[string]int, error) {
+	s.mu.Lock()
+	promise := s.memberUsage
+	if promise == nil {
+		promise = memoize.NewPromise("memberUsage", func(ctx context.Context, arg interface{}) interface{} {
+			counts, err := arg.(*Snapshot).computeMemberUsage(ctx)
+			return memberUsageResult{counts, err}
+		})
+		s.memberUsage = promise
+	}
+	s.mu.Unlock()
+
+	v, err := s.awaitPromise(ctx, promise)
+	if err != nil {
+		return nil, err
+	}
+	res := v.(memberUsageResult)
+	return res.counts, res.err
+}
+
+type memberUsageResult struct {
+	counts map[string]int
+	err    error
+}
+
+// computeMemberUsage computes the result of MemberUsage.
+func (s *Snapshot) computeMemberUsage(ctx context.Context) (map[string]int, error) {
+	ctx, done := event.Start(ctx, "cache.snapshot.MemberUsage")
+	defer done()
+
+	metas, err := s.WorkspaceMetadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]PackageID, len(metas))
+	for i, m := range metas {
+		ids[i] = m.ID
+	}
+	handles, err := s.getPackageHandles(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(handles))
+	for _, ph := range handles {
+		keys = append(keys, string(ph.key[:]))
+	}
+	sort.Strings(keys)
+	key := sha256.Sum256([]byte(strings.Join(keys, "")))
+
+	if data, err := filecache.Get(memberUsageKind, key); err == nil { // hit
+		var counts map[string]int
+		if err := json.Unmarshal(data, &counts); err == nil {
+			return counts, nil
+		}
+	} else if err != filecache.ErrNotFound {
+		event.Error(ctx, "reading member usage from filecache", err)
+	}
+
+	counts := make(map[string]int)
+	complete := true
+	for _, ph := range handles {
+		data, err := filecache.Get(xrefsKind, ph.key)
+		if err != nil {
+			if err != filecache.ErrNotFound {
+				event.Error(ctx, "reading xrefs from filecache", err)
+			}
+			complete = false
+			continue
+		}
+		for pkgPath, objects := range xrefs.Count(data) {
+			for path, n := range objects {
+				// Only package-level members have a path without selectors.
+				if !strings.Contains(string(path), ".") {
+					counts[string(pkgPath)+"."+string(path)] += n
+				}
+			}
+		}
+	}
+	if complete {
+		data, err := json.Marshal(counts)
+		if err != nil {
+			return nil, err
+		}
+		if err := filecache.Set(memberUsageKind, key, data); err != nil {
+			event.Error(ctx, "storing member usage in filecache", err)
+		}
+	}
+	return counts, nil
+}
+
+// AcceptedCompletions returns the number of times each completion candidate
+// has been accepted in the view's folder, keyed by completion.UsageKey.
+func (v *View) AcceptedCompletions(ctx context.Context) map[string]int {
+	v.acceptedMu.Lock()
+	defer v.acceptedMu.Unlock()
+
+	v.loadAcceptedCompletionsLocked(ctx)
+	counts := make(map[string]int, len(v.accepted))
+	for key, n := range v.accepted {
+		counts[key] = n
+	}
+	return counts
+}
+
+// RecordAcceptedCompletion records that the completion candidate with the
+// given key was accepted, and saves the counts of the view's folder in the
+// file cache, so that they outlive the session.
+func (v *View) RecordAcceptedCompletion(ctx context.Context, key string) {
+	v.acceptedMu.Lock()
+	defer v.acceptedMu.Unlock()
+
+	v.loadAcceptedCompletionsLocked(ctx)
+	v.accepted[key]++
+	data, err := json.Marshal(v.accepted)
+	if err != nil {
+		event.Error(ctx, "encoding accepted completions", err)
+		return
+	}
+	if err := filecache.Set(acceptedCompletionKind, v.acceptedKey(), data); err != nil {
+		event.Error(ctx, "storing accepted completions in filecache", err)
+	}
+}
+
+// loadAcceptedCompletionsLocked reads the accepted completions of the view's
+// folder from the file cache, if they have not been read yet.
+// v.acceptedMu must be held.
+func (v *View) loadAcceptedCompletionsLocked(ctx context.Context) {
+	if v.accepted != nil {
+		return
+	}
+	v.accepted = make(map[string]int)
+	data, err := filecache.Get(acceptedCompletionKind, v.acceptedKey())
+	if err != nil {
+		if err != filecache.ErrNotFound {
+			event.Error(ctx, "reading accepted completions from filecache", err)
+		}
+		return
+	}
+	if err := json.Unmarshal(data, &v.accepted); err != nil {
+		event.Error(ctx, "decoding accepted completions", err)
+	}
+}
+
+func (v *View) acceptedKey() [32]byte {
+	return sha256.Sum256([]byte(v.Folder()))
+}
@@ -0,0 +1,383 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/tools/go/analysis/pkg/analysisflags"
+	"golang.org/x/tools/gopls/pkg/file"
+	"golang.org/x/tools/gopls/pkg/lsp/filecache"
+	"golang.org/x/tools/gopls/pkg/lsp/protocol"
+	"golang.org/x/tools/gopls/pkg/lsp/source"
+	"golang.org/x/tools/pkg/event"
+	"golang.org/x/tools/pkg/event/tag"
+	"golang.org/x/tools/pkg/gocommand"
+)
+
+// vetToolKind is the filecache kind for the results of the external vet tool.
+const vetToolKind = "vettool"
+
+// VetToolDiagnostics runs the external analysis tool named by the VetTool
+// option on the specified packages, and returns its diagnostics by file.
+//
+// The tool is run by "go vet -vettool", so it must speak the protocol of
+// go/analysis/unitchecker. As the go command reads files from disk,
+// packages with unsaved files are skipped.
+//
+// Results are cached in the filecache, keyed by the package and the hash of
+// the tool executable, so that rebuilding the tool invalidates them.
+func (s *Snapshot) VetToolDiagnostics(ctx context.Context, ids ...PackageID) (map[protocol.DocumentURI][]*source.Diagnostic, error) {
+	tool := s.Options().VetTool
+	if tool == "" || len(ids) == 0 {
+		return nil, nil
+	}
+
+	ctx, done := event.Start(ctx, "cache.snapshot.VetToolDiagnostics")
+	defer done()
+
+	toolPath, toolHash, err := hashVetTool(tool)
+	if err != nil {
+		return nil, err
+	}
+	handles, err := s.getPackageHandles(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	perFile := make(map[protocol.DocumentURI][]*source.Diagnostic)
+	collect := func(diags []*source.Diagnostic) {
+		for _, diag := range diags {
+			perFile[diag.URI] = append(perFile[diag.URI], diag)
+		}
+	}
+
+	keys := make(map[PackageID]file.Hash)
+	var missing []*Metadata
+	for _, id := range ids {
+		ph := handles[id]
+		if ph == nil || !s.savedToDisk(ph.m) {
+			continue
+		}
+		key := vetToolKey(toolHash, ph.key)
+		data, err := filecache.Get(vetToolKind, key)
+		if err == nil { // hit
+			collect(decodeDiagnostics(data))
+			continue
+		} else if err != filecache.ErrNotFound {
+			event.Error(ctx, "reading vet tool diagnostics from filecache", err)
+		}
+		keys[id] = key
+		missing = append(missing, ph.m)
+	}
+	if len(missing) == 0 {
+		return perFile, nil
+	}
+
+	results, err := s.runVetTool(ctx, toolPath, missing)
+	if err != nil {
+		return perFile, err
+	}
+	for _, m := range missing {
+		// Packages absent from the results, for example because the tool
+		// failed on them, are not cached, so that they are retried.
+		diags, ok := results[m.ID]
+		if !ok {
+			continue
+		}
+		if err := filecache.Set(vetToolKind, keys[m.ID], encodeDiagnostics(diags)); err != nil {
+			event.Error(ctx, "storing vet tool diagnostics in filecache", err)
+		}
+		collect(diags)
+	}
+	return perFile, nil
+}
+
+// savedToDisk reports whether none of the files of m have unsaved changes.
+func (s *Snapshot) savedToDisk(m *Metadata) bool {
+	for _, uri := range m.CompiledGoFiles {
+		if fh := s.FindFile(uri); fh != nil && !fh.SameContentsOnDisk() {
+			return false
+		}
+	}
+	return true
+}
+
+// vetToolKey returns the filecache key for the results of the vet tool with
+// the given hash on the package with the given key.
+func vetToolKey(toolHash, pkgKey file.Hash) file.Hash {
+	hasher := sha256.New()
+	fmt.Fprintf(hasher, "vettool: %x\n", toolHash)
+	fmt.Fprintf(hasher, "package: %x\n", pkgKey)
+	var hash file.Hash
+	hasher.Sum(hash[:0])
+	return hash
+}
+
+// vetToolHashes memoizes the hashes of vet tool executables, which may be
+// large, by path, size and modification time.
+var vetToolHashes struct {
+	mu     sync.Mutex
+	hashes map[vetToolStamp]file.Hash
+}
+
+type vetToolStamp struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// hashVetTool resolves the named vet tool and returns its path and the hash
+// of its contents.
+func hashVetTool(name string) (string, file.Hash, error) {
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return "", file.Hash{}, fmt.Errorf("finding vet tool: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", file.Hash{}, err
+	}
+	stamp := vetToolStamp{path, info.Size(), info.ModTime()}
+
+	vetToolHashes.mu.Lock()
+	defer vetToolHashes.mu.Unlock()
+
+	if hash, ok := vetToolHashes.hashes[stamp]; ok {
+		return path, hash, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return "", file.Hash{}, err
+	}
+	defer f.Close()
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, f); err != nil {
+		return "", file.Hash{}, err
+	}
+	var hash file.Hash
+	hasher.Sum(hash[:0])
+	if vetToolHashes.hashes == nil {
+		vetToolHashes.hashes = make(map[vetToolStamp]file.Hash)
+	}
+	vetToolHashes.hashes[stamp] = hash
+	return path, hash, nil
+}
+
+// runVetTool runs "go vet -vettool" on the given packages, returning
+// diagnostics for each package that the tool analyzed successfully, possibly
+// none. Packages on which the tool or one of its analyzers failed are absent
+// from the result.
+func (s *Snapshot) runVetTool(ctx context.Context, toolPath string, pkgs []*Metadata) (map[PackageID][]*source.Diagnostic, error) {
+	args := []string{"-vettool=" + toolPath, "-json"}
+	seen := make(map[PackagePath]bool)
+	for _, m := range pkgs {
+		// go vet analyzes test variants along with the package under test.
+		path := m.PkgPath
+		if m.ForTest != "" {
+			path = m.ForTest
+		}
+		if !seen[path] {
+			seen[path] = true
+			args = append(args, string(path))
+		}
+	}
+
+	_, inv, cleanup, err := s.goCommandInvocation(ctx, Normal, &gocommand.Invocation{
+		Verb:       "vet",
+		Args:       args,
+		WorkingDir: s.view.goCommandDir.Path(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	_, stderr, friendlyErr, err := s.view.gocmdRunner.RunRaw(ctx, *inv)
+	if err != nil {
+		return nil, err
+	}
+	tree, parseErr := parseVetOutput(stderr.Bytes())
+	if friendlyErr != nil && len(tree) == 0 {
+		// The tool failed before producing any results, for example because
+		// a package does not build.
+		return nil, friendlyErr
+	}
+	if parseErr != nil {
+		return nil, parseErr
+	}
+
+	results := make(map[PackageID][]*source.Diagnostic)
+	mappers := make(map[string]*protocol.Mapper)
+	mapper := func(filename string) (*protocol.Mapper, error) {
+		if m, ok := mappers[filename]; ok {
+			return m, nil
+		}
+		uri := protocol.URIFromPath(filename)
+		fh, err := s.ReadFile(ctx, uri)
+		if err != nil {
+			return nil, err
+		}
+		content, err := fh.Content()
+		if err != nil {
+			return nil, err
+		}
+		m := protocol.NewMapper(uri, content)
+		mappers[filename] = m
+		return m, nil
+	}
+	for id, analyzers := range tree {
+		var diags []*source.Diagnostic
+		failed := false
+		for name, result := range analyzers {
+			var vetDiags []analysisflags.JSONDiagnostic
+			if err := json.Unmarshal(result, &vetDiags); err != nil {
+				// The result is a failure of this analyzer.
+				event.Log(ctx, fmt.Sprintf("vet tool analyzer %s failed: %s", name, result), tag.Package.Of(id))
+				failed = true
+				continue
+			}
+			for i := range vetDiags {
+				diag, err := vetToSourceDiagnostic(&vetDiags[i], name, mapper)
+				if err != nil {
+					event.Error(ctx, "converting vet tool diagnostic", err, tag.Package.Of(id))
+					failed = true
+					continue
+				}
+				diags = append(diags, diag)
+			}
+		}
+		if !failed {
+			results[PackageID(id)] = diags
+		}
+	}
+	return results, nil
+}
+
+// A vetTree is the JSON output of a unitchecker tool for one or more
+// packages, an analysisflags.JSONTree whose results are not yet decoded, as
+// each is either a list of analysisflags.JSONDiagnostic or an error.
+type vetTree map[string]map[string]json.RawMessage
+
+// parseVetOutput parses the output of "go vet -json", which consists of a
+// "# id" comment line followed by a JSON vetTree for each package analyzed.
+// Packages that the tool analyzed without findings have an empty entry in
+// the result.
+func parseVetOutput(data []byte) (vetTree, error) {
+	tree := make(vetTree)
+	add := func(header string, section []byte) error {
+		dec := json.NewDecoder(bytes.NewReader(section))
+		decoded := false
+		for {
+			var t vetTree
+			if err := dec.Decode(&t); err == io.EOF {
+				break
+			} else if err != nil {
+				return fmt.Errorf("parsing vet tool output: %v", err)
+			}
+			decoded = true
+			for id, analyzers := range t {
+				if tree[id] == nil {
+					tree[id] = make(map[string]json.RawMessage)
+				}
+				for name, result := range analyzers {
+					tree[id][name] = result
+				}
+			}
+		}
+		if decoded && header != "" && tree[header] == nil {
+			tree[header] = make(map[string]json.RawMessage)
+		}
+		return nil
+	}
+
+	var (
+		header  string
+		section bytes.Buffer
+	)
+	for _, line := range bytes.SplitAfter(data, []byte("\n")) {
+		if bytes.HasPrefix(line, []byte("#")) {
+			if err := add(header, section.Bytes()); err != nil {
+				return tree, err
+			}
+			header = string(bytes.TrimSpace(line[1:]))
+			section.Reset()
+			continue
+		}
+		section.Write(line)
+	}
+	if err := add(header, section.Bytes()); err != nil {
+		return tree, err
+	}
+	return tree, nil
+}
+
+// posnRx matches a position of the form file:line:col or file:line.
+var posnRx = regexp.MustCompile(`^(.*?):(\d+)(?::(\d+))?$`)
+
+// vetToSourceDiagnostic converts a diagnostic reported by the named analyzer of
+// the vet tool to "source" form, using the given func to map files.
+func vetToSourceDiagnostic(vd *analysisflags.JSONDiagnostic, analyzer string, mapper func(string) (*protocol.Mapper, error)) (*source.Diagnostic, error) {
+	match := posnRx.FindStringSubmatch(vd.Posn)
+	if match == nil {
+		return nil, fmt.Errorf("invalid position %q", vd.Posn)
+	}
+	line, _ := strconv.Atoi(match[2])
+	col := 1
+	if match[3] != "" {
+		col, _ = strconv.Atoi(match[3])
+	}
+	m, err := mapper(match[1])
+	if err != nil {
+		return nil, err
+	}
+	pos, err := m.LineCol8Position(line, col)
+	if err != nil {
+		return nil, err
+	}
+
+	diag := &source.Diagnostic{
+		URI:      m.URI,
+		Range:    protocol.Range{Start: pos, End: pos},
+		Severity: protocol.SeverityWarning,
+		Code:     vd.Category,
+		Source:   source.AnalyzerErrorKind(analyzer),
+		Message:  vd.Message,
+	}
+	for _, vf := range vd.SuggestedFixes {
+		fix := source.SuggestedFix{
+			Title:      vf.Message,
+			Edits:      make(map[protocol.DocumentURI][]protocol.TextEdit),
+			ActionKind: protocol.QuickFix,
+		}
+		for _, ve := range vf.Edits {
+			m, err := mapper(ve.Filename)
+			if err != nil {
+				return nil, err
+			}
+			rng, err := m.OffsetRange(ve.Start, ve.End)
+			if err != nil {
+				return nil, err
+			}
+			fix.Edits[m.URI] = append(fix.Edits[m.URI], protocol.TextEdit{
+				Range:   rng,
+				NewText: ve.New,
+			})
+		}
+		diag.SuggestedFixes = append(diag.SuggestedFixes, fix)
+	}
+	return diag, nil
+}
@@ -0,0 +1,50 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseVetOutput(t *testing.T) {
+	const output = `# example.com/a
+{
+	"example.com/a": {
+		"printf": [{"posn": "a.go:3:2", "message": "bad format"}]
+	}
+}
+# example.com/b
+{}
+# example.com/b [example.com/b.test]
+{
+	"example.com/b [example.com/b.test]": {
+		"printf": {"error": "failed"}
+	}
+}
+# example.com/c
+c.go:1:1: expected 'package', found 'EOF'
+`
+	tree, err := parseVetOutput([]byte(output))
+	if err == nil {
+		t.Errorf("parseVetOutput succeeded on output with a build error")
+	}
+	var got []string
+	for id := range tree {
+		got = append(got, id)
+	}
+	sort.Strings(got)
+	// Packages analyzed without findings are present, and those not
+	// analyzed are absent.
+	want := []string{"example.com/a", "example.com/b", "example.com/b [example.com/b.test]"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("parseVetOutput: unexpected packages (-want +got):\n%s", diff)
+	}
+	if len(tree["example.com/b"]) != 0 || len(tree["example.com/a"]) != 1 {
+		t.Errorf("parseVetOutput: got %v", tree)
+	}
+}
@@ -46,6 +46,17 @@ type Folder struct {
 	Dir     protocol.DocumentURI
 	Name    string
 	Options *settings.Options
+
+	// Trusted records that the user has marked the folder as trusted using the
+	// TrustWorkspace command. It is only meaningful if
+	// Options.RequireWorkspaceTrust is set.
+	Trusted bool
+}
+
+// Restricted reports whether go commands for the folder must run in
+// restricted mode, because the folder requires trust and has not been trusted.
+func (f *Folder) Restricted() bool {
+	return f.Options.RequireWorkspaceTrust && !f.Trusted
 }
 
 // View represents a single build context for a workspace.
@@ -115,6 +126,11 @@ type View struct {
 	// initialization of snapshots. Do not change it without adjusting snapshot
 	// accordingly.
 	initializationSema chan struct{}
+
+	// accepted counts the completion candidates accepted in the view's
+	// folder, lazily loaded from the file cache (see AcceptedCompletions).
+	acceptedMu sync.Mutex
+	accepted   map[string]int
 }
 
 // viewDefinition holds the defining features of the View workspace.
@@ -136,8 +152,8 @@ type viewDefinition struct {
 	goversionOutput string
 
 	// hasGopackagesDriver is true if the user has a value set for the
-	// GOPACKAGESDRIVER environment variable or a gopackagesdriver binary on
-	// their machine.
+	// GOPACKAGESDRIVER environment variable (in the process or the folder's
+	// env setting) or a gopackagesdriver binary on their machine.
 	hasGopackagesDriver bool
 
 	// inGOPATH reports whether the workspace directory is contained in a GOPATH
@@ -443,6 +459,17 @@ func (v *View) Folder() protocol.DocumentURI {
 	return v.folder.Dir
 }
 
+// GoModCache returns the module cache directory (GOMODCACHE) of the view.
+func (v *View) GoModCache() string {
+	return v.gomodcache
+}
+
+// Restricted reports whether the view's workspace folder is untrusted, in
+// which case go commands with side effects must not be run on its behalf.
+func (v *View) Restricted() bool {
+	return v.folder.Restricted()
+}
+
 // SetFolderOptions updates the options of each View associated with the folder
 // of the given URI.
 //
@@ -468,6 +495,40 @@ func (s *Session) SetFolderOptions(ctx context.Context, uri protocol.DocumentURI
 	return nil
 }
 
+// TrustFolder marks the workspace folder containing the given URI as trusted,
+// replacing each of its views with one that runs go commands unrestricted.
+//
+// It returns the best view for the given URI.
+func (s *Session) TrustFolder(ctx context.Context, uri protocol.DocumentURI) (*View, error) {
+	s.viewMu.Lock()
+	defer s.viewMu.Unlock()
+
+	best := bestViewForURI(uri, s.views)
+	if best == nil {
+		return nil, fmt.Errorf("no workspace folder contains %s", uri)
+	}
+	dir := best.folder.Dir
+	for _, v := range s.views {
+		if v.folder.Dir != dir || v.folder.Trusted {
+			continue
+		}
+		folder2 := *v.folder
+		folder2.Trusted = true
+		info, err := getViewDefinition(ctx, s.gocmdRunner, s, &folder2)
+		if err != nil {
+			return nil, err
+		}
+		v2, err := s.updateViewLocked(ctx, v, info, &folder2)
+		if err != nil {
+			return nil, err
+		}
+		if v == best {
+			best = v2
+		}
+	}
+	return best, nil
+}
+
 // viewEnv returns a string describing the environment of a newly created view.
 //
 // It must not be called concurrently with any other view methods.
@@ -947,9 +1008,15 @@ func getViewDefinition(ctx context.Context, runner *gocommand.Runner, fs file.So
 	}
 	def := new(viewDefinition)
 	var err error
+	env := folder.Options.EnvSlice()
+	if folder.Restricted() {
+		// Even querying the go version may download a toolchain requested by
+		// the workspace go.mod or go.work file.
+		env = append(env, "GOTOOLCHAIN=local")
+	}
 	inv := gocommand.Invocation{
 		WorkingDir: folder.Dir.Path(),
-		Env:        folder.Options.EnvSlice(),
+		Env:        env,
 	}
 	def.goversion, err = gocommand.GoVersion(ctx, inv, runner)
 	if err != nil {
@@ -959,11 +1026,16 @@ func getViewDefinition(ctx context.Context, runner *gocommand.Runner, fs file.So
 	if err != nil {
 		return nil, err
 	}
-	if err := def.load(ctx, folder.Dir.Path(), folder.Options.EnvSlice(), runner); err != nil {
+	if err := def.load(ctx, folder.Dir.Path(), env, runner); err != nil {
 		return nil, err
 	}
 	// The value of GOPACKAGESDRIVER is not returned through the go command.
-	gopackagesdriver := os.Getenv("GOPACKAGESDRIVER")
+	// Prefer the value configured for the folder, as that is what
+	// go/packages will see.
+	gopackagesdriver, ok := folder.Options.Env["GOPACKAGESDRIVER"]
+	if !ok {
+		gopackagesdriver = os.Getenv("GOPACKAGESDRIVER")
+	}
 	// A user may also have a gopackagesdriver binary on their machine, which
 	// works the same way as setting GOPACKAGESDRIVER.
 	tool, _ := exec.LookPath("gopackagesdriver")
@@ -1193,6 +1265,23 @@ func globsMatchPath(globs, target string) bool {
 
 var modFlagRegexp = regexp.MustCompile(`-mod[ =](\w+)`)
 
+// restrictedEnv returns the environment variables to add to go commands run
+// on behalf of an untrusted workspace folder whose effective GOFLAGS are
+// goflags.
+//
+// Toolchain switching is disabled, and any -mod=mod flag is removed from
+// GOFLAGS, as it may be set by a go.env file we don't control.
+func restrictedEnv(goflags string) []string {
+	var flags []string
+	for _, flag := range strings.Fields(goflags) {
+		if flag == "-mod=mod" || flag == "--mod=mod" {
+			continue
+		}
+		flags = append(flags, flag)
+	}
+	return []string{"GOTOOLCHAIN=local", "GOFLAGS=" + strings.Join(flags, " ")}
+}
+
 // TODO(rstambler): Consolidate modURI and modContent back into a FileHandle
 // after we have a version of the workspace go.mod file on disk. Getting a
 // FileHandle from the cache for temporary files is problematic, since we
@@ -238,3 +238,23 @@ func TestIgnoreFilter(t *testing.T) {
 		}
 	}
 }
+
+func TestRestrictedEnv(t *testing.T) {
+	tests := []struct {
+		goflags string
+		want    string
+	}{
+		{"", "GOFLAGS="},
+		{"-mod=mod", "GOFLAGS="},
+		{"-tags=foo -mod=mod -trimpath", "GOFLAGS=-tags=foo -trimpath"},
+		{"--mod=mod", "GOFLAGS="},
+		{"-mod=vendor", "GOFLAGS=-mod=vendor"},
+	}
+
+	for _, test := range tests {
+		env := restrictedEnv(test.goflags)
+		if len(env) != 2 || env[0] != "GOTOOLCHAIN=local" || env[1] != test.want {
+			t.Errorf("restrictedEnv(%q) = %q, want [GOTOOLCHAIN=local %s]", test.goflags, env, test.want)
+		}
+	}
+}
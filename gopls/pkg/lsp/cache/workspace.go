@@ -10,6 +10,7 @@ import (
 	"fmt"
 	"io/fs"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"golang.org/x/mod/modfile"
@@ -85,6 +86,35 @@ var errExhausted = errors.New("exhausted")
 // which point this limit was decreased to 100K.
 const fileLimit = 100_000
 
+// NestedModules returns the go.mod files of the modules in dir or any of its
+// subdirectories, in order. Directories ignored by the go command or excluded
+// by the directory filters of the view are not searched.
+//
+// If the search limit is reached, the modules found so far are returned.
+func (s *Snapshot) NestedModules(dir protocol.DocumentURI) ([]protocol.DocumentURI, error) {
+	folder := s.view.folder.Dir.Path()
+	filterer := buildFilterer(folder, s.view.gomodcache, s.Options())
+	excludePath := func(suffix string) bool {
+		// Directory filters are relative to the workspace folder, which may
+		// be a subdirectory of dir.
+		rel, err := filepath.Rel(folder, dir.Path()+suffix)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return false
+		}
+		return pathExcludedByFilter(rel, filterer)
+	}
+	modFiles, err := findModules(dir, excludePath, 0)
+	if err != nil && err != errExhausted {
+		return nil, err
+	}
+	var uris []protocol.DocumentURI
+	for uri := range modFiles {
+		uris = append(uris, uri)
+	}
+	sort.Slice(uris, func(i, j int) bool { return uris[i] < uris[j] })
+	return uris, nil
+}
+
 // findModules recursively walks the root directory looking for go.mod files,
 // returning the set of modules it discovers. If modLimit is non-zero,
 // searching stops once modLimit modules have been found.
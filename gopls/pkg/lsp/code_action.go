@@ -21,6 +21,7 @@ import (
 	"golang.org/x/tools/gopls/pkg/lsp/mod"
 	"golang.org/x/tools/gopls/pkg/lsp/protocol"
 	"golang.org/x/tools/gopls/pkg/lsp/source"
+	"golang.org/x/tools/gopls/pkg/lsp/work"
 	"golang.org/x/tools/gopls/pkg/settings"
 	"golang.org/x/tools/pkg/event"
 	"golang.org/x/tools/pkg/event/tag"
@@ -108,8 +109,22 @@ func (s *server) CodeAction(ctx context.Context, params *protocol.CodeActionPara
 			actions = append(actions, fixes...)
 		}
 
+		if want[protocol.RefactorRewrite] {
+			rewrites, err := work.CodeActions(ctx, snapshot, fh, params.Range)
+			if err != nil {
+				return nil, err
+			}
+			actions = append(actions, rewrites...)
+		}
+
 		return actions, nil
 
+	case file.Work:
+		if !want[protocol.RefactorRewrite] {
+			return nil, nil
+		}
+		return work.CodeActions(ctx, snapshot, fh, params.Range)
+
 	case file.Go:
 		diagnostics := params.Context.Diagnostics
 
@@ -6,6 +6,7 @@ package lsp
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"sort"
 
@@ -14,6 +15,7 @@ import (
 	"golang.org/x/tools/gopls/pkg/lsp/mod"
 	"golang.org/x/tools/gopls/pkg/lsp/protocol"
 	"golang.org/x/tools/gopls/pkg/lsp/source"
+	"golang.org/x/tools/gopls/pkg/lsp/work"
 	"golang.org/x/tools/pkg/event"
 	"golang.org/x/tools/pkg/event/tag"
 )
@@ -33,6 +35,11 @@ func (s *server) CodeLens(ctx context.Context, params *protocol.CodeLensParams)
 		lenses = mod.LensFuncs()
 	case file.Go:
 		lenses = source.LensFuncs()
+	case file.Work:
+		if !snapshot.Options().Codelenses[string(command.RunGoWorkCommand)] {
+			return nil, nil
+		}
+		return work.CodeLenses(ctx, snapshot, fh)
 	default:
 		// Unsupported file kind for a code lens.
 		return nil, nil
@@ -51,12 +58,46 @@ func (s *server) CodeLens(ctx context.Context, params *protocol.CodeLensParams)
 		}
 		result = append(result, added...)
 	}
+	s.benchmarks.annotateLenses(result)
 	sort.Slice(result, func(i, j int) bool {
 		a, b := result[i], result[j]
 		if cmp := protocol.CompareRange(a.Range, b.Range); cmp != 0 {
 			return cmp < 0
 		}
-		return a.Command.Command < b.Command.Command
+		return lensName(a) < lensName(b)
 	})
 	return result, nil
 }
+
+// lensName returns the name of the command of a code lens, which may not
+// be resolved yet.
+func lensName(lens protocol.CodeLens) string {
+	if data, ok := lens.Data.(source.LensData); ok {
+		return string(data.Lens)
+	}
+	return lens.Command.Command
+}
+
+func (s *server) ResolveCodeLens(ctx context.Context, lens *protocol.CodeLens) (*protocol.CodeLens, error) {
+	ctx, done := event.Start(ctx, "lsp.Server.resolveCodeLens")
+	defer done()
+
+	// The data of the lens has been decoded as a generic JSON value.
+	raw, err := json.Marshal(lens.Data)
+	if err != nil {
+		return nil, err
+	}
+	var data source.LensData
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return nil, fmt.Errorf("invalid code lens data: %v", err)
+	}
+	snapshot, fh, ok, release, err := s.beginFileRequest(ctx, data.Location.URI, file.Go)
+	defer release()
+	if !ok {
+		return nil, err
+	}
+	if err := source.ResolveCodeLens(ctx, snapshot, fh, lens, data); err != nil {
+		return nil, err
+	}
+	return lens, nil
+}
@@ -18,6 +18,7 @@ import (
 	"sort"
 	"strings"
 	"sync"
+	"time"
 
 	"golang.org/x/mod/modfile"
 	"golang.org/x/tools/go/ast/astutil"
@@ -82,11 +83,12 @@ func (*commandHandler) AddTelemetryCounters(_ context.Context, args command.AddT
 
 // commandConfig configures common command set-up and execution.
 type commandConfig struct {
-	async       bool                 // whether to run the command asynchronously. Async commands can only return errors.
-	requireSave bool                 // whether all files must be saved for the command to work
-	progress    string               // title to use for progress reporting. If empty, no progress will be reported.
-	forView     string               // view to resolve to a snapshot; incompatible with forURI
-	forURI      protocol.DocumentURI // URI to resolve to a snapshot. If unset, snapshot will be nil.
+	async        bool                 // whether to run the command asynchronously. Async commands can only return errors.
+	requireSave  bool                 // whether all files must be saved for the command to work
+	requireTrust bool                 // whether the workspace folder of forURI must be trusted
+	progress     string               // title to use for progress reporting. If empty, no progress will be reported.
+	forView      string               // view to resolve to a snapshot; incompatible with forURI
+	forURI       protocol.DocumentURI // URI to resolve to a snapshot. If unset, snapshot will be nil.
 }
 
 // commandDeps is evaluated from a commandConfig. Note that not all fields may
@@ -123,6 +125,14 @@ func (c *commandHandler) run(ctx context.Context, cfg commandConfig, run command
 	if cfg.forURI != "" && cfg.forView != "" {
 		return bug.Errorf("internal error: forURI=%q, forView=%q", cfg.forURI, cfg.forView)
 	}
+	if cfg.requireTrust {
+		if cfg.forURI == "" {
+			return bug.Errorf("internal error: requireTrust without forURI")
+		}
+		if err := c.s.checkTrusted(cfg.forURI); err != nil {
+			return err
+		}
+	}
 	if cfg.forURI != "" {
 		var ok bool
 		var release func()
@@ -215,7 +225,9 @@ func (c *commandHandler) ApplyFix(ctx context.Context, args command.ApplyFixArgs
 
 func (c *commandHandler) RegenerateCgo(ctx context.Context, args command.URIArg) error {
 	return c.run(ctx, commandConfig{
-		progress: "Regenerating Cgo",
+		progress:     "Regenerating Cgo",
+		requireTrust: true,
+		forURI:       args.URI,
 	}, func(ctx context.Context, _ commandDeps) error {
 		return c.modifyState(ctx, FromRegenerateCgo, func() (*cache.Snapshot, func(), error) {
 			// Resetting the view causes cgo to be regenerated via `go list`.
@@ -228,6 +240,34 @@ func (c *commandHandler) RegenerateCgo(ctx context.Context, args command.URIArg)
 	})
 }
 
+func (c *commandHandler) TrustWorkspace(ctx context.Context, args command.URIArg) error {
+	return c.run(ctx, commandConfig{
+		progress: "Trusting workspace folder",
+	}, func(ctx context.Context, _ commandDeps) error {
+		return c.modifyState(ctx, FromTrustWorkspace, func() (*cache.Snapshot, func(), error) {
+			// Recreating the view reloads the workspace without restrictions.
+			v, err := c.s.session.TrustFolder(ctx, args.URI)
+			if err != nil {
+				return nil, nil, err
+			}
+			return v.Snapshot()
+		})
+	})
+}
+
+// checkTrusted returns an error if the workspace folder containing uri is
+// restricted because it has not been trusted by the user.
+func (s *server) checkTrusted(uri protocol.DocumentURI) error {
+	view, err := s.session.ViewOf(uri)
+	if err != nil {
+		return err
+	}
+	if view.Restricted() {
+		return fmt.Errorf("workspace folder %s is not trusted; run the %q command to trust it", view.Folder().Path(), command.TrustWorkspace.ID())
+	}
+	return nil
+}
+
 // modifyState performs an operation that modifies the snapshot state.
 //
 // It causes a snapshot diagnosis for the provided ModificationSource.
@@ -358,9 +398,10 @@ func (c *commandHandler) Tidy(ctx context.Context, args command.URIArgs) error {
 
 func (c *commandHandler) Vendor(ctx context.Context, args command.URIArg) error {
 	return c.run(ctx, commandConfig{
-		requireSave: true,
-		progress:    "Running go mod vendor",
-		forURI:      args.URI,
+		requireSave:  true,
+		requireTrust: true,
+		progress:     "Running go mod vendor",
+		forURI:       args.URI,
 	}, func(ctx context.Context, deps commandDeps) error {
 		// Use RunGoCommandPiped here so that we don't compete with any other go
 		// command invocations. go mod vendor deletes modules.txt before recreating
@@ -484,17 +525,19 @@ func (c *commandHandler) Test(ctx context.Context, uri protocol.DocumentURI, tes
 
 func (c *commandHandler) RunTests(ctx context.Context, args command.RunTestsArgs) error {
 	return c.run(ctx, commandConfig{
-		async:       true,
-		progress:    "Running go test",
-		requireSave: true,
-		forURI:      args.URI,
+		async:        true,
+		progress:     "Running go test",
+		requireSave:  true,
+		requireTrust: true,
+		forURI:       args.URI,
 	}, func(ctx context.Context, deps commandDeps) error {
-		return c.runTests(ctx, deps.snapshot, deps.work, args.URI, args.Tests, args.Benchmarks)
+		return c.runTests(ctx, deps.snapshot, deps.work, args)
 	})
 }
 
-func (c *commandHandler) runTests(ctx context.Context, snapshot *cache.Snapshot, work *progress.WorkDone, uri protocol.DocumentURI, tests, benchmarks []string) error {
+func (c *commandHandler) runTests(ctx context.Context, snapshot *cache.Snapshot, work *progress.WorkDone, args command.RunTestsArgs) error {
 	// TODO: fix the error reporting when this runs async.
+	uri := args.URI
 	meta, err := source.NarrowestMetadataForFile(ctx, snapshot, uri)
 	if err != nil {
 		return err
@@ -508,7 +551,7 @@ func (c *commandHandler) runTests(ctx context.Context, snapshot *cache.Snapshot,
 
 	// Run `go test -run Func` on each test.
 	var failedTests int
-	for _, funcName := range tests {
+	for _, funcName := range args.Tests {
 		inv := &gocommand.Invocation{
 			Verb:       "test",
 			Args:       []string{pkgPath, "-v", "-count=1", "-run", fmt.Sprintf("^%s$", funcName)},
@@ -524,40 +567,76 @@ func (c *commandHandler) runTests(ctx context.Context, snapshot *cache.Snapshot,
 
 	// Run `go test -run=^$ -bench Func` on each test.
 	var failedBenchmarks int
-	for _, funcName := range benchmarks {
+	for _, funcName := range args.Benchmarks {
 		inv := &gocommand.Invocation{
 			Verb:       "test",
 			Args:       []string{pkgPath, "-v", "-run=^$", "-bench", fmt.Sprintf("^%s$", funcName)},
 			WorkingDir: filepath.Dir(uri.Path()),
 		}
-		if err := snapshot.RunGoCommandPiped(ctx, source.Normal, inv, out, out); err != nil {
+		// Capture the results to compare them with the next run.
+		results := &bytes.Buffer{}
+		if err := snapshot.RunGoCommandPiped(ctx, source.Normal, inv, io.MultiWriter(out, results), out); err != nil {
 			if errors.Is(err, context.Canceled) {
 				return err
 			}
 			failedBenchmarks++
+			continue
+		}
+		c.s.benchmarks.record(uri, funcName, results)
+	}
+	// Show the new results in the titles of the benchmarks' code lenses.
+	if len(args.Benchmarks) > failedBenchmarks && c.s.Options().CodeLensRefreshSupported {
+		if err := c.s.client.CodeLensRefresh(ctx); err != nil {
+			event.Error(ctx, "refreshing code lenses", err)
+		}
+	}
+
+	// Run `go test -run=^$ -fuzz Func -fuzztime T` on each fuzz target.
+	fuzzTime := args.FuzzTime
+	if fuzzTime == "" {
+		fuzzTime = "30s"
+	}
+	var failedFuzz int
+	for _, funcName := range args.Fuzz {
+		inv := &gocommand.Invocation{
+			Verb:       "test",
+			Args:       []string{pkgPath, "-v", "-run=^$", "-fuzz", fmt.Sprintf("^%s$", funcName), "-fuzztime", fuzzTime},
+			WorkingDir: filepath.Dir(uri.Path()),
+		}
+		if err := snapshot.RunGoCommandPiped(ctx, source.Normal, inv, out, out); err != nil {
+			if errors.Is(err, context.Canceled) {
+				return err
+			}
+			failedFuzz++
 		}
 	}
 
-	var title string
-	if len(tests) > 0 && len(benchmarks) > 0 {
-		title = "tests and benchmarks"
-	} else if len(tests) > 0 {
-		title = "tests"
-	} else if len(benchmarks) > 0 {
-		title = "benchmarks"
-	} else {
+	var kinds []string
+	if len(args.Tests) > 0 {
+		kinds = append(kinds, "tests")
+	}
+	if len(args.Benchmarks) > 0 {
+		kinds = append(kinds, "benchmarks")
+	}
+	if len(args.Fuzz) > 0 {
+		kinds = append(kinds, "fuzz targets")
+	}
+	if len(kinds) == 0 {
 		return errors.New("No functions were provided")
 	}
-	message := fmt.Sprintf("all %s passed", title)
-	if failedTests > 0 && failedBenchmarks > 0 {
-		message = fmt.Sprintf("%d / %d tests failed and %d / %d benchmarks failed", failedTests, len(tests), failedBenchmarks, len(benchmarks))
-	} else if failedTests > 0 {
-		message = fmt.Sprintf("%d / %d tests failed", failedTests, len(tests))
-	} else if failedBenchmarks > 0 {
-		message = fmt.Sprintf("%d / %d benchmarks failed", failedBenchmarks, len(benchmarks))
+	var failures []string
+	if failedTests > 0 {
+		failures = append(failures, fmt.Sprintf("%d / %d tests failed", failedTests, len(args.Tests)))
 	}
-	if failedTests > 0 || failedBenchmarks > 0 {
-		message += "\n" + buf.String()
+	if failedBenchmarks > 0 {
+		failures = append(failures, fmt.Sprintf("%d / %d benchmarks failed", failedBenchmarks, len(args.Benchmarks)))
+	}
+	if failedFuzz > 0 {
+		failures = append(failures, fmt.Sprintf("%d / %d fuzz targets failed", failedFuzz, len(args.Fuzz)))
+	}
+	message := fmt.Sprintf("all %s passed", strings.Join(kinds, " and "))
+	if len(failures) > 0 {
+		message = strings.Join(failures, " and ") + "\n" + buf.String()
 	}
 
 	_ = c.s.client.ShowMessage(ctx, &protocol.ShowMessageParams{
@@ -565,7 +644,7 @@ func (c *commandHandler) runTests(ctx context.Context, snapshot *cache.Snapshot,
 		Message: message,
 	})
 
-	if failedTests > 0 || failedBenchmarks > 0 {
+	if len(failures) > 0 {
 		return errors.New("gopls.test command failed")
 	}
 	return nil
@@ -577,9 +656,10 @@ func (c *commandHandler) Generate(ctx context.Context, args command.GenerateArgs
 		title = "Running go generate ./..."
 	}
 	return c.run(ctx, commandConfig{
-		requireSave: true,
-		progress:    title,
-		forURI:      args.Dir,
+		requireSave:  true,
+		requireTrust: true,
+		progress:     title,
+		forURI:       args.Dir,
 	}, func(ctx context.Context, deps commandDeps) error {
 		er := progress.NewEventWriter(ctx, "generate")
 
@@ -994,6 +1074,15 @@ func (c *commandHandler) RunGovulncheck(ctx context.Context, args command.Vulnch
 		defer release()
 		c.s.diagnoseSnapshot(snapshot, nil, false, 0)
 
+		if warning := vulncheck.StaleDBWarning(result.DB, result.DBModified, time.Now()); warning != "" {
+			if err := c.s.client.ShowMessage(ctx, &protocol.ShowMessageParams{
+				Type:    protocol.Warning,
+				Message: warning,
+			}); err != nil {
+				return err
+			}
+		}
+
 		affecting := make(map[string]bool, len(result.Entries))
 		for _, finding := range result.Findings {
 			if len(finding.Trace) > 1 { // at least 2 frames if callstack exists (vulnerability, entry)
@@ -1265,3 +1354,27 @@ func (c *commandHandler) ChangeSignature(ctx context.Context, args command.Chang
 		return nil
 	})
 }
+
+func (c *commandHandler) References(ctx context.Context, loc protocol.Location) ([]protocol.Location, error) {
+	var locs []protocol.Location
+	err := c.run(ctx, commandConfig{
+		forURI: loc.URI,
+	}, func(ctx context.Context, deps commandDeps) error {
+		var err error
+		locs, err = source.References(ctx, deps.snapshot, deps.fh, loc.Range.Start, false)
+		return err
+	})
+	return locs, err
+}
+
+func (c *commandHandler) Implementations(ctx context.Context, loc protocol.Location) ([]protocol.Location, error) {
+	var locs []protocol.Location
+	err := c.run(ctx, commandConfig{
+		forURI: loc.URI,
+	}, func(ctx context.Context, deps commandDeps) error {
+		var err error
+		locs, err = source.Implementation(ctx, deps.snapshot, deps.fh, loc.Range.Start)
+		return err
+	})
+	return locs, err
+}
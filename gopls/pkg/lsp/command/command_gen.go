@@ -33,10 +33,12 @@ const (
 	GCDetails               Command = "gc_details"
 	Generate                Command = "generate"
 	GoGetPackage            Command = "go_get_package"
+	Implementations         Command = "implementations"
 	ListImports             Command = "list_imports"
 	ListKnownPackages       Command = "list_known_packages"
 	MaybePromptForTelemetry Command = "maybe_prompt_for_telemetry"
 	MemStats                Command = "mem_stats"
+	References              Command = "references"
 	RegenerateCgo           Command = "regenerate_cgo"
 	RemoveDependency        Command = "remove_dependency"
 	ResetGoModDiagnostics   Command = "reset_go_mod_diagnostics"
@@ -49,6 +51,7 @@ const (
 	Test                    Command = "test"
 	Tidy                    Command = "tidy"
 	ToggleGCDetails         Command = "toggle_gc_details"
+	TrustWorkspace          Command = "trust_workspace"
 	UpdateGoSum             Command = "update_go_sum"
 	UpgradeDependency       Command = "upgrade_dependency"
 	Vendor                  Command = "vendor"
@@ -67,10 +70,12 @@ var Commands = []Command{
 	GCDetails,
 	Generate,
 	GoGetPackage,
+	Implementations,
 	ListImports,
 	ListKnownPackages,
 	MaybePromptForTelemetry,
 	MemStats,
+	References,
 	RegenerateCgo,
 	RemoveDependency,
 	ResetGoModDiagnostics,
@@ -83,6 +88,7 @@ var Commands = []Command{
 	Test,
 	Tidy,
 	ToggleGCDetails,
+	TrustWorkspace,
 	UpdateGoSum,
 	UpgradeDependency,
 	Vendor,
@@ -157,6 +163,12 @@ func Dispatch(ctx context.Context, params *protocol.ExecuteCommandParams, s Inte
 			return nil, err
 		}
 		return nil, s.GoGetPackage(ctx, a0)
+	case "gopls.implementations":
+		var a0 protocol.Location
+		if err := UnmarshalArgs(params.Arguments, &a0); err != nil {
+			return nil, err
+		}
+		return s.Implementations(ctx, a0)
 	case "gopls.list_imports":
 		var a0 URIArg
 		if err := UnmarshalArgs(params.Arguments, &a0); err != nil {
@@ -173,6 +185,12 @@ func Dispatch(ctx context.Context, params *protocol.ExecuteCommandParams, s Inte
 		return nil, s.MaybePromptForTelemetry(ctx)
 	case "gopls.mem_stats":
 		return s.MemStats(ctx)
+	case "gopls.references":
+		var a0 protocol.Location
+		if err := UnmarshalArgs(params.Arguments, &a0); err != nil {
+			return nil, err
+		}
+		return s.References(ctx, a0)
 	case "gopls.regenerate_cgo":
 		var a0 URIArg
 		if err := UnmarshalArgs(params.Arguments, &a0); err != nil {
@@ -247,6 +265,12 @@ func Dispatch(ctx context.Context, params *protocol.ExecuteCommandParams, s Inte
 			return nil, err
 		}
 		return nil, s.ToggleGCDetails(ctx, a0)
+	case "gopls.trust_workspace":
+		var a0 URIArg
+		if err := UnmarshalArgs(params.Arguments, &a0); err != nil {
+			return nil, err
+		}
+		return nil, s.TrustWorkspace(ctx, a0)
 	case "gopls.update_go_sum":
 		var a0 URIArgs
 		if err := UnmarshalArgs(params.Arguments, &a0); err != nil {
@@ -403,6 +427,18 @@ func NewGoGetPackageCommand(title string, a0 GoGetPackageArgs) (protocol.Command
 	}, nil
 }
 
+func NewImplementationsCommand(title string, a0 protocol.Location) (protocol.Command, error) {
+	args, err := MarshalArgs(a0)
+	if err != nil {
+		return protocol.Command{}, err
+	}
+	return protocol.Command{
+		Title:     title,
+		Command:   "gopls.implementations",
+		Arguments: args,
+	}, nil
+}
+
 func NewListImportsCommand(title string, a0 URIArg) (protocol.Command, error) {
 	args, err := MarshalArgs(a0)
 	if err != nil {
@@ -451,6 +487,18 @@ func NewMemStatsCommand(title string) (protocol.Command, error) {
 	}, nil
 }
 
+func NewReferencesCommand(title string, a0 protocol.Location) (protocol.Command, error) {
+	args, err := MarshalArgs(a0)
+	if err != nil {
+		return protocol.Command{}, err
+	}
+	return protocol.Command{
+		Title:     title,
+		Command:   "gopls.references",
+		Arguments: args,
+	}, nil
+}
+
 func NewRegenerateCgoCommand(title string, a0 URIArg) (protocol.Command, error) {
 	args, err := MarshalArgs(a0)
 	if err != nil {
@@ -595,6 +643,18 @@ func NewToggleGCDetailsCommand(title string, a0 URIArg) (protocol.Command, error
 	}, nil
 }
 
+func NewTrustWorkspaceCommand(title string, a0 URIArg) (protocol.Command, error) {
+	args, err := MarshalArgs(a0)
+	if err != nil {
+		return protocol.Command{}, err
+	}
+	return protocol.Command{
+		Title:     title,
+		Command:   "gopls.trust_workspace",
+		Arguments: args,
+	}, nil
+}
+
 func NewUpdateGoSumCommand(title string, a0 URIArgs) (protocol.Command, error) {
 	args, err := MarshalArgs(a0)
 	if err != nil {
@@ -60,6 +60,12 @@ type Interface interface {
 	// Regenerates cgo definitions.
 	RegenerateCgo(context.Context, URIArg) error
 
+	// TrustWorkspace: Trust a workspace folder
+	//
+	// Marks the workspace folder containing the given URI as trusted, lifting
+	// the restrictions imposed by the `requireWorkspaceTrust` setting.
+	TrustWorkspace(context.Context, URIArg) error
+
 	// Tidy: Run go mod tidy
 	//
 	// Runs `go mod tidy` for a module.
@@ -163,7 +169,9 @@ type Interface interface {
 
 	// RunGovulncheck: Run vulncheck.
 	//
-	// Run vulnerability check (`govulncheck`).
+	// Run vulnerability check (`govulncheck`), and report the
+	// vulnerabilities it finds on the requirements of go.mod, and at the
+	// workspace call sites from which vulnerable symbols are reachable.
 	RunGovulncheck(context.Context, VulncheckArgs) (RunVulncheckResult, error)
 
 	// FetchVulncheckResult: Get known vulncheck result
@@ -187,8 +195,12 @@ type Interface interface {
 	// command.
 	WorkspaceStats(context.Context) (WorkspaceStatsResult, error)
 
-	// RunGoWorkCommand: run `go work [args...]`, and apply the resulting go.work
-	// edits to the current go.work file.
+	// RunGoWorkCommand: Run go work command
+	//
+	// Runs `go work [args...]`, and applies the resulting go.work edits to
+	// the current go.work file. This is used by the code lenses of go.work
+	// files to use nested modules, drop uses of missing modules, and run
+	// `go work sync`.
 	RunGoWorkCommand(context.Context, RunGoWorkArgs) error
 
 	// AddTelemetryCounters: update the given telemetry counters.
@@ -207,6 +219,20 @@ type Interface interface {
 	// This command is experimental, currently only supporting parameter removal.
 	// Its signature will certainly change in the future (pun intended).
 	ChangeSignature(context.Context, ChangeSignatureArgs) error
+
+	// References: List references
+	//
+	// Lists the references to the declaration at the given location,
+	// excluding the declaration itself, as does the references request.
+	// The code lens above each top-level declaration shows their number.
+	References(context.Context, protocol.Location) ([]protocol.Location, error)
+
+	// Implementations: List implementations
+	//
+	// Lists the implementations of the type declared at the given location,
+	// as does the implementation request. The code lens above each type
+	// declaration shows their number.
+	Implementations(context.Context, protocol.Location) ([]protocol.Location, error)
 }
 
 type RunTestsArgs struct {
@@ -218,6 +244,13 @@ type RunTestsArgs struct {
 
 	// Specific benchmarks to run, e.g. BenchmarkFoo.
 	Benchmarks []string
+
+	// Specific fuzz targets to fuzz, e.g. FuzzFoo, each for FuzzTime.
+	Fuzz []string
+
+	// FuzzTime is how long each fuzz target is fuzzed, in the syntax of the
+	// -fuzztime flag of go test, e.g. "30s" or "1000x". The default is 30s.
+	FuzzTime string
 }
 
 type GenerateArgs struct {
@@ -10,6 +10,7 @@ import (
 	"strings"
 
 	"golang.org/x/tools/gopls/pkg/file"
+	"golang.org/x/tools/gopls/pkg/lsp/mod"
 	"golang.org/x/tools/gopls/pkg/lsp/protocol"
 	"golang.org/x/tools/gopls/pkg/lsp/source"
 	"golang.org/x/tools/gopls/pkg/lsp/source/completion"
@@ -29,6 +30,7 @@ func (s *server) Completion(ctx context.Context, params *protocol.CompletionPara
 
 	ctx, done := event.Start(ctx, "lsp.Server.completion", tag.URI.Of(params.TextDocument.URI))
 	defer done()
+	defer s.beginInteractive()()
 
 	snapshot, fh, ok, release, err := s.beginFileRequest(ctx, params.TextDocument.URI, file.UnknownKind)
 	defer release()
@@ -39,9 +41,17 @@ func (s *server) Completion(ctx context.Context, params *protocol.CompletionPara
 	var surrounding *completion.Selection
 	switch snapshot.FileKind(fh) {
 	case file.Go:
-		candidates, surrounding, err = completion.Completion(ctx, snapshot, fh, params.Position, params.Context)
+		var usage *completion.Usage
+		if snapshot.Options().UsageRanking {
+			usage = s.completionUsage(ctx, snapshot)
+		}
+		candidates, surrounding, err = completion.Completion(ctx, snapshot, fh, params.Position, params.Context, usage)
 	case file.Mod:
-		candidates, surrounding = nil, nil
+		cl, err := mod.Completion(ctx, snapshot, fh, params.Position)
+		if err != nil {
+			break
+		}
+		return cl, nil
 	case file.Work:
 		cl, err := work.Completion(ctx, snapshot, fh, params.Position)
 		if err != nil {
@@ -77,6 +87,9 @@ func (s *server) Completion(ctx context.Context, params *protocol.CompletionPara
 	incompleteResults := options.DeepCompletion || options.Matcher == settings.Fuzzy
 
 	items := toProtocolCompletionItems(candidates, rng, options)
+	if options.UsageRanking {
+		s.setPendingCompletion(snapshot.View(), fh.URI(), rng.Start, candidates)
+	}
 
 	return &protocol.CompletionList{
 		IsIncomplete: incompleteResults,
@@ -0,0 +1,113 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package lsp
+
+import (
+	"context"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/tools/gopls/pkg/lsp/cache"
+	"golang.org/x/tools/gopls/pkg/lsp/protocol"
+	"golang.org/x/tools/gopls/pkg/lsp/source/completion"
+	"golang.org/x/tools/pkg/event"
+	"golang.org/x/tools/pkg/event/tag"
+)
+
+// A pendingCompletion records a completion list offered to the client, so
+// that the candidate it accepts, if any, can be recognized in the following
+// edits of the file.
+type pendingCompletion struct {
+	view  *cache.View
+	uri   protocol.DocumentURI
+	start protocol.Position // start of the completed identifier
+	keys  map[string]string // usage key of each candidate, by label
+}
+
+// completionUsage returns the usage statistics of the snapshot's workspace,
+// used to rank completion candidates.
+func (s *server) completionUsage(ctx context.Context, snapshot *cache.Snapshot) *completion.Usage {
+	references, err := snapshot.MemberUsage(ctx)
+	if err != nil {
+		// The accepted completions are still useful.
+		event.Error(ctx, "computing member usage", err)
+	}
+	return &completion.Usage{
+		References: references,
+		Accepted:   snapshot.View().AcceptedCompletions(ctx),
+	}
+}
+
+// setPendingCompletion records the candidates of the completion of the
+// identifier starting at start, replacing the previous completion.
+func (s *server) setPendingCompletion(view *cache.View, uri protocol.DocumentURI, start protocol.Position, candidates []completion.CompletionItem) {
+	keys := make(map[string]string)
+	for _, cand := range candidates {
+		if cand.UsageKey != "" && cand.Depth == 0 {
+			keys[cand.Label] = cand.UsageKey
+		}
+	}
+
+	s.pendingCompletionMu.Lock()
+	defer s.pendingCompletionMu.Unlock()
+	s.pendingCompletion = &pendingCompletion{view: view, uri: uri, start: start, keys: keys}
+	if len(keys) == 0 {
+		s.pendingCompletion = nil
+	}
+}
+
+// observeCompletion checks whether an edit of the file accepted a candidate
+// of the pending completion: that the identifier at its start position is
+// one of the candidates, and was inserted by a single change rather than
+// typed. If so, it records the acceptance in the view.
+func (s *server) observeCompletion(ctx context.Context, uri protocol.DocumentURI, changes []protocol.TextDocumentContentChangeEvent, text []byte) {
+	s.pendingCompletionMu.Lock()
+	p := s.pendingCompletion
+	if p == nil || p.uri != uri {
+		s.pendingCompletionMu.Unlock()
+		return
+	}
+	offset, err := protocol.NewMapper(uri, text).PositionOffset(p.start)
+	if err != nil {
+		// The file has shrunk past the completion.
+		s.pendingCompletion = nil
+		s.pendingCompletionMu.Unlock()
+		return
+	}
+	ident := identifierAt(text, offset)
+	key, ok := p.keys[ident]
+	if ok {
+		ok = false
+		for _, change := range changes {
+			if strings.Contains(change.Text, ident) {
+				ok = true
+				break
+			}
+		}
+	}
+	if ok {
+		s.pendingCompletion = nil
+	}
+	s.pendingCompletionMu.Unlock()
+
+	if ok {
+		event.Log(ctx, "accepted completion", tag.URI.Of(uri))
+		p.view.RecordAcceptedCompletion(ctx, key)
+	}
+}
+
+// identifierAt returns the identifier starting at the given offset of text.
+func identifierAt(text []byte, offset int) string {
+	end := offset
+	for end < len(text) {
+		r, size := utf8.DecodeRune(text[end:])
+		if r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			break
+		}
+		end += size
+	}
+	return string(text[offset:end])
+}
@@ -33,6 +33,7 @@ import (
 	"golang.org/x/tools/pkg/event/export"
 	"golang.org/x/tools/pkg/event/export/metric"
 	"golang.org/x/tools/pkg/event/export/ocagent"
+	"golang.org/x/tools/pkg/event/export/otlp"
 	"golang.org/x/tools/pkg/event/export/prometheus"
 	"golang.org/x/tools/pkg/event/keys"
 	"golang.org/x/tools/pkg/event/label"
@@ -60,8 +61,11 @@ type Instance struct {
 
 	ocagent    *ocagent.Exporter
 	prometheus *prometheus.Exporter
+	otlpMu     sync.Mutex
+	otlp       *otlp.Exporter
 	rpcs       *Rpcs
 	traces     *traces
+	slow       *slowRequests
 	State      *State
 
 	serveMu              sync.Mutex
@@ -382,11 +386,42 @@ func WithInstance(ctx context.Context, workdir, agent string) context.Context {
 	i.prometheus = prometheus.New()
 	i.rpcs = &Rpcs{}
 	i.traces = &traces{}
+	i.slow = &slowRequests{}
 	i.State = &State{}
 	i.exporter = makeInstanceExporter(i)
 	return context.WithValue(ctx, instanceKey, i)
 }
 
+// ConnectOTLP starts exporting the traces and metrics of this instance
+// to the OpenTelemetry collector at the given OTLP/HTTP address, such as
+// http://localhost:4318. The returned function stops the export, after
+// flushing any pending telemetry.
+func (i *Instance) ConnectOTLP(address string) func() {
+	exporter := otlp.Connect(&otlp.Config{
+		Start:   i.StartTime,
+		Service: "gopls",
+		Address: address,
+	})
+	if exporter == nil {
+		return func() {}
+	}
+	i.otlpMu.Lock()
+	i.otlp = exporter
+	i.otlpMu.Unlock()
+	return func() {
+		i.otlpMu.Lock()
+		i.otlp = nil
+		i.otlpMu.Unlock()
+		exporter.Close()
+	}
+}
+
+func (i *Instance) otlpExporter() *otlp.Exporter {
+	i.otlpMu.Lock()
+	defer i.otlpMu.Unlock()
+	return i.otlp
+}
+
 // SetLogFile sets the logfile for use with this instance.
 func (i *Instance) SetLogFile(logfile string, isDaemon bool) (func(), error) {
 	// TODO: probably a better solution for deferring closure to the caller would
@@ -461,6 +496,16 @@ func (i *Instance) Serve(ctx context.Context, addr string) (string, error) {
 		if i.traces != nil {
 			mux.HandleFunc("/trace/", render(TraceTmpl, i.traces.getData))
 		}
+		if i.slow != nil {
+			renderSlow := render(SlowTmpl, i.slow.getData)
+			mux.HandleFunc("/slow/", func(w http.ResponseWriter, r *http.Request) {
+				if strings.HasSuffix(r.URL.Path, "/bundle.zip") {
+					i.slow.serveBundle(w, r)
+					return
+				}
+				renderSlow(w, r)
+			})
+		}
 		mux.HandleFunc("/analysis/", render(AnalysisTmpl, i.getAnalysis))
 		mux.HandleFunc("/cache/", render(CacheTmpl, i.getCache))
 		mux.HandleFunc("/session/", render(SessionTmpl, i.getSession))
@@ -554,12 +599,18 @@ func makeInstanceExporter(i *Instance) event.Exporter {
 		if i.prometheus != nil {
 			ctx = i.prometheus.ProcessEvent(ctx, ev, lm)
 		}
+		if e := i.otlpExporter(); e != nil {
+			ctx = e.ProcessEvent(ctx, ev, lm)
+		}
 		if i.rpcs != nil {
 			ctx = i.rpcs.ProcessEvent(ctx, ev, lm)
 		}
 		if i.traces != nil {
 			ctx = i.traces.ProcessEvent(ctx, ev, lm)
 		}
+		if i.slow != nil {
+			ctx = i.slow.ProcessEvent(ctx, ev, lm)
+		}
 		if event.IsLog(ev) {
 			if s := cache.KeyCreateSession.Get(ev); s != nil {
 				i.State.addClient(s)
@@ -663,6 +714,7 @@ ul.spans {
 <a href="/metrics">Metrics</a>
 <a href="/rpc">RPC</a>
 <a href="/trace">Trace</a>
+<a href="/slow">Slow</a>
 <a href="/analysis">Analysis</a>
 <hr>
 <h1>{{template "title" .}}</h1>
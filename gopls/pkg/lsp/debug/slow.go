@@ -0,0 +1,328 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package debug
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"html/template"
+	"net/http"
+	"runtime/pprof"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/tools/pkg/event"
+	"golang.org/x/tools/pkg/event/core"
+	"golang.org/x/tools/pkg/event/export"
+	"golang.org/x/tools/pkg/event/label"
+	"golang.org/x/tools/pkg/event/tag"
+)
+
+// The pprof labels of the goroutines handling an LSP request.
+const (
+	methodLabel = "lsp.method"
+	spanLabel   = "lsp.span"
+)
+
+const (
+	maxSlowRequests = 10 // number of slow requests retained
+	maxSlowSamples  = 10 // number of goroutine samples per slow request
+)
+
+// SlowTmpl extends BaseTemplate and renders a SlowResults, e.g. from getData().
+var SlowTmpl = template.Must(template.Must(BaseTemplate.Clone()).Parse(`
+{{define "title"}}Slow Requests{{end}}
+{{define "body"}}
+	{{if .Threshold}}
+		<p>
+		The goroutines handling LSP requests that run for longer than {{.Threshold}}
+		are sampled, every {{.Threshold}}, until the request completes.
+		The {{.Max}} most recent slow requests are shown below.
+		</p>
+	{{else}}
+		<p>Slow requests are not captured: start gopls with -rpc.slow.</p>
+	{{end}}
+	<ul>{{range .Requests}}
+		<li><a href="/slow/{{.ID}}">{{.Method}}</a> {{.Start.Format "15:04:05.000"}} (+{{.Duration}}), {{len .Samples}} samples</li>
+	{{end}}</ul>
+	{{with .Selected}}
+		<H2>{{.Method}} {{.RPCID}}</H2>
+		<a href="/slow/{{.ID}}/bundle.zip">Download bundle</a>
+		<p>{{.Tags}}</p>
+		{{range .Samples}}
+			<H3>After {{.Offset}}</H3>
+			<pre>{{.Stacks}}</pre>
+		{{end}}
+	{{end}}
+{{end}}
+`))
+
+// A SlowResults is the subject for the /slow HTML template.
+type SlowResults struct { // exported for testing
+	Threshold time.Duration
+	Max       int
+	Requests  []*slowRequest // most recent first
+	Selected  *slowRequest
+}
+
+// slowRequests captures the goroutines of inbound LSP requests that run
+// for longer than a threshold.
+//
+// The goroutines handling a request are identified by the pprof labels
+// added by RequestLabels. While a request is running past the
+// threshold, its goroutines are sampled periodically, by filtering the
+// goroutine profile on the span label of the request.
+type slowRequests struct {
+	mu        sync.Mutex
+	threshold time.Duration
+	running   map[export.SpanContext]*slowRequest
+	captured  []*slowRequest // most recent last
+	nextID    int
+}
+
+// A slowRequest is an inbound LSP request that ran for longer than the
+// threshold, or that is running and may do so.
+type slowRequest struct {
+	ID       int // for URLs
+	Method   string
+	RPCID    string
+	Start    time.Time
+	Duration time.Duration // set at end
+	Tags     string
+	Events   []string // set at end
+	Samples  []goroutineSample
+
+	span  *export.Span
+	timer *time.Timer
+	done  bool
+}
+
+// A goroutineSample holds the goroutines of a request at a point in time.
+type goroutineSample struct {
+	Offset  time.Duration // since the start of the request
+	Stacks  string        // of the goroutines of the request, in the text format of pprof
+	Profile []byte        // of all goroutines, in the compressed protocol buffer format of pprof
+}
+
+// RequestLabels returns the pprof labels for the goroutines handling
+// the inbound LSP request of the given method, whose span is in ctx.
+// Slow requests are captured only if these labels are applied, with
+// pprof.Do, by the handler of the request.
+func RequestLabels(ctx context.Context, method string) pprof.LabelSet {
+	if span := export.GetSpan(ctx); span != nil {
+		return pprof.Labels(methodLabel, method, spanLabel, span.ID.SpanID.String())
+	}
+	return pprof.Labels(methodLabel, method)
+}
+
+// SetSlowRequestThreshold sets the duration after which the goroutines of
+// an inbound LSP request are captured, for display by the debug server.
+// A zero duration disables the capture.
+func (i *Instance) SetSlowRequestThreshold(d time.Duration) {
+	i.slow.mu.Lock()
+	defer i.slow.mu.Unlock()
+	i.slow.threshold = d
+}
+
+func (s *slowRequests) ProcessEvent(ctx context.Context, ev core.Event, lm label.Map) context.Context {
+	switch {
+	case event.IsStart(ev):
+		span := export.GetSpan(ctx)
+		if span == nil || span.ParentID.IsValid() {
+			return ctx
+		}
+		// Only calls, which have an ID, await a response.
+		if tag.RPCDirection.Get(lm) != tag.Inbound || !lm.Find(tag.RPCID).Valid() {
+			return ctx
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.threshold <= 0 {
+			return ctx
+		}
+		if s.running == nil {
+			s.running = make(map[export.SpanContext]*slowRequest)
+		}
+		req := &slowRequest{
+			Method: tag.Method.Get(lm),
+			RPCID:  tag.RPCID.Get(lm),
+			Start:  span.Start().At(),
+			Tags:   renderLabels(span.Start()),
+			span:   span,
+		}
+		s.running[span.ID] = req
+		threshold := s.threshold
+		req.timer = time.AfterFunc(threshold, func() { s.sample(req, threshold) })
+
+	case event.IsEnd(ev):
+		span := export.GetSpan(ctx)
+		if span == nil {
+			return ctx
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		req, ok := s.running[span.ID]
+		if !ok {
+			return ctx
+		}
+		delete(s.running, span.ID)
+		req.timer.Stop()
+		req.done = true
+		if len(req.Samples) == 0 {
+			return ctx // not slow
+		}
+		req.Duration = span.Finish().At().Sub(span.Start().At())
+		for _, ev := range span.Events() {
+			req.Events = append(req.Events, fmt.Sprintf("%s +%v %s", ev.At().Format(timeFormat), ev.At().Sub(req.Start), renderLabels(ev)))
+		}
+		s.nextID++
+		req.ID = s.nextID
+		s.captured = append(s.captured, req)
+		if len(s.captured) > maxSlowRequests {
+			s.captured[0] = nil // aid GC
+			s.captured = s.captured[1:]
+		}
+	}
+	return ctx
+}
+
+// sample captures the goroutines of the running request, and schedules
+// the next sample.
+func (s *slowRequests) sample(req *slowRequest, interval time.Duration) {
+	at := time.Now()
+	var profile bytes.Buffer
+	if err := pprof.Lookup("goroutine").WriteTo(&profile, 0); err != nil {
+		return
+	}
+	var text bytes.Buffer
+	if err := pprof.Lookup("goroutine").WriteTo(&text, 1); err != nil {
+		return
+	}
+	stacks := filterGoroutines(text.String(), req.span.ID.SpanID.String())
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if req.done {
+		return
+	}
+	req.Samples = append(req.Samples, goroutineSample{
+		Offset:  at.Sub(req.Start).Round(time.Millisecond),
+		Stacks:  stacks,
+		Profile: profile.Bytes(),
+	})
+	if len(req.Samples) < maxSlowSamples {
+		req.timer.Reset(interval)
+	}
+}
+
+// filterGoroutines returns the records of a goroutine profile in the
+// text format of pprof (debug=1) whose labels include the given span.
+func filterGoroutines(profile, spanID string) string {
+	want := fmt.Sprintf("%q:%q", spanLabel, spanID)
+	var b strings.Builder
+	// Records are separated by blank lines; the first holds the header.
+	for _, record := range strings.Split(profile, "\n\n") {
+		for _, line := range strings.Split(record, "\n") {
+			if strings.HasPrefix(line, "# labels: ") && strings.Contains(line, want) {
+				b.WriteString(record)
+				b.WriteString("\n\n")
+				break
+			}
+		}
+	}
+	return b.String()
+}
+
+// getData returns the SlowResults rendered by SlowTmpl for the /slow[/id] endpoint.
+func (s *slowRequests) getData(req *http.Request) interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	results := SlowResults{Threshold: s.threshold, Max: maxSlowRequests}
+	for i := len(s.captured) - 1; i >= 0; i-- {
+		results.Requests = append(results.Requests, s.captured[i])
+	}
+	results.Selected = s.find(strings.TrimPrefix(req.URL.Path, "/slow/"))
+	return results
+}
+
+// find returns the captured request with the given ID, or nil.
+func (s *slowRequests) find(id string) *slowRequest {
+	n, err := strconv.Atoi(id)
+	if err != nil {
+		return nil
+	}
+	for _, r := range s.captured {
+		if r.ID == n {
+			return r
+		}
+	}
+	return nil
+}
+
+// serveBundle serves a zip archive of a captured request, for the
+// /slow/<id>/bundle.zip endpoint. The archive holds a summary of the
+// request, its goroutines at each sample, and the corresponding
+// goroutine profiles, which may be examined with, for example:
+//
+//	go tool pprof -tagfocus=lsp.span=<span> goroutine-1.pb.gz
+func (s *slowRequests) serveBundle(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/slow/"), "/bundle.zip")
+	s.mu.Lock()
+	req := s.find(id)
+	s.mu.Unlock()
+	if req == nil {
+		http.NotFound(w, r)
+		return
+	}
+	var buf bytes.Buffer
+	if err := req.writeBundle(&buf); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=gopls-slow-%s.zip", id))
+	w.Write(buf.Bytes())
+}
+
+// writeBundle writes the zip archive of a captured request to buf. The
+// request is complete, so its fields are immutable.
+func (req *slowRequest) writeBundle(buf *bytes.Buffer) error {
+	zw := zip.NewWriter(buf)
+	add := func(name string, data []byte) error {
+		w, err := zw.Create(name)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	}
+	var summary bytes.Buffer
+	fmt.Fprintf(&summary, "method: %s\n", req.Method)
+	fmt.Fprintf(&summary, "id: %s\n", req.RPCID)
+	fmt.Fprintf(&summary, "span: %s\n", req.span.ID.SpanID)
+	fmt.Fprintf(&summary, "start: %s\n", req.Start.Format(time.RFC3339Nano))
+	fmt.Fprintf(&summary, "duration: %v\n", req.Duration)
+	fmt.Fprintf(&summary, "labels: %s\n", req.Tags)
+	for _, ev := range req.Events {
+		fmt.Fprintf(&summary, "event: %s\n", ev)
+	}
+	if err := add("request.txt", summary.Bytes()); err != nil {
+		return err
+	}
+	for i, sample := range req.Samples {
+		header := fmt.Sprintf("goroutines of the request after %v\n\n", sample.Offset)
+		if err := add(fmt.Sprintf("stacks-%d.txt", i+1), []byte(header+sample.Stacks)); err != nil {
+			return err
+		}
+		if err := add(fmt.Sprintf("goroutine-%d.pb.gz", i+1), sample.Profile); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
@@ -0,0 +1,114 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package debug
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"io"
+	"net/http/httptest"
+	"runtime/pprof"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/tools/pkg/event"
+	"golang.org/x/tools/pkg/event/export"
+	"golang.org/x/tools/pkg/event/tag"
+)
+
+func TestSlowRequests(t *testing.T) {
+	ctx := WithInstance(context.Background(), "", "off")
+	i := GetInstance(ctx)
+
+	// request simulates an inbound LSP request, whose handler calls
+	// work with a function reporting whether the request was sampled.
+	request := func(id string, work func(sampled func() bool)) {
+		ctx, done := event.Start(ctx, "textDocument/hover",
+			tag.Method.Of("textDocument/hover"),
+			tag.RPCDirection.Of(tag.Inbound),
+			tag.RPCID.Of(id))
+		defer done()
+		span := export.GetSpan(ctx)
+		pprof.Do(ctx, RequestLabels(ctx, "textDocument/hover"), func(ctx context.Context) {
+			work(func() bool {
+				i.slow.mu.Lock()
+				defer i.slow.mu.Unlock()
+				req := i.slow.running[span.ID]
+				return req != nil && len(req.Samples) > 0
+			})
+		})
+	}
+
+	// A fast request is not captured.
+	i.SetSlowRequestThreshold(time.Hour)
+	request(`"1"`, func(func() bool) {})
+
+	// A slow request is captured, including the goroutines it starts.
+	i.SetSlowRequestThreshold(time.Millisecond)
+	request(`"2"`, func(sampled func() bool) {
+		started, release := make(chan struct{}), make(chan struct{})
+		go blockedHelper(started, release)
+		<-started
+		for !sampled() {
+			time.Sleep(time.Millisecond)
+		}
+		close(release)
+	})
+
+	data := i.slow.getData(httptest.NewRequest("GET", "/slow/1", nil)).(SlowResults)
+	if len(data.Requests) != 1 {
+		t.Fatalf("captured %d requests, want 1", len(data.Requests))
+	}
+	req := data.Selected
+	if req == nil || req.RPCID != `"2"` || req.Method != "textDocument/hover" {
+		t.Fatalf("Selected = %+v, want request 2", req)
+	}
+	if req.Duration <= 0 {
+		t.Errorf("Duration = %v, want > 0", req.Duration)
+	}
+	stacks := req.Samples[0].Stacks
+	for _, want := range []string{"TestSlowRequests", "blockedHelper", `"lsp.method":"textDocument/hover"`} {
+		if !strings.Contains(stacks, want) {
+			t.Errorf("sampled stacks do not contain %q:\n%s", want, stacks)
+		}
+	}
+	if strings.Contains(stacks, "testing.(*T).Run") {
+		t.Errorf("sampled stacks include unlabeled goroutines:\n%s", stacks)
+	}
+
+	// The bundle holds the summary, stacks and profiles.
+	rec := httptest.NewRecorder()
+	i.slow.serveBundle(rec, httptest.NewRequest("GET", "/slow/1/bundle.zip", nil))
+	body := rec.Body.Bytes()
+	zr, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+		if f.Name == "request.txt" {
+			r, err := f.Open()
+			if err != nil {
+				t.Fatal(err)
+			}
+			summary, _ := io.ReadAll(r)
+			if !strings.Contains(string(summary), "method: textDocument/hover\n") {
+				t.Errorf("request.txt lacks the method:\n%s", summary)
+			}
+		}
+	}
+	if want := 1 + 2*len(req.Samples); len(names) != want || names[0] != "request.txt" {
+		t.Errorf("bundle files = %v, want request.txt and %d samples", names, len(req.Samples))
+	}
+}
+
+//go:noinline
+func blockedHelper(started, release chan struct{}) {
+	close(started)
+	<-release
+}
@@ -34,6 +34,7 @@ var templates = map[string]struct {
 	"DebugTmpl":    {debug.DebugTmpl, nil},
 	"RPCTmpl":      {debug.RPCTmpl, &debug.Rpcs{}},
 	"TraceTmpl":    {debug.TraceTmpl, debug.TraceResults{}},
+	"SlowTmpl":     {debug.SlowTmpl, debug.SlowResults{}},
 	"CacheTmpl":    {debug.CacheTmpl, &cache.Cache{}},
 	"SessionTmpl":  {debug.SessionTmpl, &cache.Session{}},
 	"ViewTmpl":     {debug.ViewTmpl, &cache.View{}},
@@ -9,6 +9,7 @@ import (
 	"fmt"
 
 	"golang.org/x/tools/gopls/pkg/file"
+	"golang.org/x/tools/gopls/pkg/lsp/mod"
 	"golang.org/x/tools/gopls/pkg/lsp/protocol"
 	"golang.org/x/tools/gopls/pkg/lsp/source"
 	"golang.org/x/tools/gopls/pkg/lsp/template"
@@ -25,6 +26,7 @@ func (s *server) Definition(ctx context.Context, params *protocol.DefinitionPara
 
 	ctx, done := event.Start(ctx, "lsp.Server.definition", tag.URI.Of(params.TextDocument.URI))
 	defer done()
+	defer s.beginInteractive()()
 
 	// TODO(rfindley): definition requests should be multiplexed across all views.
 	snapshot, fh, ok, release, err := s.beginFileRequest(ctx, params.TextDocument.URI, file.UnknownKind)
@@ -34,9 +36,11 @@ func (s *server) Definition(ctx context.Context, params *protocol.DefinitionPara
 	}
 	switch kind := snapshot.FileKind(fh); kind {
 	case file.Tmpl:
-		return template.Definition(snapshot, fh, params.Position)
+		return template.Definition(ctx, snapshot, fh, params.Position)
 	case file.Go:
 		return source.Definition(ctx, snapshot, fh, params.Position)
+	case file.Mod:
+		return mod.Definition(ctx, snapshot, fh, params.Position)
 	default:
 		return nil, fmt.Errorf("can't find definitions for file type %s", kind)
 	}
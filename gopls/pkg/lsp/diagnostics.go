@@ -50,6 +50,8 @@ const (
 	workSource
 	modCheckUpgradesSource
 	modVulncheckSource // source.Govulncheck + source.Vulncheck
+	vetToolSource
+	embeddedLanguageSource
 )
 
 // A diagnosticReport holds results for a single diagnostic source.
@@ -112,6 +114,10 @@ func (d diagnosticSource) String() string {
 		return "FromCheckForUpgrades"
 	case modVulncheckSource:
 		return "FromModVulncheck"
+	case vetToolSource:
+		return "FromVetTool"
+	case embeddedLanguageSource:
+		return "FromEmbeddedLanguage"
 	default:
 		return fmt.Sprintf("From?%d?", d)
 	}
@@ -226,6 +232,14 @@ func (s *server) diagnoseSnapshot(snapshot *cache.Snapshot, changedURIs []protoc
 	}
 
 	s.diagnose(ctx, snapshot, analyzeOpenPackages)
+	if snapshot.Options().AnalyzeWorkspace {
+		// Publish diagnostics for open files before analyzing the rest of the
+		// workspace. The final publication below follows the background
+		// analysis, so that existing diagnostics for closed files do not
+		// flicker.
+		s.publishDiagnostics(ctx, false, snapshot)
+		s.analyzeWorkspace(ctx, snapshot)
+	}
 	s.publishDiagnostics(ctx, true, snapshot)
 }
 
@@ -364,6 +378,13 @@ func (s *server) diagnose(ctx context.Context, snapshot *cache.Snapshot, analyze
 	// Diagnose template (.tmpl) files.
 	for _, f := range snapshot.Templates() {
 		diags := template.Diagnose(f)
+		if len(diags) == 0 {
+			typeErrs, err := template.TypeErrors(ctx, snapshot, f)
+			if err != nil {
+				event.Error(ctx, "checking template types", err, tag.URI.Of(f.URI()))
+			}
+			diags = typeErrs
+		}
 		s.storeDiagnostics(snapshot, f.URI(), typeCheckSource, diags, true)
 	}
 
@@ -496,6 +517,48 @@ func (s *server) diagnosePkgs(ctx context.Context, snapshot *cache.Snapshot, toD
 		}
 	}()
 
+	// Run the external vet tool, if any, on the same packages.
+	if snapshot.Options().VetTool != "" && len(toAnalyze) > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var ids []source.PackageID
+			for id := range toAnalyze {
+				ids = append(ids, id)
+			}
+			diags, err := snapshot.VetToolDiagnostics(ctx, ids...)
+			if err != nil {
+				event.Error(ctx, "warning: running vet tool", err, snapshot.Labels()...)
+			}
+			for uri, diags := range diags {
+				s.storeDiagnostics(snapshot, uri, vetToolSource, diags, true)
+			}
+		}()
+	}
+
+	// Check the regular expressions and SQL embedded in string literals.
+	if len(snapshot.Options().EmbeddedLanguages) > 0 && len(toDiagnose) > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var ids []source.PackageID
+			for id := range toDiagnose {
+				ids = append(ids, id)
+			}
+			diags, err := source.EmbeddedLanguageDiagnostics(ctx, snapshot, ids...)
+			if err != nil {
+				event.Error(ctx, "warning: embedded language diagnostics", err, snapshot.Labels()...)
+				return
+			}
+			// Store empty diagnostics for every file, to clear fixed errors.
+			for _, m := range toDiagnose {
+				for _, uri := range m.CompiledGoFiles {
+					s.storeDiagnostics(snapshot, uri, embeddedLanguageSource, diags[uri], true)
+				}
+			}
+		}()
+	}
+
 	wg.Wait()
 
 	// TODO(rfindley): remove the guards against snapshot.IsBuiltin, after the
@@ -28,6 +28,7 @@ type ClientHooks struct {
 	OnRegisterCapability     func(context.Context, *protocol.RegistrationParams) error
 	OnUnregisterCapability   func(context.Context, *protocol.UnregistrationParams) error
 	OnApplyEdit              func(context.Context, *protocol.ApplyWorkspaceEditParams) error
+	OnCodeLensRefresh        func(context.Context) error
 }
 
 // Client is an adapter that converts an *Editor into an LSP Client. It mostly
@@ -38,7 +39,12 @@ type Client struct {
 	skipApplyEdits bool // don't apply edits from ApplyEdit downcalls to Editor
 }
 
-func (c *Client) CodeLensRefresh(context.Context) error { return nil }
+func (c *Client) CodeLensRefresh(ctx context.Context) error {
+	if c.hooks.OnCodeLensRefresh != nil {
+		return c.hooks.OnCodeLensRefresh(ctx)
+	}
+	return nil
+}
 
 func (c *Client) InlayHintRefresh(context.Context) error { return nil }
 
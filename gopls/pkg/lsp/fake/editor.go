@@ -283,6 +283,8 @@ func (e *Editor) initialize(ctx context.Context) error {
 	params.Capabilities.TextDocument.DocumentSymbol.HierarchicalDocumentSymbolSupport = true
 	// Glob pattern watching is enabled.
 	params.Capabilities.Workspace.DidChangeWatchedFiles.DynamicRegistration = true
+	// Code lenses are re-fetched on request.
+	params.Capabilities.Workspace.CodeLens = &protocol.CodeLensWorkspaceClientCapabilities{RefreshSupport: true}
 	// "rename" operations are used for package renaming.
 	//
 	// TODO(rfindley): add support for other resource operations (create, delete, ...)
@@ -1094,6 +1096,14 @@ func (e *Editor) CodeLens(ctx context.Context, path string) ([]protocol.CodeLens
 	return lens, nil
 }
 
+// ResolveCodeLens executes a codeLens/resolve request on the server.
+func (e *Editor) ResolveCodeLens(ctx context.Context, lens protocol.CodeLens) (*protocol.CodeLens, error) {
+	if e.Server == nil {
+		return nil, nil
+	}
+	return e.Server.ResolveCodeLens(ctx, &lens)
+}
+
 // Completion executes a completion request on the server.
 func (e *Editor) Completion(ctx context.Context, loc protocol.Location) (*protocol.CompletionList, error) {
 	if e.Server == nil {
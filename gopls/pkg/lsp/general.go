@@ -149,7 +149,7 @@ See https://github.com/golang/go/issues/45732 for more information.`,
 		Capabilities: protocol.ServerCapabilities{
 			CallHierarchyProvider: &protocol.Or_ServerCapabilities_callHierarchyProvider{Value: true},
 			CodeActionProvider:    codeActionProvider,
-			CodeLensProvider:      &protocol.CodeLensOptions{}, // must be non-nil to enable the code lens capability
+			CodeLensProvider:      &protocol.CodeLensOptions{ResolveProvider: true}, // must be non-nil to enable the code lens capability
 			CompletionProvider: &protocol.CompletionOptions{
 				TriggerCharacters: []string{"."},
 			},
@@ -465,6 +465,24 @@ func (s *server) SetOptions(opts *settings.Options) {
 	s.optionsMu.Lock()
 	defer s.optionsMu.Unlock()
 	s.options = opts
+	s.setTelemetryHistoryLocked(opts.TelemetryHistoryFile)
+}
+
+// setTelemetryHistoryLocked starts to append snapshots of the telemetry
+// counters to the file, or stops if file is empty. The caller must hold
+// optionsMu.
+func (s *server) setTelemetryHistoryLocked(file string) {
+	if file == s.telemetryHistoryFile {
+		return
+	}
+	if s.stopTelemetryHistory != nil {
+		s.stopTelemetryHistory()
+		s.stopTelemetryHistory = nil
+	}
+	s.telemetryHistoryFile = file
+	if file != "" {
+		s.stopTelemetryHistory = telemetry.WriteHistory(file, debug.Version())
+	}
 }
 
 func (s *server) fetchFolderOptions(ctx context.Context, folder protocol.DocumentURI) (*settings.Options, error) {
@@ -592,6 +610,9 @@ func (s *server) Shutdown(ctx context.Context) error {
 		// drop all the active views
 		s.session.Shutdown(ctx)
 		s.state = serverShutDown
+		s.optionsMu.Lock()
+		s.setTelemetryHistoryLocked("")
+		s.optionsMu.Unlock()
 		if s.tempDir != "" {
 			if err := os.RemoveAll(s.tempDir); err != nil {
 				event.Error(ctx, "removing temp dir", err)
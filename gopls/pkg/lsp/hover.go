@@ -26,6 +26,7 @@ func (s *server) Hover(ctx context.Context, params *protocol.HoverParams) (_ *pr
 
 	ctx, done := event.Start(ctx, "lsp.Server.hover", tag.URI.Of(params.TextDocument.URI))
 	defer done()
+	defer s.beginInteractive()()
 
 	snapshot, fh, ok, release, err := s.beginFileRequest(ctx, params.TextDocument.URI, file.UnknownKind)
 	defer release()
@@ -28,7 +28,20 @@ func (s *server) InlayHint(ctx context.Context, params *protocol.InlayHintParams
 	case file.Mod:
 		return mod.InlayHint(ctx, snapshot, fh, params.Range)
 	case file.Go:
-		return source.InlayHint(ctx, snapshot, fh, params.Range)
+		hints, err := source.InlayHint(ctx, snapshot, fh, params.Range)
+		if err != nil {
+			return nil, err
+		}
+		if snapshot.Options().Hints[source.HeapAllocations] {
+			escapes, err := snapshot.HeapEscapes(ctx, fh.URI())
+			if err != nil {
+				// The package may fail to build; the other hints are
+				// still useful.
+				event.Error(ctx, "computing heap allocation hints", err, tag.URI.Of(fh.URI()))
+			}
+			hints = append(hints, source.HeapAllocationHints(escapes, params.Range)...)
+		}
+		return hints, nil
 	}
 	return nil, nil
 }
@@ -13,6 +13,7 @@ import (
 	"log"
 	"net"
 	"os"
+	"runtime/pprof"
 	"strconv"
 	"strings"
 	"sync"
@@ -43,6 +44,9 @@ type StreamServer struct {
 	// optionsOverrides is passed to newly created sessions.
 	optionsOverrides func(*settings.Options)
 
+	// tenancy, if set, authenticates clients and isolates their sessions.
+	tenancy *Tenancy
+
 	// serverForTest may be set to a test fake for testing.
 	serverForTest protocol.Server
 }
@@ -54,6 +58,12 @@ func NewStreamServer(cache *cache.Cache, daemon bool, optionsFunc func(*settings
 	return &StreamServer{cache: cache, daemon: daemon, optionsOverrides: optionsFunc}
 }
 
+// SetTenancy causes the server to authenticate its clients, and to
+// isolate and limit the sessions of each tenant, according to t.
+func (s *StreamServer) SetTenancy(t *Tenancy) {
+	s.tenancy = t
+}
+
 func (s *StreamServer) Binder() *ServerBinder {
 	newServer := func(ctx context.Context, client protocol.ClientCloser) protocol.Server {
 		session := cache.NewSession(ctx, s.cache)
@@ -77,8 +87,12 @@ func (s *StreamServer) ServeStream(ctx context.Context, conn jsonrpc2.Conn) erro
 	session := cache.NewSession(ctx, s.cache)
 	server := s.serverForTest
 	if server == nil {
+		serverClient := client
+		if s.tenancy != nil {
+			serverClient = s.tenancy.client(session, client)
+		}
 		options := settings.DefaultOptions(s.optionsOverrides)
-		server = lsp.NewServer(session, client, options)
+		server = lsp.NewServer(session, serverClient, options)
 		if instance := debug.GetInstance(ctx); instance != nil {
 			instance.AddService(server, session)
 		}
@@ -97,11 +111,16 @@ func (s *StreamServer) ServeStream(ctx context.Context, conn jsonrpc2.Conn) erro
 		executable = ""
 	}
 	ctx = protocol.WithClient(ctx, client)
+	handler := handshaker(session, executable, s.daemon, s.tenancy,
+		protocol.ServerHandler(server,
+			jsonrpc2.MethodNotFound))
+	if s.tenancy != nil {
+		handler = s.tenancy.handler(session, conn, client, handler)
+		defer s.tenancy.unregister(session)
+	}
 	conn.Go(ctx,
 		protocol.Handlers(
-			handshaker(session, executable, s.daemon,
-				protocol.ServerHandler(server,
-					jsonrpc2.MethodNotFound))))
+			labelRequests(handler)))
 	if s.daemon {
 		log.Printf("Session %s: connected", session.ID())
 		defer log.Printf("Session %s: exited", session.ID())
@@ -124,6 +143,12 @@ type Forwarder struct {
 	// information changes.
 	serverConn jsonrpc2.Conn
 	serverID   string
+
+	// token, if set, authenticates the forwarder with the remote.
+	token string
+
+	// recorder, if set, records the forwarded session.
+	recorder *Recorder
 }
 
 // NewForwarder creates a new Forwarder, ready to forward connections to the
@@ -141,9 +166,28 @@ func NewForwarder(rawAddr string, argFunc func(network, address string) []string
 	return fwd, nil
 }
 
-// QueryServerState queries the server state of the current server.
-func QueryServerState(ctx context.Context, addr string) (*ServerState, error) {
-	serverConn, err := dialRemote(ctx, addr)
+// SetRecorder causes the forwarder to record the messages that it
+// forwards, and the workspace files, using rec. A recorder records a
+// single session, so it must not be set on a forwarder that serves
+// more than one.
+func (f *Forwarder) SetRecorder(rec *Recorder) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.recorder = rec
+}
+
+// SetToken causes the forwarder to authenticate with the remote using
+// token, which must then be accepted for the forwarding to proceed.
+func (f *Forwarder) SetToken(token string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.token = token
+}
+
+// QueryServerState queries the server state of the current server,
+// authenticating with token, if set.
+func QueryServerState(ctx context.Context, addr, token string) (*ServerState, error) {
+	serverConn, err := dialRemote(ctx, addr, token)
 	if err != nil {
 		return nil, err
 	}
@@ -154,10 +198,25 @@ func QueryServerState(ctx context.Context, addr string) (*ServerState, error) {
 	return &state, nil
 }
 
+// EvictSessions closes the sessions with the given IDs on the server,
+// authenticating with token, if set. Unless the token is that of an
+// administrator of the server, the sessions must belong to its tenant.
+func EvictSessions(ctx context.Context, addr, token string, ids []string) ([]string, error) {
+	serverConn, err := dialRemote(ctx, addr, token)
+	if err != nil {
+		return nil, err
+	}
+	var resp evictResponse
+	if err := protocol.Call(ctx, serverConn, evictMethod, evictRequest{SessionIDs: ids}, &resp); err != nil {
+		return nil, fmt.Errorf("evicting sessions: %w", err)
+	}
+	return resp.Evicted, nil
+}
+
 // dialRemote is used for making calls into the gopls daemon. addr should be a
 // URL, possibly on the synthetic 'auto' network (e.g. tcp://..., unix://...,
-// or auto://...).
-func dialRemote(ctx context.Context, addr string) (jsonrpc2.Conn, error) {
+// or auto://...). If token is set, it is used to authenticate.
+func dialRemote(ctx context.Context, addr, token string) (jsonrpc2.Conn, error) {
 	network, address := ParseAddr(addr)
 	if network == AutoNetwork {
 		gp, err := os.Executable()
@@ -172,11 +231,15 @@ func dialRemote(ctx context.Context, addr string) (jsonrpc2.Conn, error) {
 	}
 	serverConn := jsonrpc2.NewConn(jsonrpc2.NewHeaderStream(netConn))
 	serverConn.Go(ctx, jsonrpc2.MethodNotFound)
+	if err := Authenticate(ctx, serverConn, token); err != nil {
+		serverConn.Close()
+		return nil, fmt.Errorf("authenticating with remote: %w", err)
+	}
 	return serverConn, nil
 }
 
-func ExecuteCommand(ctx context.Context, addr string, id string, request, result interface{}) error {
-	serverConn, err := dialRemote(ctx, addr)
+func ExecuteCommand(ctx context.Context, addr, token string, id string, request, result interface{}) error {
+	serverConn, err := dialRemote(ctx, addr, token)
 	if err != nil {
 		return err
 	}
@@ -200,7 +263,13 @@ func (f *Forwarder) ServeStream(ctx context.Context, clientConn jsonrpc2.Conn) e
 	if err != nil {
 		return fmt.Errorf("forwarder: connecting to remote: %w", err)
 	}
-	serverConn := jsonrpc2.NewConn(jsonrpc2.NewHeaderStream(netConn))
+	serverStream := jsonrpc2.NewHeaderStream(netConn)
+	f.mu.Lock()
+	if f.recorder != nil {
+		serverStream = f.recorder.ServerStream(serverStream)
+	}
+	f.mu.Unlock()
+	serverConn := jsonrpc2.NewConn(serverStream)
 	server := protocol.ServerDispatcher(serverConn)
 
 	// Forward between connections.
@@ -218,12 +287,17 @@ func (f *Forwarder) ServeStream(ctx context.Context, clientConn jsonrpc2.Conn) e
 	f.serverConn = serverConn
 	f.serverID = strconv.FormatInt(index, 10)
 	f.mu.Unlock()
-	f.handshake(ctx)
+	if err := f.handshake(ctx); err != nil {
+		serverConn.Close()
+		clientConn.Close()
+		return err
+	}
 	clientConn.Go(ctx,
 		protocol.Handlers(
-			f.handler(
-				protocol.ServerHandler(server,
-					jsonrpc2.MethodNotFound))))
+			labelRequests(
+				f.handler(
+					protocol.ServerHandler(server,
+						jsonrpc2.MethodNotFound)))))
 
 	select {
 	case <-serverConn.Done():
@@ -243,7 +317,10 @@ func (f *Forwarder) ServeStream(ctx context.Context, clientConn jsonrpc2.Conn) e
 }
 
 // TODO(rfindley): remove this handshaking in favor of middleware.
-func (f *Forwarder) handshake(ctx context.Context) {
+//
+// The handshake fails only if the forwarder authenticates with a token,
+// which the remote requires.
+func (f *Forwarder) handshake(ctx context.Context) error {
 	// This call to os.Executable is redundant, and will be eliminated by the
 	// transition to the V2 API.
 	goplsPath, err := os.Executable()
@@ -251,18 +328,24 @@ func (f *Forwarder) handshake(ctx context.Context) {
 		event.Error(ctx, "getting executable for handshake", err)
 		goplsPath = ""
 	}
+	f.mu.Lock()
 	var (
 		hreq = handshakeRequest{
 			ServerID:  f.serverID,
 			GoplsPath: goplsPath,
+			Token:     f.token,
 		}
 		hresp handshakeResponse
 	)
+	f.mu.Unlock()
 	if di := debug.GetInstance(ctx); di != nil {
 		hreq.Logfile = di.Logfile
 		hreq.DebugAddr = di.ListenedDebugAddress()
 	}
 	if err := protocol.Call(ctx, f.serverConn, handshakeMethod, hreq, &hresp); err != nil {
+		if hreq.Token != "" {
+			return fmt.Errorf("forwarder: authenticating with remote: %w", err)
+		}
 		// TODO(rfindley): at some point in the future we should return an error
 		// here.  Handshakes have become functional in nature.
 		event.Error(ctx, "forwarder: gopls handshake failed", err)
@@ -277,6 +360,7 @@ func (f *Forwarder) handshake(ctx context.Context) {
 		tag.GoplsPath.Of(hresp.GoplsPath),
 		tag.ClientID.Of(hresp.SessionID),
 	)
+	return nil
 }
 
 func ConnectToRemote(ctx context.Context, addr string) (net.Conn, error) {
@@ -408,7 +492,11 @@ func (f *Forwarder) replyWithDebugAddress(outerCtx context.Context, r jsonrpc2.R
 		}
 		urls := []string{"http://" + addr}
 		modified.URLs = append(urls, modified.URLs...)
-		go f.handshake(ctx)
+		go func() {
+			if err := f.handshake(ctx); err != nil {
+				event.Error(ctx, "", err)
+			}
+		}()
 		return r(ctx, modified, nil)
 	}
 }
@@ -424,6 +512,8 @@ type handshakeRequest struct {
 	// GoplsPath is the path to the Gopls binary running the current client
 	// process.
 	GoplsPath string `json:"goplsPath"`
+	// Token authenticates the client, if the server requires it.
+	Token string `json:"token,omitempty"`
 }
 
 // A handshakeResponse is returned by the LSP server to tell the LSP client
@@ -447,6 +537,9 @@ type ClientSession struct {
 	SessionID string `json:"sessionID"`
 	Logfile   string `json:"logfile"`
 	DebugAddr string `json:"debugAddr"`
+	// Tenant is the name of the tenant of the session, if the server
+	// authenticates its clients.
+	Tenant string `json:"tenant,omitempty"`
 }
 
 // ServerState holds information about the gopls daemon process, including its
@@ -463,9 +556,27 @@ type ServerState struct {
 const (
 	handshakeMethod = "gopls/handshake"
 	sessionsMethod  = "gopls/sessions"
+	evictMethod     = "gopls/evict"
 )
 
-func handshaker(session *cache.Session, goplsPath string, logHandshakes bool, handler jsonrpc2.Handler) jsonrpc2.Handler {
+// labelRequests returns a handler that applies the pprof labels of
+// debug.RequestLabels to the goroutine handling each request, and so to
+// the goroutines it starts, allowing the debug server to capture the
+// goroutines of slow requests.
+func labelRequests(handler jsonrpc2.Handler) jsonrpc2.Handler {
+	return func(ctx context.Context, reply jsonrpc2.Replier, r jsonrpc2.Request) error {
+		var err error
+		pprof.Do(ctx, debug.RequestLabels(ctx, r.Method()), func(ctx context.Context) {
+			err = handler(ctx, reply, r)
+		})
+		return err
+	}
+}
+
+// handshaker handles the gopls/handshake, gopls/sessions and gopls/evict
+// requests. If tenancy is set, the sessions listed or evicted are only
+// those visible to the tenant of the client.
+func handshaker(session *cache.Session, goplsPath string, logHandshakes bool, tenancy *Tenancy, handler jsonrpc2.Handler) jsonrpc2.Handler {
 	return func(ctx context.Context, reply jsonrpc2.Replier, r jsonrpc2.Request) error {
 		switch r.Method() {
 		case handshakeMethod:
@@ -509,14 +620,37 @@ func handshaker(session *cache.Session, goplsPath string, logHandshakes bool, ha
 				resp.Logfile = di.Logfile
 				resp.DebugAddr = di.ListenedDebugAddress()
 				for _, c := range di.State.Clients() {
-					resp.Clients = append(resp.Clients, ClientSession{
+					cs := ClientSession{
 						SessionID: c.Session.ID(),
 						Logfile:   c.Logfile,
 						DebugAddr: c.DebugAddress,
-					})
+					}
+					if tenancy != nil {
+						visible, name := tenancy.visible(ctx, c.Session)
+						if !visible {
+							continue
+						}
+						cs.Tenant = name
+					}
+					resp.Clients = append(resp.Clients, cs)
 				}
 			}
 			return reply(ctx, resp, nil)
+
+		case evictMethod:
+			if tenancy == nil {
+				return reply(ctx, nil, fmt.Errorf("%s requires a daemon", evictMethod))
+			}
+			var req evictRequest
+			if err := json.Unmarshal(r.Params(), &req); err != nil {
+				sendError(ctx, reply, err)
+				return nil
+			}
+			resp, err := tenancy.evict(ctx, req.SessionIDs)
+			if err != nil {
+				return reply(ctx, nil, err)
+			}
+			return reply(ctx, resp, nil)
 		}
 		return handler(ctx, reply, r)
 	}
@@ -0,0 +1,378 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package lsprpc
+
+// This file defines the recording of LSP sessions, for replay by the
+// regtest package.
+//
+// A recording is a txtar archive. Its comment holds the root URI of
+// the recorded workspace. Its files are the contents of the Go files
+// of the workspace when the session was initialized, named
+// "files/<path relative to the root>", followed by the JSON-RPC
+// messages of the session, one per line, in a final file named
+// "rpc.jsonl". Messages are appended to the archive as they are sent,
+// so a recording is complete even if gopls does not exit cleanly.
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/tools/gopls/pkg/lsp/protocol"
+	"golang.org/x/tools/pkg/jsonrpc2"
+	"golang.org/x/tools/txtar"
+)
+
+const (
+	recordingHeader   = "gopls session recording"
+	recordingRoot     = "root: "
+	recordingFiles    = "files/"
+	recordingMessages = "rpc.jsonl"
+
+	// maxRecordedBytes bounds the total size of the workspace files
+	// included in a recording.
+	maxRecordedBytes = 64 << 20
+)
+
+// A Recorder records the JSON-RPC messages of an LSP session, and the
+// workspace files they refer to, to a txtar archive that may be
+// replayed by the regtest package.
+//
+// A Recorder records a single session: see ClientStream and
+// ServerStream.
+type Recorder struct {
+	start time.Time
+
+	mu          sync.Mutex
+	file        *os.File
+	wroteHeader bool
+	pending     [][]byte             // messages preceding the header
+	handshakes  map[jsonrpc2.ID]bool // IDs of forwarder handshakes, which are not recorded
+	err         error                // first write error
+}
+
+// NewRecorder returns a Recorder that records to the named file,
+// which is created or truncated.
+func NewRecorder(filename string) (*Recorder, error) {
+	f, err := os.Create(filename)
+	if err != nil {
+		return nil, err
+	}
+	return &Recorder{
+		start:      time.Now(),
+		file:       f,
+		handshakes: make(map[jsonrpc2.ID]bool),
+	}, nil
+}
+
+// ClientStream returns a stream that records the messages exchanged
+// over the given stream, which is connected to the LSP client.
+func (r *Recorder) ClientStream(stream jsonrpc2.Stream) jsonrpc2.Stream {
+	return &recordingStream{stream: stream, recorder: r, readsFromClient: true}
+}
+
+// ServerStream returns a stream that records the messages exchanged
+// over the given stream, which is connected to the LSP server.
+func (r *Recorder) ServerStream(stream jsonrpc2.Stream) jsonrpc2.Stream {
+	return &recordingStream{stream: stream, recorder: r, readsFromClient: false}
+}
+
+// Close completes the recording, and closes its file.
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.wroteHeader {
+		r.writeHeader(nil)
+	}
+	if err := r.file.Close(); err != nil && r.err == nil {
+		r.err = err
+	}
+	return r.err
+}
+
+type recordingStream struct {
+	stream          jsonrpc2.Stream
+	recorder        *Recorder
+	readsFromClient bool
+}
+
+func (s *recordingStream) Read(ctx context.Context) (jsonrpc2.Message, int64, error) {
+	msg, count, err := s.stream.Read(ctx)
+	if err == nil {
+		s.recorder.record(s.readsFromClient, msg)
+	}
+	return msg, count, err
+}
+
+func (s *recordingStream) Write(ctx context.Context, msg jsonrpc2.Message) (int64, error) {
+	s.recorder.record(!s.readsFromClient, msg)
+	return s.stream.Write(ctx, msg)
+}
+
+func (s *recordingStream) Close() error {
+	return s.stream.Close()
+}
+
+// recordedMessageJSON is the form of each line of the messages of a
+// recording.
+type recordedMessageJSON struct {
+	From    string          `json:"from"`    // "client" or "server"
+	Elapsed int64           `json:"elapsed"` // microseconds since the start of the recording
+	Message json.RawMessage `json:"message"`
+}
+
+func (r *Recorder) record(fromClient bool, msg jsonrpc2.Message) {
+	elapsed := time.Since(r.start)
+
+	// Don't record the forwarder's handshake with the daemon, which
+	// is not part of the LSP session.
+	switch msg := msg.(type) {
+	case *jsonrpc2.Call:
+		if msg.Method() == handshakeMethod {
+			r.mu.Lock()
+			r.handshakes[msg.ID()] = true
+			r.mu.Unlock()
+			return
+		}
+	case *jsonrpc2.Response:
+		r.mu.Lock()
+		handshake := r.handshakes[msg.ID()]
+		delete(r.handshakes, msg.ID())
+		r.mu.Unlock()
+		if handshake {
+			return
+		}
+	}
+
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return // can't happen for messages that were sent or received
+	}
+	from := "server"
+	if fromClient {
+		from = "client"
+	}
+	line, err := json.Marshal(recordedMessageJSON{
+		From:    from,
+		Elapsed: elapsed.Microseconds(),
+		Message: data,
+	})
+	if err != nil {
+		return
+	}
+	line = append(line, '\n')
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.wroteHeader {
+		// The workspace files are recorded when the session is
+		// initialized, before they can be modified.
+		if call, ok := msg.(*jsonrpc2.Call); ok && fromClient && call.Method() == "initialize" {
+			r.writeHeader(call.Params())
+		} else {
+			r.pending = append(r.pending, line)
+			return
+		}
+	}
+	r.write(line)
+}
+
+// writeHeader writes the comment and the workspace files of the
+// recording, followed by any pending messages. The workspace is
+// determined by the params of the initialize request, if any.
+func (r *Recorder) writeHeader(initializeParams json.RawMessage) {
+	r.wroteHeader = true
+
+	var params protocol.ParamInitialize
+	if initializeParams != nil {
+		_ = json.Unmarshal(initializeParams, &params) // best effort
+	}
+	root := params.RootURI
+	if root == "" && len(params.WorkspaceFolders) > 0 {
+		root = protocol.DocumentURI(params.WorkspaceFolders[0].URI)
+	}
+
+	var comment bytes.Buffer
+	fmt.Fprintln(&comment, recordingHeader)
+	archive := &txtar.Archive{}
+	if root != "" {
+		fmt.Fprintf(&comment, "%s%s\n", recordingRoot, root)
+		files, skipped := workspaceFiles(root.Path())
+		archive.Files = files
+		for _, name := range skipped {
+			fmt.Fprintf(&comment, "skipped: %s\n", name)
+		}
+	}
+	archive.Comment = comment.Bytes()
+	r.write(txtar.Format(archive))
+	r.write([]byte("-- " + recordingMessages + " --\n"))
+	for _, line := range r.pending {
+		r.write(line)
+	}
+	r.pending = nil
+}
+
+func (r *Recorder) write(data []byte) {
+	if r.err != nil {
+		return
+	}
+	_, r.err = r.file.Write(data)
+}
+
+// workspaceFiles returns the Go source files, module files and
+// assembly files beneath root, skipping the directories that the go
+// command ignores, along with the names of the files that could not
+// be included in a recording.
+func workspaceFiles(root string) (files []txtar.File, skipped []string) {
+	total := 0
+	filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil // best effort
+		}
+		name := d.Name()
+		if d.IsDir() {
+			if path != root && (strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") || name == "testdata") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		switch filepath.Ext(name) {
+		case ".go", ".mod", ".sum", ".work", ".s":
+		default:
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			skipped = append(skipped, rel)
+			return nil
+		}
+		// A file containing a txtar file marker can't be archived.
+		if total+len(data) > maxRecordedBytes || hasFileMarker(data) {
+			skipped = append(skipped, rel)
+			return nil
+		}
+		total += len(data)
+		files = append(files, txtar.File{Name: recordingFiles + rel, Data: data})
+		return nil
+	})
+	sort.Strings(skipped)
+	return files, skipped
+}
+
+// hasFileMarker reports whether data contains a line that txtar would
+// interpret as a file marker.
+func hasFileMarker(data []byte) bool {
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		line = bytes.TrimSuffix(line, []byte("\r"))
+		if bytes.HasPrefix(line, []byte("-- ")) && bytes.HasSuffix(line, []byte(" --")) && len(line) > len("--  --") {
+			return true
+		}
+	}
+	return false
+}
+
+// A Recording is an LSP session recorded by a Recorder.
+type Recording struct {
+	// Root is the root URI of the recorded workspace, if known.
+	Root protocol.DocumentURI
+
+	// Files holds the contents of the workspace files when the
+	// session was initialized, keyed by slash-separated path relative
+	// to Root.
+	Files map[string][]byte
+
+	// Messages are the JSON-RPC messages of the session, in order.
+	Messages []RecordedMessage
+}
+
+// A RecordedMessage is a JSON-RPC message of a recorded session.
+type RecordedMessage struct {
+	FromClient bool
+	Elapsed    time.Duration // since the start of the recording
+	Message    jsonrpc2.Message
+}
+
+// ReadRecording parses a recording created by a Recorder.
+//
+// A final truncated message, as written by a gopls process that did
+// not exit cleanly, is ignored.
+func ReadRecording(data []byte) (*Recording, error) {
+	archive := txtar.Parse(data)
+	comment := string(archive.Comment)
+	if !strings.HasPrefix(comment, recordingHeader+"\n") {
+		return nil, fmt.Errorf("not a gopls session recording")
+	}
+	rec := &Recording{Files: make(map[string][]byte)}
+	for _, line := range strings.Split(comment, "\n") {
+		if strings.HasPrefix(line, recordingRoot) {
+			rec.Root = protocol.DocumentURI(strings.TrimPrefix(line, recordingRoot))
+		}
+	}
+	for _, f := range archive.Files {
+		if strings.HasPrefix(f.Name, recordingFiles) {
+			name := strings.TrimPrefix(f.Name, recordingFiles)
+			if !isLocalPath(name) {
+				return nil, fmt.Errorf("file %q of recording is outside the workspace", f.Name)
+			}
+			rec.Files[name] = f.Data
+			continue
+		}
+		if f.Name != recordingMessages {
+			return nil, fmt.Errorf("unexpected file %q in recording", f.Name)
+		}
+		lines := bytes.Split(f.Data, []byte("\n"))
+		for i, line := range lines {
+			if len(bytes.TrimSpace(line)) == 0 {
+				continue
+			}
+			var m recordedMessageJSON
+			if err := json.Unmarshal(line, &m); err != nil {
+				if i >= len(lines)-2 {
+					break // truncated final message
+				}
+				return nil, fmt.Errorf("message %d: %v", i+1, err)
+			}
+			msg, err := jsonrpc2.DecodeMessage(m.Message)
+			if err != nil {
+				return nil, fmt.Errorf("message %d: %v", i+1, err)
+			}
+			rec.Messages = append(rec.Messages, RecordedMessage{
+				FromClient: m.From == "client",
+				Elapsed:    time.Duration(m.Elapsed) * time.Microsecond,
+				Message:    msg,
+			})
+		}
+	}
+	return rec, nil
+}
+
+// isLocalPath reports whether the slash-separated path name is a relative
+// path within the directory it is relative to: it is not empty, has no
+// volume name, and has no ".." element (like filepath.IsLocal, which
+// requires Go 1.20, but on all systems).
+func isLocalPath(name string) bool {
+	if name == "" || strings.HasPrefix(name, "/") || strings.ContainsAny(name, `\:`) {
+		return false
+	}
+	for _, elem := range strings.Split(name, "/") {
+		if elem == ".." {
+			return false
+		}
+	}
+	return true
+}
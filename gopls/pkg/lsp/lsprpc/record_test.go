@@ -0,0 +1,137 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package lsprpc
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"golang.org/x/tools/gopls/pkg/lsp/cache"
+	"golang.org/x/tools/gopls/pkg/lsp/protocol"
+	"golang.org/x/tools/pkg/jsonrpc2"
+)
+
+type InitializeServer struct{ fakeServer }
+
+func (InitializeServer) Initialize(context.Context, *protocol.ParamInitialize) (*protocol.InitializeResult, error) {
+	return &protocol.InitializeResult{}, nil
+}
+
+func TestRecording(t *testing.T) {
+	ctx := context.Background()
+
+	workspace := t.TempDir()
+	for name, content := range map[string]string{
+		"go.mod":          "module example.com\n",
+		"a/a.go":          "package a\n",
+		"a/README.md":     "not Go\n",
+		"testdata/t.go":   "package t\n",
+		".hidden/h.go":    "package h\n",
+		"marker/m.go":     "package m\n-- file --\n",
+		"marker/other.go": "package m\n",
+	} {
+		filename := filepath.Join(workspace, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filename, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	recording := filepath.Join(t.TempDir(), "session.txtar")
+	recorder, err := NewRecorder(recording)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ss := NewStreamServer(cache.New(nil), false, nil)
+	ss.serverForTest = InitializeServer{}
+	sPipe, cPipe := net.Pipe()
+	serverConn := jsonrpc2.NewConn(recorder.ClientStream(jsonrpc2.NewRawStream(sPipe)))
+	served := make(chan struct{})
+	go func() {
+		defer close(served)
+		ss.ServeStream(ctx, serverConn)
+	}()
+	clientConn := jsonrpc2.NewConn(jsonrpc2.NewRawStream(cPipe))
+	clientConn.Go(ctx, jsonrpc2.MethodNotFound)
+	server := protocol.ServerDispatcher(clientConn)
+	if _, err := server.Initialize(ctx, &protocol.ParamInitialize{
+		XInitializeParams: protocol.XInitializeParams{RootURI: protocol.URIFromPath(workspace)},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	clientConn.Close()
+	<-served
+	if err := recorder.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(recording)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec, err := ReadRecording(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := protocol.URIFromPath(workspace); rec.Root != want {
+		t.Errorf("Root = %s, want %s", rec.Root, want)
+	}
+	var files []string
+	for name := range rec.Files {
+		files = append(files, name)
+	}
+	sort.Strings(files)
+	if want := []string{"a/a.go", "go.mod", "marker/other.go"}; !reflect.DeepEqual(files, want) {
+		t.Errorf("Files = %v, want %v", files, want)
+	}
+	var got []string
+	for _, m := range rec.Messages {
+		from := "server"
+		if m.FromClient {
+			from = "client"
+		}
+		switch msg := m.Message.(type) {
+		case *jsonrpc2.Call:
+			got = append(got, from+" "+msg.Method())
+		case *jsonrpc2.Response:
+			got = append(got, from+" response")
+		}
+	}
+	want := []string{"client initialize", "server response", "client shutdown", "server response"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Messages = %v, want %v", got, want)
+	}
+}
+
+func TestReadRecordingPaths(t *testing.T) {
+	for name, want := range map[string]bool{
+		"a.go":               true,
+		"a/b..c/d.go":        true,
+		"":                   false,
+		"../.bashrc":         false,
+		"a/../../.bashrc":    false,
+		"/etc/passwd":        false,
+		`..\.bashrc`:         false,
+		"C:/Windows/win.ini": false,
+		"a/..":               false,
+	} {
+		data := recordingHeader + "\n-- " + recordingFiles + name + " --\ncontent\n"
+		_, err := ReadRecording([]byte(data))
+		if got := err == nil; got != want {
+			t.Errorf("ReadRecording with file %q: got error %v, want ok=%t", name, err, want)
+		}
+	}
+}
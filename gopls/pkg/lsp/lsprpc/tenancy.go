@@ -0,0 +1,764 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package lsprpc
+
+// This file defines the tenancy of a gopls daemon that is shared by
+// several users: the authentication of its clients, who belong to
+// tenants, the isolation of the sessions of each tenant, and their
+// quotas.
+//
+// All tenants share the operating system user of the daemon, so the
+// isolation is partial: a tenant that is not an administrator is
+// confined to its root directories, may not run commands that execute
+// code, and may not choose the programs and flags of the go command,
+// but the go command may still read files outside its roots, such as
+// the targets of replace directives.
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/tools/gopls/pkg/lsp/cache"
+	"golang.org/x/tools/gopls/pkg/lsp/command"
+	"golang.org/x/tools/gopls/pkg/lsp/protocol"
+	"golang.org/x/tools/pkg/jsonrpc2"
+)
+
+// minTokenLength is the minimum length of a token, to discourage
+// guessable ones.
+const minTokenLength = 16
+
+// errUnauthorized is the error returned to a client of a daemon with
+// tokens that did not authenticate with a valid one.
+var errUnauthorized = jsonrpc2.NewError(-32002, "unauthorized: this gopls daemon requires a valid token (see -remote.tokenfile)")
+
+// A Tenancy holds the tenants of a gopls daemon, and their quotas.
+//
+// A Tenancy without tokens is open: every client belongs to a single
+// anonymous tenant, which administers the daemon. Otherwise each client
+// must authenticate with the token of its tenant, in a gopls/handshake
+// request, before any other request. A tenant can list and evict only
+// its own sessions, unless it administers the daemon.
+//
+// A tenant with root directories may name only files within them in its
+// requests, such as its workspace folders and documents. A tenant that does not administer the daemon
+// may not run the commands of adminCommands, and its settings are
+// restricted by restrictOptions.
+type Tenancy struct {
+	// MaxViews is the maximum number of views of the sessions of each
+	// tenant, or 0 for no limit.
+	MaxViews int
+
+	// MaxMemory is the maximum memory, in bytes, of the sessions of
+	// each tenant, or 0 for no limit. The memory of a tenant is
+	// estimated as its share, in proportion to its views, of the heap
+	// of the daemon. A tenant whose memory exceeds the limit can't
+	// add views.
+	MaxMemory uint64
+
+	tenants []*tenant // nil if open
+
+	mu       sync.Mutex
+	sessions map[*cache.Session]*tenantSession
+}
+
+// A tenant is a user of a daemon, identified by its token.
+type tenant struct {
+	name  string
+	token []byte
+	admin bool
+	roots []string // absolute directories, or nil for no restriction
+}
+
+// openTenant is the single tenant of an open Tenancy.
+var openTenant = &tenant{admin: true}
+
+// A tenantSession is a session of a tenant, on the given connection.
+type tenantSession struct {
+	tenant  *tenant
+	conn    jsonrpc2.Conn
+	client  protocol.Client
+	pending int // views reserved for folders being added
+}
+
+// heapInUse returns the heap of the daemon, in bytes. It is a variable
+// for testing.
+var heapInUse = func() uint64 {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	return m.HeapInuse
+}
+
+// ReadTenancy reads the tenants of a daemon from a file of tokens.
+//
+// Each line of the file holds the name of a tenant, and its token,
+// separated by spaces, optionally followed by the word "admin" for the
+// tenants that administer the daemon, and then by the absolute root
+// directories of the tenant, which are required unless it administers
+// the daemon. Blank lines, and lines starting with '#', are ignored.
+// For example:
+//
+//	# name  token                             [admin]  [root...]
+//	alice   8c3f0e8a6b2d4c1e9f7a5b3d1c0e2f4a           /home/alice
+//	ops     f1e2d3c4b5a697887766554433221100  admin
+func ReadTenancy(filename string) (*Tenancy, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	t, err := parseTenancy(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	return t, nil
+}
+
+func parseTenancy(data []byte) (*Tenancy, error) {
+	t := &Tenancy{}
+	names := make(map[string]bool)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for lineno := 1; scanner.Scan(); lineno++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("line %d: want <name> <token> [admin] [<root>...]", lineno)
+		}
+		name, token, rest := fields[0], fields[1], fields[2:]
+		admin := len(rest) > 0 && rest[0] == "admin"
+		if admin {
+			rest = rest[1:]
+		}
+		var roots []string
+		for _, root := range rest {
+			if !filepath.IsAbs(root) {
+				return nil, fmt.Errorf("line %d: root %q of %q is not an absolute directory", lineno, root, name)
+			}
+			roots = append(roots, filepath.Clean(root))
+		}
+		if !admin && roots == nil {
+			return nil, fmt.Errorf("line %d: %q has no root directories", lineno, name)
+		}
+		if names[name] {
+			return nil, fmt.Errorf("line %d: duplicate tenant %q", lineno, name)
+		}
+		names[name] = true
+		if len(token) < minTokenLength {
+			return nil, fmt.Errorf("line %d: the token of %q is shorter than %d characters", lineno, name, minTokenLength)
+		}
+		for _, other := range t.tenants {
+			if string(other.token) == token {
+				return nil, fmt.Errorf("line %d: %q has the token of %q", lineno, name, other.name)
+			}
+		}
+		t.tenants = append(t.tenants, &tenant{
+			name:  name,
+			token: []byte(token),
+			admin: admin,
+			roots: roots,
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(t.tenants) == 0 {
+		return nil, fmt.Errorf("no tokens")
+	}
+	return t, nil
+}
+
+// authenticate returns the tenant with the given token, or nil.
+func (t *Tenancy) authenticate(token string) *tenant {
+	var found *tenant
+	for _, tnt := range t.tenants {
+		// Compare all tokens in constant time, so as not to reveal
+		// their prefixes.
+		if subtle.ConstantTimeCompare(tnt.token, []byte(token)) == 1 {
+			found = tnt
+		}
+	}
+	return found
+}
+
+func (t *Tenancy) register(session *cache.Session, ts *tenantSession) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.sessions == nil {
+		t.sessions = make(map[*cache.Session]*tenantSession)
+	}
+	t.sessions[session] = ts
+}
+
+func (t *Tenancy) unregister(session *cache.Session) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.sessions, session)
+}
+
+// allows reports whether the file or directory path is within the
+// roots of the tenant. Symbolic links in path and in the roots are
+// resolved, as far as they exist.
+func (tnt *tenant) allows(path string) bool {
+	if tnt.roots == nil {
+		return true
+	}
+	if !filepath.IsAbs(path) {
+		return false
+	}
+	path = resolvePath(path)
+	for _, root := range tnt.roots {
+		root = resolvePath(root)
+		if path == root || strings.HasPrefix(path, strings.TrimSuffix(root, string(filepath.Separator))+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowsURI reports whether uri is a file URI within the roots of the
+// tenant.
+func (tnt *tenant) allowsURI(uri string) bool {
+	if tnt.roots == nil {
+		return true
+	}
+	var u protocol.DocumentURI
+	if err := u.UnmarshalText([]byte(uri)); err != nil || !u.IsFile() {
+		return false
+	}
+	return tnt.allows(u.Path())
+}
+
+// resolvePath returns the clean absolute path, with the symbolic links
+// of its longest existing prefix resolved.
+func resolvePath(path string) string {
+	path = filepath.Clean(path)
+	dir, rest := path, ""
+	for {
+		if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+			return filepath.Join(resolved, rest)
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return path
+		}
+		rest = filepath.Join(filepath.Base(dir), rest)
+		dir = parent
+	}
+}
+
+type tenantKeyType int
+
+const tenantKey tenantKeyType = 0
+
+// tenantOf returns the tenant of the client whose request is handled
+// with ctx, or nil if the daemon has no tenancy.
+func tenantOf(ctx context.Context) *tenant {
+	tnt, _ := ctx.Value(tenantKey).(*tenant)
+	return tnt
+}
+
+// visible reports whether the session is visible to the tenant of the
+// client whose request is handled with ctx, and returns the name of
+// its tenant.
+func (t *Tenancy) visible(ctx context.Context, session *cache.Session) (bool, string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	ts, ok := t.sessions[session]
+	if !ok {
+		return false, ""
+	}
+	caller := tenantOf(ctx)
+	return caller != nil && (caller.admin || caller == ts.tenant), ts.tenant.name
+}
+
+// handler returns a handler that authenticates the client of session,
+// on conn, and enforces the roots, permissions and quotas of its
+// tenant, which it records in the context of each request.
+func (t *Tenancy) handler(session *cache.Session, conn jsonrpc2.Conn, client protocol.Client, handler jsonrpc2.Handler) jsonrpc2.Handler {
+	var (
+		mu       sync.Mutex
+		owner    *tenant // authenticated tenant, or nil
+		reserved int     // views reserved by initialize, until initialized
+	)
+	if t.tenants == nil {
+		owner = openTenant
+		t.register(session, &tenantSession{tenant: openTenant, conn: conn, client: client})
+	}
+	return func(ctx context.Context, reply jsonrpc2.Replier, r jsonrpc2.Request) error {
+		mu.Lock()
+		tnt := owner
+		mu.Unlock()
+
+		if r.Method() == handshakeMethod && t.tenants != nil {
+			var req handshakeRequest
+			_ = json.Unmarshal(r.Params(), &req) // an invalid request has no token
+			authenticated := t.authenticate(req.Token)
+			if authenticated == nil || tnt != nil && authenticated != tnt {
+				log.Printf("Session %s: authentication failed", session.ID())
+				err := reply(ctx, nil, errUnauthorized)
+				conn.Close()
+				return err
+			}
+			if tnt == nil {
+				mu.Lock()
+				owner, tnt = authenticated, authenticated
+				mu.Unlock()
+				log.Printf("Session %s: authenticated as %q", session.ID(), tnt.name)
+				t.register(session, &tenantSession{tenant: tnt, conn: conn, client: client})
+			}
+		}
+		if tnt == nil {
+			err := reply(ctx, nil, errUnauthorized)
+			conn.Close()
+			return err
+		}
+		ctx = context.WithValue(ctx, tenantKey, tnt)
+
+		release := 0 // views to release once the request is handled
+		switch method := r.Method(); {
+		case method == "initialize":
+			var params protocol.ParamInitialize
+			if err := json.Unmarshal(r.Params(), &params); err != nil {
+				break // reported by the server
+			}
+			var dirs []string
+			for _, f := range params.WorkspaceFolders {
+				if !tnt.allowsURI(string(f.URI)) {
+					dirs = append(dirs, string(f.URI))
+				}
+			}
+			if params.RootURI != "" && !tnt.allowsURI(string(params.RootURI)) {
+				dirs = append(dirs, string(params.RootURI))
+			}
+			if params.RootPath != "" && !tnt.allows(params.RootPath) {
+				dirs = append(dirs, params.RootPath)
+			}
+			if len(dirs) > 0 {
+				return reply(ctx, nil, fmt.Errorf("gopls daemon: %s outside the root directories of %s", strings.Join(dirs, ", "), tenantName(tnt)))
+			}
+			if !tnt.admin {
+				opts, dropped, vars := restrictOptions(params.InitializationOptions)
+				if len(vars) > 0 {
+					log.Printf("Session %s: ignoring environment variables %s", session.ID(), strings.Join(vars, ", "))
+				}
+				if len(dropped) > 0 {
+					_ = client.ShowMessage(ctx, &protocol.ShowMessageParams{
+						Type:    protocol.Warning,
+						Message: fmt.Sprintf("gopls daemon: ignoring settings %s, which only administrators may set", strings.Join(dropped, ", ")),
+					})
+				}
+				params.InitializationOptions = opts
+				if call, ok := r.(*jsonrpc2.Call); ok {
+					newr, err := jsonrpc2.NewCall(call.ID(), method, params)
+					if err != nil {
+						return reply(ctx, nil, err)
+					}
+					r = newr
+				}
+			}
+			folders := len(params.WorkspaceFolders)
+			if folders == 0 && (params.RootURI != "" || params.RootPath != "") {
+				folders = 1
+			}
+			if err := t.reserveQuota(session, tnt, folders); err != nil {
+				return reply(ctx, nil, err)
+			}
+			mu.Lock()
+			reserved += folders
+			mu.Unlock()
+
+		case method == "initialized":
+			// The views of the folders of initialize are now created.
+			mu.Lock()
+			release, reserved = reserved, 0
+			mu.Unlock()
+
+		case method == "workspace/didChangeWorkspaceFolders":
+			var params protocol.DidChangeWorkspaceFoldersParams
+			if err := json.Unmarshal(r.Params(), &params); err != nil {
+				break
+			}
+			var added []protocol.WorkspaceFolder
+			var outside, dropped []string
+			for _, f := range params.Event.Added {
+				if tnt.allowsURI(string(f.URI)) {
+					added = append(added, f)
+				} else {
+					outside = append(outside, f.Name)
+				}
+			}
+			allowed := t.reserveViews(session, tnt, len(added), len(params.Event.Removed))
+			release = allowed
+			for _, f := range added[allowed:] {
+				dropped = append(dropped, f.Name)
+			}
+			if len(outside) == 0 && len(dropped) == 0 {
+				break
+			}
+			params.Event.Added = added[:allowed]
+			newr, err := jsonrpc2.NewNotification(method, params)
+			if err != nil {
+				t.releaseViews(session, release)
+				return reply(ctx, nil, err)
+			}
+			r = newr
+			if len(outside) > 0 {
+				_ = client.ShowMessage(ctx, &protocol.ShowMessageParams{
+					Type:    protocol.Warning,
+					Message: fmt.Sprintf("gopls daemon: not adding workspace folders %s, which are outside the root directories of %s", strings.Join(outside, ", "), tenantName(tnt)),
+				})
+			}
+			if len(dropped) > 0 {
+				_ = client.ShowMessage(ctx, &protocol.ShowMessageParams{
+					Type:    protocol.Warning,
+					Message: fmt.Sprintf("gopls daemon: not adding workspace folders %s, which would exceed the quota of %s", strings.Join(dropped, ", "), tenantName(tnt)),
+				})
+			}
+
+		case method == "workspace/executeCommand":
+			var params protocol.ExecuteCommandParams
+			if err := json.Unmarshal(r.Params(), &params); err == nil && adminCommands[params.Command] && !tnt.admin {
+				return reply(ctx, nil, fmt.Errorf("gopls daemon: only administrators may run %s", params.Command))
+			}
+			fallthrough // check the URIs of its arguments
+
+		default:
+			if tnt.roots == nil {
+				break
+			}
+			for _, uri := range requestURIs(method, r.Params()) {
+				if tnt.allowsURI(uri) {
+					continue
+				}
+				err := fmt.Errorf("gopls daemon: %s is outside the root directories of %s", uri, tenantName(tnt))
+				if _, ok := r.(*jsonrpc2.Call); !ok {
+					// Notifications, such as didOpen, have no reply.
+					_ = client.ShowMessage(ctx, &protocol.ShowMessageParams{
+						Type:    protocol.Warning,
+						Message: err.Error(),
+					})
+					return reply(ctx, nil, nil)
+				}
+				return reply(ctx, nil, err)
+			}
+		}
+		err := handler(ctx, reply, r)
+		if release > 0 {
+			t.releaseViews(session, release)
+		}
+		return err
+	}
+}
+
+// requestURIs returns the URIs in the params of a request: the values of
+// the fields whose names end in "uri" or "uris", in any case, at any
+// depth, such as the URIs of documents, of the items of call hierarchies
+// and of the data of code lenses, and the arguments of commands that are
+// file URIs.
+func requestURIs(method string, params json.RawMessage) []string {
+	var v interface{}
+	if err := json.Unmarshal(params, &v); err != nil {
+		return nil // reported by the server
+	}
+	var uris []string
+	var visit func(v interface{}, isURI bool)
+	visit = func(v interface{}, isURI bool) {
+		switch v := v.(type) {
+		case string:
+			if isURI {
+				uris = append(uris, v)
+			}
+		case []interface{}:
+			for _, elem := range v {
+				visit(elem, isURI)
+			}
+		case map[string]interface{}:
+			for name, value := range v {
+				name = strings.ToLower(name)
+				visit(value, strings.HasSuffix(name, "uri") || strings.HasSuffix(name, "uris"))
+			}
+		}
+	}
+	visit(v, false)
+
+	// Commands may also take URIs as arguments, such as gopls.gc_details.
+	if params, ok := v.(map[string]interface{}); ok && method == "workspace/executeCommand" {
+		args, _ := params["arguments"].([]interface{})
+		for _, arg := range args {
+			if s, ok := arg.(string); ok && strings.HasPrefix(s, "file:") {
+				uris = append(uris, s)
+			}
+		}
+	}
+	return uris
+}
+
+// adminCommands are the commands that only administrators may run: those
+// that run programs of the workspace, the C compiler or the debug server,
+// which exposes all sessions, and those that lift the restrictions of
+// workspace trust or write files of the daemon.
+var adminCommands = map[string]bool{
+	command.GCDetails.ID():       true,
+	command.Generate.ID():        true,
+	command.RegenerateCgo.ID():   true,
+	command.RunTests.ID():        true,
+	command.StartDebugging.ID():  true,
+	command.StartProfile.ID():    true,
+	command.StopProfile.ID():     true,
+	command.Test.ID():            true,
+	command.ToggleGCDetails.ID(): true,
+	command.TrustWorkspace.ID():  true,
+}
+
+// adminSettings are the settings that only administrators may set, as
+// they name programs or files of the daemon, or pass flags, such as
+// -toolexec, to the go command.
+var adminSettings = map[string]bool{
+	"buildFlags":           true,
+	"telemetryHistoryFile": true,
+	"vetTool":              true,
+}
+
+// tenantEnv are the environment variables of the go command that
+// tenants that do not administer the daemon may set.
+var tenantEnv = map[string]bool{
+	"CGO_ENABLED":  true,
+	"GO111MODULE":  true,
+	"GO386":        true,
+	"GOAMD64":      true,
+	"GOARCH":       true,
+	"GOARM":        true,
+	"GOEXPERIMENT": true,
+	"GOINSECURE":   true,
+	"GOMIPS":       true,
+	"GOMIPS64":     true,
+	"GONOPROXY":    true,
+	"GONOSUMDB":    true,
+	"GOOS":         true,
+	"GOPPC64":      true,
+	"GOPRIVATE":    true,
+	"GOPROXY":      true,
+	"GOSUMDB":      true,
+	"GOWASM":       true,
+}
+
+// restrictOptions returns a copy of the settings opts of a tenant that
+// does not administer the daemon, without adminSettings, with only the
+// variables of tenantEnv in its environment, and requiring workspace
+// trust, which the tenant may not grant: the go command then neither
+// runs programs of the workspace nor downloads toolchains. It also
+// returns the names of the settings and the variables it removed.
+func restrictOptions(opts interface{}) (_ map[string]interface{}, dropped, vars []string) {
+	m, _ := opts.(map[string]interface{})
+	restricted := make(map[string]interface{})
+	for name, value := range m {
+		if adminSettings[name] {
+			dropped = append(dropped, name)
+			continue
+		}
+		if env, ok := value.(map[string]interface{}); ok && name == "env" {
+			allowed := make(map[string]interface{})
+			for v, value := range env {
+				if tenantEnv[v] {
+					allowed[v] = value
+				} else {
+					vars = append(vars, v)
+				}
+			}
+			value = allowed
+		}
+		restricted[name] = value
+	}
+	restricted["requireWorkspaceTrust"] = true
+	sort.Strings(dropped)
+	sort.Strings(vars)
+	return restricted, dropped, vars
+}
+
+// A tenantClient is the client of a session, whose configuration it
+// restricts unless the tenant of the session administers the daemon.
+type tenantClient struct {
+	protocol.ClientCloser
+	tenancy *Tenancy
+	session *cache.Session
+}
+
+// client returns the client of session, to which the server of the
+// session must send its requests.
+func (t *Tenancy) client(session *cache.Session, client protocol.ClientCloser) protocol.ClientCloser {
+	return tenantClient{client, t, session}
+}
+
+func (c tenantClient) Configuration(ctx context.Context, params *protocol.ParamConfiguration) ([]protocol.LSPAny, error) {
+	items, err := c.ClientCloser.Configuration(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	c.tenancy.mu.Lock()
+	ts := c.tenancy.sessions[c.session]
+	c.tenancy.mu.Unlock()
+	if ts != nil && ts.tenant.admin {
+		return items, nil
+	}
+	for i, item := range items {
+		opts, dropped, _ := restrictOptions(item)
+		if len(dropped) > 0 {
+			log.Printf("Session %s: ignoring settings %s", c.session.ID(), strings.Join(dropped, ", "))
+		}
+		items[i] = opts
+	}
+	return items, nil
+}
+
+// tenantName returns a description of the tenant, for messages.
+func tenantName(tnt *tenant) string {
+	if tnt == openTenant {
+		return "the daemon"
+	}
+	return fmt.Sprintf("tenant %q", tnt.name)
+}
+
+// reserveQuota reserves n views for session, or returns an error if its
+// tenant may not add them.
+func (t *Tenancy) reserveQuota(session *cache.Session, tnt *tenant, n int) error {
+	if allowed := t.reserveViews(session, tnt, n, 0); allowed < n {
+		t.releaseViews(session, allowed)
+		if t.MaxMemory > 0 && allowed == 0 {
+			return fmt.Errorf("gopls daemon: %s has exceeded its quota of %d MiB of memory, or would exceed its quota of %d views", tenantName(tnt), t.MaxMemory>>20, t.MaxViews)
+		}
+		return fmt.Errorf("gopls daemon: %d workspace folders would exceed the quota of %d views of %s", n, t.MaxViews, tenantName(tnt))
+	}
+	return nil
+}
+
+// reserveViews reserves, for session, as many of n new views as its
+// tenant may add once removed of its views are removed, and returns
+// their number. The reserved views count towards the quotas of the
+// tenant until they are released, once their views are created.
+func (t *Tenancy) reserveViews(session *cache.Session, tnt *tenant, n, removed int) int {
+	if t.MaxViews == 0 && t.MaxMemory == 0 || n == 0 {
+		return n
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	allowed := t.allowedViewsLocked(tnt, n, removed)
+	if ts := t.sessions[session]; ts != nil {
+		ts.pending += allowed
+	}
+	return allowed
+}
+
+// releaseViews releases n views reserved for session.
+func (t *Tenancy) releaseViews(session *cache.Session, n int) {
+	if t.MaxViews == 0 && t.MaxMemory == 0 || n == 0 {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if ts := t.sessions[session]; ts != nil {
+		ts.pending -= n
+	}
+}
+
+// allowedViewsLocked returns how many of n new views the tenant may
+// add, once removed of its views are removed. It requires t.mu.
+func (t *Tenancy) allowedViewsLocked(tnt *tenant, n, removed int) int {
+	tenantViews, allViews := 0, 0
+	for session, ts := range t.sessions {
+		views := len(session.Views()) + ts.pending
+		allViews += views
+		if ts.tenant == tnt {
+			tenantViews += views
+		}
+	}
+
+	if t.MaxMemory > 0 && allViews > 0 {
+		memory := heapInUse() / uint64(allViews) * uint64(tenantViews)
+		if memory > t.MaxMemory {
+			return 0
+		}
+	}
+	if t.MaxViews > 0 {
+		if room := t.MaxViews - tenantViews + removed; room < n {
+			if room < 0 {
+				room = 0
+			}
+			return room
+		}
+	}
+	return n
+}
+
+// An evictRequest requests the eviction of sessions from a daemon.
+type evictRequest struct {
+	SessionIDs []string `json:"sessionIDs"`
+}
+
+// An evictResponse reports the sessions that were evicted.
+type evictResponse struct {
+	Evicted []string `json:"evicted"`
+}
+
+// evict closes the connections of the sessions with the given IDs,
+// which must be visible to the tenant of the client whose request is
+// handled with ctx.
+func (t *Tenancy) evict(ctx context.Context, ids []string) (*evictResponse, error) {
+	caller := tenantOf(ctx)
+	t.mu.Lock()
+	byID := make(map[string]*tenantSession)
+	for session, ts := range t.sessions {
+		if caller != nil && (caller.admin || caller == ts.tenant) {
+			byID[session.ID()] = ts
+		}
+	}
+	t.mu.Unlock()
+
+	var unknown []string
+	for _, id := range ids {
+		if byID[id] == nil {
+			unknown = append(unknown, id)
+		}
+	}
+	if len(unknown) > 0 {
+		return nil, fmt.Errorf("no such sessions: %s", strings.Join(unknown, ", "))
+	}
+	resp := &evictResponse{}
+	for _, id := range ids {
+		ts := byID[id]
+		_ = ts.client.ShowMessage(ctx, &protocol.ShowMessageParams{
+			Type:    protocol.Error,
+			Message: fmt.Sprintf("gopls daemon: session %s was evicted by %s", id, tenantName(caller)),
+		})
+		ts.conn.Close()
+		log.Printf("Session %s: evicted by %s", id, tenantName(caller))
+		resp.Evicted = append(resp.Evicted, id)
+	}
+	sort.Strings(resp.Evicted)
+	return resp, nil
+}
+
+// Authenticate performs the handshake with a daemon, over conn, with
+// the given token, if any.
+func Authenticate(ctx context.Context, conn jsonrpc2.Conn, token string) error {
+	if token == "" {
+		return nil
+	}
+	goplsPath, _ := os.Executable()
+	var resp handshakeResponse
+	return protocol.Call(ctx, conn, handshakeMethod, handshakeRequest{GoplsPath: goplsPath, Token: token}, &resp)
+}
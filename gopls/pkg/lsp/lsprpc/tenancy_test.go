@@ -0,0 +1,374 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package lsprpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/tools/gopls/pkg/lsp/cache"
+	"golang.org/x/tools/gopls/pkg/lsp/command"
+	"golang.org/x/tools/gopls/pkg/lsp/debug"
+	"golang.org/x/tools/gopls/pkg/lsp/protocol"
+	"golang.org/x/tools/pkg/jsonrpc2"
+	"golang.org/x/tools/pkg/jsonrpc2/servertest"
+)
+
+// testTokens holds the tokens of the tests, whose roots are within
+// the directory $ROOT.
+const testTokens = `
+# name token [admin] [root...]
+alice 0123456789abcdef-alice $ROOT/alice
+bob   0123456789abcdef-bob   $ROOT/bob $ROOT/shared
+
+ops   0123456789abcdef-ops admin
+`
+
+// testTenancy returns the tenancy of testTokens, whose roots are within
+// root.
+func testTenancy(t *testing.T, root string) *Tenancy {
+	t.Helper()
+	tenancy, err := parseTenancy([]byte(strings.ReplaceAll(testTokens, "$ROOT", filepath.ToSlash(root))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tenancy
+}
+
+// quietClient ignores the messages of the server.
+type quietClient struct{ protocol.Client }
+
+func (quietClient) LogMessage(context.Context, *protocol.LogMessageParams) error   { return nil }
+func (quietClient) ShowMessage(context.Context, *protocol.ShowMessageParams) error { return nil }
+
+// tenancyServer is a server whose initialization creates no views.
+type tenancyServer struct{ InitializeServer }
+
+func (tenancyServer) Initialized(context.Context, *protocol.InitializedParams) error { return nil }
+
+func TestParseTenancy(t *testing.T) {
+	root := t.TempDir()
+	tenancy := testTenancy(t, root)
+	var got []string
+	for _, tnt := range tenancy.tenants {
+		got = append(got, tnt.name)
+	}
+	if strings.Join(got, " ") != "alice bob ops" {
+		t.Errorf("tenants = %v, want [alice bob ops]", got)
+	}
+	if tnt := tenancy.authenticate("0123456789abcdef-ops"); tnt == nil || tnt.name != "ops" || !tnt.admin {
+		t.Errorf("authenticate(ops token) = %+v, want admin ops", tnt)
+	}
+	if tnt := tenancy.authenticate("0123456789abcdef"); tnt != nil {
+		t.Errorf("authenticate(token prefix) = %+v, want nil", tnt)
+	}
+	bob := tenancy.authenticate("0123456789abcdef-bob")
+	if want := []string{filepath.Join(root, "bob"), filepath.Join(root, "shared")}; !reflect.DeepEqual(bob.roots, want) {
+		t.Errorf("roots of bob = %q, want %q", bob.roots, want)
+	}
+
+	for _, test := range []struct {
+		data, wantErr string
+	}{
+		{"", "no tokens"},
+		{"alice", "line 1: want"},
+		{"alice 0123456789abcdef", `line 1: "alice" has no root directories`},
+		{"alice 0123456789abcdef root", `line 1: root "root" of "alice" is not an absolute directory`},
+		{"alice short admin", "shorter than 16"},
+		{"alice 0123456789abcdef admin\nalice 0123456789abcdeg admin", `line 2: duplicate tenant "alice"`},
+		{"alice 0123456789abcdef admin\nbob 0123456789abcdef admin", `line 2: "bob" has the token of "alice"`},
+	} {
+		_, err := parseTenancy([]byte(test.data))
+		if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+			t.Errorf("parseTenancy(%q) = %v, want error containing %q", test.data, err, test.wantErr)
+		}
+	}
+}
+
+func TestTenantAllows(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{"alice/src", "bob"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	alice := testTenancy(t, root).authenticate("0123456789abcdef-alice")
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"alice", true},
+		{"alice/src/a.go", true},
+		{"alice/new/a.go", true},
+		{"alice/../bob", false},
+		{"alice2", false},
+		{"bob/b.go", false},
+	}
+	if err := os.Symlink(filepath.Join(root, "bob"), filepath.Join(root, "alice", "link")); err == nil {
+		tests = append(tests, struct {
+			path string
+			want bool
+		}{"alice/link/b.go", false})
+	}
+	for _, test := range tests {
+		path := filepath.Join(root, filepath.FromSlash(test.path))
+		if got := alice.allows(path); got != test.want {
+			t.Errorf("allows(%s) = %t, want %t", test.path, got, test.want)
+		}
+	}
+	if alice.allows("alice/src") {
+		t.Error("allows(relative path) = true")
+	}
+	if alice.allowsURI("untitled:Untitled-1") {
+		t.Error("allowsURI(untitled URI) = true")
+	}
+	if !alice.allowsURI(string(protocol.URIFromPath(filepath.Join(root, "alice", "a.go")))) {
+		t.Error("allowsURI(file within root) = false")
+	}
+}
+
+func TestRestrictOptions(t *testing.T) {
+	opts, dropped, vars := restrictOptions(map[string]interface{}{
+		"buildFlags":            []interface{}{"-toolexec=/tmp/x"},
+		"vetTool":               "/tmp/vet",
+		"usePlaceholders":       true,
+		"requireWorkspaceTrust": false,
+		"env": map[string]interface{}{
+			"GOOS":    "linux",
+			"GOFLAGS": "-toolexec=/tmp/x",
+			"CC":      "/tmp/cc",
+		},
+	})
+	want := map[string]interface{}{
+		"usePlaceholders":       true,
+		"requireWorkspaceTrust": true,
+		"env":                   map[string]interface{}{"GOOS": "linux"},
+	}
+	if !reflect.DeepEqual(opts, want) {
+		t.Errorf("restrictOptions: got options %v, want %v", opts, want)
+	}
+	if got := fmt.Sprint(dropped, vars); got != "[buildFlags vetTool] [CC GOFLAGS]" {
+		t.Errorf("restrictOptions: dropped %s, want [buildFlags vetTool] [CC GOFLAGS]", got)
+	}
+	if opts, _, _ := restrictOptions(nil); !reflect.DeepEqual(opts, map[string]interface{}{"requireWorkspaceTrust": true}) {
+		t.Errorf("restrictOptions(nil) = %v, want workspace trust required", opts)
+	}
+}
+
+func TestTenancy(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ctx = debug.WithInstance(ctx, "", "")
+
+	root := t.TempDir()
+	tenancy := testTenancy(t, root)
+	tenancy.MaxViews = 2
+	ss := NewStreamServer(cache.New(nil), true, nil)
+	ss.serverForTest = tenancyServer{}
+	ss.SetTenancy(tenancy)
+	ts := servertest.NewTCPServer(ctx, ss, nil)
+	defer ts.Close() // ignore the errors of the connections closed by the server
+	addr := "tcp;" + ts.Addr
+
+	// connect connects a client, which authenticates with token, if set.
+	connect := func(token string) (jsonrpc2.Conn, string) {
+		t.Helper()
+		conn := ts.Connect(ctx)
+		conn.Go(ctx, protocol.ClientHandler(quietClient{}, jsonrpc2.MethodNotFound))
+		var resp handshakeResponse
+		if token != "" {
+			if err := protocol.Call(ctx, conn, handshakeMethod, handshakeRequest{Token: token}, &resp); err != nil {
+				t.Fatalf("handshake: %v", err)
+			}
+		}
+		return conn, resp.SessionID
+	}
+
+	// A client that does not authenticate is disconnected.
+	anon, _ := connect("")
+	_, err := protocol.ServerDispatcher(anon).Initialize(ctx, &protocol.ParamInitialize{})
+	if err == nil || !strings.Contains(err.Error(), "unauthorized") {
+		t.Errorf("Initialize without token: got %v, want unauthorized", err)
+	}
+	select {
+	case <-anon.Done():
+	case <-time.After(5 * time.Second):
+		t.Error("unauthenticated client not disconnected")
+	}
+	if _, err := QueryServerState(ctx, addr, "0123456789abcdef-eve"); err == nil {
+		t.Error("QueryServerState with an invalid token succeeded")
+	}
+
+	// The folders of a client must not exceed the quota of views.
+	alice, aliceID := connect("0123456789abcdef-alice")
+	bob, bobID := connect("0123456789abcdef-bob")
+	folders := func(dir string, n int) *protocol.ParamInitialize {
+		params := &protocol.ParamInitialize{}
+		for i := 0; i < n; i++ {
+			uri := protocol.URIFromPath(filepath.Join(root, dir, fmt.Sprint(i)))
+			params.WorkspaceFolders = append(params.WorkspaceFolders, protocol.WorkspaceFolder{URI: protocol.URI(uri), Name: "f"})
+		}
+		return params
+	}
+	if _, err := protocol.ServerDispatcher(alice).Initialize(ctx, folders("alice", 3)); err == nil || !strings.Contains(err.Error(), "quota of 2 views") {
+		t.Errorf("Initialize with 3 folders: got %v, want quota error", err)
+	}
+	if _, err := protocol.ServerDispatcher(bob).Initialize(ctx, folders("bob", 2)); err != nil {
+		t.Errorf("Initialize with 2 folders: %v", err)
+	}
+
+	// The views of an initialize request count towards the quota until
+	// they are created, by the initialized notification.
+	bob2, _ := connect("0123456789abcdef-bob")
+	if _, err := protocol.ServerDispatcher(bob2).Initialize(ctx, folders("shared", 1)); err == nil || !strings.Contains(err.Error(), "quota of 2 views") {
+		t.Errorf("Initialize during the initialization of 2 folders: got %v, want quota error", err)
+	}
+	if err := protocol.ServerDispatcher(bob).Initialized(ctx, &protocol.InitializedParams{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := protocol.Call(ctx, bob, sessionsMethod, nil, nil); err != nil { // wait for initialized
+		t.Fatal(err)
+	}
+	if _, err := protocol.ServerDispatcher(bob2).Initialize(ctx, folders("shared", 1)); err != nil {
+		t.Errorf("Initialize after the initialization of views: %v", err)
+	}
+
+	// Tenants may not leave their roots, nor run code.
+	if _, err := protocol.ServerDispatcher(alice).Initialize(ctx, folders("bob", 1)); err == nil || !strings.Contains(err.Error(), "outside the root directories") {
+		t.Errorf("Initialize outside the roots: got %v, want error", err)
+	}
+	_, err = protocol.ServerDispatcher(alice).Hover(ctx, &protocol.HoverParams{
+		TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+			TextDocument: protocol.TextDocumentIdentifier{URI: protocol.URIFromPath(filepath.Join(root, "bob", "b.go"))},
+		},
+	})
+	if err == nil || !strings.Contains(err.Error(), "outside the root directories") {
+		t.Errorf("Hover outside the roots: got %v, want error", err)
+	}
+	bobFile := protocol.URIFromPath(filepath.Join(root, "bob", "b.go"))
+	bobLocation := protocol.Location{URI: bobFile}
+	for _, test := range []struct {
+		method string
+		params interface{}
+	}{
+		{"codeLens/resolve", protocol.CodeLens{Data: map[string]interface{}{"Location": bobLocation}}},
+		{"callHierarchy/incomingCalls", protocol.CallHierarchyIncomingCallsParams{Item: protocol.CallHierarchyItem{URI: bobFile}}},
+		{"typeHierarchy/supertypes", protocol.TypeHierarchySupertypesParams{Item: protocol.TypeHierarchyItem{URI: bobFile}}},
+		{"workspace/executeCommand", protocol.ExecuteCommandParams{Command: command.References.ID(), Arguments: []json.RawMessage{mustMarshal(t, bobLocation)}}},
+		{"workspace/executeCommand", protocol.ExecuteCommandParams{Command: command.ListImports.ID(), Arguments: []json.RawMessage{mustMarshal(t, command.URIArg{URI: bobFile})}}},
+		{"workspace/executeCommand", protocol.ExecuteCommandParams{Command: command.Tidy.ID(), Arguments: []json.RawMessage{mustMarshal(t, command.URIArgs{URIs: []protocol.DocumentURI{bobFile}})}}},
+	} {
+		err := protocol.Call(ctx, alice, test.method, test.params, nil)
+		if err == nil || !strings.Contains(err.Error(), "outside the root directories") {
+			t.Errorf("%s %s outside the roots: got %v, want error", test.method, mustMarshal(t, test.params), err)
+		}
+	}
+	// Commands may take URIs as positional arguments.
+	gcDetails := protocol.ExecuteCommandParams{Command: command.GCDetails.ID(), Arguments: []json.RawMessage{mustMarshal(t, bobFile)}}
+	if uris := requestURIs("workspace/executeCommand", mustMarshal(t, gcDetails)); len(uris) != 1 || uris[0] != string(bobFile) {
+		t.Errorf("requestURIs(gc_details) = %q, want [%s]", uris, bobFile)
+	}
+	_, err = protocol.ServerDispatcher(alice).ExecuteCommand(ctx, &protocol.ExecuteCommandParams{Command: command.RunTests.ID()})
+	if err == nil || !strings.Contains(err.Error(), "only administrators") {
+		t.Errorf("ExecuteCommand(run_tests): got %v, want error", err)
+	}
+
+	// Tenants see their own sessions, and administrators all sessions.
+	tenantsOf := func(token string) map[string]string {
+		t.Helper()
+		state, err := QueryServerState(ctx, addr, token)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tenants := make(map[string]string)
+		for _, c := range state.Clients {
+			tenants[c.SessionID] = c.Tenant
+		}
+		return tenants
+	}
+	aliceSees := tenantsOf("0123456789abcdef-alice")
+	if aliceSees[aliceID] != "alice" || aliceSees[bobID] != "" {
+		t.Errorf("alice sees sessions %v, want alice's (%s) and not bob's (%s)", aliceSees, aliceID, bobID)
+	}
+	for _, tenant := range aliceSees {
+		if tenant != "alice" {
+			t.Errorf("alice sees a session of %q", tenant)
+		}
+	}
+	opsSees := tenantsOf("0123456789abcdef-ops")
+	if opsSees[aliceID] != "alice" || opsSees[bobID] != "bob" {
+		t.Errorf("ops sees sessions %v, want those of alice (%s) and bob (%s)", opsSees, aliceID, bobID)
+	}
+
+	// Tenants evict only their own sessions, and administrators any.
+	if _, err := EvictSessions(ctx, addr, "0123456789abcdef-alice", []string{bobID}); err == nil {
+		t.Error("alice evicted bob's session")
+	}
+	evicted, err := EvictSessions(ctx, addr, "0123456789abcdef-ops", []string{bobID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(evicted) != 1 || evicted[0] != bobID {
+		t.Errorf("evicted %v, want [%s]", evicted, bobID)
+	}
+	select {
+	case <-bob.Done():
+	case <-time.After(5 * time.Second):
+		t.Error("evicted client not disconnected")
+	}
+	if err := protocol.Call(ctx, alice, sessionsMethod, nil, nil); err != nil {
+		t.Errorf("alice disconnected by the eviction of bob: %v", err)
+	}
+}
+
+func TestForwarderToken(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	tenancy := testTenancy(t, t.TempDir())
+	ss := NewStreamServer(cache.New(nil), true, nil)
+	ss.serverForTest = InitializeServer{}
+	ss.SetTenancy(tenancy)
+	tsBackend := servertest.NewTCPServer(ctx, ss, nil)
+	defer checkClose(t, tsBackend.Close)
+
+	for _, test := range []struct {
+		token  string
+		wantOK bool
+	}{
+		{"0123456789abcdef-alice", true},
+		{"0123456789abcdef-eve", false},
+	} {
+		forwarder, err := NewForwarder("tcp;"+tsBackend.Addr, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		forwarder.SetToken(test.token)
+		tsForwarder := servertest.NewPipeServer(forwarder, nil)
+		conn := tsForwarder.Connect(ctx)
+		conn.Go(ctx, jsonrpc2.MethodNotFound)
+		_, err = protocol.ServerDispatcher(conn).Initialize(ctx, &protocol.ParamInitialize{})
+		if ok := err == nil; ok != test.wantOK {
+			t.Errorf("Initialize through a forwarder with token %q: got error %v, want success %t", test.token, err, test.wantOK)
+		}
+		tsForwarder.Close()
+	}
+}
+
+func mustMarshal(t *testing.T, v interface{}) json.RawMessage {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
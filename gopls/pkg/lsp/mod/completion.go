@@ -0,0 +1,312 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+	"golang.org/x/tools/gopls/pkg/file"
+	"golang.org/x/tools/gopls/pkg/lsp/cache"
+	"golang.org/x/tools/gopls/pkg/lsp/protocol"
+	"golang.org/x/tools/pkg/event"
+	"golang.org/x/tools/pkg/gocommand"
+)
+
+// Completion returns completions for the module paths and versions of
+// require and replace directives in a go.mod file.
+//
+// Candidates are taken from the module cache and, if implicit network access
+// is allowed, versions are also fetched from the module proxy.
+func Completion(ctx context.Context, snapshot *cache.Snapshot, fh file.Handle, position protocol.Position) (*protocol.CompletionList, error) {
+	ctx, done := event.Start(ctx, "mod.Completion")
+	defer done()
+
+	content, err := fh.Content()
+	if err != nil {
+		return nil, err
+	}
+	// The go.mod file is not parsed, as it is likely to be incomplete while
+	// the user is typing.
+	mapper := protocol.NewMapper(fh.URI(), content)
+	cursor, err := mapper.PositionOffset(position)
+	if err != nil {
+		return nil, fmt.Errorf("computing cursor offset: %w", err)
+	}
+	arg := argAt(content, cursor)
+	if arg == nil {
+		return &protocol.CompletionList{}, nil
+	}
+
+	var (
+		kind       protocol.CompletionItemKind
+		candidates []string
+	)
+	switch path, ok := arg.versionOf(); {
+	case ok && path != "":
+		kind = protocol.ValueCompletion
+		candidates = moduleVersions(ctx, snapshot, fh.URI(), path)
+	case !ok && !isLocalPath(arg.prefix):
+		kind = protocol.ModuleCompletion
+		candidates, err = cachedModulePaths(snapshot.View().GoModCache(), arg.prefix)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Replace the entire argument surrounding the cursor.
+	end := cursor
+	for end < len(content) && !isSpace(content[end]) {
+		end++
+	}
+	rng, err := mapper.OffsetRange(arg.start, end)
+	if err != nil {
+		return nil, err
+	}
+	items := []protocol.CompletionItem{} // must be a slice
+	for _, c := range candidates {
+		if !strings.HasPrefix(c, arg.prefix) {
+			continue
+		}
+		items = append(items, protocol.CompletionItem{
+			Label:    c,
+			Kind:     kind,
+			SortText: fmt.Sprintf("%05d", len(items)),
+			TextEdit: &protocol.TextEdit{
+				Range:   rng,
+				NewText: c,
+			},
+		})
+	}
+	return &protocol.CompletionList{Items: items}, nil
+}
+
+// A directiveArg describes the argument of a go.mod directive that encloses
+// the cursor.
+type directiveArg struct {
+	verb   string   // directive, such as "require"
+	prev   []string // preceding arguments of the directive
+	prefix string   // text of the argument preceding the cursor
+	start  int      // offset of the start of the argument
+}
+
+// argAt returns the directive argument at the given offset of the go.mod
+// file content, or nil if the offset is not within a directive argument.
+func argAt(content []byte, offset int) *directiveArg {
+	lineStart := bytes.LastIndexByte(content[:offset], '\n') + 1
+
+	// Find the enclosing block, if any.
+	var block string
+	for _, line := range strings.Split(string(content[:lineStart]), "\n") {
+		fields := strings.Fields(stripComment(line))
+		switch {
+		case len(fields) == 2 && fields[1] == "(":
+			block = fields[0]
+		case len(fields) == 1 && strings.HasSuffix(fields[0], "("):
+			block = strings.TrimSuffix(fields[0], "(")
+		case len(fields) == 1 && fields[0] == ")":
+			block = ""
+		}
+	}
+
+	text := string(content[lineStart:offset])
+	if strings.Contains(text, "//") {
+		return nil // in a comment
+	}
+	arg := &directiveArg{verb: block, start: offset}
+	fields := strings.Fields(text)
+	if len(fields) > 0 && !isSpace(text[len(text)-1]) {
+		arg.prefix = fields[len(fields)-1]
+		arg.start = offset - len(arg.prefix)
+		fields = fields[:len(fields)-1]
+	}
+	if arg.verb == "" {
+		if len(fields) == 0 {
+			return nil // completing the directive itself
+		}
+		arg.verb, fields = fields[0], fields[1:]
+	}
+	arg.prev = fields
+	return arg
+}
+
+// versionOf reports whether the argument is a module version, and if so
+// returns the path of the module. It returns "" if the argument is a version
+// but the module is a local directory.
+//
+// If versionOf returns false, the argument is a module path.
+func (arg *directiveArg) versionOf() (string, bool) {
+	args := arg.prev
+	switch arg.verb {
+	case "require":
+	case "replace":
+		for i, a := range args {
+			if a == "=>" {
+				args = args[i+1:]
+				if len(args) > 0 && isLocalPath(args[0]) {
+					return "", true
+				}
+				break
+			}
+		}
+	default:
+		return "", true
+	}
+	switch len(args) {
+	case 0:
+		return "", false
+	case 1:
+		return args[0], true
+	default:
+		return "", true
+	}
+}
+
+// cachedModulePaths returns the paths of the modules in the module cache
+// that start with the given prefix, in order.
+func cachedModulePaths(gomodcache, prefix string) ([]string, error) {
+	root := filepath.Join(gomodcache, "cache", "download")
+
+	// Stop traversing once we've seen 10k directories, to keep completion
+	// fast in large module caches.
+	const numSeenBound = 10000
+	var numSeen int
+	stopWalking := errors.New("hit numSeenBound")
+
+	var paths []string
+	err := filepath.Walk(root, func(dir string, info os.FileInfo, err error) error {
+		if err != nil || !info.IsDir() || dir == root {
+			return nil
+		}
+		if numSeen > numSeenBound {
+			return stopWalking
+		}
+		numSeen++
+
+		rel := filepath.ToSlash(dir[len(root)+1:])
+		if rel == "sumdb" {
+			return filepath.SkipDir
+		}
+		if filepath.Base(dir) == "@v" {
+			// The parent directory is a module.
+			if path, err := module.UnescapePath(filepath.ToSlash(filepath.Dir(rel))); err == nil && strings.HasPrefix(path, prefix) {
+				paths = append(paths, path)
+			}
+			return filepath.SkipDir
+		}
+		path, err := module.UnescapePath(rel)
+		if err != nil {
+			path = rel // a partial path, such as a host name without a dot
+		}
+		if !strings.HasPrefix(path, prefix) && !strings.HasPrefix(prefix, path+"/") {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, stopWalking) {
+		return nil, fmt.Errorf("walking module cache: %w", err)
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// moduleVersions returns the known versions of the module with the given
+// path, most recent first. Errors are logged.
+func moduleVersions(ctx context.Context, snapshot *cache.Snapshot, modURI protocol.DocumentURI, path string) []string {
+	seen := make(map[string]bool)
+	versions, err := cachedVersions(snapshot.View().GoModCache(), path)
+	if err != nil {
+		event.Error(ctx, "reading module cache", err)
+	}
+	for _, v := range versions {
+		seen[v] = true
+	}
+
+	if snapshot.Options().AllowImplicitNetworkAccess {
+		inv := &gocommand.Invocation{
+			Verb:       "list",
+			Args:       []string{"-m", "-versions", "-json", path},
+			WorkingDir: filepath.Dir(modURI.Path()),
+		}
+		stdout, err := snapshot.RunGoCommandDirect(ctx, cache.Normal, inv)
+		if err != nil {
+			event.Error(ctx, "listing module versions", err)
+		} else {
+			var mod struct{ Versions []string }
+			if err := json.Unmarshal(stdout.Bytes(), &mod); err != nil {
+				event.Error(ctx, "decoding module versions", err)
+			}
+			for _, v := range mod.Versions {
+				if !seen[v] {
+					seen[v] = true
+					versions = append(versions, v)
+				}
+			}
+		}
+	}
+
+	sort.Slice(versions, func(i, j int) bool {
+		return semver.Compare(versions[i], versions[j]) > 0
+	})
+	return versions
+}
+
+// cachedVersions returns the versions of the module with the given path for
+// which metadata is present in the module cache.
+func cachedVersions(gomodcache, path string) ([]string, error) {
+	escaped, err := module.EscapePath(path)
+	if err != nil {
+		return nil, nil // not a valid module path
+	}
+	entries, err := os.ReadDir(filepath.Join(gomodcache, "cache", "download", escaped, "@v"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	var versions []string
+	for _, e := range entries {
+		name := e.Name()
+		ext := filepath.Ext(name)
+		if ext != ".mod" && ext != ".info" {
+			continue
+		}
+		v, err := module.UnescapeVersion(strings.TrimSuffix(name, ext))
+		if err != nil || !semver.IsValid(v) || seen[v] {
+			continue
+		}
+		seen[v] = true
+		versions = append(versions, v)
+	}
+	return versions, nil
+}
+
+// isLocalPath reports whether the replacement path is a directory rather
+// than a module path.
+func isLocalPath(path string) bool {
+	return strings.HasPrefix(path, ".") || filepath.IsAbs(path)
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n'
+}
+
+func stripComment(line string) string {
+	if i := strings.Index(line, "//"); i >= 0 {
+		return line[:i]
+	}
+	return line
+}
@@ -0,0 +1,144 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+	"golang.org/x/tools/gopls/pkg/file"
+	"golang.org/x/tools/gopls/pkg/lsp/cache"
+	"golang.org/x/tools/gopls/pkg/lsp/protocol"
+	"golang.org/x/tools/gopls/pkg/lsp/source"
+	"golang.org/x/tools/pkg/event"
+)
+
+// Definition returns the location of the go.mod file of the module named by
+// the require or replace directive at the given position.
+//
+// For a required module, replace directives are taken into account: the
+// result is the go.mod file of the replacement, which may be a local
+// directory. Otherwise, the go.mod file is found in the module cache.
+func Definition(ctx context.Context, snapshot *cache.Snapshot, fh file.Handle, position protocol.Position) ([]protocol.Location, error) {
+	ctx, done := event.Start(ctx, "mod.Definition")
+	defer done()
+
+	pm, err := snapshot.ParseMod(ctx, fh)
+	if err != nil {
+		return nil, fmt.Errorf("getting modfile handle: %w", err)
+	}
+	if pm.File == nil {
+		return nil, nil // unparseable
+	}
+	offset, err := pm.Mapper.PositionOffset(position)
+	if err != nil {
+		return nil, fmt.Errorf("computing cursor position: %w", err)
+	}
+
+	var target module.Version
+	if req, _, _ := requireAt(pm, offset); req != nil {
+		target = replacement(pm.File, req.Mod)
+	} else {
+		for _, r := range pm.File.Replace {
+			if r.Syntax.Start.Byte <= offset && offset <= r.Syntax.End.Byte {
+				target = r.New
+				break
+			}
+		}
+	}
+	if target.Path == "" {
+		return nil, nil // not on a require or replace directive
+	}
+
+	filename, err := modFileFor(snapshot.View().GoModCache(), filepath.Dir(fh.URI().Path()), target)
+	if err != nil {
+		return nil, err
+	}
+	return []protocol.Location{{URI: protocol.URIFromPath(filename)}}, nil
+}
+
+// requireAt returns the require directive of pm at the given offset, and the
+// extent of its module path, or nil if there is none.
+func requireAt(pm *source.ParsedModule, offset int) (req *modfile.Require, start, end int) {
+	for _, r := range pm.File.Require {
+		dep := []byte(r.Mod.Path)
+		s, e := r.Syntax.Start.Byte, r.Syntax.End.Byte
+		i := bytes.Index(pm.Mapper.Content[s:e], dep)
+		if i == -1 {
+			continue
+		}
+		// The directive extends from the start of the module path,
+		// excluding the "require" keyword of a single-line directive.
+		if s+i <= offset && offset <= e {
+			return r, s + i, s + i + len(dep)
+		}
+	}
+	return nil, 0, 0
+}
+
+// replacement returns the module that replaces mod according to the replace
+// directives of f, or mod itself if it is not replaced. As with the go
+// command, a replacement of a specific version takes precedence over one
+// for all versions.
+func replacement(f *modfile.File, mod module.Version) module.Version {
+	var result *module.Version
+	for _, r := range f.Replace {
+		if r.Old.Path != mod.Path {
+			continue
+		}
+		if r.Old.Version == mod.Version {
+			return r.New
+		}
+		if r.Old.Version == "" {
+			result = &r.New
+		}
+	}
+	if result != nil {
+		return *result
+	}
+	return mod
+}
+
+// modFileFor returns the name of the go.mod file of the given module: for a
+// module with no version, a local directory relative to dir; otherwise the
+// go.mod file in the module cache.
+func modFileFor(gomodcache, dir string, mod module.Version) (string, error) {
+	if mod.Version == "" {
+		root := filepath.FromSlash(mod.Path)
+		if !filepath.IsAbs(root) {
+			root = filepath.Join(dir, root)
+		}
+		filename := filepath.Join(root, "go.mod")
+		if _, err := os.Stat(filename); err != nil {
+			return "", err
+		}
+		return filename, nil
+	}
+
+	escPath, err := module.EscapePath(mod.Path)
+	if err != nil {
+		return "", err
+	}
+	escVersion, err := module.EscapeVersion(mod.Version)
+	if err != nil {
+		return "", err
+	}
+	// Prefer the extracted module, falling back to the go.mod file that is
+	// downloaded for every module in the build list.
+	for _, filename := range []string{
+		filepath.Join(gomodcache, escPath+"@"+escVersion, "go.mod"),
+		filepath.Join(gomodcache, "cache", "download", escPath, "@v", escVersion+".mod"),
+	} {
+		if _, err := os.Stat(filename); err == nil {
+			return filename, nil
+		}
+	}
+	return "", fmt.Errorf("module %s is not in the module cache", mod)
+}
@@ -53,12 +53,23 @@ func UpgradeDiagnostics(ctx context.Context, snapshot *cache.Snapshot) (map[prot
 }
 
 // VulnerabilityDiagnostics returns vulnerability diagnostics for the active modules in the
-// workspace with known vulnerabilities.
+// workspace with known vulnerabilities, and for the workspace call sites
+// that reach vulnerable symbols.
 func VulnerabilityDiagnostics(ctx context.Context, snapshot *cache.Snapshot) (map[protocol.DocumentURI][]*source.Diagnostic, error) {
 	ctx, done := event.Start(ctx, "mod.VulnerabilityDiagnostics", snapshot.Labels()...)
 	defer done()
 
-	return collectDiagnostics(ctx, snapshot, ModVulnerabilityDiagnostics)
+	return collectDiagnostics(ctx, snapshot, func(ctx context.Context, snapshot *cache.Snapshot, fh file.Handle) ([]*source.Diagnostic, error) {
+		diagnostics, err := ModVulnerabilityDiagnostics(ctx, snapshot, fh)
+		if err != nil {
+			return nil, err
+		}
+		callDiagnostics, err := VulnerableCallDiagnostics(ctx, snapshot, fh)
+		if err != nil {
+			return nil, err
+		}
+		return append(diagnostics, callDiagnostics...), nil
+	})
 }
 
 func collectDiagnostics(ctx context.Context, snapshot *cache.Snapshot, diagFn func(context.Context, *cache.Snapshot, file.Handle) ([]*source.Diagnostic, error)) (map[protocol.DocumentURI][]*source.Diagnostic, error) {
@@ -82,7 +93,7 @@ func collectDiagnostics(ctx context.Context, snapshot *cache.Snapshot, diagFn fu
 			}
 			for _, d := range diagnostics {
 				mu.Lock()
-				reports[d.URI] = append(reports[fh.URI()], d)
+				reports[d.URI] = append(reports[d.URI], d)
 				mu.Unlock()
 			}
 			return nil
@@ -10,6 +10,7 @@ import (
 	"fmt"
 	"sort"
 	"strings"
+	"time"
 
 	"golang.org/x/mod/modfile"
 	"golang.org/x/mod/semver"
@@ -118,7 +119,7 @@ func hoverOnRequireStatement(ctx context.Context, pm *source.ParsedModule, offse
 	isPrivate := snapshot.IsGoPrivatePath(req.Mod.Path)
 	header := formatHeader(req.Mod.Path, options)
 	explanation = formatExplanation(explanation, req, options, isPrivate)
-	vulns := formatVulnerabilities(affecting, nonaffecting, osvs, options, fromGovulncheck)
+	vulns := formatVulnerabilities(affecting, nonaffecting, osvs, options, fromGovulncheck) + formatStaleDB(vs)
 
 	return &protocol.Hover{
 		Contents: protocol.MarkupContent{
@@ -156,7 +157,7 @@ func hoverOnModuleStatement(ctx context.Context, pm *source.ParsedModule, offset
 	goVersion := snapshot.View().GoVersionString()
 	affecting, nonaffecting, osvs := lookupVulns(vs, modpath, goVersion)
 	options := snapshot.Options()
-	vulns := formatVulnerabilities(affecting, nonaffecting, osvs, options, fromGovulncheck)
+	vulns := formatVulnerabilities(affecting, nonaffecting, osvs, options, fromGovulncheck) + formatStaleDB(vs)
 
 	return &protocol.Hover{
 		Contents: protocol.MarkupContent{
@@ -307,6 +308,19 @@ func formatVulnerabilities(affecting, nonaffecting []*govulncheck.Finding, osvs
 	return b.String()
 }
 
+// formatStaleDB returns a note that the vulnerability database of vs is
+// stale, if it is.
+func formatStaleDB(vs *vulncheck.Result) string {
+	if vs == nil {
+		return ""
+	}
+	warning := vulncheck.StaleDBWarning(vs.DB, vs.DBModified, time.Now())
+	if warning == "" {
+		return ""
+	}
+	return "\n**Note:** " + warning + "\n\n"
+}
+
 func vulnerablePkgsInfo(findings []*govulncheck.Finding, useMarkdown bool) string {
 	var b strings.Builder
 	seen := map[string]bool{}
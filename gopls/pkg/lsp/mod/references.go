@@ -0,0 +1,99 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"golang.org/x/tools/gopls/pkg/file"
+	"golang.org/x/tools/gopls/pkg/lsp/cache"
+	"golang.org/x/tools/gopls/pkg/lsp/protocol"
+	"golang.org/x/tools/gopls/pkg/lsp/source"
+	"golang.org/x/tools/pkg/event"
+)
+
+// References returns the locations of the imports, in workspace packages, of
+// packages provided by the module required at the given position. If
+// includeDeclaration is set, the module path of the require directive is
+// included.
+func References(ctx context.Context, snapshot *cache.Snapshot, fh file.Handle, position protocol.Position, includeDeclaration bool) ([]protocol.Location, error) {
+	ctx, done := event.Start(ctx, "mod.References")
+	defer done()
+
+	pm, err := snapshot.ParseMod(ctx, fh)
+	if err != nil {
+		return nil, fmt.Errorf("getting modfile handle: %w", err)
+	}
+	if pm.File == nil {
+		return nil, nil // unparseable
+	}
+	offset, err := pm.Mapper.PositionOffset(position)
+	if err != nil {
+		return nil, fmt.Errorf("computing cursor position: %w", err)
+	}
+	req, start, end := requireAt(pm, offset)
+	if req == nil {
+		return nil, nil // not on a require directive
+	}
+
+	var locs []protocol.Location
+	if includeDeclaration {
+		loc, err := pm.Mapper.OffsetLocation(start, end)
+		if err != nil {
+			return nil, err
+		}
+		locs = append(locs, loc)
+	}
+
+	workspace, err := snapshot.WorkspaceMetadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[protocol.Location]bool)
+	for _, m := range workspace {
+		// Find the imports of packages in the required module.
+		imports := make(map[source.ImportPath]bool)
+		for impPath, id := range m.DepsByImpPath {
+			if id == "" {
+				continue // missing
+			}
+			if dep := snapshot.Metadata(id); dep != nil && dep.Module != nil && dep.Module.Path == req.Mod.Path {
+				imports[impPath] = true
+			}
+		}
+		if len(imports) == 0 {
+			continue
+		}
+		for _, uri := range m.CompiledGoFiles {
+			fh, err := snapshot.ReadFile(ctx, uri)
+			if err != nil {
+				return nil, err
+			}
+			pgf, err := snapshot.ParseGo(ctx, fh, source.ParseHeader)
+			if err != nil {
+				return nil, err
+			}
+			for _, imp := range pgf.File.Imports {
+				if !imports[source.UnquoteImportPath(imp)] {
+					continue
+				}
+				loc, err := pgf.NodeLocation(imp.Path)
+				if err != nil {
+					return nil, err
+				}
+				if !seen[loc] {
+					seen[loc] = true
+					locs = append(locs, loc)
+				}
+			}
+		}
+	}
+	sort.Slice(locs, func(i, j int) bool {
+		return protocol.CompareLocation(locs[i], locs[j]) < 0
+	})
+	return locs, nil
+}
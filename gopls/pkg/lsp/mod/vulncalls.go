@@ -0,0 +1,248 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"path"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/semver"
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/gopls/pkg/file"
+	"golang.org/x/tools/gopls/pkg/lsp/cache"
+	"golang.org/x/tools/gopls/pkg/lsp/protocol"
+	"golang.org/x/tools/gopls/pkg/lsp/source"
+	"golang.org/x/tools/gopls/pkg/vulncheck/govulncheck"
+)
+
+// VulnerableCallDiagnostics returns diagnostics at the workspace call
+// sites from which the govulncheck result recorded for the go.mod file fh
+// found a call stack reaching a vulnerable symbol. The related
+// information of each diagnostic holds the frames of the stack from the
+// call site down to the vulnerable symbol.
+//
+// Only govulncheck results have call stacks: the results of the
+// "Imports" vulncheck mode yield no diagnostics.
+func VulnerableCallDiagnostics(ctx context.Context, snapshot *cache.Snapshot, fh file.Handle) ([]*source.Diagnostic, error) {
+	vs := snapshot.Vulnerabilities(fh.URI())[fh.URI()]
+	if vs == nil || len(vs.Findings) == 0 {
+		return nil, nil
+	}
+	var called []*govulncheck.Finding
+	for _, finding := range vs.Findings {
+		if _, typ := foundVuln(finding); typ == vulnCalled {
+			called = append(called, finding)
+		}
+	}
+	if len(called) == 0 {
+		return nil, nil
+	}
+
+	pm, err := snapshot.ParseMod(ctx, fh)
+	if err != nil {
+		// The go.mod diagnostics report the parse errors.
+		if pm != nil && len(pm.ParseErrors) != 0 {
+			return nil, nil
+		}
+		return nil, err
+	}
+	requires := make(map[string]*modfile.Require)
+	for _, req := range pm.File.Require {
+		requires[req.Mod.Path] = req
+	}
+
+	// Call sites are only reported in the files of workspace packages.
+	workspace, err := snapshot.WorkspaceMetadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+	workspaceFiles := make(map[protocol.DocumentURI]bool)
+	for _, m := range workspace {
+		for _, uri := range m.CompiledGoFiles {
+			workspaceFiles[uri] = true
+		}
+	}
+
+	resetGovulncheck, err := suggestGovulncheckAction(true, fh.URI())
+	if err != nil {
+		// must not happen
+		return nil, err // TODO: bug report
+	}
+
+	type callSite struct {
+		rng protocol.Range
+		osv string
+	}
+	var (
+		diagnostics []*source.Diagnostic
+		seen        = make(map[protocol.DocumentURI]map[callSite]bool)
+		frames      = &frameLocator{snapshot: snapshot, files: make(map[protocol.DocumentURI]*source.ParsedGoFile)}
+	)
+	for _, finding := range called {
+		vuln := finding.Trace[0]
+
+		// As in ModVulnerabilityDiagnostics, assume that a module
+		// required at or above the fixed version was upgraded since
+		// govulncheck ran.
+		req := requires[vuln.Module]
+		if req != nil && finding.FixedVersion != "" && semver.IsValid(req.Mod.Version) && semver.Compare(finding.FixedVersion, req.Mod.Version) <= 0 {
+			continue
+		}
+		var fixes []source.SuggestedFix
+		if req != nil && semver.IsValid(finding.FixedVersion) && semver.Compare(req.Mod.Version, finding.FixedVersion) < 0 {
+			cmd, err := getUpgradeCodeAction(fh, req, finding.FixedVersion)
+			if err != nil {
+				return nil, err // TODO: bug report
+			}
+			fixes = append(fixes, cache.SuggestedFixFromCommand(cmd, protocol.QuickFix))
+		}
+		fixes = append(fixes, resetGovulncheck)
+
+		// Frame i of the trace calls frame i-1, at the position of frame i.
+		for i := 1; i < len(finding.Trace); i++ {
+			frame := finding.Trace[i]
+			if frame.Position == nil || frame.Position.Line <= 0 {
+				continue
+			}
+			uri := protocol.URIFromPath(frame.Position.Filename)
+			if !workspaceFiles[uri] {
+				continue
+			}
+			rng, ok := frames.callRange(ctx, uri, frame.Position)
+			if !ok {
+				continue // the file changed since govulncheck ran
+			}
+			site := callSite{rng, finding.OSV}
+			if seen[uri][site] {
+				continue
+			}
+			if seen[uri] == nil {
+				seen[uri] = make(map[callSite]bool)
+			}
+			seen[uri][site] = true
+
+			var related []protocol.DiagnosticRelatedInformation
+			for j := i - 1; j > 0; j-- {
+				loc, ok := frames.location(ctx, finding.Trace[j].Position)
+				if !ok {
+					continue
+				}
+				related = append(related, protocol.DiagnosticRelatedInformation{
+					Location: loc,
+					Message:  fmt.Sprintf("%s calls %s", frameName(finding.Trace[j]), frameName(finding.Trace[j-1])),
+				})
+			}
+			var msg string
+			if i == 1 {
+				msg = fmt.Sprintf("%s is vulnerable: %s.", frameName(vuln), finding.OSV)
+			} else {
+				msg = fmt.Sprintf("This call reaches %s, which is vulnerable: %s.", frameName(vuln), finding.OSV)
+			}
+			diagnostics = append(diagnostics, &source.Diagnostic{
+				URI:            uri,
+				Range:          rng,
+				Severity:       protocol.SeverityWarning,
+				Source:         source.Govulncheck,
+				Code:           finding.OSV,
+				CodeHref:       href(finding.OSV),
+				Message:        msg,
+				Related:        related,
+				SuggestedFixes: fixes,
+			})
+		}
+	}
+	return diagnostics, nil
+}
+
+// frameName returns the name of the function of a trace frame, qualified
+// by its package name and receiver type, such as "avuln.VulnData.Vuln1".
+func frameName(frame *govulncheck.Frame) string {
+	name := frame.Function
+	if frame.Receiver != "" {
+		name = strings.TrimPrefix(frame.Receiver, "*") + "." + name
+	}
+	return path.Base(frame.Package) + "." + name
+}
+
+// A frameLocator maps the positions of govulncheck trace frames to
+// locations in the files of a snapshot.
+type frameLocator struct {
+	snapshot *cache.Snapshot
+	files    map[protocol.DocumentURI]*source.ParsedGoFile // nil for files that cannot be parsed
+}
+
+func (l *frameLocator) parse(ctx context.Context, uri protocol.DocumentURI) *source.ParsedGoFile {
+	pgf, ok := l.files[uri]
+	if !ok {
+		if fh, err := l.snapshot.ReadFile(ctx, uri); err == nil {
+			pgf, _ = l.snapshot.ParseGo(ctx, fh, source.ParseFull)
+		}
+		l.files[uri] = pgf
+	}
+	return pgf
+}
+
+// pos returns the position of the line and column of p in pgf, or
+// NoPos if pgf does not have them.
+func (l *frameLocator) pos(pgf *source.ParsedGoFile, p *govulncheck.Position) token.Pos {
+	if p.Line < 1 || p.Line > pgf.Tok.LineCount() || p.Column < 1 {
+		return token.NoPos
+	}
+	pos := pgf.Tok.LineStart(p.Line) + token.Pos(p.Column-1)
+	if int(pos) > pgf.Tok.Base()+pgf.Tok.Size() {
+		return token.NoPos
+	}
+	return pos
+}
+
+// callRange returns the range of the function of the call whose opening
+// parenthesis is at p, in the file uri. It reports false if there is no
+// such call, as happens when the file was edited since govulncheck ran.
+func (l *frameLocator) callRange(ctx context.Context, uri protocol.DocumentURI, p *govulncheck.Position) (protocol.Range, bool) {
+	pgf := l.parse(ctx, uri)
+	if pgf == nil {
+		return protocol.Range{}, false
+	}
+	pos := l.pos(pgf, p)
+	if !pos.IsValid() {
+		return protocol.Range{}, false
+	}
+	path, _ := astutil.PathEnclosingInterval(pgf.File, pos, pos)
+	for _, n := range path {
+		call, ok := n.(*ast.CallExpr)
+		if !ok || call.Lparen != pos {
+			continue
+		}
+		var fun ast.Node = call.Fun
+		if sel, ok := call.Fun.(*ast.SelectorExpr); ok {
+			fun = sel.Sel
+		}
+		rng, err := pgf.NodeRange(fun)
+		return rng, err == nil
+	}
+	return protocol.Range{}, false
+}
+
+// location returns the location of the position p of a frame.
+func (l *frameLocator) location(ctx context.Context, p *govulncheck.Position) (protocol.Location, bool) {
+	if p == nil || p.Line <= 0 {
+		return protocol.Location{}, false
+	}
+	pgf := l.parse(ctx, protocol.URIFromPath(p.Filename))
+	if pgf == nil {
+		return protocol.Location{}, false
+	}
+	pos := l.pos(pgf, p)
+	if !pos.IsValid() {
+		return protocol.Location{}, false
+	}
+	loc, err := pgf.PosLocation(pos, pos)
+	return loc, err == nil
+}
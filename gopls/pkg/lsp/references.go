@@ -8,6 +8,7 @@ import (
 	"context"
 
 	"golang.org/x/tools/gopls/pkg/file"
+	"golang.org/x/tools/gopls/pkg/lsp/mod"
 	"golang.org/x/tools/gopls/pkg/lsp/protocol"
 	"golang.org/x/tools/gopls/pkg/lsp/source"
 	"golang.org/x/tools/gopls/pkg/lsp/template"
@@ -30,8 +31,11 @@ func (s *server) References(ctx context.Context, params *protocol.ReferenceParam
 	if !ok {
 		return nil, err
 	}
-	if snapshot.FileKind(fh) == file.Tmpl {
+	switch snapshot.FileKind(fh) {
+	case file.Tmpl:
 		return template.References(ctx, snapshot, fh, params)
+	case file.Mod:
+		return mod.References(ctx, snapshot, fh, params.Position, params.Context.IncludeDeclaration)
 	}
 	return source.References(ctx, snapshot, fh, params.Position, params.Context.IncludeDeclaration)
 }
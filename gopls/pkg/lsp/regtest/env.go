@@ -75,6 +75,7 @@ func (a *Awaiter) Hooks() fake.ClientHooks {
 		OnRegisterCapability:     a.onRegisterCapability,
 		OnUnregisterCapability:   a.onUnregisterCapability,
 		OnApplyEdit:              a.onApplyEdit,
+		OnCodeLensRefresh:        a.onCodeLensRefresh,
 	}
 }
 
@@ -86,6 +87,7 @@ type State struct {
 	showDocument       []*protocol.ShowDocumentParams
 	showMessage        []*protocol.ShowMessageParams
 	showMessageRequest []*protocol.ShowMessageRequestParams
+	codeLensRefreshes  int
 
 	registrations          []*protocol.RegistrationParams
 	registeredCapabilities map[string]protocol.Registration
@@ -203,6 +205,15 @@ func (a *Awaiter) onDiagnostics(_ context.Context, d *protocol.PublishDiagnostic
 	return nil
 }
 
+func (a *Awaiter) onCodeLensRefresh(context.Context) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.state.codeLensRefreshes++
+	a.checkConditionsLocked()
+	return nil
+}
+
 func (a *Awaiter) onShowDocument(_ context.Context, params *protocol.ShowDocumentParams) error {
 	a.mu.Lock()
 	defer a.mu.Unlock()
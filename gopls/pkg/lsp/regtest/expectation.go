@@ -229,6 +229,21 @@ func ShownDocument(uri protocol.URI) Expectation {
 	}
 }
 
+// CodeLensRefreshed asserts that the client has received at least one
+// workspace/codeLens/refresh request.
+func CodeLensRefreshed() Expectation {
+	check := func(s State) Verdict {
+		if s.codeLensRefreshes > 0 {
+			return Met
+		}
+		return Unmet
+	}
+	return Expectation{
+		Check:       check,
+		Description: "received workspace/codeLens/refresh",
+	}
+}
+
 // NoShownMessage asserts that the editor has not received a ShowMessage.
 func NoShownMessage(subString string) Expectation {
 	check := func(s State) Verdict {
@@ -0,0 +1,285 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package regtest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"golang.org/x/tools/gopls/pkg/lsp/lsprpc"
+	"golang.org/x/tools/gopls/pkg/lsp/protocol"
+	"golang.org/x/tools/pkg/jsonrpc2"
+	"golang.org/x/tools/pkg/jsonrpc2/servertest"
+)
+
+// A ReplayResult reports the outcome of replaying a recorded session.
+type ReplayResult struct {
+	// Mismatches are the responses that differ from the recorded ones.
+	Mismatches []ReplayMismatch
+
+	// Latencies holds the latencies of the replayed requests, by method.
+	Latencies map[string]*MethodLatency
+
+	// Skipped is the number of recorded requests that were not
+	// replayed, because the recording holds no response to them.
+	Skipped int
+}
+
+// A ReplayMismatch is a response that differs from the recorded one.
+type ReplayMismatch struct {
+	Method    string
+	Elapsed   time.Duration // of the request, since the start of the recording
+	Want, Got string        // JSON results, or errors
+}
+
+func (m ReplayMismatch) String() string {
+	return fmt.Sprintf("%s at %v:\nwant: %s\ngot:  %s", m.Method, m.Elapsed, m.Want, m.Got)
+}
+
+// MethodLatency aggregates the latencies of the requests of a method.
+type MethodLatency struct {
+	Count                    int
+	Recorded, Replayed       time.Duration // total
+	MaxRecorded, MaxReplayed time.Duration
+}
+
+func (l *MethodLatency) add(recorded, replayed time.Duration) {
+	l.Count++
+	l.Recorded += recorded
+	l.Replayed += replayed
+	if recorded > l.MaxRecorded {
+		l.MaxRecorded = recorded
+	}
+	if replayed > l.MaxReplayed {
+		l.MaxReplayed = replayed
+	}
+}
+
+// WriteReport writes a table of the per-method latencies of the replay,
+// and the number of mismatched responses, to w.
+func (r *ReplayResult) WriteReport(w io.Writer) error {
+	var methods []string
+	for method := range r.Latencies {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "method\tcount\trecorded mean\treplayed mean\trecorded max\treplayed max")
+	for _, method := range methods {
+		l := r.Latencies[method]
+		n := time.Duration(l.Count)
+		fmt.Fprintf(tw, "%s\t%d\t%v\t%v\t%v\t%v\n", method, l.Count,
+			(l.Recorded / n).Round(time.Microsecond),
+			(l.Replayed / n).Round(time.Microsecond),
+			l.MaxRecorded.Round(time.Microsecond),
+			l.MaxReplayed.Round(time.Microsecond))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(w, "%d mismatched responses, %d requests skipped\n", len(r.Mismatches), r.Skipped)
+	return err
+}
+
+// uncomparedMethods are the methods whose responses are not expected
+// to be equivalent across gopls processes.
+var uncomparedMethods = map[string]bool{
+	"initialize": true, // holds the gopls version
+	"shutdown":   true,
+}
+
+// Replay replays the session of rec against the gopls server that ts
+// connects to, in the directory dir, into which the workspace files of
+// the recording are written.
+//
+// The messages of the client are sent in their recorded order, each
+// request waiting for its response, which is compared with the
+// recorded one. Requests from the server are answered with the
+// responses recorded for the requests of the same method, in order.
+// Notifications from the server, such as diagnostics, depend on timing
+// and are not compared.
+func Replay(ctx context.Context, rec *lsprpc.Recording, dir string, ts servertest.Connector) (*ReplayResult, error) {
+	for name, data := range rec.Files {
+		filename := filepath.Join(dir, filepath.FromSlash(name))
+		// ReadRecording rejects the names outside the workspace, but rec
+		// may have been built otherwise.
+		if rel, err := filepath.Rel(dir, filename); err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return nil, fmt.Errorf("file %q of recording is outside the workspace", name)
+		}
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(filename, data, 0644); err != nil {
+			return nil, err
+		}
+	}
+
+	// Rewrite the recorded workspace root, as a URI or as a path, to
+	// the replay directory.
+	rewrite := func(data []byte) []byte { return data }
+	if rec.Root != "" {
+		quote := func(s string) []byte {
+			data, _ := json.Marshal(s)
+			return data[1 : len(data)-1]
+		}
+		oldURI, newURI := quote(string(rec.Root)), quote(string(protocol.URIFromPath(dir)))
+		oldPath, newPath := quote(rec.Root.Path()), quote(dir)
+		rewrite = func(data []byte) []byte {
+			data = bytes.ReplaceAll(data, oldURI, newURI)
+			return bytes.ReplaceAll(data, oldPath, newPath)
+		}
+	}
+
+	// Index the recorded responses: those of the server to the
+	// requests of the client, and those of the client to the requests
+	// of the server, by method.
+	var (
+		serverResponses = make(map[jsonrpc2.ID]lsprpc.RecordedMessage)
+		serverMethods   = make(map[jsonrpc2.ID]string)
+
+		clientResponsesMu sync.Mutex
+		clientResponses   = make(map[string][]*jsonrpc2.Response)
+	)
+	for _, m := range rec.Messages {
+		switch msg := m.Message.(type) {
+		case *jsonrpc2.Call:
+			if !m.FromClient {
+				serverMethods[msg.ID()] = msg.Method()
+			}
+		case *jsonrpc2.Response:
+			if m.FromClient {
+				if method, ok := serverMethods[msg.ID()]; ok {
+					clientResponses[method] = append(clientResponses[method], msg)
+				}
+			} else {
+				serverResponses[msg.ID()] = m
+			}
+		}
+	}
+
+	conn := ts.Connect(ctx)
+	defer conn.Close()
+	conn.Go(ctx, protocol.Handlers(func(ctx context.Context, reply jsonrpc2.Replier, req jsonrpc2.Request) error {
+		if _, ok := req.(*jsonrpc2.Call); !ok {
+			return reply(ctx, nil, nil)
+		}
+		clientResponsesMu.Lock()
+		responses := clientResponses[req.Method()]
+		var resp *jsonrpc2.Response
+		if len(responses) > 0 {
+			resp, clientResponses[req.Method()] = responses[0], responses[1:]
+		}
+		clientResponsesMu.Unlock()
+		if resp == nil {
+			return reply(ctx, nil, nil)
+		}
+		if resp.Err() != nil {
+			return reply(ctx, nil, resp.Err())
+		}
+		return reply(ctx, json.RawMessage(rewrite(resp.Result())), nil)
+	}))
+
+	result := &ReplayResult{Latencies: make(map[string]*MethodLatency)}
+	for _, m := range rec.Messages {
+		if !m.FromClient {
+			continue
+		}
+		switch msg := m.Message.(type) {
+		case *jsonrpc2.Call:
+			want, ok := serverResponses[msg.ID()]
+			if !ok {
+				result.Skipped++
+				continue
+			}
+			start := time.Now()
+			var got json.RawMessage
+			_, err := conn.Call(ctx, msg.Method(), json.RawMessage(rewrite(msg.Params())), &got)
+			replayed := time.Since(start)
+			select {
+			case <-conn.Done():
+				return nil, fmt.Errorf("replaying %s: server disconnected: %v", msg.Method(), conn.Err())
+			default:
+			}
+
+			l := result.Latencies[msg.Method()]
+			if l == nil {
+				l = new(MethodLatency)
+				result.Latencies[msg.Method()] = l
+			}
+			l.add(want.Elapsed-m.Elapsed, replayed)
+
+			if uncomparedMethods[msg.Method()] {
+				continue
+			}
+			wantResp := want.Message.(*jsonrpc2.Response)
+			mismatch := ReplayMismatch{Method: msg.Method(), Elapsed: m.Elapsed}
+			switch {
+			case wantResp.Err() != nil && wantResp.Err().Error() == protocol.RequestCancelledError.Error():
+				// The recorded request was cancelled, so there
+				// is no result to compare.
+			case wantResp.Err() != nil && err != nil:
+				// Both failed. Errors such as cancellation
+				// depend on timing, so their messages aren't
+				// compared.
+			case wantResp.Err() != nil:
+				mismatch.Want, mismatch.Got = "error: "+wantResp.Err().Error(), string(got)
+				result.Mismatches = append(result.Mismatches, mismatch)
+			case err != nil:
+				mismatch.Want, mismatch.Got = string(rewrite(wantResp.Result())), "error: "+err.Error()
+				result.Mismatches = append(result.Mismatches, mismatch)
+			default:
+				wantResult := rewrite(wantResp.Result())
+				if !equalJSON(wantResult, got) {
+					mismatch.Want, mismatch.Got = string(wantResult), string(got)
+					result.Mismatches = append(result.Mismatches, mismatch)
+				}
+			}
+
+		case *jsonrpc2.Notification:
+			switch msg.Method() {
+			case "$/cancelRequest":
+				// Requests are replayed synchronously, so
+				// there is nothing to cancel.
+				continue
+			case "exit":
+				// Closing the connection ends the session.
+				continue
+			}
+			if err := conn.Notify(ctx, msg.Method(), json.RawMessage(rewrite(msg.Params()))); err != nil {
+				return nil, fmt.Errorf("replaying %s: %v", msg.Method(), err)
+			}
+		}
+	}
+	return result, nil
+}
+
+// equalJSON reports whether x and y encode equal JSON values.
+func equalJSON(x, y []byte) bool {
+	var xv, yv interface{}
+	if len(x) == 0 {
+		x = []byte("null")
+	}
+	if len(y) == 0 {
+		y = []byte("null")
+	}
+	if err := json.Unmarshal(x, &xv); err != nil {
+		return false
+	}
+	if err := json.Unmarshal(y, &yv); err != nil {
+		return false
+	}
+	return reflect.DeepEqual(xv, yv)
+}
@@ -0,0 +1,119 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package regtest
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/tools/gopls/pkg/lsp/cache"
+	"golang.org/x/tools/gopls/pkg/lsp/fake"
+	"golang.org/x/tools/gopls/pkg/lsp/lsprpc"
+	"golang.org/x/tools/pkg/jsonrpc2"
+	"golang.org/x/tools/pkg/jsonrpc2/servertest"
+)
+
+// A recordingConnector connects to a gopls server whose session is
+// recorded.
+type recordingConnector struct {
+	server   jsonrpc2.StreamServer
+	recorder *lsprpc.Recorder
+	served   chan struct{}
+}
+
+func (c *recordingConnector) Connect(ctx context.Context) jsonrpc2.Conn {
+	sPipe, cPipe := net.Pipe()
+	serverConn := jsonrpc2.NewConn(c.recorder.ClientStream(jsonrpc2.NewRawStream(sPipe)))
+	go func() {
+		defer close(c.served)
+		c.server.ServeStream(ctx, serverConn)
+	}()
+	return jsonrpc2.NewConn(jsonrpc2.NewRawStream(cPipe))
+}
+
+func TestReplay(t *testing.T) {
+	ctx := context.Background()
+
+	// Record a session of the fake editor.
+	sandbox, err := fake.NewSandbox(&fake.SandboxConfig{
+		Files: fake.UnpackTxt(`
+-- go.mod --
+module mod.com
+
+go 1.18
+-- a.go --
+package a
+
+func F() int { return G() }
+
+func G() int { return 1 }
+`),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sandbox.Close()
+	recording := filepath.Join(t.TempDir(), "session.txtar")
+	recorder, err := lsprpc.NewRecorder(recording)
+	if err != nil {
+		t.Fatal(err)
+	}
+	connector := &recordingConnector{
+		server:   lsprpc.NewStreamServer(cache.New(nil), false, nil),
+		recorder: recorder,
+		served:   make(chan struct{}),
+	}
+	editor, err := fake.NewEditor(sandbox, fake.EditorConfig{}).Connect(ctx, connector, fake.ClientHooks{}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := editor.OpenFile(ctx, "a.go"); err != nil {
+		t.Fatal(err)
+	}
+	loc, err := editor.RegexpSearch("a.go", `G\(\)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := editor.Hover(ctx, loc); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := editor.Definition(ctx, loc); err != nil {
+		t.Fatal(err)
+	}
+	if err := editor.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+	<-connector.served
+	if err := recorder.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Replay it against a new server, in another directory.
+	data, err := os.ReadFile(recording)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec, err := lsprpc.ReadRecording(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := servertest.NewPipeServer(lsprpc.NewStreamServer(cache.New(nil), false, nil), nil)
+	defer ts.Close()
+	result, err := Replay(ctx, rec, t.TempDir(), ts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range result.Mismatches {
+		t.Errorf("mismatched response to %v", m)
+	}
+	for _, method := range []string{"initialize", "textDocument/hover", "textDocument/definition"} {
+		if l := result.Latencies[method]; l == nil || l.Count != 1 || l.Replayed <= 0 {
+			t.Errorf("latencies of %s = %+v, want 1 replayed request", method, l)
+		}
+	}
+}
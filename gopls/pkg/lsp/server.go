@@ -107,12 +107,27 @@ type server struct {
 	gcOptimizationDetailsMu sync.Mutex
 	gcOptimizationDetails   map[source.PackageID]struct{}
 
+	// benchmarks holds the results of the benchmarks run by the RunTests
+	// command, shown in their code lenses.
+	benchmarks benchmarkResults
+
+	// pendingCompletion is the last completion list offered to the client,
+	// whose accepted candidate is observed in the following edits.
+	pendingCompletionMu sync.Mutex
+	pendingCompletion   *pendingCompletion
+
 	// diagnosticsSema limits the concurrency of diagnostics runs, which can be
 	// expensive.
 	diagnosticsSema chan struct{}
 
 	progress *progress.Tracker
 
+	// interactiveMu guards the count of interactive requests (such as
+	// completion) in progress, so that background work may yield to them.
+	interactiveMu sync.Mutex
+	interactive   int
+	idle          chan struct{} // closed when interactive drops to zero; nil if zero
+
 	// When the workspace fails to load, we show its status through a progress
 	// report with an error message.
 	criticalErrorStatusMu sync.Mutex
@@ -126,6 +141,12 @@ type server struct {
 	// Track most recently requested options.
 	optionsMu sync.Mutex
 	options   *settings.Options
+
+	// telemetryHistoryFile is the file to which snapshots of the telemetry
+	// counters are appended until stopTelemetryHistory is called.
+	// Guarded by optionsMu.
+	telemetryHistoryFile string
+	stopTelemetryHistory func()
 }
 
 func (s *server) WorkDoneProgressCancel(ctx context.Context, params *protocol.WorkDoneProgressCancelParams) error {
@@ -6,6 +6,7 @@ package source
 
 import (
 	"context"
+	"fmt"
 	"go/ast"
 	"go/token"
 	"go/types"
@@ -23,16 +24,19 @@ type LensFunc func(context.Context, Snapshot, file.Handle) ([]protocol.CodeLens,
 // LensFuncs returns the supported lensFuncs for Go files.
 func LensFuncs() map[command.Command]LensFunc {
 	return map[command.Command]LensFunc{
-		command.Generate:      goGenerateCodeLens,
-		command.Test:          runTestCodeLens,
-		command.RegenerateCgo: regenerateCgoLens,
-		command.GCDetails:     toggleDetailsCodeLens,
+		command.Generate:        goGenerateCodeLens,
+		command.Test:            runTestCodeLens,
+		command.RegenerateCgo:   regenerateCgoLens,
+		command.GCDetails:       toggleDetailsCodeLens,
+		command.References:      referencesCodeLens,
+		command.Implementations: implementationsCodeLens,
 	}
 }
 
 var (
 	testRe      = regexp.MustCompile(`^Test([^a-z]|$)`) // TestFoo or Test but not Testable
 	benchmarkRe = regexp.MustCompile(`^Benchmark([^a-z]|$)`)
+	fuzzRe      = regexp.MustCompile(`^Fuzz([^a-z]|$)`)
 )
 
 func runTestCodeLens(ctx context.Context, snapshot Snapshot, fh file.Handle) ([]protocol.CodeLens, error) {
@@ -65,6 +69,21 @@ func runTestCodeLens(ctx context.Context, snapshot Snapshot, fh file.Handle) ([]
 		codeLens = append(codeLens, protocol.CodeLens{Range: rng, Command: &cmd})
 	}
 
+	for _, fn := range fns.Fuzzes {
+		fuzzCmd, err := command.NewRunTestsCommand("run fuzz for 30s", command.RunTestsArgs{URI: puri, Fuzz: []string{fn.Name}, FuzzTime: "30s"})
+		if err != nil {
+			return nil, err
+		}
+		seedCmd, err := command.NewRunTestsCommand("run seed corpus", command.RunTestsArgs{URI: puri, Tests: []string{fn.Name}})
+		if err != nil {
+			return nil, err
+		}
+		rng := protocol.Range{Start: fn.Rng.Start, End: fn.Rng.Start}
+		codeLens = append(codeLens,
+			protocol.CodeLens{Range: rng, Command: &fuzzCmd},
+			protocol.CodeLens{Range: rng, Command: &seedCmd})
+	}
+
 	if len(fns.Benchmarks) > 0 {
 		pgf, err := snapshot.ParseGo(ctx, fh, ParseFull)
 		if err != nil {
@@ -96,6 +115,7 @@ type TestFn struct {
 type TestFns struct {
 	Tests      []TestFn
 	Benchmarks []TestFn
+	Fuzzes     []TestFn
 }
 
 func TestsAndBenchmarks(pkg Package, pgf *ParsedGoFile) (TestFns, error) {
@@ -123,6 +143,10 @@ func TestsAndBenchmarks(pkg Package, pgf *ParsedGoFile) (TestFns, error) {
 		if matchTestFunc(fn, pkg, benchmarkRe, "B") {
 			out.Benchmarks = append(out.Benchmarks, TestFn{fn.Name.Name, rng})
 		}
+
+		if matchTestFunc(fn, pkg, fuzzRe, "F") {
+			out.Fuzzes = append(out.Fuzzes, TestFn{fn.Name.Name, rng})
+		}
 	}
 
 	return out, nil
@@ -246,3 +270,107 @@ func toggleDetailsCodeLens(ctx context.Context, snapshot Snapshot, fh file.Handl
 	}
 	return []protocol.CodeLens{{Range: rng, Command: &cmd}}, nil
 }
+
+// LensData is the data of the code lenses whose command is computed lazily,
+// by ResolveCodeLens, as counting references requires type-checking the
+// reverse dependencies of the package.
+type LensData struct {
+	Lens     command.Command   // References or Implementations
+	Location protocol.Location // name of the declaration
+}
+
+// referencesCodeLens returns an unresolved lens above each top-level
+// function, method and type declaration, which shows the number of its
+// references.
+func referencesCodeLens(ctx context.Context, snapshot Snapshot, fh file.Handle) ([]protocol.CodeLens, error) {
+	return declarationCodeLenses(ctx, snapshot, fh, command.References, true)
+}
+
+// implementationsCodeLens returns an unresolved lens above each top-level
+// type declaration, which shows the number of its implementations.
+func implementationsCodeLens(ctx context.Context, snapshot Snapshot, fh file.Handle) ([]protocol.CodeLens, error) {
+	return declarationCodeLenses(ctx, snapshot, fh, command.Implementations, false)
+}
+
+func declarationCodeLenses(ctx context.Context, snapshot Snapshot, fh file.Handle, lens command.Command, funcs bool) ([]protocol.CodeLens, error) {
+	pgf, err := snapshot.ParseGo(ctx, fh, ParseFull)
+	if err != nil {
+		return nil, err
+	}
+	var lenses []protocol.CodeLens
+	add := func(pos token.Pos, name *ast.Ident) error {
+		if name.Name == "_" {
+			return nil
+		}
+		rng, err := pgf.PosRange(pos, pos)
+		if err != nil {
+			return err
+		}
+		loc, err := pgf.NodeLocation(name)
+		if err != nil {
+			return err
+		}
+		lenses = append(lenses, protocol.CodeLens{
+			Range: rng,
+			Data:  LensData{Lens: lens, Location: loc},
+		})
+		return nil
+	}
+	for _, decl := range pgf.File.Decls {
+		switch decl := decl.(type) {
+		case *ast.FuncDecl:
+			if funcs {
+				if err := add(decl.Pos(), decl.Name); err != nil {
+					return nil, err
+				}
+			}
+		case *ast.GenDecl:
+			if decl.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range decl.Specs {
+				spec := spec.(*ast.TypeSpec)
+				pos := decl.Pos()
+				if decl.Lparen.IsValid() {
+					pos = spec.Pos() // grouped declaration
+				}
+				if err := add(pos, spec.Name); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return lenses, nil
+}
+
+// ResolveCodeLens computes the command of a code lens with the given data,
+// whose title is the number of references or implementations of the
+// declaration.
+func ResolveCodeLens(ctx context.Context, snapshot Snapshot, fh file.Handle, lens *protocol.CodeLens, data LensData) error {
+	var (
+		locs []protocol.Location
+		err  error
+		cmd  protocol.Command
+	)
+	switch data.Lens {
+	case command.References:
+		locs, err = References(ctx, snapshot, fh, data.Location.Range.Start, false)
+		if err != nil {
+			return err
+		}
+		cmd, err = command.NewReferencesCommand(fmt.Sprintf("%d %s", len(locs), pluralize(len(locs), "reference", "references")), data.Location)
+	case command.Implementations:
+		locs, err = Implementation(ctx, snapshot, fh, data.Location.Range.Start)
+		if err != nil {
+			return err
+		}
+		cmd, err = command.NewImplementationsCommand(fmt.Sprintf("%d %s", len(locs), pluralize(len(locs), "implementation", "implementations")), data.Location)
+	default:
+		return fmt.Errorf("code lens %q cannot be resolved", data.Lens)
+	}
+	if err != nil {
+		return err
+	}
+	lens.Command = &cmd
+	return nil
+}
@@ -78,6 +78,10 @@ type CompletionItem struct {
 	// A higher score indicates that this completion item is more relevant.
 	Score float64
 
+	// UsageKey identifies the completed object in usage statistics (see
+	// Usage), or is empty if it is not tracked.
+	UsageKey string
+
 	// snippet is the LSP snippet for the completion item. The LSP
 	// specification contains details about LSP snippets. For example, a
 	// snippet for a function with the following signature:
@@ -111,6 +115,7 @@ type completionOptions struct {
 	placeholders          bool
 	snippets              bool
 	postfix               bool
+	postfixSnippets       map[string]string
 	matcher               settings.Matcher
 	budget                time.Duration
 	completeFunctionCalls bool
@@ -172,6 +177,7 @@ type completer struct {
 	qf       types.Qualifier          // for qualifying typed expressions
 	mq       source.MetadataQualifier // for syntactic qualifying
 	opts     *completionOptions
+	usage    *Usage // may be nil
 
 	// completionContext contains information about the trigger for this
 	// completion request.
@@ -443,7 +449,10 @@ func (e ErrIsDefinition) Error() string {
 // The selection is computed based on the preceding identifier and can be used by
 // the client to score the quality of the completion. For instance, some clients
 // may tolerate imperfect matches as valid completion results, since users may make typos.
-func Completion(ctx context.Context, snapshot source.Snapshot, fh file.Handle, protoPos protocol.Position, protoContext protocol.CompletionContext) ([]CompletionItem, *Selection, error) {
+//
+// If usage is non-nil, the usage statistics of the workspace are blended
+// into the relevance score of the candidates.
+func Completion(ctx context.Context, snapshot source.Snapshot, fh file.Handle, protoPos protocol.Position, protoContext protocol.CompletionContext, usage *Usage) ([]CompletionItem, *Selection, error) {
 	ctx, done := event.Start(ctx, "completion.Completion")
 	defer done()
 
@@ -484,6 +493,10 @@ func Completion(ctx context.Context, snapshot source.Snapshot, fh file.Handle, p
 				break
 			}
 		}
+		// Complete the language embedded in the literal, if any.
+		if items, sel, ok := embeddedLanguageCompletions(pkg, pgf, path, pos, snapshot.Options().EmbeddedLanguages); ok {
+			return items, sel, nil
+		}
 		return nil, nil, nil
 	case *ast.CallExpr:
 		if n.Ellipsis.IsValid() && pos > n.Ellipsis && pos <= n.Ellipsis+token.Pos(len("...")) {
@@ -525,6 +538,7 @@ func Completion(ctx context.Context, snapshot source.Snapshot, fh file.Handle, p
 	c := &completer{
 		pkg:      pkg,
 		snapshot: snapshot,
+		usage:    usage,
 		qf:       source.Qualifier(pgf.File, pkg.GetTypes(), pkg.GetTypesInfo()),
 		mq:       source.MetadataQualifierForFile(snapshot, pgf.File, pkg.Metadata()),
 		completionContext: completionContext{
@@ -552,6 +566,7 @@ func Completion(ctx context.Context, snapshot source.Snapshot, fh file.Handle, p
 			budget:                opts.CompletionBudget,
 			snippets:              opts.InsertTextFormat == protocol.SnippetTextFormat,
 			postfix:               opts.ExperimentalPostfixCompletions,
+			postfixSnippets:       opts.PostfixSnippets,
 			completeFunctionCalls: opts.CompleteFunctionCalls,
 		},
 		// default to a matcher that always matches
@@ -0,0 +1,279 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package completion
+
+import (
+	"go/ast"
+	"go/token"
+	"strings"
+	"unicode"
+
+	"golang.org/x/tools/gopls/pkg/lsp/protocol"
+	"golang.org/x/tools/gopls/pkg/lsp/source"
+	"golang.org/x/tools/gopls/pkg/settings"
+)
+
+// embeddedLanguageCompletions returns completions within the string literal
+// path[0] that holds a regular expression, SQL statement or struct tag, as
+// determined by source.EmbeddedLanguage. ok is false if the literal has no
+// embedded language.
+func embeddedLanguageCompletions(pkg source.Package, pgf *source.ParsedGoFile, path []ast.Node, pos token.Pos, langs map[string]string) (items []CompletionItem, sel *Selection, ok bool) {
+	lang := source.EmbeddedLanguage(pkg.GetTypesInfo(), path, langs)
+	if lang == "" {
+		return nil, nil, false
+	}
+	lit := path[0].(*ast.BasicLit)
+	offset := int(pos - lit.Pos())
+	if offset < 1 || offset > len(lit.Value) {
+		return nil, nil, true // on the opening quote
+	}
+	// The text of the literal preceding the cursor, as written in the source.
+	text := lit.Value[1:offset]
+	raw := lit.Value[0] == '`'
+
+	var (
+		prefix     string
+		candidates []embeddedCandidate
+	)
+	switch lang {
+	case settings.RegexpLanguage:
+		prefix, candidates = regexpCandidates(text, raw)
+	case settings.SQLLanguage:
+		prefix, candidates = sqlCandidates(text)
+	case settings.StructTagLanguage:
+		if !raw {
+			return nil, nil, true
+		}
+		prefix, candidates = structTagCandidates(text, path[1].(*ast.Field))
+	}
+
+	for _, cand := range candidates {
+		if !strings.HasPrefix(strings.ToLower(cand.text), strings.ToLower(prefix)) {
+			continue
+		}
+		items = append(items, CompletionItem{
+			Label:      cand.text,
+			InsertText: cand.text,
+			Detail:     cand.detail,
+			Kind:       cand.kind,
+			// Preserve the order of the candidates.
+			Score: float64(len(candidates) - len(items)),
+		})
+	}
+	start := pos - token.Pos(len(prefix))
+	return items, &Selection{
+		content: prefix,
+		cursor:  pos,
+		tokFile: pgf.Tok,
+		start:   start,
+		end:     pos,
+		mapper:  pgf.Mapper,
+	}, true
+}
+
+// An embeddedCandidate is a completion candidate in an embedded language.
+type embeddedCandidate struct {
+	text   string
+	detail string
+	kind   protocol.CompletionItemKind
+}
+
+// regexpCandidates returns the regular expression constructs that complete
+// the escape sequence, character class or group at the end of text, and the
+// prefix they replace. Backslashes are doubled unless the literal is raw.
+func regexpCandidates(text string, raw bool) (string, []embeddedCandidate) {
+	backslash := `\`
+	if !raw {
+		backslash = `\\`
+	}
+
+	var constructs [][2]string
+	var prefix string
+	if i := strings.LastIndex(text, backslash); i >= 0 && len(text)-i-len(backslash) <= 1 {
+		prefix = text[i:]
+		constructs = [][2]string{
+			{`\d`, "digit"},
+			{`\D`, "not digit"},
+			{`\s`, "whitespace"},
+			{`\S`, "not whitespace"},
+			{`\w`, "word character"},
+			{`\W`, "not word character"},
+			{`\b`, "word boundary"},
+			{`\B`, "not word boundary"},
+			{`\A`, "beginning of text"},
+			{`\z`, "end of text"},
+			{`\pL`, "Unicode letter"},
+			{`\QLiteral\E`, "literal text"},
+		}
+	} else if i := strings.LastIndex(text, "[:"); i >= 0 && isLetters(text[i+len("[:"):]) {
+		prefix = text[i:]
+		for _, class := range []string{"alnum", "alpha", "ascii", "blank", "cntrl", "digit", "graph", "lower", "print", "punct", "space", "upper", "word", "xdigit"} {
+			constructs = append(constructs, [2]string{"[:" + class + ":]", "ASCII character class"})
+		}
+	} else if i := strings.LastIndex(text, "(?"); i >= 0 && !strings.ContainsAny(text[i:], ")>:") {
+		prefix = text[i:]
+		constructs = [][2]string{
+			{"(?:", "non-capturing group"},
+			{"(?P<", "named capturing group"},
+			{"(?i)", "case-insensitive"},
+			{"(?m)", "multi-line mode"},
+			{"(?s)", "let . match \\n"},
+			{"(?U)", "ungreedy"},
+		}
+	} else {
+		return "", nil
+	}
+
+	var candidates []embeddedCandidate
+	for _, c := range constructs {
+		candidates = append(candidates, embeddedCandidate{
+			text:   strings.ReplaceAll(c[0], `\`, backslash),
+			detail: c[1],
+			kind:   protocol.ConstantCompletion,
+		})
+	}
+	return prefix, candidates
+}
+
+var sqlKeywords = []string{
+	"SELECT", "FROM", "WHERE", "AND", "OR", "NOT", "NULL", "IS", "IN", "LIKE",
+	"BETWEEN", "AS", "DISTINCT", "JOIN", "LEFT JOIN", "INNER JOIN", "ON",
+	"GROUP BY", "ORDER BY", "HAVING", "LIMIT", "OFFSET", "ASC", "DESC",
+	"INSERT INTO", "VALUES", "UPDATE", "SET", "DELETE FROM", "RETURNING",
+	"COUNT", "EXISTS", "UNION", "CASE", "WHEN", "THEN", "ELSE", "END",
+}
+
+// sqlCandidates returns the SQL keywords completing the word at the end of
+// text, and the word. Keywords are lower case if the word is.
+func sqlCandidates(text string) (string, []embeddedCandidate) {
+	i := len(text)
+	for i > 0 && (isLetter(rune(text[i-1])) || text[i-1] == '_') {
+		i--
+	}
+	prefix := text[i:]
+	lower := prefix != "" && prefix == strings.ToLower(prefix)
+
+	var candidates []embeddedCandidate
+	for _, kw := range sqlKeywords {
+		if lower {
+			kw = strings.ToLower(kw)
+		}
+		candidates = append(candidates, embeddedCandidate{text: kw, kind: protocol.KeywordCompletion})
+	}
+	return prefix, candidates
+}
+
+// structTagOptions holds the options of the values of well-known struct tag
+// keys.
+var structTagOptions = map[string][]string{
+	"json": {"omitempty", "string"},
+	"xml":  {"attr", "chardata", "cdata", "innerxml", "comment", "omitempty", "any"},
+	"yaml": {"omitempty", "flow", "inline"},
+}
+
+// structTagCandidates returns the candidates completing the key, name or
+// option at the end of the text of the tag of field, and the prefix they
+// replace.
+func structTagCandidates(text string, field *ast.Field) (string, []embeddedCandidate) {
+	var fieldName string
+	if len(field.Names) > 0 {
+		fieldName = field.Names[0].Name
+	}
+
+	// Scan the key:"value" pairs preceding the cursor.
+	for {
+		text = strings.TrimLeft(text, " ")
+		colon := strings.IndexByte(text, ':')
+		if colon < 0 {
+			// Completing a key: offer whole pairs, named after the field.
+			var candidates []embeddedCandidate
+			for _, key := range []string{"json", "xml", "yaml", "toml", "db"} {
+				candidates = append(candidates, embeddedCandidate{
+					text:   key + `:"` + tagName(key, fieldName) + `"`,
+					detail: key + " field name",
+					kind:   protocol.PropertyCompletion,
+				})
+			}
+			return text, candidates
+		}
+		key, rest := text[:colon], text[colon+1:]
+		if !strings.HasPrefix(rest, `"`) {
+			return "", nil // malformed
+		}
+		value := rest[1:]
+		end := strings.IndexByte(value, '"')
+		if end >= 0 {
+			text = value[end+1:]
+			continue
+		}
+
+		// Completing a value.
+		if comma := strings.LastIndexByte(value, ','); comma >= 0 {
+			var candidates []embeddedCandidate
+			for _, opt := range structTagOptions[key] {
+				candidates = append(candidates, embeddedCandidate{text: opt, detail: key + " option", kind: protocol.KeywordCompletion})
+			}
+			return value[comma+1:], candidates
+		}
+		var candidates []embeddedCandidate
+		if name := tagName(key, fieldName); name != "" {
+			candidates = append(candidates, embeddedCandidate{text: name, detail: "field name", kind: protocol.FieldCompletion})
+		}
+		candidates = append(candidates, embeddedCandidate{text: "-", detail: "omit field", kind: protocol.KeywordCompletion})
+		return value, candidates
+	}
+}
+
+// tagName returns the conventional name for the field in the value of the
+// given tag key: snake_case for database columns, and lowerCamelCase for
+// encodings.
+func tagName(key, fieldName string) string {
+	if key == "db" {
+		return snakeCase(fieldName)
+	}
+	return lowerCamelCase(fieldName)
+}
+
+// lowerCamelCase converts a Go identifier to lower camel case, lowering the
+// leading initialism, if any: "URLPath" becomes "urlPath".
+func lowerCamelCase(name string) string {
+	runes := []rune(name)
+	for i := 0; i < len(runes) && unicode.IsUpper(runes[i]); i++ {
+		// Keep the upper case letter that starts the next word.
+		if i > 0 && i+1 < len(runes) && unicode.IsLower(runes[i+1]) {
+			break
+		}
+		runes[i] = unicode.ToLower(runes[i])
+	}
+	return string(runes)
+}
+
+// snakeCase converts a Go identifier to snake case: "URLPath" becomes
+// "url_path".
+func snakeCase(name string) string {
+	var b strings.Builder
+	runes := []rune(name)
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			// Start a new word before an upper case letter that follows a
+			// lower case letter, or that starts a word after an initialism.
+			if i > 0 && (unicode.IsLower(runes[i-1]) || i+1 < len(runes) && unicode.IsLower(runes[i+1])) {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func isLetters(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if !isLetter(rune(s[i])) {
+			return false
+		}
+	}
+	return true
+}
@@ -37,6 +37,7 @@ func (c *completer) item(ctx context.Context, cand candidate) (CompletionItem, e
 		return CompletionItem{}, errNoMatch
 	}
 	cand.score *= float64(matchScore)
+	cand.score *= c.usage.boost(UsageKey(obj))
 
 	// Ignore deep candidates that won't be in the MaxDeepCompletions anyway.
 	if len(cand.path) != 0 && !c.deepState.isHighScore(cand.score) {
@@ -232,6 +233,7 @@ Suffixes:
 		Detail:              detail,
 		Kind:                kind,
 		Score:               cand.score,
+		UsageKey:            UsageKey(obj),
 		Depth:               len(cand.path),
 		snippet:             &snip,
 		isSlice:             isSlice(obj),
@@ -12,6 +12,7 @@ import (
 	"go/types"
 	"log"
 	"reflect"
+	"sort"
 	"strings"
 	"sync"
 	"text/template"
@@ -379,7 +380,7 @@ func (c *completer) addPostfixSnippetCandidates(ctx context.Context, sel *ast.Se
 		afterDot = c.pos
 	}
 
-	for _, rule := range postfixTmpls {
+	for _, rule := range c.postfixRules(ctx) {
 		// When completing foo.print<>, "print" is naturally overwritten,
 		// but we need to also remove "foo." so the snippet has a clean
 		// slate.
@@ -446,6 +447,51 @@ func initPostfixRules() {
 	})
 }
 
+// userPostfixTmpls caches the parsed templates of user-defined postfix
+// snippets, keyed by their body.
+var userPostfixTmpls sync.Map // string -> *template.Template
+
+// postfixRules returns the built-in postfix snippets, followed by the
+// user-defined snippets in label order. A user-defined snippet replaces the
+// built-in snippet with the same label.
+func (c *completer) postfixRules(ctx context.Context) []postfixTmpl {
+	if len(c.opts.postfixSnippets) == 0 {
+		return postfixTmpls
+	}
+
+	var rules []postfixTmpl
+	for _, rule := range postfixTmpls {
+		if _, ok := c.opts.postfixSnippets[rule.label]; !ok {
+			rules = append(rules, rule)
+		}
+	}
+	var labels []string
+	for label := range c.opts.postfixSnippets {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+	for _, label := range labels {
+		body := c.opts.postfixSnippets[label]
+		tmpl, ok := userPostfixTmpls.Load(body)
+		if !ok {
+			// Snippets are validated when the settings are parsed, so an error
+			// here is unexpected.
+			parsed, err := template.New("postfix_snippet").Parse(body)
+			if err != nil {
+				event.Error(ctx, fmt.Sprintf("error parsing postfix snippet %q", label), err)
+				continue
+			}
+			tmpl, _ = userPostfixTmpls.LoadOrStore(body, parsed)
+		}
+		rules = append(rules, postfixTmpl{
+			label: label,
+			body:  body,
+			tmpl:  tmpl.(*template.Template),
+		})
+	}
+	return rules
+}
+
 // importIfNeeded returns the package identifier and any necessary
 // edits to import package pkgPath.
 func (c *completer) importIfNeeded(pkgPath string, scope *types.Scope) (string, []protocol.TextEdit, error) {
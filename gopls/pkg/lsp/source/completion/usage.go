@@ -0,0 +1,70 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package completion
+
+import (
+	"go/types"
+	"math"
+)
+
+// Usage holds statistics of how much objects are used in the workspace,
+// keyed by UsageKey. They are blended into the relevance score of the
+// candidates, so that the objects used the most rank first among otherwise
+// equally relevant candidates.
+type Usage struct {
+	// References counts the references from the workspace packages to
+	// the package-level members of the packages they import.
+	References map[string]int
+
+	// Accepted counts the candidates accepted in previous completions.
+	Accepted map[string]int
+}
+
+// Weights of the usage statistics in the relevance score. Usage is a weak
+// signal compared to the expected type, so the score grows with the
+// logarithm of the counts.
+const (
+	referenceWeight = 0.05
+	acceptedWeight  = 0.1
+)
+
+// UsageKey returns the key of the object in usage statistics: its package
+// path and name for package-level members, or its package path, receiver
+// type name and name for methods. It returns "" for objects that are not
+// tracked, such as fields, local variables and builtins, which would
+// otherwise share the keys of unrelated members.
+func UsageKey(obj types.Object) string {
+	if obj == nil || obj.Pkg() == nil {
+		return ""
+	}
+	if fn, ok := obj.(*types.Func); ok {
+		if recv := fn.Type().(*types.Signature).Recv(); recv != nil {
+			t := recv.Type()
+			if ptr, ok := t.(*types.Pointer); ok {
+				t = ptr.Elem()
+			}
+			named, ok := t.(*types.Named)
+			if !ok {
+				return "" // method of an unnamed interface
+			}
+			return obj.Pkg().Path() + "." + named.Obj().Name() + "." + obj.Name()
+		}
+	}
+	if obj.Parent() != obj.Pkg().Scope() {
+		return "" // field or local
+	}
+	return obj.Pkg().Path() + "." + obj.Name()
+}
+
+// boost returns the factor by which the score of a candidate with the given
+// usage key is multiplied.
+func (u *Usage) boost(key string) float64 {
+	if u == nil || key == "" {
+		return 1
+	}
+	return 1 +
+		referenceWeight*math.Log1p(float64(u.References[key])) +
+		acceptedWeight*math.Log1p(float64(u.Accepted[key]))
+}
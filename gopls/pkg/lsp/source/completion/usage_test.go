@@ -0,0 +1,57 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package completion
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"testing"
+)
+
+func TestUsageKey(t *testing.T) {
+	const src = `package p
+
+type Regexp struct{ Match int }
+
+func (*Regexp) String() string { return "" }
+
+func Match() bool { return false }
+
+type Stringer interface{ String() string }
+
+func f() { var Match int; _ = Match }
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := &types.Info{Defs: make(map[*ast.Ident]types.Object)}
+	if _, err := new(types.Config).Check("example.com/p", fset, []*ast.File{f}, info); err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]string) // key of each object, by position
+	for id, obj := range info.Defs {
+		if obj != nil {
+			got[fset.Position(id.Pos()).String()] = UsageKey(obj)
+		}
+	}
+	for pos, want := range map[string]string{
+		"p.go:3:6":   "example.com/p.Regexp",
+		"p.go:3:21":  "", // field
+		"p.go:5:16":  "example.com/p.Regexp.String",
+		"p.go:7:6":   "example.com/p.Match",
+		"p.go:9:26":  "example.com/p.Stringer.String",
+		"p.go:11:16": "", // local
+	} {
+		if key, ok := got[pos]; !ok {
+			t.Errorf("no object defined at %s", pos)
+		} else if key != want {
+			t.Errorf("UsageKey of the object at %s = %q, want %q", pos, key, want)
+		}
+	}
+}
@@ -0,0 +1,265 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"regexp/syntax"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/types/typeutil"
+	"golang.org/x/tools/gopls/pkg/lsp/protocol"
+	"golang.org/x/tools/gopls/pkg/settings"
+	"golang.org/x/tools/pkg/event"
+)
+
+// EmbeddedLanguage returns the language of the string literal path[0], as
+// determined by its context path[1:], or "" if it has none.
+//
+// The literal of a struct field tag has the "structtag" language. A literal
+// passed to a function of the langs table (see settings.EmbeddedLanguages)
+// has the language of the function if it is the argument of the first string
+// parameter.
+func EmbeddedLanguage(info *types.Info, path []ast.Node, langs map[string]string) string {
+	if len(path) < 2 {
+		return ""
+	}
+	lit, ok := path[0].(*ast.BasicLit)
+	if !ok || lit.Kind != token.STRING {
+		return ""
+	}
+	switch parent := path[1].(type) {
+	case *ast.Field:
+		if parent.Tag == lit {
+			return settings.StructTagLanguage
+		}
+	case *ast.CallExpr:
+		fn, _ := typeutil.Callee(info, parent).(*types.Func)
+		if fn == nil {
+			return ""
+		}
+		lang := langs[fn.FullName()]
+		if lang == "" {
+			return ""
+		}
+		params := fn.Type().(*types.Signature).Params()
+		for i := 0; i < params.Len() && i < len(parent.Args); i++ {
+			if basic, ok := params.At(i).Type().Underlying().(*types.Basic); ok && basic.Kind() == types.String {
+				if parent.Args[i] == lit {
+					return lang
+				}
+				break
+			}
+		}
+	}
+	return ""
+}
+
+// EmbeddedLanguageDiagnostics returns the errors in the regular expressions
+// and SQL statements embedded in the string literals of the given packages,
+// as configured by the EmbeddedLanguages option.
+//
+// SQL statements are only checked for balanced parentheses, quotes and
+// comments, and their errors are reported as warnings.
+func EmbeddedLanguageDiagnostics(ctx context.Context, snapshot Snapshot, ids ...PackageID) (map[protocol.DocumentURI][]*Diagnostic, error) {
+	langs := snapshot.Options().EmbeddedLanguages
+	if len(langs) == 0 || len(ids) == 0 {
+		return nil, nil
+	}
+
+	ctx, done := event.Start(ctx, "source.EmbeddedLanguageDiagnostics")
+	defer done()
+
+	pkgs, err := snapshot.TypeCheck(ctx, ids...)
+	if err != nil {
+		return nil, err
+	}
+	reports := make(map[protocol.DocumentURI][]*Diagnostic)
+	for _, pkg := range pkgs {
+		info := pkg.GetTypesInfo()
+		for _, pgf := range pkg.CompiledGoFiles() {
+			var inspectErr error
+			ast.Inspect(pgf.File, func(n ast.Node) bool {
+				call, ok := n.(*ast.CallExpr)
+				if !ok || inspectErr != nil {
+					return inspectErr == nil
+				}
+				for _, arg := range call.Args {
+					lit, ok := arg.(*ast.BasicLit)
+					if !ok {
+						continue
+					}
+					lang := EmbeddedLanguage(info, []ast.Node{lit, call}, langs)
+					if lang == "" {
+						continue
+					}
+					diag, err := embeddedLanguageDiagnostic(pgf, lit, lang)
+					if err != nil {
+						inspectErr = err
+						return false
+					}
+					if diag != nil {
+						reports[pgf.URI] = append(reports[pgf.URI], diag)
+					}
+				}
+				return true
+			})
+			if inspectErr != nil {
+				return nil, inspectErr
+			}
+		}
+	}
+	return reports, nil
+}
+
+// embeddedLanguageDiagnostic returns the diagnostic for the error in the
+// string literal of the given language, or nil if there is none.
+func embeddedLanguageDiagnostic(pgf *ParsedGoFile, lit *ast.BasicLit, lang string) (*Diagnostic, error) {
+	value, err := strconv.Unquote(lit.Value)
+	if err != nil {
+		return nil, nil // ill-formed literal; reported by the parser
+	}
+
+	var (
+		msg      string
+		start    = lit.Pos()
+		end      = lit.End()
+		severity = protocol.SeverityError
+	)
+	switch lang {
+	case settings.RegexpLanguage:
+		_, err := syntax.Parse(value, syntax.Perl)
+		if err == nil {
+			return nil, nil
+		}
+		msg = err.Error()
+		// Narrow the range to the erroneous expression, if it appears
+		// verbatim in the literal.
+		var serr *syntax.Error
+		if errors.As(err, &serr) && serr.Expr != "" {
+			if i := strings.Index(lit.Value, serr.Expr); i >= 0 {
+				start = lit.Pos() + token.Pos(i)
+				end = start + token.Pos(len(serr.Expr))
+			}
+		}
+	case settings.SQLLanguage:
+		msg = checkSQL(value)
+		if msg == "" {
+			return nil, nil
+		}
+		// The check does not know the dialect, so it may be wrong.
+		severity = protocol.SeverityWarning
+	default:
+		return nil, nil
+	}
+
+	rng, err := pgf.PosRange(start, end)
+	if err != nil {
+		return nil, err
+	}
+	return &Diagnostic{
+		URI:      pgf.URI,
+		Range:    rng,
+		Severity: severity,
+		Source:   EmbeddedLanguageError,
+		Message:  fmt.Sprintf("%s: %s", lang, msg),
+	}, nil
+}
+
+// checkSQL reports unbalanced parentheses and unterminated quoted strings,
+// identifiers or comments in the SQL statement, returning "" if there are
+// none.
+//
+// Comments (-- and /* */) and quoted text are skipped. Besides the standard
+// '...' strings and "..." identifiers, it recognizes the `...` identifiers
+// of MySQL and the $$...$$ and $tag$...$tag$ strings of PostgreSQL.
+func checkSQL(stmt string) string {
+	depth := 0
+	for i := 0; i < len(stmt); {
+		switch c := stmt[i]; {
+		case strings.HasPrefix(stmt[i:], "--"):
+			end := strings.IndexByte(stmt[i:], '\n')
+			if end < 0 {
+				return depthError(depth)
+			}
+			i += end + 1
+		case strings.HasPrefix(stmt[i:], "/*"):
+			end := strings.Index(stmt[i+len("/*"):], "*/")
+			if end < 0 {
+				return "unterminated comment"
+			}
+			i += len("/*") + end + len("*/")
+		case c == '\'' || c == '"' || c == '`':
+			// A doubled quote is an escaped quote, which this treats as
+			// closing and reopening the string.
+			end := strings.IndexByte(stmt[i+1:], c)
+			if end < 0 {
+				if c == '\'' {
+					return "unterminated string"
+				}
+				return "unterminated quoted identifier"
+			}
+			i += 1 + end + 1
+		case c == '$':
+			// A dollar-quoted string, or otherwise a parameter such as $1
+			// or a $ within an identifier.
+			tag := dollarQuoteTag(stmt[i:])
+			if tag == "" {
+				i++
+				break
+			}
+			end := strings.Index(stmt[i+len(tag):], tag)
+			if end < 0 {
+				return "unterminated dollar-quoted string"
+			}
+			i += len(tag) + end + len(tag)
+		case c == '(':
+			depth++
+			i++
+		case c == ')':
+			if depth == 0 {
+				return "unexpected )"
+			}
+			depth--
+			i++
+		default:
+			i++
+		}
+	}
+	return depthError(depth)
+}
+
+// depthError returns the error for the given depth of parentheses at the
+// end of a SQL statement, or "" if they are balanced.
+func depthError(depth int) string {
+	if depth > 0 {
+		return "missing closing )"
+	}
+	return ""
+}
+
+// dollarQuoteTag returns the opening $tag$ of the PostgreSQL dollar-quoted
+// string at the start of s, or "" if there is none. The tag, which may be
+// empty, is an identifier that does not start with a digit.
+func dollarQuoteTag(s string) string {
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '$':
+			return s[:i+1]
+		case c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || c >= 0x80:
+		case '0' <= c && c <= '9' && i > 1:
+		default:
+			return ""
+		}
+	}
+	return ""
+}
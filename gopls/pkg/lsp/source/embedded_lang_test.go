@@ -0,0 +1,35 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import "testing"
+
+func TestCheckSQL(t *testing.T) {
+	for _, test := range []struct {
+		stmt, want string
+	}{
+		{"SELECT (1)", ""},
+		{"SELECT 'it''s'", ""},
+		{"SELECT 1 -- don't", ""},
+		{"SELECT 1 -- don't\nFROM t WHERE (a = 'x'", "missing closing )"},
+		{"SELECT /* ( it's */ 1", ""},
+		{"SELECT /* 1", "unterminated comment"},
+		{"SELECT `it's` FROM t", ""},
+		{"SELECT `a", "unterminated quoted identifier"},
+		{`SELECT "a`, "unterminated quoted identifier"},
+		{"SELECT 'a", "unterminated string"},
+		{"SELECT $$it's ($$", ""},
+		{"SELECT $fn$ it's $$ ( $fn$", ""},
+		{"SELECT $fn$ it's", "unterminated dollar-quoted string"},
+		{"SELECT * FROM t WHERE a = $1 AND b = $2", ""},
+		{"SELECT a$b FROM t", ""},
+		{"SELECT (1", "missing closing )"},
+		{"SELECT 1)", "unexpected )"},
+	} {
+		if got := checkSQL(test.stmt); got != test.want {
+			t.Errorf("checkSQL(%q) = %q, want %q", test.stmt, got, test.want)
+		}
+	}
+}
@@ -19,15 +19,37 @@ import (
 )
 
 func GCOptimizationDetails(ctx context.Context, snapshot Snapshot, m *Metadata) (map[protocol.DocumentURI][]*Diagnostic, error) {
-	if len(m.CompiledGoFiles) == 0 {
-		return nil, nil
-	}
-	pkgDir := filepath.Dir(m.CompiledGoFiles[0].Path())
 	outDir := filepath.Join(os.TempDir(), fmt.Sprintf("gopls-%d.details", os.Getpid()))
-
 	if err := os.MkdirAll(outDir, 0700); err != nil {
 		return nil, err
 	}
+	return gcDetails(ctx, snapshot, m, outDir, snapshot.Options())
+}
+
+// HeapEscapes returns the escape analysis decisions of the compiler for the
+// package, regardless of the Annotations option. The compiler reads files
+// from disk, so the results are only meaningful for saved files.
+func HeapEscapes(ctx context.Context, snapshot Snapshot, m *Metadata) (map[protocol.DocumentURI][]*Diagnostic, error) {
+	// Use a fresh directory, so that the results of concurrent builds for
+	// GCOptimizationDetails are not mixed in.
+	outDir, err := os.MkdirTemp("", "gopls-escapes")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(outDir)
+	opts := &settings.Options{}
+	opts.Annotations = map[settings.Annotation]bool{settings.Escape: true}
+	return gcDetails(ctx, snapshot, m, outDir, opts)
+}
+
+// gcDetails builds the package, writing the optimization details of the
+// compiler to outDir, and returns those permitted by the Annotations of
+// opts.
+func gcDetails(ctx context.Context, snapshot Snapshot, m *Metadata, outDir string, opts *settings.Options) (map[protocol.DocumentURI][]*Diagnostic, error) {
+	if len(m.CompiledGoFiles) == 0 {
+		return nil, nil
+	}
+	pkgDir := filepath.Dir(m.CompiledGoFiles[0].Path())
 	tmpFile, err := os.CreateTemp(os.TempDir(), "gopls-x")
 	if err != nil {
 		return nil, err
@@ -58,7 +80,6 @@ func GCOptimizationDetails(ctx context.Context, snapshot Snapshot, m *Metadata)
 		return nil, err
 	}
 	reports := make(map[protocol.DocumentURI][]*Diagnostic)
-	opts := snapshot.Options()
 	var parseError error
 	for _, fn := range files {
 		uri, diagnostics, err := parseDetailsFile(fn, opts)
@@ -60,6 +60,16 @@ type HoverJSON struct {
 	// LinkAnchor is the pkg.go.dev link anchor for the given symbol.
 	// For example, the "Node" part of "pkg.go.dev/go/ast#Node".
 	LinkAnchor string `json:"linkAnchor"`
+
+	// Layout describes the size and alignment of a named type, and the
+	// offset, size and padding of its fields, if enabled by the
+	// HoverStructure option.
+	Layout string `json:"layout,omitempty"`
+
+	// Implementations describes the interfaces implemented by a named type,
+	// or the types implementing an interface, if enabled by the
+	// HoverStructure option.
+	Implementations string `json:"implementations,omitempty"`
 }
 
 // Hover implements the "textDocument/hover" RPC for Go files.
@@ -256,6 +266,12 @@ func hover(ctx context.Context, snapshot Snapshot, fh file.Handle, pp protocol.P
 		signature = b.String()
 	}
 
+	// Describe the structure of named types, if requested.
+	var layout, impls string
+	if named, ok := obj.Type().(*types.Named); ok && isTypeName && !isTypeParam && snapshot.Options().HoverStructure != settings.NoHoverStructure {
+		layout, impls = hoverStructure(ctx, snapshot, pkg, named, qf)
+	}
+
 	// Compute link data (on pkg.go.dev or other documentation host).
 	//
 	// If linkPath is empty, the symbol is not linkable.
@@ -361,6 +377,8 @@ func hover(ctx context.Context, snapshot Snapshot, fh file.Handle, pp protocol.P
 		Signature:         signature,
 		LinkPath:          linkPath,
 		LinkAnchor:        anchor,
+		Layout:            layout,
+		Implementations:   impls,
 	}, nil
 }
 
@@ -855,7 +873,10 @@ func formatHover(h *HoverJSON, options *settings.Options) (string, error) {
 	case settings.SingleLine:
 		return h.SingleLine, nil
 	case settings.NoDocumentation:
-		return signature, nil
+		if h.Layout == "" && h.Implementations == "" {
+			return signature, nil
+		}
+		return joinHoverParts([]string{signature, formatLayout(h, options), h.Implementations}, options), nil
 	case settings.Structured:
 		b, err := json.Marshal(h)
 		if err != nil {
@@ -867,8 +888,13 @@ func formatHover(h *HoverJSON, options *settings.Options) (string, error) {
 	link := formatLink(h, options)
 	doc := formatDoc(h, options)
 
+	return joinHoverParts([]string{signature, formatLayout(h, options), h.Implementations, doc, link}, options), nil
+}
+
+// joinHoverParts joins the non-empty parts of the hover text, separating
+// them by blank lines in Markdown.
+func joinHoverParts(parts []string, options *settings.Options) string {
 	var b strings.Builder
-	parts := []string{signature, doc, link}
 	for i, el := range parts {
 		if el != "" {
 			b.WriteString(el)
@@ -884,7 +910,7 @@ func formatHover(h *HoverJSON, options *settings.Options) (string, error) {
 			}
 		}
 	}
-	return b.String(), nil
+	return b.String()
 }
 
 func formatSignature(h *HoverJSON, options *settings.Options) string {
@@ -895,6 +921,14 @@ func formatSignature(h *HoverJSON, options *settings.Options) string {
 	return signature
 }
 
+func formatLayout(h *HoverJSON, options *settings.Options) string {
+	layout := h.Layout
+	if layout != "" && options.PreferredContentFormat == protocol.Markdown {
+		layout = fmt.Sprintf("```\n%s\n```", layout)
+	}
+	return layout
+}
+
 func formatLink(h *HoverJSON, options *settings.Options) string {
 	if !options.LinksInHover || options.LinkTarget == "" || h.LinkPath == "" {
 		return ""
@@ -0,0 +1,177 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"context"
+	"fmt"
+	"go/types"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/gopls/pkg/lsp/source/methodsets"
+	"golang.org/x/tools/gopls/pkg/settings"
+	"golang.org/x/tools/pkg/event"
+	"golang.org/x/tools/pkg/event/tag"
+	"golang.org/x/tools/pkg/typeparams"
+)
+
+// maxHoverImplementations is the number of implementing or implemented
+// types listed by name in the hover text.
+const maxHoverImplementations = 5
+
+// hoverLayout describes the memory layout of the named type for the given
+// sizes: its size and alignment, and the offset, size and padding of each
+// field if it is a struct. It returns "" for generic types, whose layout
+// depends on their type arguments.
+func hoverLayout(named *types.Named, sizes types.Sizes, qf types.Qualifier) string {
+	if sizes == nil || typeparams.ForNamed(named).Len() > 0 {
+		return ""
+	}
+	size, align := sizes.Sizeof(named), sizes.Alignof(named)
+	// Before Go 1.22, StdSizes omits the final padding of structs, which the
+	// compiler adds so that the elements of arrays are aligned.
+	if align > 0 {
+		size = (size + align - 1) / align * align
+	}
+
+	strct, ok := named.Underlying().(*types.Struct)
+	if !ok || strct.NumFields() == 0 {
+		return fmt.Sprintf("size=%d, align=%d", size, align)
+	}
+
+	fields := make([]*types.Var, strct.NumFields())
+	for i := range fields {
+		fields[i] = strct.Field(i)
+	}
+	offsets := sizes.Offsetsof(fields)
+
+	var (
+		lines   []string
+		padding int64
+	)
+	for i, field := range fields {
+		fieldSize := sizes.Sizeof(field.Type())
+		end := size
+		if i+1 < len(fields) {
+			end = offsets[i+1]
+		}
+		line := fmt.Sprintf("%s %s: offset=%d, size=%d, align=%d",
+			field.Name(), types.TypeString(field.Type(), qf), offsets[i], fieldSize, sizes.Alignof(field.Type()))
+		if pad := end - offsets[i] - fieldSize; pad > 0 {
+			line += fmt.Sprintf(", padding=%d", pad)
+			padding += pad
+		}
+		lines = append(lines, line)
+	}
+	header := fmt.Sprintf("size=%d, align=%d", size, align)
+	if padding > 0 {
+		header += fmt.Sprintf(", padding=%d", padding)
+	}
+	return header + "\n" + strings.Join(lines, "\n")
+}
+
+// hoverImplementations describes the interfaces implemented by the named
+// type, or the types implementing it if it is an interface, among the
+// package-level types of the workspace packages: their number, and a sample
+// of their names.
+//
+// Like Implementation, it does not relate interfaces to interfaces.
+func hoverImplementations(ctx context.Context, snapshot Snapshot, named *types.Named) (string, error) {
+	key, hasMethods := methodsets.KeyOf(named)
+	if !hasMethods {
+		return "", nil
+	}
+	// As in the local search of Implementation, restrict the search to the
+	// workspace, as the method sets of all dependencies would be too
+	// expensive to compute for each hover.
+	metas, err := snapshot.WorkspaceMetadata(ctx)
+	if err != nil {
+		return "", err
+	}
+	RemoveIntermediateTestVariants(&metas)
+	ids := make([]PackageID, len(metas))
+	for i, m := range metas {
+		ids[i] = m.ID
+	}
+	indexes, err := snapshot.MethodSets(ctx, ids...)
+	if err != nil {
+		return "", err
+	}
+
+	type typeKey struct {
+		pkgPath PackagePath
+		name    string
+	}
+	var self typeKey
+	if pkg := named.Obj().Pkg(); pkg != nil {
+		self = typeKey{PackagePath(pkg.Path()), named.Obj().Name()}
+	}
+	seen := map[typeKey]bool{self: true} // also dedups test variants
+	var names []string
+	for i, index := range indexes {
+		for _, res := range index.Search(key, "") {
+			// The index of a package only holds the types it declares,
+			// whose names are qualified by the package name.
+			name := res.TypeName
+			if dot := strings.LastIndexByte(name, '.'); dot >= 0 {
+				name = name[dot+1:]
+			}
+			k := typeKey{metas[i].PkgPath, name}
+			if !seen[k] {
+				seen[k] = true
+				names = append(names, res.TypeName)
+			}
+		}
+	}
+	if len(names) == 0 {
+		return "", nil
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	if types.IsInterface(named) {
+		fmt.Fprintf(&b, "Implemented by %d %s", len(names), pluralize(len(names), "type", "types"))
+	} else {
+		fmt.Fprintf(&b, "Implements %d %s", len(names), pluralize(len(names), "interface", "interfaces"))
+	}
+	sample := names
+	if len(sample) > maxHoverImplementations {
+		sample = sample[:maxHoverImplementations]
+	}
+	fmt.Fprintf(&b, ": %s", strings.Join(sample, ", "))
+	if more := len(names) - len(sample); more > 0 {
+		fmt.Fprintf(&b, ", and %d more", more)
+	}
+	return b.String(), nil
+}
+
+// hoverStructure computes the layout and implementations of the named
+// type, as enabled by the HoverStructure option.
+//
+// A failure to compute the implementations is logged, and leaves impls
+// empty, so that it does not prevent the hover.
+func hoverStructure(ctx context.Context, snapshot Snapshot, pkg Package, named *types.Named, qf types.Qualifier) (layout, impls string) {
+	mode := snapshot.Options().HoverStructure
+	if mode == settings.LayoutHoverStructure || mode == settings.FullHoverStructure {
+		layout = hoverLayout(named, pkg.Metadata().TypesSizes, qf)
+	}
+	if mode == settings.ImplementationsHoverStructure || mode == settings.FullHoverStructure {
+		var err error
+		impls, err = hoverImplementations(ctx, snapshot, named)
+		if err != nil {
+			event.Error(ctx, "computing implementations for hover", err, tag.Type.Of(named))
+			impls = ""
+		}
+	}
+	return layout, impls
+}
+
+func pluralize(n int, singular, plural string) string {
+	if n == 1 {
+		return singular
+	}
+	return plural
+}
@@ -13,6 +13,7 @@ import (
 	"go/types"
 	"strings"
 
+	"golang.org/x/tools/go/ast/astutil"
 	"golang.org/x/tools/gopls/pkg/file"
 	"golang.org/x/tools/gopls/pkg/lsp/protocol"
 	"golang.org/x/tools/pkg/event"
@@ -39,6 +40,11 @@ const (
 	CompositeLiteralTypes      = "compositeLiteralTypes"
 	CompositeLiteralFieldNames = "compositeLiteralFields"
 	FunctionTypeParameters     = "functionTypeParameters"
+	InstantiatedSignatures     = "instantiatedSignatures"
+	InterfaceConversions       = "interfaceConversions"
+	HeapAllocations            = "heapAllocations"
+	PromotedSelections         = "promotedSelections"
+	DiscardedErrors            = "discardedErrors"
 )
 
 var AllInlayHints = map[string]*Hint{
@@ -77,6 +83,31 @@ var AllInlayHints = map[string]*Hint{
 		Doc:  "Enable/disable inlay hints for implicit type parameters on generic functions:\n```go\n\tmyFoo/*[int, string]*/(1, \"hello\")\n```",
 		Run:  funcTypeParams,
 	},
+	InstantiatedSignatures: {
+		Name: InstantiatedSignatures,
+		Doc:  "Enable/disable inlay hints for the instantiated signatures of calls to generic functions with inferred type arguments:\n```go\n\tslices.Index(s, \"x\")/* func([]string, string) int*/\n```",
+		Run:  instantiatedSignatures,
+	},
+	InterfaceConversions: {
+		Name: InterfaceConversions,
+		Doc:  "Enable/disable inlay hints for implicit conversions of concrete values to non-empty interface parameters:\n```go\n\tio.Copy(/*io.Writer(*/w/*)*/, /*io.Reader(*/f/*)*/)\n```",
+		Run:  interfaceConversions,
+	},
+	HeapAllocations: {
+		Name: HeapAllocations,
+		Doc:  "Enable/disable inlay hints for the values that escape to the heap, according to the escape analysis of the compiler:\n```go\n\treturn make/*heap*/([]int, n)\n```\nThe package is built to compute these hints, which are only shown for saved files, in trusted workspace folders.",
+		// Computed from the compiler's escape analysis; see HeapAllocationHints.
+	},
+	PromotedSelections: {
+		Name: PromotedSelections,
+		Doc:  "Enable/disable inlay hints for the embedded fields through which promoted fields and methods are selected:\n```go\n\tbuf./*Buffer.*/WriteString(\"x\")\n```",
+		Run:  promotedSelections,
+	},
+	DiscardedErrors: {
+		Name: DiscardedErrors,
+		Doc:  "Enable/disable inlay hints for errors that are assigned to the blank identifier or ignored along with the results of a call statement:\n```go\n\t_/* discarded error*/ = f.Close()\n\tw.Write(b)/* discarded error*/\n```",
+		Run:  discardedErrors,
+	},
 }
 
 func InlayHint(ctx context.Context, snapshot Snapshot, fh file.Handle, pRng protocol.Range) ([]protocol.InlayHint, error) {
@@ -95,7 +126,7 @@ func InlayHint(ctx context.Context, snapshot Snapshot, fh file.Handle, pRng prot
 		if !enabled {
 			continue
 		}
-		if h, ok := AllInlayHints[hint]; ok {
+		if h, ok := AllInlayHints[hint]; ok && h.Run != nil {
 			enabledHints = append(enabledHints, h.Run)
 		}
 	}
@@ -209,6 +240,237 @@ func funcTypeParams(node ast.Node, m *protocol.Mapper, tf *token.File, info *typ
 	}}
 }
 
+func instantiatedSignatures(node ast.Node, m *protocol.Mapper, tf *token.File, info *types.Info, q *types.Qualifier) []protocol.InlayHint {
+	ce, ok := node.(*ast.CallExpr)
+	if !ok {
+		return nil
+	}
+	// Explicit type arguments are visible, and leave the Fun an index
+	// expression.
+	var id *ast.Ident
+	switch fun := ce.Fun.(type) {
+	case *ast.Ident:
+		id = fun
+	case *ast.SelectorExpr:
+		id = fun.Sel
+	default:
+		return nil
+	}
+	inst := typeparams.GetInstances(info)[id]
+	if inst.TypeArgs == nil || inst.Type == nil {
+		return nil
+	}
+	end, err := m.PosPosition(tf, ce.End())
+	if err != nil {
+		return nil
+	}
+	sig := types.TypeString(inst.Type, *q)
+	return []protocol.InlayHint{{
+		Position:    end,
+		Label:       buildLabel(sig),
+		Kind:        protocol.Type,
+		PaddingLeft: true,
+		Tooltip:     &protocol.OrPTooltip_textDocument_inlayHint{Value: sig},
+	}}
+}
+
+func interfaceConversions(node ast.Node, m *protocol.Mapper, tf *token.File, info *types.Info, q *types.Qualifier) []protocol.InlayHint {
+	ce, ok := node.(*ast.CallExpr)
+	if !ok {
+		return nil
+	}
+	sig, ok := info.TypeOf(ce.Fun).(*types.Signature)
+	if !ok {
+		return nil
+	}
+	params := sig.Params()
+
+	var hints []protocol.InlayHint
+	for i, arg := range ce.Args {
+		var ptyp types.Type
+		switch {
+		case i < params.Len()-1 || i < params.Len() && !sig.Variadic():
+			ptyp = params.At(i).Type()
+		case sig.Variadic() && params.Len() > 0 && !ce.Ellipsis.IsValid():
+			ptyp = params.At(params.Len() - 1).Type().(*types.Slice).Elem()
+		default:
+			continue
+		}
+		// Skip type parameters, whose type sets are concrete, and empty
+		// interfaces, which would decorate every argument of fmt.Printf.
+		if _, ok := ptyp.(*typeparams.TypeParam); ok {
+			continue
+		}
+		iface, ok := ptyp.Underlying().(*types.Interface)
+		if !ok || iface.Empty() {
+			continue
+		}
+		atyp := info.TypeOf(arg)
+		if atyp == nil || types.IsInterface(atyp) {
+			continue
+		}
+		if basic, ok := atyp.(*types.Basic); ok && basic.Kind() == types.UntypedNil {
+			continue
+		}
+		start, err := m.PosPosition(tf, arg.Pos())
+		if err != nil {
+			continue
+		}
+		end, err := m.PosPosition(tf, arg.End())
+		if err != nil {
+			continue
+		}
+		hints = append(hints, protocol.InlayHint{
+			Position: start,
+			Label:    buildLabel(types.TypeString(ptyp, *q) + "("),
+			Kind:     protocol.Type,
+		}, protocol.InlayHint{
+			Position: end,
+			Label:    buildLabel(")"),
+			Kind:     protocol.Type,
+		})
+	}
+	return hints
+}
+
+func promotedSelections(node ast.Node, m *protocol.Mapper, tf *token.File, info *types.Info, _ *types.Qualifier) []protocol.InlayHint {
+	se, ok := node.(*ast.SelectorExpr)
+	if !ok {
+		return nil
+	}
+	sel, ok := info.Selections[se]
+	if !ok || len(sel.Index()) < 2 {
+		return nil
+	}
+	// Follow the embedded fields of the path, all but the last index of
+	// which select a field.
+	var names []string
+	typ := sel.Recv()
+	for _, index := range sel.Index()[:len(sel.Index())-1] {
+		if ptr, ok := typ.Underlying().(*types.Pointer); ok {
+			typ = ptr.Elem()
+		}
+		strct, ok := typ.Underlying().(*types.Struct)
+		if !ok || index >= strct.NumFields() {
+			return nil
+		}
+		field := strct.Field(index)
+		names = append(names, field.Name())
+		typ = field.Type()
+	}
+	start, err := m.PosPosition(tf, se.Sel.Pos())
+	if err != nil {
+		return nil
+	}
+	return []protocol.InlayHint{{
+		Position: start,
+		Label:    buildLabel(strings.Join(names, ".") + "."),
+		Kind:     protocol.Type,
+	}}
+}
+
+func discardedErrors(node ast.Node, m *protocol.Mapper, tf *token.File, info *types.Info, _ *types.Qualifier) []protocol.InlayHint {
+	var discarded []token.Pos // the end of each expression discarding an error
+	switch stmt := node.(type) {
+	case *ast.AssignStmt:
+		// The types of the values assigned to the left-hand sides.
+		var typs []types.Type
+		if len(stmt.Rhs) == len(stmt.Lhs) {
+			for _, rhs := range stmt.Rhs {
+				typs = append(typs, info.TypeOf(rhs))
+			}
+		} else if len(stmt.Rhs) == 1 {
+			if tuple, ok := info.TypeOf(stmt.Rhs[0]).(*types.Tuple); ok && tuple.Len() == len(stmt.Lhs) {
+				for i := 0; i < tuple.Len(); i++ {
+					typs = append(typs, tuple.At(i).Type())
+				}
+			}
+		}
+		for i, t := range typs {
+			if id, ok := stmt.Lhs[i].(*ast.Ident); ok && id.Name == "_" && isErrorType(t) {
+				discarded = append(discarded, id.End())
+			}
+		}
+	case *ast.ExprStmt:
+		call, ok := astutil.Unparen(stmt.X).(*ast.CallExpr)
+		if !ok {
+			return nil
+		}
+		switch t := info.TypeOf(call).(type) {
+		case *types.Tuple:
+			for i := 0; i < t.Len(); i++ {
+				if isErrorType(t.At(i).Type()) {
+					discarded = append(discarded, call.End())
+					break
+				}
+			}
+		default:
+			if isErrorType(t) {
+				discarded = append(discarded, call.End())
+			}
+		}
+	}
+
+	var hints []protocol.InlayHint
+	for _, pos := range discarded {
+		end, err := m.PosPosition(tf, pos)
+		if err != nil {
+			continue
+		}
+		hints = append(hints, protocol.InlayHint{
+			Position:    end,
+			Label:       buildLabel("discarded error"),
+			Kind:        protocol.Type,
+			PaddingLeft: true,
+		})
+	}
+	return hints
+}
+
+// isErrorType reports whether t is a type other than an untyped nil that
+// implements the error interface.
+func isErrorType(t types.Type) bool {
+	if t == nil {
+		return false
+	}
+	if basic, ok := t.(*types.Basic); ok && basic.Kind() == types.UntypedNil {
+		return false
+	}
+	return types.Implements(t, errorInterfaceType)
+}
+
+// HeapAllocationHints returns the inlay hints in the given range of the
+// file for the values that escape to the heap, according to the escape
+// analysis decisions of the compiler (see HeapEscapes). An invalid range
+// selects the whole file.
+func HeapAllocationHints(escapes []*Diagnostic, pRng protocol.Range) []protocol.InlayHint {
+	whole := !(pRng.Start.Line < pRng.End.Line || pRng.Start.Character < pRng.End.Character)
+	seen := make(map[protocol.Position]bool)
+	var hints []protocol.InlayHint
+	for _, diag := range escapes {
+		if !strings.Contains(diag.Message, "escapes to heap") && !strings.Contains(diag.Message, "moved to heap") {
+			continue
+		}
+		pos := diag.Range.Start
+		if seen[pos] || !whole && !protocol.Intersect(pRng, protocol.Range{Start: pos, End: pos}) {
+			continue
+		}
+		seen[pos] = true
+		// Strip the "escape(...)" decoration of the compiler's code.
+		msg := diag.Message
+		if i := strings.IndexByte(msg, '('); i >= 0 && strings.HasSuffix(msg, ")") {
+			msg = msg[i+1 : len(msg)-1]
+		}
+		hints = append(hints, protocol.InlayHint{
+			Position:     pos,
+			Label:        buildLabel("heap"),
+			PaddingRight: true,
+			Tooltip:      &protocol.OrPTooltip_textDocument_inlayHint{Value: msg},
+		})
+	}
+	return hints
+}
+
 func assignVariableTypes(node ast.Node, m *protocol.Mapper, tf *token.File, info *types.Info, q *types.Qualifier) []protocol.InlayHint {
 	stmt, ok := node.(*ast.AssignStmt)
 	if !ok || stmt.Tok != token.DEFINE {
@@ -111,6 +111,9 @@ func KeyOf(t types.Type) (Key, bool) {
 type Result struct {
 	Location Location // location of the type or method
 
+	// types only:
+	TypeName string // qualified name of the type, e.g. "io.Reader"
+
 	// methods only:
 	PkgPath    string          // path of declaring package (may differ due to embedding)
 	ObjectPath objectpath.Path // path of method within declaring package
@@ -143,7 +146,10 @@ func (index *Index) Search(key Key, methodID string) []Result {
 		}
 
 		if methodID == "" {
-			results = append(results, Result{Location: index.location(candidate.Posn)})
+			results = append(results, Result{
+				Location: index.location(candidate.Posn),
+				TypeName: index.pkg.Strings[candidate.Name],
+			})
 		} else {
 			for _, m := range candidate.Methods {
 				// Here we exploit knowledge of the shape of the fingerprint string.
@@ -244,6 +250,7 @@ func (b *indexBuilder) build(fset *token.FileSet, pkg *types.Package) *Index {
 		if tname, ok := scope.Lookup(name).(*types.TypeName); ok && !tname.IsAlias() {
 			if mset := methodSetInfo(tname.Type(), setIndexInfo); mset.Mask != 0 {
 				mset.Posn = objectPos(tname)
+				mset.Name = b.string(pkg.Name() + "." + tname.Name())
 				// Only record types with non-trivial method sets.
 				b.MethodSets = append(b.MethodSets, mset)
 			}
@@ -466,6 +473,7 @@ type gobPackage struct {
 // A gobMethodSet records the method set of a single type.
 type gobMethodSet struct {
 	Posn        gobPosition
+	Name        int // index of qualified type name (index records only)
 	IsInterface bool
 	Tricky      bool   // at least one method is tricky; assignability requires go/types
 	Mask        uint64 // mask with 1 bit from each of methods[*].sum
@@ -689,6 +689,7 @@ const (
 	TemplateError            DiagnosticSource = "template"
 	WorkFileError            DiagnosticSource = "go.work file"
 	ConsistencyInfo          DiagnosticSource = "consistency"
+	EmbeddedLanguageError    DiagnosticSource = "embedded language"
 )
 
 func AnalyzerErrorKind(name string) DiagnosticSource {
@@ -160,6 +160,29 @@ func Lookup(m *source.Metadata, data []byte, targets map[source.PackagePath]map[
 	return locs
 }
 
+// Count returns the number of references recorded in a serialized index
+// produced by an indexPackage operation to each object, denoted by its
+// package path and object path. Imports of packages are not counted.
+func Count(data []byte) map[source.PackagePath]map[objectpath.Path]int {
+	var packages []*gobPackage
+	packageCodec.Decode(data, &packages)
+	counts := make(map[source.PackagePath]map[objectpath.Path]int)
+	for _, gp := range packages {
+		for _, gobObj := range gp.Objects {
+			if gobObj.Path == "" {
+				continue
+			}
+			objects := counts[gp.PkgPath]
+			if objects == nil {
+				objects = make(map[objectpath.Path]int)
+				counts[gp.PkgPath] = objects
+			}
+			objects[gobObj.Path] += len(gobObj.Refs)
+		}
+	}
+	return counts
+}
+
 // -- serialized representation --
 
 // The cross-reference index records the location of all references
@@ -10,6 +10,7 @@ import (
 	"fmt"
 	"go/scanner"
 	"go/token"
+	"go/types"
 	"strings"
 
 	"golang.org/x/tools/gopls/pkg/file"
@@ -24,6 +25,7 @@ type completer struct {
 	offset int // offset of the start of the Token
 	ctx    protocol.CompletionContext
 	syms   map[string]symbol
+	info   *typeInfo // types of the expressions, if the template has a gotype directive
 }
 
 func Completion(ctx context.Context, snapshot *cache.Snapshot, fh file.Handle, pos protocol.Position, context protocol.CompletionContext) (*protocol.CompletionList, error) {
@@ -53,6 +55,9 @@ func Completion(ctx context.Context, snapshot *cache.Snapshot, fh file.Handle, p
 		ctx:    context,
 		syms:   syms,
 	}
+	if gotypeRe.Match(p.buf) {
+		c.info = checkTypes(ctx, snapshot, completionParse(p, pos))
+	}
 	return c.complete()
 }
 
@@ -120,6 +125,10 @@ func (c *completer) complete() (*protocol.CompletionList, error) {
 	}
 	// sofar could be parsed by either c.analyzer() or scan(). The latter is precise
 	// and slower, but fast enough
+	if items, ok := c.typedCompletions(sofar); ok {
+		ans.Items = items
+		return ans, nil
+	}
 	words := scan(sofar)
 	// 1. if pattern starts $, show variables
 	// 2. if pattern starts ., show methods (and . by itself?)
@@ -190,6 +199,83 @@ func (c *completer) complete() (*protocol.CompletionList, error) {
 	return ans, nil
 }
 
+// completionParse parses the template as if the field name being completed
+// at pos were not missing, so that the enclosing actions, and the types of
+// dot and variables, are known.
+func completionParse(p *Parsed, pos protocol.Position) *Parsed {
+	offset := p.FromPosition(pos)
+	if offset == 0 || p.buf[offset-1] != '.' {
+		return p
+	}
+	buf := make([]byte, 0, len(p.buf)+1)
+	buf = append(buf, p.buf[:offset]...)
+	buf = append(buf, 'X')
+	buf = append(buf, p.buf[offset:]...)
+	return parseBuffer(buf)
+}
+
+// typedCompletions returns the exported fields and methods completing the
+// field chain that ends sofar, such as ".Items.Na" or "$x.", using the types
+// computed from the gotype directive. ok is false if there is no chain or no
+// type information.
+func (c *completer) typedCompletions(sofar []byte) (items []protocol.CompletionItem, ok bool) {
+	if c.info == nil || c.info.root == nil {
+		return nil, false
+	}
+	i := len(sofar)
+	for i > 0 && (isIdentByte(sofar[i-1]) || sofar[i-1] == '.' || sofar[i-1] == '$') {
+		i--
+	}
+	chain := string(sofar[i:])
+	names := strings.Split(chain, ".")
+	var t types.Type
+	switch {
+	case strings.HasPrefix(chain, "."):
+		start := c.offset + i
+		t, ok = c.info.dots[start]
+		if !ok {
+			t = c.info.root
+		}
+	case strings.HasPrefix(chain, "$") && len(names) > 1:
+		t = c.info.vars[names[0]]
+	default:
+		return nil, false
+	}
+	names = names[1:]
+	for _, name := range names[:len(names)-1] {
+		if t == nil {
+			break
+		}
+		_, t, _ = lookupField(t, name)
+	}
+	if t == nil {
+		return nil, true // unknown type: offer nothing rather than guesses
+	}
+
+	prefix := strings.ToLower(names[len(names)-1])
+	qf := types.RelativeTo(c.info.pkg.GetTypes())
+	for _, obj := range members(t) {
+		if !strings.HasPrefix(strings.ToLower(obj.Name()), prefix) {
+			continue
+		}
+		item := protocol.CompletionItem{
+			Label:  obj.Name(),
+			Kind:   protocol.FieldCompletion,
+			Detail: types.TypeString(obj.Type(), qf),
+		}
+		if _, ok := obj.(*types.Func); ok {
+			item.Kind = protocol.MethodCompletion
+			item.Detail = strings.TrimPrefix(item.Detail, "func")
+		}
+		items = append(items, item)
+	}
+	return items, true
+}
+
+func isIdentByte(b byte) bool {
+	return b == '_' || 'a' <= b && b <= 'z' || 'A' <= b && b <= 'Z' || '0' <= b && b <= '9'
+}
+
 // someday think about comments, strings, backslashes, etc
 // this would repeat some of the template parsing, but because the user is typing
 // there may be no parse tree here.
@@ -7,6 +7,7 @@ package template
 import (
 	"context"
 	"fmt"
+	"go/types"
 	"regexp"
 	"strconv"
 	"time"
@@ -74,11 +75,21 @@ func Diagnose(f file.Handle) []*source.Diagnostic {
 // does not understand scoping (if any) in templates. This code is
 // for definitions, type definitions, and implementations.
 // Results only for variables and templates.
-func Definition(snapshot *cache.Snapshot, fh file.Handle, loc protocol.Position) ([]protocol.Location, error) {
-	x, _, err := symAtPosition(fh, loc)
+//
+// The definitions of fields and methods are found in the Go source of the
+// type named by the gotype directive of the template, if any.
+func Definition(ctx context.Context, snapshot *cache.Snapshot, fh file.Handle, loc protocol.Position) ([]protocol.Location, error) {
+	x, p, err := symAtPosition(fh, loc)
 	if err != nil {
 		return nil, err
 	}
+	if obj, info := typedObject(ctx, snapshot, p, x); obj != nil {
+		loc, err := objectLocation(ctx, snapshot, info.pkg.FileSet(), obj)
+		if err != nil {
+			return nil, err
+		}
+		return []protocol.Location{loc}, nil
+	}
 	sym := x.name
 	ans := []protocol.Location{}
 	// PJW: this is probably a pattern to abstract
@@ -100,6 +111,11 @@ func Hover(ctx context.Context, snapshot source.Snapshot, fh file.Handle, positi
 		return nil, err
 	}
 	ans := protocol.Hover{Range: p.Range(sym.start, sym.length), Contents: protocol.MarkupContent{Kind: protocol.Markdown}}
+	if obj, info := typedObject(ctx, snapshot, p, sym); obj != nil {
+		qf := types.RelativeTo(info.pkg.GetTypes())
+		ans.Contents.Value = fmt.Sprintf("```go\n%s\n```", types.ObjectString(obj, qf))
+		return &ans, nil
+	}
 	switch sym.kind {
 	case protocol.Function:
 		ans.Contents.Value = fmt.Sprintf("function: %s", sym.name)
@@ -0,0 +1,527 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package template
+
+// This file contains the checking of templates against the Go type of their
+// data, named by a directive such as
+//
+//	{{/* gotype: example.com/pkg.Page */}}
+//
+// The walk follows the evaluation rules of text/template: dot starts as the
+// data and is rebound by range and with; fields and methods must be
+// exported; maps are indexed by key; and values of interface type are only
+// known at run time, so nothing is reported about them.
+
+import (
+	"context"
+	"fmt"
+	"go/token"
+	"go/types"
+	"regexp"
+	"strings"
+	"text/template/parse"
+
+	"golang.org/x/tools/gopls/pkg/file"
+	"golang.org/x/tools/gopls/pkg/lsp/protocol"
+	"golang.org/x/tools/gopls/pkg/lsp/source"
+)
+
+// gotypeRe matches the directive naming the Go type of the data of a
+// template, as an import path and type name, optionally preceded by '*'.
+var gotypeRe = regexp.MustCompile(`\{\{-?\s*/\*\s*gotype:\s*(\S+?)\s*\*/\s*-?\}\}`)
+
+// typeInfo records the results of checking a template against the type of
+// its data.
+type typeInfo struct {
+	pkg  source.Package // the package declaring the data type
+	root types.Type     // the type of the data: $, and dot at the top level
+
+	dots    map[int]types.Type    // type of dot, by offset of the field chain
+	vars    map[string]types.Type // type of each variable, by name
+	objects map[int]types.Object  // field or method, by offset of its name
+	errors  []typeError
+}
+
+// A typeError is an error in a template, at a range of buf.
+type typeError struct {
+	start, length int
+	msg           string
+}
+
+// checkTypes checks the template against the Go type named by its gotype
+// directive. It returns nil if there is no directive. If the type cannot be
+// found, the only error is reported on the directive.
+//
+// Nothing is checked in the templates defined by the file, as their data is
+// determined by their invocations.
+func checkTypes(ctx context.Context, snapshot source.Snapshot, p *Parsed) *typeInfo {
+	match := gotypeRe.FindSubmatchIndex(p.buf)
+	if match == nil {
+		return nil
+	}
+	info := &typeInfo{
+		dots:    make(map[int]types.Type),
+		vars:    make(map[string]types.Type),
+		objects: make(map[int]types.Object),
+	}
+	name := string(p.buf[match[2]:match[3]])
+	pkg, root, err := resolveType(ctx, snapshot, name)
+	if err != nil {
+		info.errors = append(info.errors, typeError{match[2], match[3] - match[2], err.Error()})
+		return info
+	}
+	info.pkg, info.root = pkg, root
+	info.vars["$"] = root
+
+	for _, t := range p.named {
+		if t.Name() == "" && t.Root != nil {
+			c := checker{p: p, info: info}
+			c.walk(t.Root, root)
+		}
+	}
+	return info
+}
+
+// resolveType returns the package and type named by the gotype directive.
+func resolveType(ctx context.Context, snapshot source.Snapshot, name string) (source.Package, types.Type, error) {
+	ptr := strings.HasPrefix(name, "*")
+	name = strings.TrimPrefix(name, "*")
+	dot := strings.LastIndex(name, ".")
+	if dot <= 0 || strings.HasSuffix(name, "/") {
+		return nil, nil, fmt.Errorf("gotype %q is not of the form path/to/pkg.Type", name)
+	}
+	path, typeName := name[:dot], name[dot+1:]
+
+	metas, err := snapshot.AllMetadata(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	var id source.PackageID
+	for _, m := range metas {
+		if string(m.PkgPath) == path && m.ForTest == "" {
+			id = m.ID
+			break
+		}
+	}
+	if id == "" {
+		return nil, nil, fmt.Errorf("no package %q in the workspace or its dependencies", path)
+	}
+	pkgs, err := snapshot.TypeCheck(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	tname, ok := pkgs[0].GetTypes().Scope().Lookup(typeName).(*types.TypeName)
+	if !ok {
+		return nil, nil, fmt.Errorf("no type %s in package %s", typeName, path)
+	}
+	typ := tname.Type()
+	if ptr {
+		typ = types.NewPointer(typ)
+	}
+	return pkgs[0], typ, nil
+}
+
+// TypeErrors returns the errors in the fields, methods and function calls
+// of the template file, checked against the type named by its gotype
+// directive.
+func TypeErrors(ctx context.Context, snapshot source.Snapshot, fh file.Handle) ([]*source.Diagnostic, error) {
+	buf, err := fh.Content()
+	if err != nil {
+		return nil, err
+	}
+	p := parseBuffer(buf)
+	info := checkTypes(ctx, snapshot, p)
+	if info == nil {
+		return nil, nil
+	}
+	var diags []*source.Diagnostic
+	for _, e := range info.errors {
+		diags = append(diags, &source.Diagnostic{
+			URI:      fh.URI(),
+			Range:    p.Range(e.start, e.length),
+			Severity: protocol.SeverityError,
+			Source:   source.TemplateError,
+			Message:  e.msg,
+		})
+	}
+	return diags, nil
+}
+
+// typedObject returns the Go field or method selected by the field symbol
+// of the template, and the results of checking the template, if it has a
+// gotype directive.
+func typedObject(ctx context.Context, snapshot source.Snapshot, p *Parsed, sym *symbol) (types.Object, *typeInfo) {
+	if sym.kind != protocol.Method {
+		return nil, nil
+	}
+	info := checkTypes(ctx, snapshot, p)
+	if info == nil {
+		return nil, nil
+	}
+	return info.objects[sym.start], info
+}
+
+// objectLocation returns the location of the name of the Go object, whose
+// position is recorded in fset.
+func objectLocation(ctx context.Context, snapshot source.Snapshot, fset *token.FileSet, obj types.Object) (protocol.Location, error) {
+	tok := fset.File(obj.Pos())
+	if tok == nil {
+		return protocol.Location{}, fmt.Errorf("no file for %s", obj.Name())
+	}
+	fh, err := snapshot.ReadFile(ctx, protocol.URIFromPath(tok.Name()))
+	if err != nil {
+		return protocol.Location{}, err
+	}
+	content, err := fh.Content()
+	if err != nil {
+		return protocol.Location{}, err
+	}
+	m := protocol.NewMapper(fh.URI(), content)
+	return m.PosLocation(tok, obj.Pos(), obj.Pos()+token.Pos(len(obj.Name())))
+}
+
+// A checker walks the parse tree of a template, computing the types of its
+// expressions.
+type checker struct {
+	p    *Parsed
+	info *typeInfo
+}
+
+func (c *checker) errorf(start, length int, format string, args ...interface{}) {
+	c.info.errors = append(c.info.errors, typeError{start, length, fmt.Sprintf(format, args...)})
+}
+
+// walk checks the node, in which dot has the given type.
+func (c *checker) walk(n parse.Node, dot types.Type) {
+	switch x := n.(type) {
+	case *parse.ListNode:
+		if x == nil {
+			return
+		}
+		for _, n := range x.Nodes {
+			c.walk(n, dot)
+		}
+	case *parse.ActionNode:
+		c.pipe(x.Pipe, dot)
+	case *parse.IfNode:
+		c.pipe(x.Pipe, dot)
+		c.walk(x.List, dot)
+		c.walk(x.ElseList, dot)
+	case *parse.WithNode:
+		t := c.pipe(x.Pipe, dot)
+		c.walk(x.List, t)
+		c.walk(x.ElseList, dot)
+	case *parse.RangeNode:
+		key, elem := rangeTypes(c.pipeValue(x.Pipe, dot))
+		switch len(x.Pipe.Decl) {
+		case 1:
+			c.declare(x.Pipe.Decl[0], elem)
+		case 2:
+			c.declare(x.Pipe.Decl[0], key)
+			c.declare(x.Pipe.Decl[1], elem)
+		}
+		c.walk(x.List, elem)
+		c.walk(x.ElseList, dot)
+	case *parse.TemplateNode:
+		if x.Pipe != nil {
+			c.pipe(x.Pipe, dot)
+		}
+	}
+}
+
+// pipe returns the type of the value of the pipeline, assigning it to the
+// variable it declares, if any.
+func (c *checker) pipe(pipe *parse.PipeNode, dot types.Type) types.Type {
+	t := c.pipeValue(pipe, dot)
+	if pipe != nil && len(pipe.Decl) == 1 {
+		c.declare(pipe.Decl[0], t)
+	}
+	return t
+}
+
+// pipeValue returns the type of the value of the pipeline, whose result
+// is passed to each command as its final argument.
+func (c *checker) pipeValue(pipe *parse.PipeNode, dot types.Type) types.Type {
+	if pipe == nil {
+		return nil
+	}
+	var t types.Type
+	for i, cmd := range pipe.Cmds {
+		t = c.command(cmd, dot, i > 0)
+	}
+	return t
+}
+
+func (c *checker) declare(v *parse.VariableNode, t types.Type) {
+	if len(v.Ident) == 1 {
+		c.info.vars[v.Ident[0]] = t
+	}
+}
+
+// command returns the type of the result of the command. If piped, the
+// command receives the result of the previous command as an extra
+// argument.
+func (c *checker) command(cmd *parse.CommandNode, dot types.Type, piped bool) types.Type {
+	if len(cmd.Args) == 0 {
+		return nil
+	}
+	nargs := len(cmd.Args) - 1
+	if piped {
+		nargs++
+	}
+	for _, arg := range cmd.Args[1:] {
+		c.operand(arg, dot, 0)
+	}
+	return c.operand(cmd.Args[0], dot, nargs)
+}
+
+// operand returns the type of the value of the operand, which is invoked
+// with nargs arguments if it is a function or method.
+func (c *checker) operand(n parse.Node, dot types.Type, nargs int) types.Type {
+	switch x := n.(type) {
+	case *parse.DotNode:
+		return dot
+	case *parse.FieldNode:
+		return c.chain(c.p.fields(x.Ident, x), dot, nargs)
+	case *parse.VariableNode:
+		syms := c.p.fields(x.Ident, x)
+		if len(syms) == 0 {
+			return nil
+		}
+		return c.chain(syms[1:], c.info.vars[x.Ident[0]], nargs)
+	case *parse.ChainNode:
+		var t types.Type
+		if pipe, ok := x.Node.(*parse.PipeNode); ok {
+			t = c.pipeValue(pipe, dot)
+		} else {
+			t = c.operand(x.Node, dot, 0)
+		}
+		return c.chain(c.p.fields(x.Field, x), t, nargs)
+	case *parse.PipeNode:
+		return c.pipeValue(x, dot)
+	case *parse.IdentifierNode:
+		return c.function(x, nargs)
+	case *parse.StringNode:
+		return types.Typ[types.String]
+	case *parse.BoolNode:
+		return types.Typ[types.Bool]
+	}
+	return nil
+}
+
+// chain returns the type of the result of selecting the named fields and
+// methods in turn from a value of type t, the last of which is invoked with
+// nargs arguments.
+func (c *checker) chain(syms []symbol, t types.Type, nargs int) types.Type {
+	if len(syms) > 0 {
+		// The chain starts with the '.' before its first name.
+		c.info.dots[syms[0].start-1] = t
+	}
+	for i, sym := range syms {
+		if t == nil {
+			return nil // unknown
+		}
+		args := 0
+		if i == len(syms)-1 {
+			args = nargs
+		}
+		obj, result, ok := lookupField(t, sym.name)
+		if !ok {
+			c.errorf(sym.start, sym.length, "can't evaluate field %s in type %s", sym.name, c.typeString(t))
+			return nil
+		}
+		if obj == nil {
+			t = result // map element or dynamic value
+			continue
+		}
+		c.info.objects[sym.start] = obj
+		switch obj := obj.(type) {
+		case *types.Func:
+			c.checkArity(sym, obj.Type().(*types.Signature), args)
+		case *types.Var:
+			if args > 0 {
+				c.errorf(sym.start, sym.length, "%s has arguments but cannot be invoked as function", sym.name)
+			}
+		}
+		t = result
+	}
+	return t
+}
+
+func (c *checker) checkArity(sym symbol, sig *types.Signature, nargs int) {
+	want := sig.Params().Len()
+	switch {
+	case sig.Variadic() && nargs < want-1:
+		c.errorf(sym.start, sym.length, "wrong number of args for %s: want at least %d got %d", sym.name, want-1, nargs)
+	case !sig.Variadic() && nargs != want:
+		c.errorf(sym.start, sym.length, "wrong number of args for %s: want %d got %d", sym.name, want, nargs)
+	}
+}
+
+// builtinArity records the minimum number of arguments of the predefined
+// functions of text/template, and whether they accept more.
+var builtinArity = map[string]struct {
+	min      int
+	variadic bool
+}{
+	"and":      {1, true},
+	"call":     {1, true},
+	"eq":       {2, true},
+	"ge":       {2, false},
+	"gt":       {2, false},
+	"html":     {0, true},
+	"index":    {1, true},
+	"js":       {0, true},
+	"le":       {2, false},
+	"len":      {1, false},
+	"lt":       {2, false},
+	"ne":       {2, false},
+	"not":      {1, false},
+	"or":       {1, true},
+	"print":    {0, true},
+	"printf":   {1, true},
+	"println":  {0, true},
+	"slice":    {1, true},
+	"urlquery": {0, true},
+}
+
+// function checks the arity of a call to a predefined function, and returns
+// the type of its result, if known. Other functions are added by the
+// program, so nothing is known about them.
+func (c *checker) function(x *parse.IdentifierNode, nargs int) types.Type {
+	arity, ok := builtinArity[x.Ident]
+	if !ok {
+		return nil
+	}
+	start, length := int(x.Pos), len(x.Ident)
+	switch {
+	case arity.variadic && nargs < arity.min:
+		c.errorf(start, length, "wrong number of args for %s: want at least %d got %d", x.Ident, arity.min, nargs)
+	case !arity.variadic && nargs != arity.min:
+		c.errorf(start, length, "wrong number of args for %s: want %d got %d", x.Ident, arity.min, nargs)
+	}
+	switch x.Ident {
+	case "len":
+		return types.Typ[types.Int]
+	case "eq", "ne", "lt", "le", "gt", "ge", "not":
+		return types.Typ[types.Bool]
+	case "html", "js", "print", "printf", "println", "urlquery":
+		return types.Typ[types.String]
+	}
+	return nil
+}
+
+func (c *checker) typeString(t types.Type) string {
+	return types.TypeString(t, types.RelativeTo(c.info.pkg.GetTypes()))
+}
+
+// lookupField returns the exported field or method of type t with the given
+// name, and the type of its value: the type of a field, or of the first
+// result of a method. For maps with string keys and interfaces, whose
+// elements and dynamic values are only known at run time, the object is
+// nil. ok is false if there is no such field or method.
+func lookupField(t types.Type, name string) (obj types.Object, result types.Type, ok bool) {
+	if obj, _, _ := types.LookupFieldOrMethod(t, true, nil, name); obj != nil && obj.Exported() {
+		switch obj := obj.(type) {
+		case *types.Var:
+			return obj, obj.Type(), true
+		case *types.Func:
+			var result types.Type
+			if res := obj.Type().(*types.Signature).Results(); res.Len() > 0 {
+				result = res.At(0).Type()
+			}
+			return obj, result, true
+		}
+	}
+	elem := t
+	if ptr, ok := t.Underlying().(*types.Pointer); ok {
+		elem = ptr.Elem()
+	}
+	switch u := elem.Underlying().(type) {
+	case *types.Map:
+		if basic, ok := u.Key().Underlying().(*types.Basic); ok && basic.Info()&types.IsString != 0 {
+			return nil, u.Elem(), true
+		}
+	case *types.Interface:
+		return nil, nil, true
+	}
+	return nil, nil, false
+}
+
+// rangeTypes returns the types of the keys and elements of ranging over a
+// value of type t, if known.
+func rangeTypes(t types.Type) (key, elem types.Type) {
+	if t == nil {
+		return nil, nil
+	}
+	if ptr, ok := t.Underlying().(*types.Pointer); ok {
+		t = ptr.Elem()
+	}
+	switch u := t.Underlying().(type) {
+	case *types.Slice:
+		return types.Typ[types.Int], u.Elem()
+	case *types.Array:
+		return types.Typ[types.Int], u.Elem()
+	case *types.Map:
+		return u.Key(), u.Elem()
+	case *types.Chan:
+		return u.Elem(), nil
+	}
+	return nil, nil
+}
+
+// members returns the exported fields, including promoted fields, and
+// methods of values of type t, in order of declaration.
+func members(t types.Type) []types.Object {
+	var objs []types.Object
+	seen := make(map[string]bool)
+	elem := t
+	if ptr, ok := t.Underlying().(*types.Pointer); ok {
+		elem = ptr.Elem()
+	}
+	if _, ok := elem.Underlying().(*types.Struct); ok {
+		// Visit the fields breadth first, so that shallower fields hide
+		// deeper ones, and each struct once, as structs may embed
+		// themselves or each other.
+		level := []*types.Struct{elem.Underlying().(*types.Struct)}
+		visited := map[*types.Struct]bool{level[0]: true}
+		for len(level) > 0 {
+			var next []*types.Struct
+			for _, strct := range level {
+				for i := 0; i < strct.NumFields(); i++ {
+					f := strct.Field(i)
+					if f.Exported() && !seen[f.Name()] {
+						seen[f.Name()] = true
+						objs = append(objs, f)
+					}
+					if f.Embedded() {
+						ft := f.Type()
+						if ptr, ok := ft.Underlying().(*types.Pointer); ok {
+							ft = ptr.Elem()
+						}
+						if s, ok := ft.Underlying().(*types.Struct); ok && !visited[s] {
+							visited[s] = true
+							next = append(next, s)
+						}
+					}
+				}
+			}
+			level = next
+		}
+	}
+	mt := t
+	if _, ok := t.(*types.Named); ok && !types.IsInterface(t) {
+		mt = types.NewPointer(t) // values in templates are addressable
+	}
+	mset := types.NewMethodSet(mt)
+	for i := 0; i < mset.Len(); i++ {
+		m := mset.At(i).Obj()
+		if m.Exported() && !seen[m.Name()] {
+			seen[m.Name()] = true
+			objs = append(objs, m)
+		}
+	}
+	return objs
+}
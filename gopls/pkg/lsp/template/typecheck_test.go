@@ -0,0 +1,59 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package template
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"strings"
+	"testing"
+)
+
+func TestMembers(t *testing.T) {
+	const src = `package p
+
+type Node struct {
+	*Node
+	Val int
+}
+
+func (*Node) Next() *Node { return nil }
+
+type A struct {
+	*B
+	X int
+}
+
+type B struct {
+	*A
+	X, Y int
+}
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg, err := new(types.Config).Check("p", fset, []*ast.File{f}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		typ, want string
+	}{
+		{"Node", "Node Val Next"},
+		{"A", "B X A Y"},
+	} {
+		var got []string
+		for _, obj := range members(pkg.Scope().Lookup(test.typ).Type()) {
+			got = append(got, obj.Name())
+		}
+		if strings.Join(got, " ") != test.want {
+			t.Errorf("members(%s) = %v, want %s", test.typ, got, test.want)
+		}
+	}
+}
@@ -57,6 +57,10 @@ const (
 	// FromResetGoModDiagnostics refers to state changes resulting from the
 	// ResetGoModDiagnostics command.
 	FromResetGoModDiagnostics
+
+	// FromTrustWorkspace refers to state changes resulting from the
+	// TrustWorkspace command.
+	FromTrustWorkspace
 )
 
 func (m ModificationSource) String() string {
@@ -79,6 +83,8 @@ func (m ModificationSource) String() string {
 		return "from check upgrades"
 	case FromResetGoModDiagnostics:
 		return "from resetting go.mod diagnostics"
+	case FromTrustWorkspace:
+		return "from trusting workspace"
 	default:
 		return "unknown file modification"
 	}
@@ -141,6 +147,7 @@ func (s *server) DidChange(ctx context.Context, params *protocol.DidChangeTextDo
 	if err := s.didModifyFiles(ctx, []file.Modification{c}, FromDidChange); err != nil {
 		return err
 	}
+	s.observeCompletion(ctx, uri, params.ContentChanges, text)
 	return s.warnAboutModifyingGeneratedFiles(ctx, uri)
 }
 
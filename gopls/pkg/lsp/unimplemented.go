@@ -106,10 +106,6 @@ func (s *server) ResolveCodeAction(context.Context, *protocol.CodeAction) (*prot
 	return nil, notImplemented("ResolveCodeAction")
 }
 
-func (s *server) ResolveCodeLens(context.Context, *protocol.CodeLens) (*protocol.CodeLens, error) {
-	return nil, notImplemented("ResolveCodeLens")
-}
-
 func (s *server) ResolveCompletionItem(context.Context, *protocol.CompletionItem) (*protocol.CompletionItem, error) {
 	return nil, notImplemented("ResolveCompletionItem")
 }
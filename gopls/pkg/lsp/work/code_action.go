@@ -0,0 +1,160 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package work
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/tools/gopls/pkg/file"
+	"golang.org/x/tools/gopls/pkg/lsp/cache"
+	"golang.org/x/tools/gopls/pkg/lsp/protocol"
+	"golang.org/x/tools/gopls/pkg/lsp/source"
+	"golang.org/x/tools/pkg/diff"
+	"golang.org/x/tools/pkg/event"
+)
+
+// CodeActions returns the refactorings of workspace modules available in the
+// given range of the view's go.work file, or of the go.mod file of one of its
+// modules.
+//
+// In the go.work file, these are the commands offered by its code lenses. In
+// a go.mod file, a replace directive whose replacement is a local module may
+// be converted into a use directive of the go.work file.
+func CodeActions(ctx context.Context, snapshot *cache.Snapshot, fh file.Handle, rng protocol.Range) ([]protocol.CodeAction, error) {
+	workURI := snapshot.WorkFile()
+	if workURI == "" {
+		return nil, nil
+	}
+
+	ctx, done := event.Start(ctx, "work.CodeActions")
+	defer done()
+
+	workFH, err := snapshot.ReadFile(ctx, workURI)
+	if err != nil {
+		return nil, err
+	}
+	pw, err := snapshot.ParseWork(ctx, workFH)
+	if err != nil || pw.File == nil {
+		return nil, err
+	}
+
+	if fh.URI() == workURI {
+		cmds, err := workCommands(ctx, snapshot, pw)
+		if err != nil {
+			return nil, err
+		}
+		var actions []protocol.CodeAction
+		for i := range cmds {
+			actions = append(actions, protocol.CodeAction{
+				Title:   cmds[i].Title,
+				Kind:    protocol.RefactorRewrite,
+				Command: &cmds[i],
+			})
+		}
+		return actions, nil
+	}
+	return replaceActions(ctx, snapshot, pw, fh, rng)
+}
+
+// replaceActions returns actions converting the replace directives in the
+// given range of a go.mod file into use directives of the go.work file.
+//
+// Only replacements by a local directory containing the replaced module are
+// converted, and only in modules used by the go.work file: for those, using
+// the replacement in the workspace has the same effect.
+func replaceActions(ctx context.Context, snapshot *cache.Snapshot, pw *source.ParsedWorkFile, fh file.Handle, rng protocol.Range) ([]protocol.CodeAction, error) {
+	used := make(map[protocol.DocumentURI]bool)
+	for _, use := range pw.File.Use {
+		used[modFileURI(pw, use)] = true
+	}
+	if !used[fh.URI()] {
+		return nil, nil // not a workspace module
+	}
+
+	pm, err := snapshot.ParseMod(ctx, fh)
+	if err != nil || pm.File == nil {
+		return nil, err
+	}
+	start, end, err := pm.Mapper.RangeOffsets(rng)
+	if err != nil {
+		return nil, err
+	}
+
+	var actions []protocol.CodeAction
+	for _, rep := range pm.File.Replace {
+		if rep.Syntax.End.Byte < start || end < rep.Syntax.Start.Byte {
+			continue
+		}
+		if rep.New.Version != "" {
+			continue // not a local directory
+		}
+		dir := filepath.FromSlash(rep.New.Path)
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(filepath.Dir(fh.URI().Path()), dir)
+		}
+		modURI := protocol.URIFromPath(filepath.Join(dir, "go.mod"))
+		modFH, err := snapshot.ReadFile(ctx, modURI)
+		if err != nil {
+			return nil, err
+		}
+		repPM, err := snapshot.ParseMod(ctx, modFH)
+		if err != nil || repPM.File == nil || repPM.File.Module == nil || repPM.File.Module.Mod.Path != rep.Old.Path {
+			continue // not the replaced module
+		}
+
+		edits, err := dropReplaceEdits(pm, rep)
+		if err != nil {
+			return nil, err
+		}
+		action := protocol.CodeAction{
+			Title: fmt.Sprintf("Use %s in go.work instead of replacing it", rep.Old.Path),
+			Kind:  protocol.RefactorRewrite,
+			Edit: &protocol.WorkspaceEdit{
+				DocumentChanges: []protocol.DocumentChanges{
+					{
+						TextDocumentEdit: &protocol.TextDocumentEdit{
+							TextDocument: protocol.OptionalVersionedTextDocumentIdentifier{
+								Version:                fh.Version(),
+								TextDocumentIdentifier: protocol.TextDocumentIdentifier{URI: fh.URI()},
+							},
+							Edits: edits,
+						},
+					},
+				},
+			},
+		}
+		if !used[modURI] {
+			cmd, err := useCommand(snapshot, "Run go work use", []string{dir})
+			if err != nil {
+				return nil, err
+			}
+			action.Command = &cmd
+		}
+		actions = append(actions, action)
+	}
+	return actions, nil
+}
+
+// dropReplaceEdits returns the edits to the go.mod file that remove the
+// replace directive.
+func dropReplaceEdits(pm *source.ParsedModule, rep *modfile.Replace) ([]protocol.TextEdit, error) {
+	// Parse a copy, as the parsed module is shared.
+	copied, err := modfile.Parse("", pm.Mapper.Content, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := copied.DropReplace(rep.Old.Path, rep.Old.Version); err != nil {
+		return nil, err
+	}
+	copied.Cleanup()
+	newContent, err := copied.Format()
+	if err != nil {
+		return nil, err
+	}
+	return protocol.EditsFromDiffEdits(pm.Mapper, diff.Bytes(pm.Mapper.Content, newContent))
+}
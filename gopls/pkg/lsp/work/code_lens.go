@@ -0,0 +1,149 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package work
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/tools/gopls/pkg/file"
+	"golang.org/x/tools/gopls/pkg/lsp/cache"
+	"golang.org/x/tools/gopls/pkg/lsp/command"
+	"golang.org/x/tools/gopls/pkg/lsp/protocol"
+	"golang.org/x/tools/gopls/pkg/lsp/source"
+	"golang.org/x/tools/pkg/event"
+)
+
+// CodeLenses returns code lenses for the view's go.work file that run
+// `go work` commands: adding the nested modules that are not yet used,
+// removing use directives of directories that no longer contain a module,
+// and syncing the build list back to the workspace modules.
+func CodeLenses(ctx context.Context, snapshot *cache.Snapshot, fh file.Handle) ([]protocol.CodeLens, error) {
+	// We only provide code lenses for the view's go.work file.
+	if fh.URI() != snapshot.WorkFile() {
+		return nil, nil
+	}
+
+	ctx, done := event.Start(ctx, "work.CodeLenses")
+	defer done()
+
+	pw, err := snapshot.ParseWork(ctx, fh)
+	if err != nil || pw.File == nil {
+		return nil, err
+	}
+	cmds, err := workCommands(ctx, snapshot, pw)
+	if err != nil {
+		return nil, err
+	}
+
+	// Put the lenses on the go directive, or at the start of the file if
+	// there is none.
+	var rng protocol.Range
+	if pw.File.Go != nil && pw.File.Go.Syntax != nil {
+		syntax := pw.File.Go.Syntax
+		rng, err = pw.Mapper.OffsetRange(syntax.Start.Byte, syntax.End.Byte)
+		if err != nil {
+			return nil, err
+		}
+	}
+	var lenses []protocol.CodeLens
+	for i := range cmds {
+		lenses = append(lenses, protocol.CodeLens{Range: rng, Command: &cmds[i]})
+	}
+	return lenses, nil
+}
+
+// workCommands returns the commands that apply to the go.work file: "go work
+// edit -dropuse" for used directories that do not contain a go.mod file, or
+// else "go work use" for nested modules that are not used, and "go work sync".
+func workCommands(ctx context.Context, snapshot *cache.Snapshot, pw *source.ParsedWorkFile) ([]protocol.Command, error) {
+	workDir := filepath.Dir(pw.URI.Path())
+
+	used := make(map[protocol.DocumentURI]bool)
+	dropArgs := []string{"edit"}
+	for _, use := range pw.File.Use {
+		uri := modFileURI(pw, use)
+		used[uri] = true
+		// Like the go command, check the file system rather than overlays.
+		if _, err := os.Stat(uri.Path()); os.IsNotExist(err) {
+			dropArgs = append(dropArgs, "-dropuse="+use.Path)
+		}
+	}
+
+	modFiles, err := snapshot.NestedModules(protocol.URIFromPath(workDir))
+	if err != nil {
+		return nil, err
+	}
+	var unused []string
+	for _, uri := range modFiles {
+		if !used[uri] {
+			unused = append(unused, filepath.Dir(uri.Path()))
+		}
+	}
+
+	var cmds []protocol.Command
+	// "go work use" fails to load the workspace while any use directive
+	// lacks a go.mod file, so those must be dropped first.
+	if len(unused) > 0 && len(dropArgs) == 1 {
+		cmd, err := useCommand(snapshot, fmt.Sprintf("Use %s", plural(len(unused), "nested module")), unused)
+		if err != nil {
+			return nil, err
+		}
+		cmds = append(cmds, cmd)
+	}
+	if n := len(dropArgs) - 1; n > 0 {
+		// "go work use" fails for directories that no longer exist, so the
+		// use directives are dropped by path instead.
+		cmd, err := command.NewRunGoWorkCommandCommand(fmt.Sprintf("Drop use of %s without a go.mod file", plural(n, "directory")), command.RunGoWorkArgs{
+			ViewID: snapshot.View().ID(),
+			Args:   dropArgs,
+		})
+		if err != nil {
+			return nil, err
+		}
+		cmds = append(cmds, cmd)
+	}
+	sync, err := command.NewRunGoWorkCommandCommand("Run go work sync", command.RunGoWorkArgs{
+		ViewID: snapshot.View().ID(),
+		Args:   []string{"sync"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return append(cmds, sync), nil
+}
+
+// useCommand returns a command that runs "go work use" for the given
+// absolute directories.
+//
+// The go command runs in the view's folder, so the directories are made
+// relative to it; the go command records relative directories relative to the
+// go.work file.
+func useCommand(snapshot *cache.Snapshot, title string, dirs []string) (protocol.Command, error) {
+	viewDir := snapshot.Folder().Path()
+	args := []string{"use"}
+	for _, dir := range dirs {
+		if rel, err := filepath.Rel(viewDir, dir); err == nil {
+			dir = rel
+		}
+		args = append(args, dir)
+	}
+	return command.NewRunGoWorkCommandCommand(title, command.RunGoWorkArgs{
+		ViewID: snapshot.View().ID(),
+		Args:   args,
+	})
+}
+
+func plural(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	if noun[len(noun)-1] == 'y' {
+		return fmt.Sprintf("%d %sies", n, noun[:len(noun)-1])
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
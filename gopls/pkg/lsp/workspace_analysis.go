@@ -0,0 +1,174 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package lsp
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"golang.org/x/tools/gopls/pkg/lsp/cache"
+	"golang.org/x/tools/gopls/pkg/lsp/progress"
+	"golang.org/x/tools/gopls/pkg/lsp/source"
+	"golang.org/x/tools/pkg/event"
+)
+
+// WorkspaceAnalysisTitle is the title of the progress report for background
+// analysis of the workspace. It is sought by regression tests.
+const WorkspaceAnalysisTitle = "Analyzing workspace"
+
+// workspaceAnalysisBatchSize is the number of packages analyzed between
+// checks for interactive requests.
+const workspaceAnalysisBatchSize = 8
+
+// beginInteractive records the start of an interactive request, during which
+// background work such as workspace analysis is paused. The resulting func
+// must be called when the request is complete.
+func (s *server) beginInteractive() func() {
+	s.interactiveMu.Lock()
+	defer s.interactiveMu.Unlock()
+
+	if s.interactive == 0 {
+		s.idle = make(chan struct{})
+	}
+	s.interactive++
+	return func() {
+		s.interactiveMu.Lock()
+		defer s.interactiveMu.Unlock()
+
+		s.interactive--
+		if s.interactive == 0 {
+			close(s.idle)
+			s.idle = nil
+		}
+	}
+}
+
+// awaitIdle waits until no interactive requests are in progress, or ctx is
+// done.
+func (s *server) awaitIdle(ctx context.Context) error {
+	s.interactiveMu.Lock()
+	idle := s.idle
+	s.interactiveMu.Unlock()
+
+	if idle == nil {
+		return ctx.Err()
+	}
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// analyzeWorkspace analyzes the workspace packages of the snapshot that do
+// not contain open files (which have already been analyzed by diagnose),
+// storing and publishing their diagnostics as it goes.
+//
+// The work is done in small batches, at low priority: each batch waits for
+// interactive requests and foreground diagnostics to complete. The whole
+// operation is abandoned when ctx is cancelled, as happens when the
+// snapshot is superseded; analysis results are cached, so the next pass
+// quickly catches up.
+func (s *server) analyzeWorkspace(ctx context.Context, snapshot *cache.Snapshot) {
+	ctx, done := event.Start(ctx, "Server.analyzeWorkspace", snapshot.Labels()...)
+	defer done()
+
+	workspace, err := snapshot.WorkspaceMetadata(ctx)
+	if err != nil {
+		return
+	}
+	var toAnalyze []*source.Metadata
+	for _, m := range workspace {
+		var hasNonIgnored, hasOpenFile bool
+		for _, uri := range m.CompiledGoFiles {
+			if !hasNonIgnored && !snapshot.IgnoredFile(uri) {
+				hasNonIgnored = true
+			}
+			if !hasOpenFile && snapshot.IsOpen(uri) {
+				hasOpenFile = true
+			}
+		}
+		if hasNonIgnored && !hasOpenFile {
+			toAnalyze = append(toAnalyze, m)
+		}
+	}
+	if len(toAnalyze) == 0 {
+		return
+	}
+	sort.Slice(toAnalyze, func(i, j int) bool {
+		return toAnalyze[i].ID < toAnalyze[j].ID
+	})
+
+	// As with analysis of dependencies, report progress only once the
+	// work has taken a noticeable amount of time.
+	options := snapshot.Options()
+	start := time.Now()
+	var wd *progress.WorkDone
+	defer func() {
+		if wd != nil {
+			wd.End(ctx, "Done.")
+		}
+	}()
+	maybeReport := func(completed int) {
+		if !s.progress.SupportsWorkDoneProgress() || !options.AnalysisProgressReporting {
+			return
+		}
+		if time.Since(start) < options.ReportAnalysisProgressAfter {
+			return
+		}
+		if wd == nil {
+			wd = s.progress.Start(ctx, WorkspaceAnalysisTitle, "", nil, nil)
+		}
+		msg := fmt.Sprintf("Analyzed %d/%d packages.", completed, len(toAnalyze))
+		wd.Report(ctx, msg, 100*float64(completed)/float64(len(toAnalyze)))
+	}
+
+	for i := 0; i < len(toAnalyze); i += workspaceAnalysisBatchSize {
+		maybeReport(i)
+		if !s.analyzeBatch(ctx, snapshot, toAnalyze[i:min(i+workspaceAnalysisBatchSize, len(toAnalyze))]) {
+			return
+		}
+		s.publishDiagnostics(ctx, false, snapshot)
+	}
+	maybeReport(len(toAnalyze))
+}
+
+// analyzeBatch type-checks and analyzes a batch of packages in the
+// background, storing their diagnostics. It reports whether the batch was
+// completed; it returns false if ctx was cancelled.
+func (s *server) analyzeBatch(ctx context.Context, snapshot *cache.Snapshot, batch []*source.Metadata) bool {
+	if s.awaitIdle(ctx) != nil {
+		return false
+	}
+
+	// Yield to foreground diagnostics, which hold the same semaphore.
+	select {
+	case <-ctx.Done():
+		return false
+	case s.diagnosticsSema <- struct{}{}:
+	}
+	defer func() {
+		<-s.diagnosticsSema
+	}()
+
+	toDiagnose := make(map[source.PackageID]*source.Metadata)
+	toAnalyze := make(map[source.PackageID]unit)
+	for _, m := range batch {
+		toDiagnose[m.ID] = m
+		toAnalyze[m.ID] = unit{}
+	}
+	s.diagnosePkgs(ctx, snapshot, toDiagnose, toAnalyze)
+	return ctx.Err() == nil
+}
+
+func min(x, y int) int {
+	if x < y {
+		return x
+	}
+	return y
+}
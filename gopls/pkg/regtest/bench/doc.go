@@ -27,6 +27,12 @@
 // span only the critical section of the benchmark. It is up to each benchmark
 // to implement profiling as appropriate.
 //
+// # Replaying recorded sessions
+//
+// TestReplay replays a session recorded by gopls -rpc.record, given by the
+// -replay flag, against a new gopls process, reporting the responses that
+// differ from the recorded ones and the latency of each method.
+//
 // # Integration with perf.golang.org
 //
 // Benchmarks that run with -short are automatically tracked by
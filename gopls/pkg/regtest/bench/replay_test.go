@@ -0,0 +1,56 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bench
+
+import (
+	"context"
+	"flag"
+	"os"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/gopls/pkg/lsp/lsprpc"
+	"golang.org/x/tools/gopls/pkg/lsp/regtest"
+)
+
+var replay = flag.String("replay", "", "if set, the session recording (see gopls serve -rpc.record) replayed by TestReplay")
+
+// TestReplay replays the session recorded in the -replay file against a
+// new gopls process, reporting the responses that differ from the
+// recorded ones, and the per-method latencies.
+//
+// For example:
+//
+//	$ gopls -rpc.record=session.txtar   # as run by the editor
+//	$ go test ./pkg/regtest/bench -run=TestReplay -replay=session.txtar -v
+func TestReplay(t *testing.T) {
+	if *replay == "" {
+		t.Skip("no -replay recording")
+	}
+	data, err := os.ReadFile(*replay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec, err := lsprpc.ReadRecording(data)
+	if err != nil {
+		t.Fatalf("reading %s: %v", *replay, err)
+	}
+	ts, err := newGoplsConnector(profileArgs("replay", true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := regtest.Replay(context.Background(), rec, t.TempDir(), ts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var report strings.Builder
+	if err := result.WriteReport(&report); err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("replay of %s:\n%s", *replay, report.String())
+	for _, m := range result.Mismatches {
+		t.Errorf("mismatched response to %v", m)
+	}
+}
@@ -346,3 +346,122 @@ func Foo() {
 		)
 	})
 }
+
+func TestReferencesCodeLens(t *testing.T) {
+	const files = `
+-- go.mod --
+module codelens.test
+
+go 1.18
+-- lib.go --
+package lib
+
+type Shape interface {
+	Area() float64
+}
+
+type Square struct{}
+
+func (Square) Area() float64 { return 1 }
+
+func Use(s Shape) {}
+-- a.go --
+package lib
+
+func _() {
+	var s Shape = Square{}
+	Use(s)
+	Use(Square{})
+}
+`
+	WithOptions(
+		Settings{"codelenses": map[string]bool{
+			string(command.References):      true,
+			string(command.Implementations): true,
+		}},
+	).Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("lib.go")
+		got := make(map[string]bool)
+		for _, lens := range env.CodeLens("lib.go") {
+			if lens.Command != nil {
+				t.Errorf("code lens at %v is resolved eagerly: %q", lens.Range, lens.Command.Title)
+			}
+			lens = env.ResolveCodeLens(lens)
+			got[fmt.Sprintf("%d: %s", lens.Range.Start.Line, lens.Command.Title)] = true
+		}
+		line := func(re string) uint32 {
+			return env.RegexpSearch("lib.go", re).Range.Start.Line
+		}
+		for _, want := range []string{
+			fmt.Sprintf("%d: 2 references", line("type Shape")),
+			fmt.Sprintf("%d: 1 implementation", line("type Shape")),
+			fmt.Sprintf("%d: 3 references", line("type Square")),
+			fmt.Sprintf("%d: 1 implementation", line("type Square")),
+			fmt.Sprintf("%d: 2 references", line("func Use")),
+		} {
+			if !got[want] {
+				t.Errorf("missing code lens %q, got %v", want, got)
+			}
+		}
+
+		// The command of the lens lists the references.
+		var locs []protocol.Location
+		cmd, err := command.NewReferencesCommand("", env.RegexpSearch("lib.go", "func (Use)"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		env.ExecuteCommand(&protocol.ExecuteCommandParams{
+			Command:   cmd.Command,
+			Arguments: cmd.Arguments,
+		}, &locs)
+		if len(locs) != 2 {
+			t.Errorf("references command returned %d locations, want 2: %v", len(locs), locs)
+		}
+	})
+}
+
+func TestRunBenchmarksAndFuzzTargets(t *testing.T) {
+	testenv.NeedsGo1Point(t, 18) // for fuzzing
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.18
+-- a_test.go --
+package a
+
+import "testing"
+
+func BenchmarkA(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+	}
+}
+
+func FuzzA(f *testing.F) {
+	f.Add(1)
+	f.Fuzz(func(t *testing.T, n int) {})
+}
+`
+	Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("a_test.go")
+		cmd, err := command.NewRunTestsCommand("run", command.RunTestsArgs{
+			URI:        env.Sandbox.Workdir.URI("a_test.go"),
+			Benchmarks: []string{"BenchmarkA"},
+			Fuzz:       []string{"FuzzA"},
+			FuzzTime:   "1x",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		env.ExecuteCommand(&protocol.ExecuteCommandParams{
+			Command:   cmd.Command,
+			Arguments: cmd.Arguments,
+		}, nil)
+		// The recorded benchmark results are shown by refreshing the
+		// code lenses.
+		env.Await(
+			ShownMessage("all benchmarks and fuzz targets passed"),
+			CodeLensRefreshed(),
+		)
+	})
+}
@@ -607,7 +607,11 @@ func main() {
 	math.Ldex
 }
 `
-	Run(t, src, func(t *testing.T, env *Env) {
+	WithOptions(
+		// The first candidate is accepted, which usage ranking would
+		// change to math.Sqrt, referenced by main.
+		Settings{"usageRanking": false},
+	).Run(t, src, func(t *testing.T, env *Env) {
 		env.OpenFile("main.go")
 		env.Await(env.DoneWithOpen())
 		loc := env.RegexpSearch("main.go", "Ldex()")
@@ -997,3 +1001,90 @@ func Join() {}
 		}
 	})
 }
+
+func TestUsageRanking(t *testing.T) {
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.18
+-- dep/dep.go --
+package dep
+
+func Alpha() {}
+func Beta()  {}
+func Gamma() {}
+-- a/a.go --
+package a
+
+import "mod.com/dep"
+
+func _() {
+	dep.Beta()
+	dep.Beta()
+}
+-- main.go --
+package main
+
+import "mod.com/dep"
+
+func main() {
+	dep.
+}
+`
+	labels := func(list *protocol.CompletionList) []string {
+		var labels []string
+		for _, item := range list.Items {
+			labels = append(labels, item.Label)
+		}
+		return labels
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		WithOptions(
+			Settings{"usageRanking": false},
+		).Run(t, files, func(t *testing.T, env *Env) {
+			env.OpenFile("main.go")
+			env.Await(env.DoneWithOpen())
+			got := labels(env.Completion(env.RegexpSearch("main.go", `dep\.()`)))
+			if want := []string{"Alpha", "Beta", "Gamma"}; !cmp.Equal(got, want) {
+				t.Errorf("completions = %v, want %v", got, want)
+			}
+		})
+	})
+
+	t.Run("enabled", func(t *testing.T) {
+		Run(t, files, func(t *testing.T, env *Env) {
+			env.OpenFile("main.go")
+			env.Await(env.DoneWithOpen())
+
+			// Beta is referenced by package a.
+			loc := env.RegexpSearch("main.go", `dep\.()`)
+			completions := env.Completion(loc)
+			if got, want := labels(completions), []string{"Beta", "Alpha", "Gamma"}; !cmp.Equal(got, want) {
+				t.Errorf("completions = %v, want %v", got, want)
+			}
+
+			// Accepting Gamma ranks it above Alpha in the next completion.
+			for _, item := range completions.Items {
+				if item.Label == "Gamma" {
+					env.AcceptCompletion(loc, item)
+				}
+			}
+			env.Await(env.DoneWithChange())
+			env.RegexpReplace("main.go", `dep\.Gamma.*`, "dep.")
+			got := labels(env.Completion(env.RegexpSearch("main.go", `dep\.()`)))
+			index := func(label string) int {
+				for i, l := range got {
+					if l == label {
+						return i
+					}
+				}
+				return len(got)
+			}
+			if index("Gamma") > index("Alpha") {
+				t.Errorf("completions after accepting Gamma = %v, want Gamma before Alpha", got)
+			}
+		})
+	})
+}
@@ -0,0 +1,128 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package completion
+
+import (
+	"testing"
+
+	"golang.org/x/tools/gopls/pkg/lsp/protocol"
+	. "golang.org/x/tools/gopls/pkg/lsp/regtest"
+)
+
+func TestEmbeddedLanguageCompletion(t *testing.T) {
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.18
+-- a.go --
+package a
+
+import (
+	"database/sql"
+	"regexp"
+)
+
+var _ = regexp.MustCompile(` + "`" + `[[:al` + "`" + `)
+
+var _ = regexp.MustCompile("x(?")
+
+func _(db *sql.DB) {
+	db.Query("SEL")
+	db.Query("select x fr")
+}
+
+func Query(string) {}
+
+func _() {
+	Query("sel")
+}
+
+type T struct {
+	UserID string ` + "`" + `js` + "`" + `
+	URLPath string ` + "`" + `json:"urlPath,om` + "`" + `
+	Name string ` + "`" + `db:"` + "`" + `
+}
+`
+
+	tests := []struct {
+		re   string
+		want []string
+	}{
+		{`\[\[:al()`, []string{"[:alnum:]", "[:alpha:]"}},
+		{`x\(\?()`, []string{"(?:", "(?P<", "(?i)", "(?m)", "(?s)", "(?U)"}},
+		{`"SEL()"`, []string{"SELECT"}},
+		{`select x fr()`, []string{"from"}},
+		{`Query\("sel()"\)\n}`, nil}, // not a known function
+		{"`js()", []string{`json:"userID"`}},
+		{`,om()`, []string{"omitempty"}},
+		{`db:"()`, []string{"name", "-"}},
+	}
+
+	Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("a.go")
+		env.Await(env.DoneWithOpen())
+		for _, test := range tests {
+			completions := env.Completion(env.RegexpSearch("a.go", test.re))
+			if diff := compareCompletionLabels(test.want, completions.Items); diff != "" {
+				t.Errorf("completing at %q: %s", test.re, diff)
+			}
+		}
+	})
+}
+
+func TestEmbeddedLanguageDiagnostics(t *testing.T) {
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.18
+-- a.go --
+package a
+
+import (
+	"database/sql"
+	"regexp"
+)
+
+var _ = regexp.MustCompile(` + "`" + `a(b` + "`" + `)
+
+func _(db *sql.DB) {
+	db.Exec("insert into t values ('x)")
+	db.Query("select 1 -- don't")
+	db.Query("select (1")
+}
+
+func Match(string) {}
+
+func _() {
+	Match("a(b")
+}
+`
+
+	WithOptions(
+		Settings{
+			"embeddedLanguages": map[string]interface{}{
+				"mod.com.Match":           "regexp",
+				"(*database/sql.DB).Exec": "",
+			},
+		},
+	).Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("a.go")
+		env.AfterChange(
+			Diagnostics(env.AtRegexp("a.go", "a\\(b`"), WithMessage("missing closing )")),
+			Diagnostics(env.AtRegexp("a.go", `Match\("(a\(b)`), WithMessage("missing closing )")),
+			NoDiagnostics(env.AtRegexp("a.go", "insert")),
+			NoDiagnostics(env.AtRegexp("a.go", "select 1")),
+			Diagnostics(env.AtRegexp("a.go", `"select \(1"`), WithMessage("missing closing )"), WithSeverityTags("embedded language", protocol.SeverityWarning, nil)),
+		)
+
+		// Fixing the regular expression clears the diagnostic.
+		env.RegexpReplace("a.go", "a\\(b`", "a(b)`")
+		env.AfterChange(
+			NoDiagnostics(env.AtRegexp("a.go", "a\\(b\\)`")),
+		)
+	})
+}
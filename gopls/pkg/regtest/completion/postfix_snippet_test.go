@@ -588,3 +588,78 @@ func _() {
 		}
 	})
 }
+
+func TestUserPostfixSnippetCompletion(t *testing.T) {
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.12
+-- foo.go --
+package foo
+
+func _() error {
+	var err error
+	return err.errwrap
+}
+
+func _() {
+	var foo []int
+	foo.last
+}
+`
+
+	const want = `package foo
+
+import "fmt"
+
+func _() error {
+	var err error
+	return fmt.Errorf("$0: %w", err)
+}
+
+func _() {
+	var foo []int
+	foo[0]
+}
+`
+
+	WithOptions(
+		Settings{
+			"experimentalPostfixCompletions": true,
+			"postfixSnippets": map[string]interface{}{
+				"errwrap": `{{if eq (.TypeName .Type) "error"}}{{.Import "fmt"}}.Errorf("{{.Cursor}}: %w", {{.X}}){{end}}`,
+				// Replaces the built-in snippet.
+				"last": `{{if eq .Kind "slice"}}{{.X}}[0]{{end}}`,
+			},
+		},
+	).Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("foo.go")
+		for _, re := range []string{`errwrap()`, `last()`} {
+			loc := env.RegexpSearch("foo.go", re)
+			completions := env.Completion(loc)
+			if len(completions.Items) != 1 {
+				t.Fatalf("completing %s: expected one completion, got %v", re, completions.Items)
+			}
+			env.AcceptCompletion(loc, completions.Items[0])
+		}
+		if got := env.BufferText("foo.go"); got != want {
+			t.Errorf("\nGOT:\n%s\nEXPECTED:\n%s", got, want)
+		}
+	})
+}
+
+func TestInvalidPostfixSnippet(t *testing.T) {
+	WithOptions(
+		Settings{
+			"postfixSnippets": map[string]interface{}{
+				"broken": `{{if .StmtOK}}`,
+			},
+		},
+	).Run(t, "", func(t *testing.T, env *Env) {
+		env.OnceMet(
+			InitialWorkspaceLoad,
+			ShownMessage(`invalid postfix snippet "broken"`),
+		)
+	})
+}
@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"testing"
 
+	"golang.org/x/tools/gopls/pkg/lsp"
 	"golang.org/x/tools/gopls/pkg/lsp/cache"
 	"golang.org/x/tools/gopls/pkg/lsp/protocol"
 	. "golang.org/x/tools/gopls/pkg/lsp/regtest"
@@ -86,6 +87,74 @@ func main() {
 	}
 }
 
+// TestAnalyzeWorkspace checks that with "analyzeWorkspace", analysis
+// findings are reported for closed files throughout the workspace.
+func TestAnalyzeWorkspace(t *testing.T) {
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.18
+-- a/a.go --
+package a
+
+func _() {
+	x := 1
+	x = x
+}
+-- b/b.go --
+package b
+
+import "fmt"
+
+func _() {
+	fmt.Printf("%d", "s")
+}
+`
+
+	t.Run("disabled", func(t *testing.T) {
+		Run(t, files, func(t *testing.T, env *Env) {
+			env.OnceMet(
+				InitialWorkspaceLoad,
+				NoDiagnostics(ForFile("a/a.go")),
+				NoDiagnostics(ForFile("b/b.go")),
+			)
+		})
+	})
+
+	t.Run("enabled", func(t *testing.T) {
+		WithOptions(
+			Settings{
+				"analyzeWorkspace":            true,
+				"reportAnalysisProgressAfter": "0s",
+			},
+		).Run(t, files, func(t *testing.T, env *Env) {
+			env.OnceMet(
+				InitialWorkspaceLoad,
+				Diagnostics(env.AtRegexp("a/a.go", "x = x"), WithMessage("self-assignment")),
+				Diagnostics(env.AtRegexp("b/b.go", "fmt.Printf"), WithMessage("wrong type")),
+				CompletedWork(lsp.WorkspaceAnalysisTitle, 1, true),
+			)
+
+			// Fixing a closed file clears its analysis diagnostics, while those
+			// of other closed files persist.
+			env.WriteWorkspaceFile("a/a.go", "package a\n")
+			env.AfterChange(
+				NoDiagnostics(ForFile("a/a.go")),
+				Diagnostics(env.AtRegexp("b/b.go", "fmt.Printf")),
+			)
+
+			// Opening a file does not duplicate its diagnostics.
+			env.OpenFile("b/b.go")
+			var d protocol.PublishDiagnosticsParams
+			env.AfterChange(ReadDiagnostics("b/b.go", &d))
+			if len(d.Diagnostics) != 1 {
+				t.Errorf("got %d diagnostics for b/b.go, want 1: %v", len(d.Diagnostics), d.Diagnostics)
+			}
+		})
+	})
+}
+
 // Test the embed directive analyzer.
 //
 // There is a fix for missing imports, but it should not trigger for other
@@ -67,3 +67,60 @@ const (
 		})
 	}
 }
+
+func TestHeapAllocationHints(t *testing.T) {
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.18
+-- a.go --
+package a
+
+type T struct{ x int }
+
+func New() *T {
+	t := T{}
+	return &t
+}
+
+func Sum() int {
+	s := make([]int, 3)
+	return s[0]
+}
+`
+	WithOptions(
+		Settings{
+			"hints": map[string]bool{source.HeapAllocations: true},
+		},
+	).Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("a.go")
+		hints := env.InlayHints("a.go")
+		if len(hints) != 1 {
+			t.Fatalf("got %d inlay hints, want 1: %v", len(hints), hints)
+		}
+		want := env.RegexpSearch("a.go", "t := T").Range.Start
+		if got := hints[0].Position; got != want || hints[0].Label[0].Value != "heap" {
+			t.Errorf("got hint %q at %v, want %q at %v", hints[0].Label[0].Value, got, "heap", want)
+		}
+
+		// Unsaved changes are not built.
+		env.RegexpReplace("a.go", "return &t", "return &T{}")
+		if hints := env.InlayHints("a.go"); len(hints) != 0 {
+			t.Errorf("got %d inlay hints for an unsaved file, want 0", len(hints))
+		}
+	})
+
+	// Packages of untrusted workspace folders are not built.
+	WithOptions(
+		Settings{
+			"hints":                 map[string]bool{source.HeapAllocations: true},
+			"requireWorkspaceTrust": true,
+		},
+	).Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("a.go")
+		if hints := env.InlayHints("a.go"); len(hints) != 0 {
+			t.Errorf("got %d inlay hints in an untrusted folder, want 0", len(hints))
+		}
+	})
+}
@@ -6,6 +6,7 @@ package misc
 
 import (
 	"fmt"
+	"runtime"
 	"strings"
 	"testing"
 
@@ -491,3 +492,75 @@ func TestHoverEmbedDirective(t *testing.T) {
 		}
 	})
 }
+
+func TestHoverStructure(t *testing.T) {
+	if runtime.GOARCH != "amd64" && runtime.GOARCH != "arm64" {
+		t.Skip("layouts are for 64-bit architectures")
+	}
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.18
+-- a.go --
+package a
+
+type T struct {
+	a bool
+	b int64
+	c bool
+}
+
+func (*T) Read([]byte) (int, error) { return 0, nil }
+func (*T) Close() error { return nil }
+
+type Reader interface {
+	Read([]byte) (int, error)
+}
+
+type Closer interface {
+	Close() error
+}
+
+type R struct{}
+
+func (R) Read([]byte) (int, error) { return 0, nil }
+-- other/a.go --
+package a
+
+// T is not the type T of mod.com, though its name is the same.
+type T interface {
+	Close() error
+}
+`
+	tests := []struct {
+		mode, re string
+		want     []string
+		notWant  []string
+	}{
+		{"None", "type (T)", nil, []string{"size=", "Implements"}},
+		{"Layout", "type (T)", []string{"size=24, align=8, padding=14", "a bool: offset=0, size=1, align=1, padding=7", "c bool: offset=16, size=1, align=1, padding=7"}, []string{"Implements"}},
+		{"Implementations", "type (T)", []string{"Implements 3 interfaces: a.Closer, a.Reader, a.T"}, []string{"size="}},
+		{"Full", "type (Reader)", []string{"size=16, align=8", "Implemented by 2 types: a.R, a.T"}, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.mode, func(t *testing.T) {
+			WithOptions(
+				Settings{"hoverStructure": test.mode},
+			).Run(t, files, func(t *testing.T, env *Env) {
+				env.OpenFile("a.go")
+				got, _ := env.Hover(env.RegexpSearch("a.go", test.re))
+				for _, want := range test.want {
+					if !strings.Contains(got.Value, want) {
+						t.Errorf("hover over %q: missing %q. Got:\n%s", test.re, want, got.Value)
+					}
+				}
+				for _, notWant := range test.notWant {
+					if strings.Contains(got.Value, notWant) {
+						t.Errorf("hover over %q: unexpected %q. Got:\n%s", test.re, notWant, got.Value)
+					}
+				}
+			})
+		})
+	}
+}
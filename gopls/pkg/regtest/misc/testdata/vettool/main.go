@@ -0,0 +1,18 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// The vettool command is a unitchecker-based vet tool used to test the
+// vetTool setting. It reports calls to functions named println, with a
+// suggested fix.
+package main
+
+import (
+	"golang.org/x/tools/go/analysis/passes/findcall"
+	"golang.org/x/tools/go/analysis/unitchecker"
+)
+
+func main() {
+	findcall.Analyzer.Flags.Set("name", "println")
+	unitchecker.Main(findcall.Analyzer)
+}
@@ -0,0 +1,78 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package misc
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/gopls/pkg/lsp"
+	"golang.org/x/tools/gopls/pkg/lsp/command"
+	"golang.org/x/tools/gopls/pkg/lsp/protocol"
+	. "golang.org/x/tools/gopls/pkg/lsp/regtest"
+)
+
+func TestWorkspaceTrust(t *testing.T) {
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.18
+-- gen/gen.go --
+// +build ignore
+
+package main
+
+import "os"
+
+func main() {
+	os.WriteFile("generated.go", []byte("package lib\n\nconst Answer = 42"), 0644)
+}
+-- lib/lib.go --
+package lib
+
+//` + `go:generate go run ../gen/gen.go
+-- main.go --
+package main
+
+import "mod.com/lib"
+
+func main() {
+	println(lib.Answer)
+}
+`
+
+	WithOptions(
+		Settings{"requireWorkspaceTrust": true},
+	).Run(t, files, func(t *testing.T, env *Env) {
+		env.OnceMet(
+			InitialWorkspaceLoad,
+			Diagnostics(env.AtRegexp("main.go", "lib.(Answer)")),
+		)
+
+		// go generate must be refused until the workspace is trusted.
+		err := env.Editor.RunGenerate(env.Ctx, "lib")
+		if err == nil || !strings.Contains(err.Error(), "not trusted") {
+			t.Fatalf("RunGenerate in untrusted workspace: got error %v, want 'not trusted'", err)
+		}
+
+		cmd, err := command.NewTrustWorkspaceCommand("", command.URIArg{
+			URI: env.Sandbox.Workdir.URI("main.go"),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		env.ExecuteCommand(&protocol.ExecuteCommandParams{
+			Command:   cmd.Command,
+			Arguments: cmd.Arguments,
+		}, nil)
+		env.Await(CompletedWork(lsp.DiagnosticWorkTitle(lsp.FromTrustWorkspace), 1, true))
+
+		env.RunGenerate("lib")
+		env.AfterChange(
+			NoDiagnostics(ForFile("main.go")),
+		)
+	})
+}
@@ -0,0 +1,62 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package misc
+
+import (
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/gopls/pkg/lsp/protocol"
+	. "golang.org/x/tools/gopls/pkg/lsp/regtest"
+	"golang.org/x/tools/pkg/testenv"
+)
+
+func TestVetTool(t *testing.T) {
+	testenv.NeedsGoBuild(t)
+
+	tool := filepath.Join(t.TempDir(), "vettool.exe")
+	cmd := exec.Command("go", "build", "-o", tool, "./testdata/vettool")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("building vet tool: %v\n%s", err, out)
+	}
+
+	const files = `
+-- go.mod --
+module mod.com
+
+go 1.18
+-- main.go --
+package main
+
+func main() {
+	println("hello")
+}
+`
+	WithOptions(
+		Settings{"vetTool": tool},
+	).Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("main.go")
+		var d protocol.PublishDiagnosticsParams
+		env.AfterChange(
+			Diagnostics(
+				env.AtRegexp("main.go", `\("hello"\)`),
+				WithMessage("call of println(...)"),
+				FromSource("findcall"),
+			),
+			ReadDiagnostics("main.go", &d),
+		)
+
+		// The tool's suggested fix is offered as a quick fix.
+		env.ApplyQuickFixes("main.go", d.Diagnostics)
+		if got := env.BufferText("main.go"); !strings.Contains(got, `println_TEST_("hello")`) {
+			t.Errorf("after applying fix, got:\n%s", got)
+		}
+
+		// Unsaved packages are not analyzed by the tool.
+		env.AfterChange(NoDiagnostics(ForFile("main.go"), FromSource("findcall")))
+	})
+}
@@ -10,6 +10,8 @@ package misc
 import (
 	"context"
 	"encoding/json"
+	"path"
+	"path/filepath"
 	"sort"
 	"strings"
 	"testing"
@@ -24,6 +26,7 @@ import (
 	"golang.org/x/tools/gopls/pkg/lsp/source"
 	"golang.org/x/tools/gopls/pkg/lsp/tests/compare"
 	"golang.org/x/tools/gopls/pkg/vulncheck"
+	"golang.org/x/tools/gopls/pkg/vulncheck/osv"
 	"golang.org/x/tools/gopls/pkg/vulncheck/scan"
 	"golang.org/x/tools/gopls/pkg/vulncheck/vulntest"
 )
@@ -216,6 +219,13 @@ func main() {
 			ShownMessage("Found GOSTDLIB"),
 			NoDiagnostics(ForFile("go.mod")),
 		)
+		// The standard library cannot be upgraded by a quick fix.
+		testVulnCallDiagnostics(t, env, "main.go", map[string]vulnDiag{
+			`zip\.(OpenReader)`: {
+				msg:         "zip.OpenReader is vulnerable: GOSTDLIB.",
+				codeActions: []string{"Reset govulncheck result"},
+			},
+		})
 		testFetchVulncheckResult(t, env, map[string]fetchVulncheckResult{
 			"go.mod": {IDs: []string{"GOSTDLIB"}, Mode: vulncheck.ModeGovulncheck}})
 	})
@@ -634,6 +644,88 @@ func TestRunGovulncheck_Expiry(t *testing.T) {
 	})
 }
 
+// TestRunGovulncheckOfflineSnapshot checks that govulncheck runs on a
+// vendored module without a network, using a local snapshot of the
+// vulnerability database, and warns that the snapshot is stale.
+func TestRunGovulncheckOfflineSnapshot(t *testing.T) {
+	const files = `
+-- go.mod --
+module golang.org/entry
+
+go 1.18
+
+require golang.org/bmod v0.5.0
+-- x/x.go --
+package x
+
+import "golang.org/bmod/bvuln"
+
+func X() {
+	bvuln.Vuln()
+}
+-- vendor/modules.txt --
+# golang.org/bmod v0.5.0
+## explicit; go 1.14
+golang.org/bmod/bvuln
+-- vendor/golang.org/bmod/bvuln/bvuln.go --
+package bvuln
+
+func Vuln() {
+	// something evil
+}
+`
+	// Every snapshot is stale.
+	defer func(prev time.Duration) {
+		vulncheck.MaxDBAge = prev
+	}(vulncheck.MaxDBAge)
+	vulncheck.MaxDBAge = 0
+
+	db, err := vulntest.NewDatabase(context.Background(), []byte(vulnsData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Clean()
+	snapshot, err := osv.ImportSnapshot(filepath.Join(t.TempDir(), "vulndb"), protocol.DocumentURI(db.URI()).Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	WithOptions(
+		EnvVars{
+			"GOVULNDB":                        snapshot.URI(),
+			"GOFLAGS":                         "-mod=vendor",
+			"GOPROXY":                         "off",
+			scan.GoVersionForVulnTest:         "go1.18",
+			"_GOPLS_TEST_BINARY_RUN_AS_GOPLS": "true", // needed to run `gopls vulncheck`.
+		},
+		Settings{
+			"codelenses": map[string]bool{
+				"run_govulncheck": true,
+			},
+		},
+	).Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("go.mod")
+
+		var result command.RunVulncheckResult
+		env.ExecuteCodeLensCommand("go.mod", command.RunGovulncheck, &result)
+		env.OnceMet(
+			CompletedProgress(result.Token, nil),
+			ShownMessage("Found GO-2022-02"),
+			ShownMessage("was last modified on"),
+		)
+		env.Await(Diagnostics(env.AtRegexp("go.mod", "golang.org/bmod")))
+		testFetchVulncheckResult(t, env, map[string]fetchVulncheckResult{
+			"go.mod": {IDs: []string{"GO-2022-02"}, Mode: vulncheck.ModeGovulncheck},
+		})
+
+		// Hovers over vulnerable modules note that the snapshot is stale.
+		hover, _ := env.Hover(env.RegexpSearch("go.mod", "golang.org/bmod"))
+		if !strings.Contains(hover.Value, snapshot.URI()+" was last modified") {
+			t.Errorf("hover over golang.org/bmod = %q, want a note that the database is stale", hover.Value)
+		}
+	})
+}
+
 func stringify(a interface{}) string {
 	data, _ := json.Marshal(a)
 	return string(data)
@@ -666,6 +758,28 @@ func TestRunVulncheckWarning(t *testing.T) {
 		})
 		env.OpenFile("x/x.go")
 		env.OpenFile("y/y.go")
+
+		// Call sites that reach vulnerable symbols are diagnosed in Go files.
+		testVulnCallDiagnostics(t, env, "x/x.go", map[string]vulnDiag{
+			`(Vuln1)\(\)`: {
+				msg:         "avuln.VulnData.Vuln1 is vulnerable: GO-2022-01.",
+				codeActions: []string{"Upgrade to v1.0.4", "Reset govulncheck result"},
+			},
+			`c\.(C1)`: {
+				msg:         "This call reaches avuln.VulnData.Vuln2, which is vulnerable: GO-2022-01.",
+				codeActions: []string{"Upgrade to v1.0.4", "Reset govulncheck result"},
+				relatedInfo: []vulnRelatedInfo{
+					{Filename: "c.go", Line: 13, Message: "c.C1 calls avuln.VulnData.Vuln2"},
+				},
+			},
+		})
+		testVulnCallDiagnostics(t, env, "y/y.go", map[string]vulnDiag{
+			`c\.C2\(\)`: {
+				msg:         "bvuln.Vuln is vulnerable: GO-2022-02.",
+				codeActions: []string{"Reset govulncheck result"}, // no fix
+			},
+		})
+
 		wantDiagnostics := map[string]vulnDiagExpectation{
 			"golang.org/amod": {
 				applyAction: "Upgrade to v1.0.6",
@@ -753,6 +867,12 @@ require (
 		if got := env.BufferText("go.mod"); got != wantGoMod {
 			t.Fatalf("go.mod vulncheck fix failed:\n%s", compare.Text(wantGoMod, got))
 		}
+
+		// The call sites of fixed vulnerabilities are no longer diagnosed.
+		env.AfterChange(
+			NoDiagnostics(env.AtRegexp("x/x.go", `(Vuln1)\(\)`)),
+			Diagnostics(env.AtRegexp("y/y.go", `c\.C2\(\)`)),
+		)
 	})
 }
 
@@ -912,6 +1032,71 @@ func testVulnDiagnostics(t *testing.T, env *Env, pattern string, want vulnDiagEx
 	return modPathDiagnostics
 }
 
+// testVulnCallDiagnostics checks that the govulncheck diagnostics in the
+// Go file name are those of want, which maps a regular expression matching
+// the start of each diagnostic to its expectation. The severity and source
+// of the diagnostics are not set in want, as they are always the same, and
+// the codeActions of want are the titles of the quick fixes only.
+func testVulnCallDiagnostics(t *testing.T, env *Env, name string, want map[string]vulnDiag) {
+	t.Helper()
+	got := &protocol.PublishDiagnosticsParams{}
+	env.OnceMet(
+		Diagnostics(ForFile(name), FromSource(string(source.Govulncheck))),
+		ReadDiagnostics(name, got),
+	)
+	var vulnDiags []protocol.Diagnostic
+	for _, d := range got.Diagnostics {
+		if d.Source == string(source.Govulncheck) {
+			vulnDiags = append(vulnDiags, d)
+		}
+	}
+	if len(vulnDiags) != len(want) {
+		t.Errorf("got %d govulncheck diagnostics in %s, want %d:\n%s", len(vulnDiags), name, len(want), stringify(vulnDiags))
+	}
+	for re, w := range want {
+		loc := env.RegexpSearch(name, re)
+		var diag *protocol.Diagnostic
+		for i, d := range vulnDiags {
+			if d.Range.Start == loc.Range.Start && d.Message == w.msg {
+				diag = &vulnDiags[i]
+			}
+		}
+		if diag == nil {
+			t.Errorf("no diagnostic at %#q in %s matching %q:\n%s", re, name, w.msg, stringify(vulnDiags))
+			continue
+		}
+		if diag.Severity != protocol.SeverityWarning {
+			t.Errorf("severity of %q = %v, want %v", w.msg, diag.Severity, protocol.SeverityWarning)
+		}
+		if diff := cmp.Diff(w.relatedInfo, summarizeRelatedInfo(diag.RelatedInformation)); diff != "" {
+			t.Errorf("related info of %q mismatch (-want +got):\n%s", w.msg, diff)
+		}
+		var gotActions []protocol.CodeAction
+		for _, action := range env.CodeAction(name, []protocol.Diagnostic{*diag}) {
+			if action.Kind == protocol.QuickFix {
+				gotActions = append(gotActions, action)
+			}
+		}
+		if diff := diffCodeActions(gotActions, w.codeActions); diff != "" {
+			t.Errorf("quick fixes for %q do not match, want %v, got %v\n%v\n", w.msg, w.codeActions, gotActions, diff)
+		}
+	}
+}
+
+// summarizeRelatedInfo summarizes related information by the base names
+// of its files, its lines, and its messages.
+func summarizeRelatedInfo(related []protocol.DiagnosticRelatedInformation) []vulnRelatedInfo {
+	var summary []vulnRelatedInfo
+	for _, r := range related {
+		summary = append(summary, vulnRelatedInfo{
+			Filename: path.Base(r.Location.URI.Path()),
+			Line:     r.Location.Range.Start.Line,
+			Message:  r.Message,
+		})
+	}
+	return summary
+}
+
 type vulnRelatedInfo struct {
 	Filename string
 	Line     uint32
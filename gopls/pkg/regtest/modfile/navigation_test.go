@@ -0,0 +1,147 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package modfile
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "golang.org/x/tools/gopls/pkg/lsp/regtest"
+
+	"golang.org/x/tools/gopls/pkg/lsp/protocol"
+)
+
+const navigationProxy = `
+-- example.com@v1.2.2/go.mod --
+module example.com
+
+go 1.12
+-- example.com@v1.2.2/blah/blah.go --
+package blah
+
+const Name = "Blah"
+-- example.com@v1.2.3/go.mod --
+module example.com
+
+go 1.12
+-- example.com@v1.2.3/blah/blah.go --
+package blah
+
+const Name = "Blah"
+-- example.com/other@v1.0.0/go.mod --
+module example.com/other
+
+go 1.12
+-- example.com/other@v1.0.0/other.go --
+package other
+`
+
+const navigationModule = `
+-- go.mod --
+module mod.com
+
+go 1.12
+
+require example.com v1.2.3
+-- main.go --
+package main
+
+import "example.com/blah"
+
+func main() {
+	println(blah.Name)
+}
+-- other/other.go --
+package other
+
+import _ "example.com/blah"
+`
+
+func TestModCompletion(t *testing.T) {
+	WithOptions(
+		ProxyFiles(navigationProxy),
+	).Run(t, navigationModule, func(t *testing.T, env *Env) {
+		env.RunGoCommand("mod", "download", "example.com@v1.2.2", "example.com@v1.2.3", "example.com/other@v1.0.0")
+		env.OpenFile("go.mod")
+
+		// Versions are offered most recent first.
+		got := completionLabels(env.Completion(env.RegexpSearch("go.mod", `v1\.2\.3`)))
+		if want := []string{"v1.2.3", "v1.2.2"}; !equalStrings(got, want) {
+			t.Errorf("version completion: got %v, want %v", got, want)
+		}
+
+		// Module paths are completed from the module cache.
+		env.RegexpReplace("go.mod", `require example.com v1.2.3`, "require (\n\texample.com v1.2.3\n\texample.com/o\n)")
+		got = completionLabels(env.Completion(env.RegexpSearch("go.mod", `example.com/o()`)))
+		if want := []string{"example.com/other"}; !equalStrings(got, want) {
+			t.Errorf("path completion: got %v, want %v", got, want)
+		}
+	})
+}
+
+func TestModDefinition(t *testing.T) {
+	WithOptions(
+		ProxyFiles(navigationProxy),
+	).Run(t, navigationModule, func(t *testing.T, env *Env) {
+		env.RunGoCommand("mod", "download", "example.com@v1.2.3")
+		env.OpenFile("go.mod")
+
+		loc := env.GoToDefinition(env.RegexpSearch("go.mod", `example.com v1.2.3`))
+		if got, want := filepath.ToSlash(loc.URI.Path()), "example.com@v1.2.3/go.mod"; !strings.HasSuffix(got, want) {
+			t.Errorf("definition of requirement: got %s, want suffix %s", got, want)
+		}
+
+		// A requirement replaced by a local directory jumps to its go.mod file.
+		env.WriteWorkspaceFile("local/go.mod", "module example.com\n\ngo 1.12\n")
+		env.RegexpReplace("go.mod", `(require example.com v1.2.3)`, "require example.com v1.2.3\n\nreplace example.com => ./local")
+		loc = env.GoToDefinition(env.RegexpSearch("go.mod", `example.com v1.2.3`))
+		if got, want := loc.URI, env.Sandbox.Workdir.URI("local/go.mod"); got != want {
+			t.Errorf("definition of replaced requirement: got %s, want %s", got, want)
+		}
+	})
+}
+
+func TestModReferences(t *testing.T) {
+	WithOptions(
+		ProxyFiles(navigationProxy),
+	).Run(t, navigationModule, func(t *testing.T, env *Env) {
+		env.RunGoCommand("mod", "tidy")
+		env.OpenFile("go.mod")
+		env.AfterChange()
+
+		var got []string
+		for _, loc := range env.References(env.RegexpSearch("go.mod", `example.com v1.2.3`)) {
+			name := env.Sandbox.Workdir.URIToPath(loc.URI)
+			got = append(got, fmt.Sprintf("%s:%d:%d", name, loc.Range.Start.Line, loc.Range.Start.Character))
+		}
+		// The declaration, followed by the imports of example.com/blah.
+		want := []string{"go.mod:4:8", "main.go:2:7", "other/other.go:2:9"}
+		if !equalStrings(got, want) {
+			t.Errorf("references: got %v, want %v", got, want)
+		}
+	})
+}
+
+func completionLabels(list *protocol.CompletionList) []string {
+	var labels []string
+	for _, item := range list.Items {
+		labels = append(labels, item.Label)
+	}
+	return labels
+}
+
+func equalStrings(x, y []string) bool {
+	if len(x) != len(y) {
+		return false
+	}
+	for i := range x {
+		if x[i] != y[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,134 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package template
+
+import (
+	"sort"
+	"strings"
+	"testing"
+
+	. "golang.org/x/tools/gopls/pkg/lsp/regtest"
+)
+
+const gotypeFiles = `
+-- go.mod --
+module mod.com
+
+go 1.18
+-- data/data.go --
+package data
+
+type Page struct {
+	Title string
+	Items []Item
+	Meta  map[string]string
+	Owner
+}
+
+type Owner struct {
+	Name string
+}
+
+type Item struct {
+	Name  string
+	Price int
+}
+
+func (p *Page) Count() int { return len(p.Items) }
+
+func (p *Page) Format(layout string) string { return "" }
+-- page.tmpl --
+{{/* gotype: mod.com/data.Page */}}
+<h1>{{.Title}}</h1>
+{{range .Items}}{{.Name}} {{.Price}}{{end}}
+{{.Meta.anything}} {{.Name}} {{.Count}}
+{{.Format "x"}}
+`
+
+func TestTemplateGotypeDiagnostics(t *testing.T) {
+	WithOptions(
+		Settings{"templateExtensions": []string{"tmpl"}},
+	).Run(t, gotypeFiles, func(t *testing.T, env *Env) {
+		env.OpenFile("page.tmpl")
+		env.AfterChange(
+			NoDiagnostics(ForFile("page.tmpl")),
+		)
+
+		env.RegexpReplace("page.tmpl", `\.Price`, ".Cost")
+		env.AfterChange(
+			Diagnostics(env.AtRegexp("page.tmpl", `\.(Cost)`), WithMessage("can't evaluate field Cost in type Item")),
+		)
+
+		env.RegexpReplace("page.tmpl", `\.Format "x"`, ".Format")
+		env.AfterChange(
+			Diagnostics(env.AtRegexp("page.tmpl", `\.(Format)`), WithMessage("wrong number of args for Format: want 1 got 0")),
+		)
+
+		env.RegexpReplace("page.tmpl", `data\.Page`, "data.Missing")
+		env.AfterChange(
+			Diagnostics(env.AtRegexp("page.tmpl", `mod.com/data.Missing`), WithMessage("no type Missing in package mod.com/data")),
+		)
+	})
+}
+
+func TestTemplateGotypeCompletion(t *testing.T) {
+	WithOptions(
+		Settings{"templateExtensions": []string{"tmpl"}},
+	).Run(t, gotypeFiles, func(t *testing.T, env *Env) {
+		env.OpenFile("page.tmpl")
+		env.AfterChange()
+
+		tests := []struct {
+			re   string
+			want []string
+		}{
+			{`<h1>{{\.()Title`, []string{"Count", "Format", "Items", "Meta", "Name", "Owner", "Title"}},
+			{`<h1>{{\.T()itle`, []string{"Title"}},
+			{`{{range \.Items}}{{\.N()ame`, []string{"Name"}},
+		}
+		for _, test := range tests {
+			list := env.Completion(env.RegexpSearch("page.tmpl", test.re))
+			var got []string
+			for _, item := range list.Items {
+				got = append(got, item.Label)
+			}
+			sort.Strings(got)
+			if strings.Join(got, ",") != strings.Join(test.want, ",") {
+				t.Errorf("completing at %q: got %v, want %v", test.re, got, test.want)
+			}
+		}
+
+		// A trailing dot completes the fields of the chain.
+		env.RegexpReplace("page.tmpl", `{{\.Count}}`, "{{.Owner.}}")
+		list := env.Completion(env.RegexpSearch("page.tmpl", `\.Owner\.()`))
+		if len(list.Items) != 1 || list.Items[0].Label != "Name" {
+			t.Errorf("completing .Owner.: got %v, want [Name]", list.Items)
+		}
+	})
+}
+
+func TestTemplateGotypeHoverAndDefinition(t *testing.T) {
+	WithOptions(
+		Settings{"templateExtensions": []string{"tmpl"}},
+	).Run(t, gotypeFiles, func(t *testing.T, env *Env) {
+		env.OpenFile("page.tmpl")
+		env.AfterChange()
+
+		loc := env.RegexpSearch("page.tmpl", `{{\.(Price)`)
+		hover, _ := env.Hover(loc)
+		if !strings.Contains(hover.Value, "field Price int") {
+			t.Errorf("hover over .Price: got %q, want field Price int", hover.Value)
+		}
+
+		def := env.GoToDefinition(loc)
+		if got, want := env.Sandbox.Workdir.URIToPath(def.URI), "data/data.go"; got != want {
+			t.Errorf("definition of .Price in %s, want %s", got, want)
+		}
+		want := env.RegexpSearch("data/data.go", `(Price) +int`)
+		if def.Range != want.Range {
+			t.Errorf("definition of .Price at %v, want %v", def.Range, want.Range)
+		}
+	})
+}
@@ -0,0 +1,155 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package workspace
+
+import (
+	"testing"
+
+	"golang.org/x/tools/gopls/pkg/lsp/protocol"
+	"golang.org/x/tools/gopls/pkg/lsp/tests/compare"
+
+	. "golang.org/x/tools/gopls/pkg/lsp/regtest"
+)
+
+func TestGoWorkLenses(t *testing.T) {
+	const files = `
+-- go.work --
+go 1.20
+
+use (
+	./a
+	./missing
+)
+-- a/go.mod --
+module mod.com/a
+
+go 1.18
+-- a/a.go --
+package a
+-- b/go.mod --
+module mod.com/b
+
+go 1.18
+-- b/b.go --
+package b
+-- nested/c/go.mod --
+module mod.com/c
+
+go 1.18
+-- nested/c/c.go --
+package c
+-- testdata/d/go.mod --
+module mod.com/d
+
+go 1.18
+`
+
+	Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("go.work")
+		env.AfterChange()
+
+		// Uses of missing modules must be dropped before using nested
+		// modules, as the go command fails to load the workspace.
+		executeLens(env, "go.work", "Drop use of 1 directory without a go.mod file")
+		env.AfterChange()
+		executeLens(env, "go.work", "Use 2 nested modules")
+
+		want := `go 1.20
+
+use (
+	./a
+	./b
+	./nested/c
+)
+`
+		if diff := compare.Text(want, env.ReadWorkspaceFile("go.work")); diff != "" {
+			t.Errorf("unexpected go.work content:\n%s", diff)
+		}
+	})
+}
+
+// executeLens executes the command of the code lens of the file with the
+// given title.
+func executeLens(env *Env, path, title string) {
+	env.T.Helper()
+	var titles []string
+	for _, lens := range env.CodeLens(path) {
+		if lens.Command.Title == title {
+			env.ExecuteCommand(&protocol.ExecuteCommandParams{
+				Command:   lens.Command.Command,
+				Arguments: lens.Command.Arguments,
+			}, nil)
+			return
+		}
+		titles = append(titles, lens.Command.Title)
+	}
+	env.T.Fatalf("no code lens %q in %s; got %q", title, path, titles)
+}
+
+func TestGoWorkReplaceToUse(t *testing.T) {
+	const files = `
+-- go.work --
+go 1.20
+
+use ./a
+-- a/go.mod --
+module mod.com/a
+
+go 1.18
+
+require mod.com/b v1.0.0
+
+replace mod.com/b => ../b
+-- a/a.go --
+package a
+
+import _ "mod.com/b"
+-- b/go.mod --
+module mod.com/b
+
+go 1.18
+-- b/b.go --
+package b
+`
+	Run(t, files, func(t *testing.T, env *Env) {
+		env.OpenFile("a/go.mod")
+		env.AfterChange()
+		loc := env.RegexpSearch("a/go.mod", "replace")
+		actions, err := env.Editor.CodeAction(env.Ctx, loc, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var found bool
+		for _, action := range actions {
+			if action.Title == "Use mod.com/b in go.work instead of replacing it" {
+				env.ApplyCodeAction(action)
+				found = true
+			}
+		}
+		if !found {
+			t.Fatalf("no code action converting the replace directive; got %v", actions)
+		}
+
+		wantMod := `module mod.com/a
+
+go 1.18
+
+require mod.com/b v1.0.0
+`
+		if diff := compare.Text(wantMod, env.BufferText("a/go.mod")); diff != "" {
+			t.Errorf("unexpected go.mod content:\n%s", diff)
+		}
+		wantWork := `go 1.20
+
+use (
+	./a
+	./b
+)
+`
+		if diff := compare.Text(wantWork, env.ReadWorkspaceFile("go.work")); diff != "" {
+			t.Errorf("unexpected go.work content:\n%s", diff)
+		}
+	})
+}
@@ -0,0 +1,68 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package workspace
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/go/packages/packagestest"
+	"golang.org/x/tools/gopls/pkg/lsp/protocol"
+
+	. "golang.org/x/tools/gopls/pkg/lsp/regtest"
+)
+
+// TestIncrementalPackagesDriver checks that gopls reloads only the affected
+// packages, using "file=" queries, when a GOPACKAGESDRIVER declares support
+// for incremental queries, and that changes to the non-Go files the driver
+// reports as owned by a package invalidate that package.
+func TestIncrementalPackagesDriver(t *testing.T) {
+	const files = `
+-- go.mod --
+module mod.test
+
+go 1.18
+-- a/BUILD --
+-- a/a.go --
+package a
+
+import "mod.test/b"
+
+var _ = b.B
+-- b/b.go --
+package b
+
+const B = 1
+`
+	driverEnv, err := packagestest.FakeDriverEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	vars := make(EnvVars)
+	for _, kv := range driverEnv {
+		k, v, _ := strings.Cut(kv, "=")
+		vars[k] = v
+	}
+
+	WithOptions(
+		vars,
+		Modes(Default), // the fake driver is the test executable itself
+	).Run(t, files, func(t *testing.T, env *Env) {
+		env.Await(InitialWorkspaceLoad)
+
+		// Changing the BUILD file reloads only package a.
+		env.WriteWorkspaceFile("a/BUILD", "# changed\n")
+		env.AfterChange(
+			LogMatching(protocol.Info, `query=\[file=[^ \]]*a\.go\]`, 1, false),
+		)
+
+		// Changing the imports of b reloads b and its reverse dependency a.
+		env.WriteWorkspaceFile("b/b.go", "package b\n\nimport _ \"fmt\"\n\nconst B = 1\n")
+		env.AfterChange(
+			NoDiagnostics(ForFile("a/a.go")),
+			LogMatching(protocol.Info, `query=\[file=[^ \]]*a\.go file=[^ \]]*b\.go\]`, 1, false),
+		)
+	})
+}
@@ -11,6 +11,7 @@ import (
 	"strings"
 	"testing"
 
+	"golang.org/x/tools/go/packages/packagestest"
 	"golang.org/x/tools/gopls/pkg/bug"
 	"golang.org/x/tools/gopls/pkg/goversion"
 	"golang.org/x/tools/gopls/pkg/hooks"
@@ -22,6 +23,7 @@ import (
 )
 
 func TestMain(m *testing.M) {
+	packagestest.RunFakeDriverIfRequested()
 	bug.PanicOnBugs = true
 	Main(m, hooks.Options)
 }
@@ -72,6 +72,14 @@ var GeneratedAPIJSON = &APIJSON{
 				Status:    "experimental",
 				Hierarchy: "build",
 			},
+			{
+				Name:      "requireWorkspaceTrust",
+				Type:      "bool",
+				Doc:       "requireWorkspaceTrust causes gopls to treat each workspace folder as\nuntrusted until it is marked trusted using the `gopls.trust_workspace`\ncommand.\n\nIn an untrusted folder, gopls refuses to run commands with side effects\n(such as `go generate`, `go test`, or `go mod vendor`), builds no\npackages to compute heap allocation hints, and runs all other go\ncommands with `GOTOOLCHAIN=local` and with `-mod=mod` removed from\nGOFLAGS, so that opening a repository cannot cause code from it, or a\ntoolchain it requests, to be downloaded or executed.\n",
+				Default:   "false",
+				Status:    "experimental",
+				Hierarchy: "build",
+			},
 			{
 				Name:      "standaloneTags",
 				Type:      "[]string",
@@ -110,6 +118,32 @@ var GeneratedAPIJSON = &APIJSON{
 				Default:   "true",
 				Hierarchy: "ui.documentation",
 			},
+			{
+				Name: "hoverStructure",
+				Type: "enum",
+				Doc:  "hoverStructure controls the structural information about named types\nthat appears in the hover text, in addition to their declaration and\ndocumentation.\n\nThe layout is the size and alignment of the type, and the offset,\nsize and padding of each field of a struct type, for the GOARCH of\nthe view. The implementations are the number of interfaces a type\nimplements, or of types implementing an interface, with a sample of\ntheir names. Computing them requires the method sets of all packages\nin the workspace.\n",
+				EnumValues: []EnumValue{
+					{
+						Value: "\"Full\"",
+						Doc:   "`\"Full\"` shows both the layout and implementations.\n",
+					},
+					{
+						Value: "\"Implementations\"",
+						Doc:   "`\"Implementations\"` shows the implementation relations of\ntypes.\n",
+					},
+					{
+						Value: "\"Layout\"",
+						Doc:   "`\"Layout\"` shows the memory layout of types.\n",
+					},
+					{
+						Value: "\"None\"",
+						Doc:   "`\"None\"` shows no structural information.\n",
+					},
+				},
+				Default:   "\"None\"",
+				Status:    "experimental",
+				Hierarchy: "ui.documentation",
+			},
 			{
 				Name:      "usePlaceholders",
 				Type:      "bool",
@@ -146,6 +180,22 @@ var GeneratedAPIJSON = &APIJSON{
 				Status:    "experimental",
 				Hierarchy: "ui.completion",
 			},
+			{
+				Name:      "postfixSnippets",
+				Type:      "map[string]string",
+				Doc:       "postfixSnippets defines additional postfix snippets, mapping the label\nof each snippet to its body. Bodies use the same text/template language\nand helpers (such as .Kind, .StmtOK, .TypeName and .Import) as the\nbuilt-in snippets, and a snippet is only offered if its body expands to\nnon-blank text. A snippet with the label of a built-in snippet replaces\nit. Postfix snippets must be enabled with experimentalPostfixCompletions.\n\nExample Usage:\n\n```json5\n\"gopls\": {\n...\n  \"postfixSnippets\": {\n    \"errwrap\": \"{{if eq (.TypeName .Type) \\\"error\\\"}}{{.Import \\\"fmt\\\"}}.Errorf(\\\"{{.Cursor}}: %w\\\", {{.X}}){{end}}\"\n  }\n...\n}\n```\n",
+				Default:   "{}",
+				Status:    "experimental",
+				Hierarchy: "ui.completion",
+			},
+			{
+				Name:      "embeddedLanguages",
+				Type:      "map[string]string",
+				Doc:       "embeddedLanguages maps functions to the language of the string literal\npassed as their first string parameter. Within such literals, gopls\noffers completion and diagnostics for the language. Functions are\nnamed as by types.Func.FullName, such as \"regexp.MustCompile\" or\n\"(*database/sql.DB).Query\", and the supported languages are \"regexp\"\nand \"sql\". The literals of struct field tags always use the\n\"structtag\" language.\n\nThe given entries are added to the defaults, which cover the regexp\nand database/sql packages; an entry with an empty language removes\na function.\n",
+				Default:   "{\"(*database/sql.Conn).ExecContext\":\"sql\",\"(*database/sql.Conn).PrepareContext\":\"sql\",\"(*database/sql.Conn).QueryContext\":\"sql\",\"(*database/sql.Conn).QueryRowContext\":\"sql\",\"(*database/sql.DB).Exec\":\"sql\",\"(*database/sql.DB).ExecContext\":\"sql\",\"(*database/sql.DB).Prepare\":\"sql\",\"(*database/sql.DB).PrepareContext\":\"sql\",\"(*database/sql.DB).Query\":\"sql\",\"(*database/sql.DB).QueryContext\":\"sql\",\"(*database/sql.DB).QueryRow\":\"sql\",\"(*database/sql.DB).QueryRowContext\":\"sql\",\"(*database/sql.Tx).Exec\":\"sql\",\"(*database/sql.Tx).ExecContext\":\"sql\",\"(*database/sql.Tx).Prepare\":\"sql\",\"(*database/sql.Tx).PrepareContext\":\"sql\",\"(*database/sql.Tx).Query\":\"sql\",\"(*database/sql.Tx).QueryContext\":\"sql\",\"(*database/sql.Tx).QueryRow\":\"sql\",\"(*database/sql.Tx).QueryRowContext\":\"sql\",\"regexp.Compile\":\"regexp\",\"regexp.CompilePOSIX\":\"regexp\",\"regexp.Match\":\"regexp\",\"regexp.MatchReader\":\"regexp\",\"regexp.MatchString\":\"regexp\",\"regexp.MustCompile\":\"regexp\",\"regexp.MustCompilePOSIX\":\"regexp\"}",
+				Status:    "experimental",
+				Hierarchy: "ui.completion",
+			},
 			{
 				Name:      "completeFunctionCalls",
 				Type:      "bool",
@@ -153,6 +203,14 @@ var GeneratedAPIJSON = &APIJSON{
 				Default:   "true",
 				Hierarchy: "ui.completion",
 			},
+			{
+				Name:      "usageRanking",
+				Type:      "bool",
+				Doc:       "usageRanking ranks completion candidates higher the more they are used\nin the workspace. Gopls counts the references of the workspace\npackages to the members of the packages they import, and the\ncandidates that are accepted in completions, which it remembers\nacross sessions.\n",
+				Default:   "true",
+				Status:    "experimental",
+				Hierarchy: "ui.completion",
+			},
 			{
 				Name: "importShortcut",
 				Type: "enum",
@@ -582,6 +640,22 @@ var GeneratedAPIJSON = &APIJSON{
 				Default:   "true",
 				Hierarchy: "ui.diagnostic",
 			},
+			{
+				Name:      "analyzeWorkspace",
+				Type:      "bool",
+				Doc:       "analyzeWorkspace enables analysis of every package in the workspace,\nnot just those of open files. Workspace packages are analyzed in the\nbackground, after diagnostics for open files have been published, and\nanalysis pauses while interactive requests such as completion are in\nprogress. Findings are reported as diagnostics for closed files too.\n",
+				Default:   "false",
+				Status:    "experimental",
+				Hierarchy: "ui.diagnostic",
+			},
+			{
+				Name:      "vetTool",
+				Type:      "string",
+				Doc:       "vetTool is the name or path of an external analysis tool, such as\none built with golang.org/x/tools/go/analysis/unitchecker, to run as\n`go vet -vettool` on analyzed packages. Its findings and suggested\nfixes are reported as diagnostics. Packages with unsaved changes are\nnot analyzed by the tool, as it reads files from disk.\n\nResults are cached, and invalidated when the tool executable changes.\n",
+				Default:   "\"\"",
+				Status:    "experimental",
+				Hierarchy: "ui.diagnostic",
+			},
 			{
 				Name: "hints",
 				Type: "map[string]bool",
@@ -607,16 +681,41 @@ var GeneratedAPIJSON = &APIJSON{
 						Doc:     "Enable/disable inlay hints for constant values:\n```go\n\tconst (\n\t\tKindNone   Kind = iota/* = 0*/\n\t\tKindPrint/*  = 1*/\n\t\tKindPrintf/* = 2*/\n\t\tKindErrorf/* = 3*/\n\t)\n```",
 						Default: "false",
 					},
+					{
+						Name:    "\"discardedErrors\"",
+						Doc:     "Enable/disable inlay hints for errors that are assigned to the blank identifier or ignored along with the results of a call statement:\n```go\n\t_/* discarded error*/ = f.Close()\n\tw.Write(b)/* discarded error*/\n```",
+						Default: "false",
+					},
 					{
 						Name:    "\"functionTypeParameters\"",
 						Doc:     "Enable/disable inlay hints for implicit type parameters on generic functions:\n```go\n\tmyFoo/*[int, string]*/(1, \"hello\")\n```",
 						Default: "false",
 					},
+					{
+						Name:    "\"heapAllocations\"",
+						Doc:     "Enable/disable inlay hints for the values that escape to the heap, according to the escape analysis of the compiler:\n```go\n\treturn make/*heap*/([]int, n)\n```\nThe package is built to compute these hints, which are only shown for saved files, in trusted workspace folders.",
+						Default: "false",
+					},
+					{
+						Name:    "\"instantiatedSignatures\"",
+						Doc:     "Enable/disable inlay hints for the instantiated signatures of calls to generic functions with inferred type arguments:\n```go\n\tslices.Index(s, \"x\")/* func([]string, string) int*/\n```",
+						Default: "false",
+					},
+					{
+						Name:    "\"interfaceConversions\"",
+						Doc:     "Enable/disable inlay hints for implicit conversions of concrete values to non-empty interface parameters:\n```go\n\tio.Copy(/*io.Writer(*/w/*)*/, /*io.Reader(*/f/*)*/)\n```",
+						Default: "false",
+					},
 					{
 						Name:    "\"parameterNames\"",
 						Doc:     "Enable/disable inlay hints for parameter names:\n```go\n\tparseInt(/* str: */ \"123\", /* radix: */ 8)\n```",
 						Default: "false",
 					},
+					{
+						Name:    "\"promotedSelections\"",
+						Doc:     "Enable/disable inlay hints for the embedded fields through which promoted fields and methods are selected:\n```go\n\tbuf./*Buffer.*/WriteString(\"x\")\n```",
+						Default: "false",
+					},
 					{
 						Name:    "\"rangeVariableTypes\"",
 						Doc:     "Enable/disable inlay hints for variable types in range statements:\n```go\n\tfor k/* int*/, v/* string*/ := range []string{} {\n\t\tfmt.Println(k, v)\n\t}\n```",
@@ -644,14 +743,29 @@ var GeneratedAPIJSON = &APIJSON{
 							Doc:     "Runs `go generate` for a given directory.",
 							Default: "true",
 						},
+						{
+							Name:    "\"implementations\"",
+							Doc:     "Lists the implementations of the type declared at the given location,\nas does the implementation request. The code lens above each type\ndeclaration shows their number.",
+							Default: "false",
+						},
+						{
+							Name:    "\"references\"",
+							Doc:     "Lists the references to the declaration at the given location,\nexcluding the declaration itself, as does the references request.\nThe code lens above each top-level declaration shows their number.",
+							Default: "false",
+						},
 						{
 							Name:    "\"regenerate_cgo\"",
 							Doc:     "Regenerates cgo definitions.",
 							Default: "true",
 						},
+						{
+							Name:    "\"run_go_work_command\"",
+							Doc:     "Runs `go work [args...]`, and applies the resulting go.work edits to\nthe current go.work file. This is used by the code lenses of go.work\nfiles to use nested modules, drop uses of missing modules, and run\n`go work sync`.",
+							Default: "true",
+						},
 						{
 							Name:    "\"run_govulncheck\"",
-							Doc:     "Run vulnerability check (`govulncheck`).",
+							Doc:     "Run vulnerability check (`govulncheck`), and report the\nvulnerabilities it finds on the requirements of go.mod, and at the\nworkspace call sites from which vulnerable symbols are reachable.",
 							Default: "false",
 						},
 						{
@@ -676,7 +790,7 @@ var GeneratedAPIJSON = &APIJSON{
 						},
 					},
 				},
-				Default:   "{\"gc_details\":false,\"generate\":true,\"regenerate_cgo\":true,\"tidy\":true,\"upgrade_dependency\":true,\"vendor\":true}",
+				Default:   "{\"gc_details\":false,\"generate\":true,\"regenerate_cgo\":true,\"run_go_work_command\":true,\"tidy\":true,\"upgrade_dependency\":true,\"vendor\":true}",
 				Hierarchy: "ui",
 			},
 			{
@@ -717,6 +831,13 @@ var GeneratedAPIJSON = &APIJSON{
 				Default:   "false",
 				Hierarchy: "formatting",
 			},
+			{
+				Name:    "telemetryHistoryFile",
+				Type:    "string",
+				Doc:     "telemetryHistoryFile is the absolute name of a file to which gopls\nappends a snapshot of its telemetry counters, including the latency\nof operations such as completion, every ten minutes and when the\nsession ends, as a line of JSON. The counts of a snapshot are those\nsince gopls started. The `gopls stats -history` command summarizes\nthe file by day.\n\nUnlike Go telemetry, the file is not uploaded.\n",
+				Default: "\"\"",
+				Status:  "experimental",
+			},
 			{
 				Name:    "verboseOutput",
 				Type:    "bool",
@@ -794,6 +915,13 @@ var GeneratedAPIJSON = &APIJSON{
 			Doc:     "Runs `go get` to fetch a package.",
 			ArgDoc:  "{\n\t// Any document URI within the relevant module.\n\t\"URI\": string,\n\t// The package to go get.\n\t\"Pkg\": string,\n\t\"AddRequire\": bool,\n}",
 		},
+		{
+			Command:   "gopls.implementations",
+			Title:     "List implementations",
+			Doc:       "Lists the implementations of the type declared at the given location,\nas does the implementation request. The code lens above each type\ndeclaration shows their number.",
+			ArgDoc:    "{\n\t\"uri\": string,\n\t\"range\": {\n\t\t\"start\": {\n\t\t\t\"line\": uint32,\n\t\t\t\"character\": uint32,\n\t\t},\n\t\t\"end\": {\n\t\t\t\"line\": uint32,\n\t\t\t\"character\": uint32,\n\t\t},\n\t},\n}",
+			ResultDoc: "[]{\n\t\"uri\": string,\n\t\"range\": {\n\t\t\"start\": {\n\t\t\t\"line\": uint32,\n\t\t\t\"character\": uint32,\n\t\t},\n\t\t\"end\": {\n\t\t\t\"line\": uint32,\n\t\t\t\"character\": uint32,\n\t\t},\n\t},\n}",
+		},
 		{
 			Command:   "gopls.list_imports",
 			Title:     "List imports of a file and its package",
@@ -819,6 +947,13 @@ var GeneratedAPIJSON = &APIJSON{
 			Doc:       "Call runtime.GC multiple times and return memory statistics as reported by\nruntime.MemStats.\n\nThis command is used for benchmarking, and may change in the future.",
 			ResultDoc: "{\n\t\"HeapAlloc\": uint64,\n\t\"HeapInUse\": uint64,\n\t\"TotalAlloc\": uint64,\n}",
 		},
+		{
+			Command:   "gopls.references",
+			Title:     "List references",
+			Doc:       "Lists the references to the declaration at the given location,\nexcluding the declaration itself, as does the references request.\nThe code lens above each top-level declaration shows their number.",
+			ArgDoc:    "{\n\t\"uri\": string,\n\t\"range\": {\n\t\t\"start\": {\n\t\t\t\"line\": uint32,\n\t\t\t\"character\": uint32,\n\t\t},\n\t\t\"end\": {\n\t\t\t\"line\": uint32,\n\t\t\t\"character\": uint32,\n\t\t},\n\t},\n}",
+			ResultDoc: "[]{\n\t\"uri\": string,\n\t\"range\": {\n\t\t\"start\": {\n\t\t\t\"line\": uint32,\n\t\t\t\"character\": uint32,\n\t\t},\n\t\t\"end\": {\n\t\t\t\"line\": uint32,\n\t\t\t\"character\": uint32,\n\t\t},\n\t},\n}",
+		},
 		{
 			Command: "gopls.regenerate_cgo",
 			Title:   "Regenerate cgo",
@@ -839,14 +974,14 @@ var GeneratedAPIJSON = &APIJSON{
 		},
 		{
 			Command: "gopls.run_go_work_command",
-			Title:   "run `go work [args...]`, and apply the resulting go.work",
-			Doc:     "edits to the current go.work file.",
+			Title:   "Run go work command",
+			Doc:     "Runs `go work [args...]`, and applies the resulting go.work edits to\nthe current go.work file. This is used by the code lenses of go.work\nfiles to use nested modules, drop uses of missing modules, and run\n`go work sync`.",
 			ArgDoc:  "{\n\t\"ViewID\": string,\n\t\"InitFirst\": bool,\n\t\"Args\": []string,\n}",
 		},
 		{
 			Command:   "gopls.run_govulncheck",
 			Title:     "Run vulncheck.",
-			Doc:       "Run vulnerability check (`govulncheck`).",
+			Doc:       "Run vulnerability check (`govulncheck`), and report the\nvulnerabilities it finds on the requirements of go.mod, and at the\nworkspace call sites from which vulnerable symbols are reachable.",
 			ArgDoc:    "{\n\t// Any document in the directory from which govulncheck will run.\n\t\"URI\": string,\n\t// Package pattern. E.g. \"\", \".\", \"./...\".\n\t\"Pattern\": string,\n}",
 			ResultDoc: "{\n\t// Token holds the progress token for LSP workDone reporting of the vulncheck\n\t// invocation.\n\t\"Token\": interface{},\n}",
 		},
@@ -854,7 +989,7 @@ var GeneratedAPIJSON = &APIJSON{
 			Command: "gopls.run_tests",
 			Title:   "Run test(s)",
 			Doc:     "Runs `go test` for a specific set of test or benchmark functions.",
-			ArgDoc:  "{\n\t// The test file containing the tests to run.\n\t\"URI\": string,\n\t// Specific test names to run, e.g. TestFoo.\n\t\"Tests\": []string,\n\t// Specific benchmarks to run, e.g. BenchmarkFoo.\n\t\"Benchmarks\": []string,\n}",
+			ArgDoc:  "{\n\t// The test file containing the tests to run.\n\t\"URI\": string,\n\t// Specific test names to run, e.g. TestFoo.\n\t\"Tests\": []string,\n\t// Specific benchmarks to run, e.g. BenchmarkFoo.\n\t\"Benchmarks\": []string,\n\t// Specific fuzz targets to fuzz, e.g. FuzzFoo, each for FuzzTime.\n\t\"Fuzz\": []string,\n\t// FuzzTime is how long each fuzz target is fuzzed, in the syntax of the\n\t// -fuzztime flag of go test, e.g. \"30s\" or \"1000x\". The default is 30s.\n\t\"FuzzTime\": string,\n}",
 		},
 		{
 			Command:   "gopls.start_debugging",
@@ -895,6 +1030,12 @@ var GeneratedAPIJSON = &APIJSON{
 			Doc:     "Toggle the calculation of gc annotations.",
 			ArgDoc:  "{\n\t// The file URI.\n\t\"URI\": string,\n}",
 		},
+		{
+			Command: "gopls.trust_workspace",
+			Title:   "Trust a workspace folder",
+			Doc:     "Marks the workspace folder containing the given URI as trusted, lifting\nthe restrictions imposed by the `requireWorkspaceTrust` setting.",
+			ArgDoc:  "{\n\t// The file URI.\n\t\"URI\": string,\n}",
+		},
 		{
 			Command: "gopls.update_go_sum",
 			Title:   "Update go.sum",
@@ -931,15 +1072,30 @@ var GeneratedAPIJSON = &APIJSON{
 			Title: "Run go generate",
 			Doc:   "Runs `go generate` for a given directory.",
 		},
+		{
+			Lens:  "implementations",
+			Title: "List implementations",
+			Doc:   "Lists the implementations of the type declared at the given location,\nas does the implementation request. The code lens above each type\ndeclaration shows their number.",
+		},
+		{
+			Lens:  "references",
+			Title: "List references",
+			Doc:   "Lists the references to the declaration at the given location,\nexcluding the declaration itself, as does the references request.\nThe code lens above each top-level declaration shows their number.",
+		},
 		{
 			Lens:  "regenerate_cgo",
 			Title: "Regenerate cgo",
 			Doc:   "Regenerates cgo definitions.",
 		},
+		{
+			Lens:  "run_go_work_command",
+			Title: "Run go work command",
+			Doc:   "Runs `go work [args...]`, and applies the resulting go.work edits to\nthe current go.work file. This is used by the code lenses of go.work\nfiles to use nested modules, drop uses of missing modules, and run\n`go work sync`.",
+		},
 		{
 			Lens:  "run_govulncheck",
 			Title: "Run vulncheck.",
-			Doc:   "Run vulnerability check (`govulncheck`).",
+			Doc:   "Run vulnerability check (`govulncheck`), and report the\nvulnerabilities it finds on the requirements of go.mod, and at the\nworkspace call sites from which vulnerable symbols are reachable.",
 		},
 		{
 			Lens:  "test",
@@ -1272,14 +1428,34 @@ var GeneratedAPIJSON = &APIJSON{
 			Name: "constantValues",
 			Doc:  "Enable/disable inlay hints for constant values:\n```go\n\tconst (\n\t\tKindNone   Kind = iota/* = 0*/\n\t\tKindPrint/*  = 1*/\n\t\tKindPrintf/* = 2*/\n\t\tKindErrorf/* = 3*/\n\t)\n```",
 		},
+		{
+			Name: "discardedErrors",
+			Doc:  "Enable/disable inlay hints for errors that are assigned to the blank identifier or ignored along with the results of a call statement:\n```go\n\t_/* discarded error*/ = f.Close()\n\tw.Write(b)/* discarded error*/\n```",
+		},
 		{
 			Name: "functionTypeParameters",
 			Doc:  "Enable/disable inlay hints for implicit type parameters on generic functions:\n```go\n\tmyFoo/*[int, string]*/(1, \"hello\")\n```",
 		},
+		{
+			Name: "heapAllocations",
+			Doc:  "Enable/disable inlay hints for the values that escape to the heap, according to the escape analysis of the compiler:\n```go\n\treturn make/*heap*/([]int, n)\n```\nThe package is built to compute these hints, which are only shown for saved files, in trusted workspace folders.",
+		},
+		{
+			Name: "instantiatedSignatures",
+			Doc:  "Enable/disable inlay hints for the instantiated signatures of calls to generic functions with inferred type arguments:\n```go\n\tslices.Index(s, \"x\")/* func([]string, string) int*/\n```",
+		},
+		{
+			Name: "interfaceConversions",
+			Doc:  "Enable/disable inlay hints for implicit conversions of concrete values to non-empty interface parameters:\n```go\n\tio.Copy(/*io.Writer(*/w/*)*/, /*io.Reader(*/f/*)*/)\n```",
+		},
 		{
 			Name: "parameterNames",
 			Doc:  "Enable/disable inlay hints for parameter names:\n```go\n\tparseInt(/* str: */ \"123\", /* radix: */ 8)\n```",
 		},
+		{
+			Name: "promotedSelections",
+			Doc:  "Enable/disable inlay hints for the embedded fields through which promoted fields and methods are selected:\n```go\n\tbuf./*Buffer.*/WriteString(\"x\")\n```",
+		},
 		{
 			Name: "rangeVariableTypes",
 			Doc:  "Enable/disable inlay hints for variable types in range statements:\n```go\n\tfor k/* int*/, v/* string*/ := range []string{} {\n\t\tfmt.Println(k, v)\n\t}\n```",
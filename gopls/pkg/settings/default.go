@@ -52,8 +52,11 @@ func DefaultOptions(overrides ...func(*Options)) *Options {
 					file.Mod: {
 						protocol.SourceOrganizeImports: true,
 						protocol.QuickFix:              true,
+						protocol.RefactorRewrite:       true,
+					},
+					file.Work: {
+						protocol.RefactorRewrite: true,
 					},
-					file.Work: {},
 					file.Sum:  {},
 					file.Tmpl: {},
 				},
@@ -82,9 +85,10 @@ func DefaultOptions(overrides ...func(*Options)) *Options {
 					},
 					InlayHintOptions: InlayHintOptions{},
 					DocumentationOptions: DocumentationOptions{
-						HoverKind:    FullDocumentation,
-						LinkTarget:   "pkg.go.dev",
-						LinksInHover: true,
+						HoverKind:      FullDocumentation,
+						LinkTarget:     "pkg.go.dev",
+						LinksInHover:   true,
+						HoverStructure: NoHoverStructure,
 					},
 					NavigationOptions: NavigationOptions{
 						ImportShortcut: BothShortcuts,
@@ -97,6 +101,8 @@ func DefaultOptions(overrides ...func(*Options)) *Options {
 						CompletionBudget:               100 * time.Millisecond,
 						ExperimentalPostfixCompletions: true,
 						CompleteFunctionCalls:          true,
+						UsageRanking:                   true,
+						EmbeddedLanguages:              defaultEmbeddedLanguages(),
 					},
 					Codelenses: map[string]bool{
 						string(command.Generate):          true,
@@ -105,6 +111,7 @@ func DefaultOptions(overrides ...func(*Options)) *Options {
 						string(command.GCDetails):         false,
 						string(command.UpgradeDependency): true,
 						string(command.Vendor):            true,
+						string(command.RunGoWorkCommand):  true,
 						// TODO(hyangah): enable command.RunGovulncheck.
 					},
 				},
@@ -139,3 +146,28 @@ func DefaultOptions(overrides ...func(*Options)) *Options {
 	}
 	return options
 }
+
+// defaultEmbeddedLanguages returns the functions of the standard library
+// whose string parameters are known to hold a regular expression or SQL.
+func defaultEmbeddedLanguages() map[string]string {
+	langs := map[string]string{
+		"regexp.Compile":          RegexpLanguage,
+		"regexp.CompilePOSIX":     RegexpLanguage,
+		"regexp.MustCompile":      RegexpLanguage,
+		"regexp.MustCompilePOSIX": RegexpLanguage,
+		"regexp.Match":            RegexpLanguage,
+		"regexp.MatchReader":      RegexpLanguage,
+		"regexp.MatchString":      RegexpLanguage,
+	}
+	for _, recv := range []string{"DB", "Tx", "Conn"} {
+		for _, method := range []string{"ExecContext", "PrepareContext", "QueryContext", "QueryRowContext"} {
+			langs["(*database/sql."+recv+")."+method] = SQLLanguage
+		}
+		if recv != "Conn" {
+			for _, method := range []string{"Exec", "Prepare", "Query", "QueryRow"} {
+				langs["(*database/sql."+recv+")."+method] = SQLLanguage
+			}
+		}
+	}
+	return langs
+}
@@ -7,10 +7,13 @@ package settings
 import (
 	"context"
 	"fmt"
+	"go/token"
 	"path/filepath"
 	"regexp"
 	"runtime"
+	"sort"
 	"strings"
+	"text/template"
 	"time"
 
 	"golang.org/x/tools/go/analysis"
@@ -132,6 +135,7 @@ type ClientOptions struct {
 	CompletionTags                             bool
 	CompletionDeprecated                       bool
 	SupportedResourceOperations                []protocol.ResourceOperationKind
+	CodeLensRefreshSupported                   bool
 }
 
 // ServerOptions holds LSP-specific configuration that is provided by the
@@ -198,6 +202,18 @@ type BuildOptions struct {
 	// be removed.
 	AllowImplicitNetworkAccess bool `status:"experimental"`
 
+	// RequireWorkspaceTrust causes gopls to treat each workspace folder as
+	// untrusted until it is marked trusted using the `gopls.trust_workspace`
+	// command.
+	//
+	// In an untrusted folder, gopls refuses to run commands with side effects
+	// (such as `go generate`, `go test`, or `go mod vendor`), builds no
+	// packages to compute heap allocation hints, and runs all other go
+	// commands with `GOTOOLCHAIN=local` and with `-mod=mod` removed from
+	// GOFLAGS, so that opening a repository cannot cause code from it, or a
+	// toolchain it requests, to be downloaded or executed.
+	RequireWorkspaceTrust bool `status:"experimental"`
+
 	// StandaloneTags specifies a set of build constraints that identify
 	// individual Go source files that make up the entire main package of an
 	// executable.
@@ -276,12 +292,52 @@ type CompletionOptions struct {
 	// such as "someSlice.sort!".
 	ExperimentalPostfixCompletions bool `status:"experimental"`
 
+	// PostfixSnippets defines additional postfix snippets, mapping the label
+	// of each snippet to its body. Bodies use the same text/template language
+	// and helpers (such as .Kind, .StmtOK, .TypeName and .Import) as the
+	// built-in snippets, and a snippet is only offered if its body expands to
+	// non-blank text. A snippet with the label of a built-in snippet replaces
+	// it. Postfix snippets must be enabled with experimentalPostfixCompletions.
+	//
+	// Example Usage:
+	//
+	// ```json5
+	// "gopls": {
+	// ...
+	//   "postfixSnippets": {
+	//     "errwrap": "{{if eq (.TypeName .Type) \"error\"}}{{.Import \"fmt\"}}.Errorf(\"{{.Cursor}}: %w\", {{.X}}){{end}}"
+	//   }
+	// ...
+	// }
+	// ```
+	PostfixSnippets map[string]string `status:"experimental"`
+
+	// EmbeddedLanguages maps functions to the language of the string literal
+	// passed as their first string parameter. Within such literals, gopls
+	// offers completion and diagnostics for the language. Functions are
+	// named as by types.Func.FullName, such as "regexp.MustCompile" or
+	// "(*database/sql.DB).Query", and the supported languages are "regexp"
+	// and "sql". The literals of struct field tags always use the
+	// "structtag" language.
+	//
+	// The given entries are added to the defaults, which cover the regexp
+	// and database/sql packages; an entry with an empty language removes
+	// a function.
+	EmbeddedLanguages map[string]string `status:"experimental"`
+
 	// CompleteFunctionCalls enables function call completion.
 	//
 	// When completing a statement, or when a function return type matches the
 	// expected of the expression being completed, completion may suggest call
 	// expressions (i.e. may include parentheses).
 	CompleteFunctionCalls bool
+
+	// UsageRanking ranks completion candidates higher the more they are used
+	// in the workspace. Gopls counts the references of the workspace
+	// packages to the members of the packages they import, and the
+	// candidates that are accepted in completions, which it remembers
+	// across sessions.
+	UsageRanking bool `status:"experimental"`
 }
 
 type DocumentationOptions struct {
@@ -303,6 +359,18 @@ type DocumentationOptions struct {
 
 	// LinksInHover toggles the presence of links to documentation in hover.
 	LinksInHover bool
+
+	// HoverStructure controls the structural information about named types
+	// that appears in the hover text, in addition to their declaration and
+	// documentation.
+	//
+	// The layout is the size and alignment of the type, and the offset,
+	// size and padding of each field of a struct type, for the GOARCH of
+	// the view. The implementations are the number of interfaces a type
+	// implements, or of types implementing an interface, with a sample of
+	// their names. Computing them requires the method sets of all packages
+	// in the workspace.
+	HoverStructure HoverStructure `status:"experimental"`
 }
 
 type FormattingOptions struct {
@@ -367,6 +435,22 @@ type DiagnosticOptions struct {
 	// analysis facts for all its dependencies. The index is cached in the
 	// filesystem, so subsequent analysis should be faster.
 	AnalysisProgressReporting bool
+
+	// AnalyzeWorkspace enables analysis of every package in the workspace,
+	// not just those of open files. Workspace packages are analyzed in the
+	// background, after diagnostics for open files have been published, and
+	// analysis pauses while interactive requests such as completion are in
+	// progress. Findings are reported as diagnostics for closed files too.
+	AnalyzeWorkspace bool `status:"experimental"`
+
+	// VetTool is the name or path of an external analysis tool, such as
+	// one built with golang.org/x/tools/go/analysis/unitchecker, to run as
+	// `go vet -vettool` on analyzed packages. Its findings and suggested
+	// fixes are reported as diagnostics. Packages with unsaved changes are
+	// not analyzed by the tool, as it reads files from disk.
+	//
+	// Results are cached, and invalidated when the tool executable changes.
+	VetTool string `status:"experimental"`
 }
 
 type InlayHintOptions struct {
@@ -412,6 +496,16 @@ type UserOptions struct {
 	UIOptions
 	FormattingOptions
 
+	// TelemetryHistoryFile is the absolute name of a file to which gopls
+	// appends a snapshot of its telemetry counters, including the latency
+	// of operations such as completion, every ten minutes and when the
+	// session ends, as a line of JSON. The counts of a snapshot are those
+	// since gopls started. The `gopls stats -history` command summarizes
+	// the file by day.
+	//
+	// Unlike Go telemetry, the file is not uploaded.
+	TelemetryHistoryFile string `status:"experimental"`
+
 	// VerboseOutput enables additional debug logging.
 	VerboseOutput bool `status:"debug"`
 }
@@ -598,6 +692,13 @@ func (s ImportShortcut) ShowDefinition() bool {
 	return s == BothShortcuts || s == DefinitionShortcut
 }
 
+// Languages embedded in string literals.
+const (
+	RegexpLanguage    = "regexp"
+	SQLLanguage       = "sql"
+	StructTagLanguage = "structtag"
+)
+
 type Matcher string
 
 const (
@@ -661,6 +762,20 @@ const (
 	Structured HoverKind = "Structured"
 )
 
+type HoverStructure string
+
+const (
+	// NoHoverStructure shows no structural information.
+	NoHoverStructure HoverStructure = "None"
+	// LayoutHoverStructure shows the memory layout of types.
+	LayoutHoverStructure HoverStructure = "Layout"
+	// ImplementationsHoverStructure shows the implementation relations of
+	// types.
+	ImplementationsHoverStructure HoverStructure = "Implementations"
+	// FullHoverStructure shows both the layout and implementations.
+	FullHoverStructure HoverStructure = "Full"
+)
+
 type MemoryMode string
 
 const (
@@ -748,6 +863,10 @@ func (o *Options) ForClientCapabilities(clientName *protocol.Msg_XInitializePara
 	o.DynamicConfigurationSupported = caps.Workspace.DidChangeConfiguration.DynamicRegistration
 	o.DynamicRegistrationSemanticTokensSupported = caps.TextDocument.SemanticTokens.DynamicRegistration
 	o.DynamicWatchedFilesSupported = caps.Workspace.DidChangeWatchedFiles.DynamicRegistration
+	// Check if the client supports requests to refresh code lenses.
+	if cl := caps.Workspace.CodeLens; cl != nil {
+		o.CodeLensRefreshSupported = cl.RefreshSupport
+	}
 
 	// Check which types of content format are supported by this client.
 	if hover := caps.TextDocument.Hover; hover != nil && len(hover.ContentFormat) > 0 {
@@ -1009,6 +1128,16 @@ func (o *Options) set(name string, value interface{}, seen map[string]struct{})
 	case "linksInHover":
 		result.setBool(&o.LinksInHover)
 
+	case "hoverStructure":
+		if s, ok := result.asOneOf(
+			string(NoHoverStructure),
+			string(LayoutHoverStructure),
+			string(ImplementationsHoverStructure),
+			string(FullHoverStructure),
+		); ok {
+			o.HoverStructure = HoverStructure(s)
+		}
+
 	case "importShortcut":
 		if s, ok := result.asOneOf(string(BothShortcuts), string(LinkShortcut), string(DefinitionShortcut)); ok {
 			o.ImportShortcut = ImportShortcut(s)
@@ -1061,6 +1190,15 @@ func (o *Options) set(name string, value interface{}, seen map[string]struct{})
 	case "local":
 		result.setString(&o.Local)
 
+	case "telemetryHistoryFile":
+		if file, ok := result.asString(); ok {
+			if file != "" && !filepath.IsAbs(file) {
+				result.parseErrorf("must be an absolute file name, got %q", file)
+				break
+			}
+			o.TelemetryHistoryFile = file
+		}
+
 	case "verboseOutput":
 		result.setBool(&o.VerboseOutput)
 
@@ -1084,6 +1222,9 @@ func (o *Options) set(name string, value interface{}, seen map[string]struct{})
 	case "completeFunctionCalls":
 		result.setBool(&o.CompleteFunctionCalls)
 
+	case "usageRanking":
+		result.setBool(&o.UsageRanking)
+
 	case "semanticTokens":
 		result.setBool(&o.SemanticTokens)
 
@@ -1100,6 +1241,59 @@ func (o *Options) set(name string, value interface{}, seen map[string]struct{})
 	case "experimentalPostfixCompletions":
 		result.setBool(&o.ExperimentalPostfixCompletions)
 
+	case "postfixSnippets":
+		snippets, ok := value.(map[string]interface{})
+		if !ok {
+			result.parseErrorf("invalid type %T, expect map", value)
+			break
+		}
+		// Visit the snippets in order, so that the reported error is stable.
+		labels := make([]string, 0, len(snippets))
+		for label := range snippets {
+			labels = append(labels, label)
+		}
+		sort.Strings(labels)
+		o.PostfixSnippets = make(map[string]string)
+		for _, label := range labels {
+			v := snippets[label]
+			body, ok := v.(string)
+			if !ok {
+				result.parseErrorf("invalid type %T for postfix snippet %q, expect string", v, label)
+				continue
+			}
+			if !token.IsIdentifier(label) {
+				result.parseErrorf("invalid postfix snippet label %q: not an identifier", label)
+				continue
+			}
+			if _, err := template.New(label).Parse(body); err != nil {
+				result.parseErrorf("invalid postfix snippet %q: %v", label, err)
+				continue
+			}
+			o.PostfixSnippets[label] = body
+		}
+
+	case "embeddedLanguages":
+		langs, ok := value.(map[string]interface{})
+		if !ok {
+			result.parseErrorf("invalid type %T, expect map", value)
+			break
+		}
+		merged := make(map[string]string)
+		for fn, lang := range o.EmbeddedLanguages {
+			merged[fn] = lang
+		}
+		for fn, v := range langs {
+			switch lang, _ := v.(string); lang {
+			case "":
+				delete(merged, fn)
+			case RegexpLanguage, SQLLanguage:
+				merged[fn] = lang
+			default:
+				result.parseErrorf("invalid language %v for function %q, expect %q or %q", v, fn, RegexpLanguage, SQLLanguage)
+			}
+		}
+		o.EmbeddedLanguages = merged
+
 	case "experimentalWorkspaceModule":
 		result.deprecated("")
 
@@ -1138,6 +1332,12 @@ func (o *Options) set(name string, value interface{}, seen map[string]struct{})
 	case "analysisProgressReporting":
 		result.setBool(&o.AnalysisProgressReporting)
 
+	case "analyzeWorkspace":
+		result.setBool(&o.AnalyzeWorkspace)
+
+	case "vetTool":
+		result.setString(&o.VetTool)
+
 	case "experimentalWatchedFileDelay":
 		result.deprecated("")
 
@@ -1150,6 +1350,9 @@ func (o *Options) set(name string, value interface{}, seen map[string]struct{})
 	case "allowImplicitNetworkAccess":
 		result.setBool(&o.AllowImplicitNetworkAccess)
 
+	case "requireWorkspaceTrust":
+		result.setBool(&o.RequireWorkspaceTrust)
+
 	case "experimentalUseInvalidMetadata":
 		result.deprecated("")
 
@@ -5,6 +5,8 @@
 package settings
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 )
@@ -96,6 +98,21 @@ func TestSetOption(t *testing.T) {
 				return o.HoverKind == Structured
 			},
 		},
+		{
+			name:  "hoverStructure",
+			value: "Layout",
+			check: func(o Options) bool {
+				return o.HoverStructure == LayoutHoverStructure
+			},
+		},
+		{
+			name:      "hoverStructure",
+			value:     "Sizes",
+			wantError: true,
+			check: func(o Options) bool {
+				return o.HoverStructure == ""
+			},
+		},
 		{
 			name:  "matcher",
 			value: "Fuzzy",
@@ -133,6 +150,69 @@ func TestSetOption(t *testing.T) {
 				return o.Env == nil
 			},
 		},
+		{
+			name:  "postfixSnippets",
+			value: map[string]interface{}{"errwrap": `{{.Import "fmt"}}.Errorf("%w", {{.X}})`},
+			check: func(o Options) bool {
+				return o.PostfixSnippets["errwrap"] != ""
+			},
+		},
+		{
+			name:      "postfixSnippets",
+			value:     map[string]interface{}{"errwrap": `{{if .StmtOK}}`, "ok": `{{.X}}`},
+			wantError: true,
+			check: func(o Options) bool {
+				_, found := o.PostfixSnippets["errwrap"]
+				return !found && o.PostfixSnippets["ok"] != ""
+			},
+		},
+		{
+			name:      "postfixSnippets",
+			value:     map[string]interface{}{"not-an-identifier": `{{.X}}`},
+			wantError: true,
+			check: func(o Options) bool {
+				return len(o.PostfixSnippets) == 0
+			},
+		},
+		{
+			name:  "embeddedLanguages",
+			value: map[string]interface{}{"example.com/db.Query": "sql", "regexp.Match": ""},
+			check: func(o Options) bool {
+				_, found := o.EmbeddedLanguages["regexp.Match"]
+				return !found && o.EmbeddedLanguages["example.com/db.Query"] == "sql"
+			},
+		},
+		{
+			name:      "embeddedLanguages",
+			value:     map[string]interface{}{"example.com/db.Query": "cobol"},
+			wantError: true,
+			check: func(o Options) bool {
+				_, found := o.EmbeddedLanguages["example.com/db.Query"]
+				return !found
+			},
+		},
+		{
+			name:  "usageRanking",
+			value: true,
+			check: func(o Options) bool {
+				return o.UsageRanking
+			},
+		},
+		{
+			name:  "telemetryHistoryFile",
+			value: filepath.Join(os.TempDir(), "gopls-history.jsonl"),
+			check: func(o Options) bool {
+				return filepath.Base(o.TelemetryHistoryFile) == "gopls-history.jsonl"
+			},
+		},
+		{
+			name:      "telemetryHistoryFile",
+			value:     "gopls-history.jsonl",
+			wantError: true,
+			check: func(o Options) bool {
+				return o.TelemetryHistoryFile == ""
+			},
+		},
 		{
 			name:  "directoryFilters",
 			value: []interface{}{"-node_modules", "+project_a"},
@@ -0,0 +1,347 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package telemetry
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/telemetry/counter"
+	"golang.org/x/tools/pkg/event"
+)
+
+// The counters of gopls are also tallied in memory, as x/telemetry
+// provides no way to read them, so that they can be appended to history
+// files, for users who do not upload telemetry but want to track how
+// gopls performs over time.
+
+var (
+	processStart = time.Now()
+
+	tallyMu sync.Mutex
+	tally   = make(map[string]int64) // counts since processStart, by counter name
+)
+
+// add adds n to the counter with the given name.
+func add(name string, n int64) {
+	counter.Add(name, n)
+	record(name, n)
+}
+
+// record adds n to the tally of the counter with the given name.
+func record(name string, n int64) {
+	tallyMu.Lock()
+	tally[name] += n
+	tallyMu.Unlock()
+}
+
+// A HistorySnapshot is a line of a history file: the counts of the
+// counters of a gopls process since it started.
+type HistorySnapshot struct {
+	Time         time.Time        `json:"time"`
+	PID          int              `json:"pid"`
+	Start        time.Time        `json:"start"` // when the process started
+	GoplsVersion string           `json:"goplsVersion,omitempty"`
+	GoVersion    string           `json:"goVersion,omitempty"`
+	Counters     map[string]int64 `json:"counters"`
+}
+
+// HistoryInterval is the interval between the snapshots that
+// WriteHistory appends to a history file.
+var HistoryInterval = 10 * time.Minute
+
+var (
+	historyMu sync.Mutex
+	histories = make(map[string]*historyWriter) // by file name
+)
+
+// A historyWriter appends snapshots to a history file.
+type historyWriter struct {
+	refs int
+	stop chan struct{} // closed to append a last snapshot and stop
+	done chan struct{} // closed after the last snapshot
+}
+
+// WriteHistory starts to append a snapshot of the counters to the history
+// file name every HistoryInterval, as a line of JSON, until the returned
+// function is called, which appends a last snapshot.
+//
+// Calls for the same file share their snapshots, which stop when the
+// functions returned by all of them are called.
+func WriteHistory(name, goplsVersion string) (stop func()) {
+	historyMu.Lock()
+	defer historyMu.Unlock()
+
+	w := histories[name]
+	if w == nil {
+		w = &historyWriter{stop: make(chan struct{}), done: make(chan struct{})}
+		histories[name] = w
+		go w.run(name, goplsVersion)
+	}
+	w.refs++
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			historyMu.Lock()
+			w.refs--
+			last := w.refs == 0
+			if last {
+				delete(histories, name)
+			}
+			historyMu.Unlock()
+
+			if last {
+				close(w.stop)
+				<-w.done
+			}
+		})
+	}
+}
+
+func (w *historyWriter) run(name, goplsVersion string) {
+	defer close(w.done)
+
+	ticker := time.NewTicker(HistoryInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-w.stop:
+			appendSnapshot(name, goplsVersion)
+			return
+		}
+		appendSnapshot(name, goplsVersion)
+	}
+}
+
+// appendSnapshot appends a snapshot of the counters to the history file
+// name, and logs any error.
+func appendSnapshot(name, goplsVersion string) {
+	snapshot := HistorySnapshot{
+		Time:         time.Now(),
+		PID:          os.Getpid(),
+		Start:        processStart,
+		GoplsVersion: goplsVersion,
+		GoVersion:    runtime.Version(),
+		Counters:     make(map[string]int64),
+	}
+	tallyMu.Lock()
+	for k, v := range tally {
+		snapshot.Counters[k] = v
+	}
+	tallyMu.Unlock()
+
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		event.Error(context.Background(), "encoding telemetry history", err)
+		return
+	}
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0666)
+	if err != nil {
+		event.Error(context.Background(), "writing telemetry history", err)
+		return
+	}
+	// A single write, so that the snapshots of concurrent gopls processes
+	// are not interleaved.
+	_, err = f.Write(append(data, '\n'))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		event.Error(context.Background(), "writing telemetry history", err)
+	}
+}
+
+// ReadHistory reads the snapshots of a history file. It skips the lines
+// that are not snapshots, such as a line truncated by a crash, and
+// returns their number.
+func ReadHistory(r io.Reader) (snapshots []HistorySnapshot, invalid int, err error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 16<<20)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(strings.TrimSpace(string(line))) == 0 {
+			continue
+		}
+		var s HistorySnapshot
+		if err := json.Unmarshal(line, &s); err != nil || s.Time.IsZero() {
+			invalid++
+			continue
+		}
+		snapshots = append(snapshots, s)
+	}
+	return snapshots, invalid, scanner.Err()
+}
+
+// A HistorySummary summarizes the snapshots of a history file, by day.
+type HistorySummary struct {
+	From, To  time.Time // times of the first and last snapshots
+	Processes int       // number of gopls processes
+	Days      []*DaySummary
+}
+
+// A DaySummary summarizes the counts of the gopls processes that took
+// snapshots during a day (in UTC).
+type DaySummary struct {
+	Date          string                     // YYYY-MM-DD
+	Processes     int                        // number of gopls processes
+	GoplsVersions []string                   // versions of gopls
+	Counters      map[string]int64           // counts, other than latency
+	Latency       map[string]*LatencySummary // by operation, such as "completion"
+}
+
+// A LatencySummary summarizes the latency histogram of an operation.
+type LatencySummary struct {
+	Count   int64            // number of operations
+	Errors  int64            // number of operations that failed
+	Buckets map[string]int64 // number of successful operations by latency bucket, such as "<50ms"
+	Median  string           // latency bucket of the median successful operation
+	P90     string           // latency bucket of the 90th percentile successful operation
+}
+
+// SummarizeHistory summarizes snapshots read by ReadHistory.
+//
+// As the counts of a snapshot are those since its process started, the
+// counts of a day are the differences between the snapshots of each
+// process taken that day and the previous snapshots of the process.
+func SummarizeHistory(snapshots []HistorySnapshot) *HistorySummary {
+	type processKey struct {
+		pid   int
+		start time.Time
+	}
+	processes := make(map[processKey][]HistorySnapshot)
+	for _, s := range snapshots {
+		k := processKey{s.PID, s.Start.UTC()}
+		processes[k] = append(processes[k], s)
+	}
+
+	summary := &HistorySummary{Processes: len(processes)}
+	days := make(map[string]*DaySummary)
+	dayProcesses := make(map[string]map[processKey]bool)
+	dayVersions := make(map[string]map[string]bool)
+	for k, ss := range processes {
+		sort.Slice(ss, func(i, j int) bool { return ss[i].Time.Before(ss[j].Time) })
+		prev := map[string]int64{}
+		for _, s := range ss {
+			if summary.From.IsZero() || s.Time.Before(summary.From) {
+				summary.From = s.Time
+			}
+			if s.Time.After(summary.To) {
+				summary.To = s.Time
+			}
+			date := s.Time.UTC().Format("2006-01-02")
+			day := days[date]
+			if day == nil {
+				day = &DaySummary{
+					Date:     date,
+					Counters: make(map[string]int64),
+					Latency:  make(map[string]*LatencySummary),
+				}
+				days[date] = day
+				dayProcesses[date] = make(map[processKey]bool)
+				dayVersions[date] = make(map[string]bool)
+			}
+			dayProcesses[date][k] = true
+			if s.GoplsVersion != "" {
+				dayVersions[date][s.GoplsVersion] = true
+			}
+			for name, n := range s.Counters {
+				if delta := n - prev[name]; delta > 0 {
+					day.add(name, delta)
+				}
+			}
+			prev = s.Counters
+		}
+	}
+
+	for date, day := range days {
+		day.Processes = len(dayProcesses[date])
+		for v := range dayVersions[date] {
+			day.GoplsVersions = append(day.GoplsVersions, v)
+		}
+		sort.Strings(day.GoplsVersions)
+		for _, l := range day.Latency {
+			l.Median = l.percentile(0.5)
+			l.P90 = l.percentile(0.9)
+		}
+		summary.Days = append(summary.Days, day)
+	}
+	sort.Slice(summary.Days, func(i, j int) bool { return summary.Days[i].Date < summary.Days[j].Date })
+	return summary
+}
+
+// add adds n to the counter name of the day, or to its latency histogram
+// if name is a latency counter.
+func (day *DaySummary) add(name string, n int64) {
+	operation, bucket, isError, ok := parseLatencyCounter(name)
+	if !ok {
+		day.Counters[name] += n
+		return
+	}
+	l := day.Latency[operation]
+	if l == nil {
+		l = &LatencySummary{Buckets: make(map[string]int64)}
+		day.Latency[operation] = l
+	}
+	l.Count += n
+	if isError {
+		l.Errors += n
+	} else {
+		l.Buckets[bucket] += n
+	}
+}
+
+// parseLatencyCounter parses the name of a counter of getLatencyCounter.
+func parseLatencyCounter(name string) (operation, bucket string, isError, ok bool) {
+	if !strings.HasPrefix(name, "gopls/") {
+		return "", "", false, false
+	}
+	name = strings.TrimPrefix(name, "gopls/")
+	colon := strings.IndexByte(name, ':')
+	if colon < 0 {
+		return "", "", false, false
+	}
+	name, bucket = name[:colon], name[colon+1:]
+	switch {
+	case strings.HasSuffix(name, "/error-latency"):
+		return strings.TrimSuffix(name, "/error-latency"), bucket, true, true
+	case strings.HasSuffix(name, "/latency"):
+		return strings.TrimSuffix(name, "/latency"), bucket, false, true
+	}
+	return "", "", false, false
+}
+
+// percentile returns the latency bucket holding the successful operation
+// at the given fraction of l, from the fastest.
+func (l *LatencySummary) percentile(fraction float64) string {
+	var total int64
+	for _, n := range l.Buckets {
+		total += n
+	}
+	if total == 0 {
+		return ""
+	}
+	rank := int64(fraction * float64(total))
+	if rank >= total {
+		rank = total - 1
+	}
+	var seen int64
+	for _, b := range latencyBuckets {
+		seen += l.Buckets[b.name]
+		if seen > rank {
+			return b.name
+		}
+	}
+	return "" // unknown buckets
+}
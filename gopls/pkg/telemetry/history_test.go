@@ -0,0 +1,112 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package telemetry
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSummarizeHistory(t *testing.T) {
+	const history = `
+{"time": "2023-11-01T10:00:00Z", "pid": 1, "start": "2023-11-01T09:00:00Z", "goplsVersion": "v0.14.0", "counters": {"gopls/client:vscode": 1, "gopls/completion/latency:<10ms": 6, "gopls/completion/latency:<50ms": 2}}
+{"time": "2023-11-01T11:00:00Z", "pid": 2, "start": "2023-11-01T10:30:00Z", "goplsVersion": "v0.14.1", "counters": {"gopls/client:neovim": 1, "gopls/hover/error-latency:<10ms": 1}}
+not json
+{"time": "2023-11-02T10:00:00Z", "pid": 1, "start": "2023-11-01T09:00:00Z", "goplsVersion": "v0.14.0", "counters": {"gopls/client:vscode": 1, "gopls/completion/latency:<10ms": 7, "gopls/completion/latency:<50ms": 2, "gopls/completion/latency:<1s": 2}}
+{"time": "2023-11-01T12:00:00Z", "pid": 1, "start": "2023-11-01T09:00:00Z", "goplsVersion": "v0.14.0", "counters": {"gopls/client:vscode": 1, "gopls/completion/latency:<10ms": 8, "gopls/completion/latency:<50ms": 2}}
+{"time": "2023-11-02T10:0`
+
+	snapshots, invalid, err := ReadHistory(strings.NewReader(history))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snapshots) != 4 || invalid != 2 {
+		t.Fatalf("ReadHistory: got %d snapshots and %d invalid lines, want 4 and 2", len(snapshots), invalid)
+	}
+
+	got := SummarizeHistory(snapshots)
+	want := &HistorySummary{
+		From:      time.Date(2023, 11, 1, 10, 0, 0, 0, time.UTC),
+		To:        time.Date(2023, 11, 2, 10, 0, 0, 0, time.UTC),
+		Processes: 2,
+		Days: []*DaySummary{
+			{
+				Date:          "2023-11-01",
+				Processes:     2,
+				GoplsVersions: []string{"v0.14.0", "v0.14.1"},
+				Counters:      map[string]int64{"gopls/client:vscode": 1, "gopls/client:neovim": 1},
+				Latency: map[string]*LatencySummary{
+					"completion": {Count: 10, Buckets: map[string]int64{"<10ms": 8, "<50ms": 2}, Median: "<10ms", P90: "<50ms"},
+					"hover":      {Count: 1, Errors: 1, Buckets: map[string]int64{}},
+				},
+			},
+			{
+				// Counts below those of the previous snapshot of the
+				// process are ignored.
+				Date:          "2023-11-02",
+				Processes:     1,
+				GoplsVersions: []string{"v0.14.0"},
+				Counters:      map[string]int64{},
+				Latency: map[string]*LatencySummary{
+					"completion": {Count: 2, Buckets: map[string]int64{"<1s": 2}, Median: "<1s", P90: "<1s"},
+				},
+			},
+		},
+	}
+	for _, s := range []*HistorySummary{got, want} {
+		s.From, s.To = s.From.UTC(), s.To.UTC()
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("SummarizeHistory:\ngot  %s\nwant %s", stringify(t, got), stringify(t, want))
+	}
+}
+
+func TestWriteHistory(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "history.jsonl")
+
+	record("gopls/test-history", 3)
+	stop1 := WriteHistory(file, "v1.2.3")
+	stop2 := WriteHistory(file, "v1.2.3")
+	stop1()
+	stop1() // no-op
+	if _, err := os.Stat(file); err == nil {
+		t.Fatalf("%s was written before the last stop", file)
+	}
+	record("gopls/test-history", 1)
+	stop2()
+
+	f, err := os.Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	snapshots, invalid, err := ReadHistory(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snapshots) != 1 || invalid != 0 {
+		t.Fatalf("got %d snapshots and %d invalid lines, want 1 and 0", len(snapshots), invalid)
+	}
+	s := snapshots[0]
+	if got := s.Counters["gopls/test-history"]; got != 4 {
+		t.Errorf("gopls/test-history = %d, want 4", got)
+	}
+	if s.PID != os.Getpid() || s.GoplsVersion != "v1.2.3" || !s.Start.Equal(processStart) {
+		t.Errorf("got snapshot of pid %d, version %s, start %v, want %d, v1.2.3, %v", s.PID, s.GoplsVersion, s.Start, os.Getpid(), processStart)
+	}
+}
+
+func stringify(t *testing.T, s *HistorySummary) string {
+	data, err := json.Marshal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
@@ -96,7 +96,9 @@ func StartLatencyTimer(operation string) func(context.Context, error) {
 		})
 		if bucketIdx < len(latencyBuckets) { // ignore latency longer than a day :)
 			bucketName := latencyBuckets[bucketIdx].name
-			getLatencyCounter(operation, bucketName, err != nil).Inc()
+			c := getLatencyCounter(operation, bucketName, err != nil)
+			c.Inc()
+			record(c.Name(), 1)
 		}
 	}
 }
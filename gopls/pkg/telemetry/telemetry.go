@@ -64,11 +64,11 @@ func RecordClientInfo(params *protocol.ParamInitialize) {
 			client = "gopls/client:sublimetext"
 		default:
 			// at least accumulate the client name locally
-			counter.New(fmt.Sprintf("gopls/client-other:%s", params.ClientInfo.Name)).Inc()
+			add(fmt.Sprintf("gopls/client-other:%s", params.ClientInfo.Name), 1)
 			// but also record client:other
 		}
 	}
-	counter.Inc(client)
+	add(client, 1)
 }
 
 // RecordViewGoVersion records the Go minor version number (1.x) used for a view.
@@ -77,7 +77,7 @@ func RecordViewGoVersion(x int) {
 		return
 	}
 	name := fmt.Sprintf("gopls/goversion:1.%d", x)
-	counter.Inc(name)
+	add(name, 1)
 }
 
 // AddForwardedCounters adds the given counters on behalf of clients.
@@ -88,6 +88,6 @@ func AddForwardedCounters(names []string, values []int64) {
 		if n == "" || v < 0 {
 			continue // Should we report an error? Who is the audience?
 		}
-		counter.Add("fwd/"+n, v)
+		add("fwd/"+n, v)
 	}
 }
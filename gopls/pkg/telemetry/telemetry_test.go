@@ -11,6 +11,7 @@ import (
 	"context"
 	"errors"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"testing"
@@ -213,3 +214,52 @@ func _() {
 		}
 	})
 }
+
+func TestTelemetryHistory(t *testing.T) {
+	const files = `
+-- go.mod --
+module mod.test/a
+go 1.18
+-- a.go --
+package a
+
+func _() {
+	x := 0
+	_ = x
+}
+`
+	file := filepath.Join(t.TempDir(), "history.jsonl")
+	WithOptions(
+		Modes(Default), // the counters are those of the test process
+		Settings{"telemetryHistoryFile": file},
+		ClientName("Visual Studio Code"),
+	).Run(t, files, func(_ *testing.T, env *Env) {
+		env.OpenFile("a.go")
+		loc := env.RegexpSearch("a.go", "x")
+		for i := 0; i < 3; i++ {
+			env.Hover(loc)
+		}
+	})
+
+	// The shutdown of the session appends a last snapshot.
+	f, err := os.Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	snapshots, invalid, err := telemetry.ReadHistory(f)
+	if err != nil || len(snapshots) == 0 || invalid != 0 {
+		t.Fatalf("ReadHistory: got %d snapshots, %d invalid lines, and error %v, want snapshots", len(snapshots), invalid, err)
+	}
+	summary := telemetry.SummarizeHistory(snapshots)
+	var hovers, clients int64
+	for _, day := range summary.Days {
+		if l := day.Latency["hover"]; l != nil {
+			hovers += l.Count
+		}
+		clients += day.Counters["gopls/client:vscode"]
+	}
+	if hovers < 3 || clients < 1 {
+		t.Errorf("got %d hovers and %d vscode clients in the history, want at least 3 and 1", hovers, clients)
+	}
+}
@@ -0,0 +1,319 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package osv
+
+// This file is not copied from golang.org/x/vuln: it implements local
+// snapshots of vulnerability databases, for use without a network.
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/mod/semver"
+)
+
+// A Snapshot is a local copy of a vulnerability database, in the layout
+// of the Go vulnerability database (https://go.dev/security/vuln/database#api):
+// index/db.json, index/modules.json, and an OSV entry ID/<id>.json per
+// vulnerability.
+//
+// A snapshot is versioned by the time at which its database was last
+// modified.
+type Snapshot struct {
+	Dir      string    // directory of the snapshot
+	Modified time.Time // last modified time of the database
+}
+
+// URI returns the file URI of the snapshot, the form of the GOVULNDB
+// environment variable, and of the -db flag of govulncheck.
+func (s *Snapshot) URI() string {
+	p := filepath.ToSlash(s.Dir)
+	if !strings.HasPrefix(p, "/") {
+		p = "/" + p // Windows: file:///C:/...
+	}
+	u := url.URL{Scheme: "file", Path: p}
+	return u.String()
+}
+
+// Version returns the version of the snapshot, its last modified time.
+func (s *Snapshot) Version() string {
+	return s.Modified.UTC().Format(time.RFC3339)
+}
+
+// The files of the index of a snapshot.
+const (
+	dbIndex      = "index/db.json"
+	modulesIndex = "index/modules.json"
+)
+
+// dbMeta is the content of index/db.json.
+type dbMeta struct {
+	Modified time.Time `json:"modified"`
+}
+
+// moduleMeta is an element of the list in index/modules.json.
+type moduleMeta struct {
+	Path  string       `json:"path"`
+	Vulns []moduleVuln `json:"vulns"`
+}
+
+// moduleVuln is a vulnerability of a moduleMeta.
+type moduleVuln struct {
+	ID       string    `json:"id"`
+	Modified time.Time `json:"modified"`
+	Fixed    string    `json:"fixed,omitempty"` // latest fixed version, without "v"
+}
+
+// OpenSnapshot returns the snapshot in the directory dir.
+func OpenSnapshot(dir string) (*Snapshot, error) {
+	data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(dbIndex)))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("%s is not a vulnerability database snapshot: it has no %s", dir, dbIndex)
+		}
+		return nil, err
+	}
+	var meta dbMeta
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return nil, fmt.Errorf("reading %s of %s: %v", dbIndex, dir, err)
+	}
+	return &Snapshot{Dir: dir, Modified: meta.Modified}, nil
+}
+
+// ImportSnapshot imports the vulnerability database src, a zip file or a
+// directory, into the snapshot in the directory dir, and returns the
+// snapshot.
+//
+// The database src is either in the layout of the Go vulnerability
+// database, such as https://vuln.go.dev/vulndb.zip, or a tree of OSV
+// entries, one per .json file. Its last modified time is that of its
+// index/db.json, if any, or else that of its most recently modified
+// entry.
+//
+// The snapshot in dir, if any, is replaced if src is more recent, and
+// left unchanged if it is as recent. It is an error for src to be older,
+// or for dir to hold anything but a snapshot.
+func ImportSnapshot(dir, src string) (*Snapshot, error) {
+	db, err := readDatabase(src)
+	if err != nil {
+		return nil, err
+	}
+
+	var current *Snapshot
+	if _, err := os.Stat(dir); err == nil {
+		current, err = OpenSnapshot(dir)
+		if err != nil {
+			return nil, fmt.Errorf("not replacing %s: %v", dir, err)
+		}
+		switch {
+		case db.modified.Equal(current.Modified):
+			return current, nil
+		case db.modified.Before(current.Modified):
+			return nil, fmt.Errorf("%s (modified %s) is older than the snapshot in %s (modified %s)",
+				src, db.modified.UTC().Format(time.RFC3339), dir, current.Version())
+		}
+	}
+
+	// Write the snapshot next to dir, then swap them.
+	if err := os.MkdirAll(filepath.Dir(dir), 0777); err != nil {
+		return nil, err
+	}
+	tmp, err := os.MkdirTemp(filepath.Dir(dir), filepath.Base(dir)+".import-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp) // ignore error
+	if err := db.write(tmp); err != nil {
+		return nil, fmt.Errorf("writing snapshot: %v", err)
+	}
+	if current != nil {
+		old := tmp + ".old"
+		if err := os.Rename(dir, old); err != nil {
+			return nil, err
+		}
+		defer os.RemoveAll(old) // ignore error
+	}
+	if err := os.Rename(tmp, dir); err != nil {
+		return nil, err
+	}
+	return &Snapshot{Dir: dir, Modified: db.modified}, nil
+}
+
+// A database holds the entries of a vulnerability database read by
+// readDatabase.
+type database struct {
+	entries  map[string][]byte // JSON OSV entries, by ID
+	modules  map[string]*moduleMeta
+	modified time.Time
+}
+
+// readDatabase reads the database in the zip file or directory src.
+func readDatabase(src string) (*database, error) {
+	db := &database{
+		entries: make(map[string][]byte),
+		modules: make(map[string]*moduleMeta),
+	}
+	var indexModified *time.Time
+
+	// add adds the .json file name, read by open, to db.
+	add := func(name string, open func() (io.ReadCloser, error)) error {
+		if path.Ext(name) != ".json" {
+			return nil
+		}
+		isIndex := path.Base(path.Dir(name)) == "index"
+		if isIndex && path.Base(name) != "db.json" {
+			return nil // recomputed by write
+		}
+		r, err := open()
+		if err != nil {
+			return err
+		}
+		data, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			return err
+		}
+		if isIndex {
+			var meta dbMeta
+			if err := json.Unmarshal(data, &meta); err != nil {
+				return fmt.Errorf("%s: %v", name, err)
+			}
+			indexModified = &meta.Modified
+			return nil
+		}
+		var entry Entry
+		if err := json.Unmarshal(data, &entry); err != nil {
+			return fmt.Errorf("%s: not an OSV entry: %v", name, err)
+		}
+		if entry.ID == "" || entry.ID == "." || entry.ID == ".." || strings.ContainsAny(entry.ID, `/\`) {
+			return fmt.Errorf("%s: OSV entry has an invalid id %q", name, entry.ID)
+		}
+		if _, dup := db.entries[entry.ID]; dup {
+			return fmt.Errorf("%s: duplicate OSV entry %s", name, entry.ID)
+		}
+		db.entries[entry.ID] = data
+		db.index(&entry)
+		return nil
+	}
+
+	fi, err := os.Stat(src)
+	if err != nil {
+		return nil, err
+	}
+	if fi.IsDir() {
+		err = filepath.WalkDir(src, func(file string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+			rel, err := filepath.Rel(src, file)
+			if err != nil {
+				return err
+			}
+			return add(filepath.ToSlash(rel), func() (io.ReadCloser, error) { return os.Open(file) })
+		})
+	} else {
+		var zr *zip.ReadCloser
+		zr, err = zip.OpenReader(src)
+		if err != nil {
+			return nil, fmt.Errorf("%s is neither a directory nor a zip file: %v", src, err)
+		}
+		defer zr.Close()
+		for _, f := range zr.File {
+			if err = add(f.Name, f.Open); err != nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(db.entries) == 0 {
+		return nil, fmt.Errorf("%s holds no OSV entries", src)
+	}
+	if indexModified != nil {
+		db.modified = *indexModified
+	}
+	return db, nil
+}
+
+// index adds entry to the modules index of db, and to its last
+// modified time.
+func (db *database) index(entry *Entry) {
+	if entry.Modified.After(db.modified) {
+		db.modified = entry.Modified
+	}
+	for _, a := range entry.Affected {
+		if a.Module.Ecosystem != GoEcosystem {
+			continue
+		}
+		m := db.modules[a.Module.Path]
+		if m == nil {
+			m = &moduleMeta{Path: a.Module.Path}
+			db.modules[a.Module.Path] = m
+		}
+		var vuln *moduleVuln
+		for i := range m.Vulns {
+			if m.Vulns[i].ID == entry.ID {
+				vuln = &m.Vulns[i]
+			}
+		}
+		if vuln == nil {
+			m.Vulns = append(m.Vulns, moduleVuln{ID: entry.ID, Modified: entry.Modified})
+			vuln = &m.Vulns[len(m.Vulns)-1]
+		}
+		for _, r := range a.Ranges {
+			for _, e := range r.Events {
+				if e.Fixed != "" && (vuln.Fixed == "" || semver.Compare("v"+e.Fixed, "v"+vuln.Fixed) > 0) {
+					vuln.Fixed = e.Fixed
+				}
+			}
+		}
+	}
+}
+
+// write writes the snapshot of db in the directory dir.
+func (db *database) write(dir string) error {
+	for _, sub := range []string{"ID", "index"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0777); err != nil {
+			return err
+		}
+	}
+	for id, data := range db.entries {
+		if err := os.WriteFile(filepath.Join(dir, "ID", id+".json"), data, 0666); err != nil {
+			return err
+		}
+	}
+
+	modules := make([]*moduleMeta, 0, len(db.modules))
+	for _, m := range db.modules {
+		sort.Slice(m.Vulns, func(i, j int) bool { return m.Vulns[i].ID < m.Vulns[j].ID })
+		modules = append(modules, m)
+	}
+	sort.Slice(modules, func(i, j int) bool { return modules[i].Path < modules[j].Path })
+	for name, v := range map[string]interface{}{
+		modulesIndex: modules,
+		dbIndex:      dbMeta{Modified: db.modified},
+	} {
+		data, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(dir, filepath.FromSlash(name)), data, 0666); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,246 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package osv
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// entry returns an OSV entry of ID, modified at the given day of 2023,
+// that affects module mod below the fixed versions.
+func entry(id string, day int, mod string, fixed ...string) *Entry {
+	r := Range{Type: RangeTypeSemver, Events: []RangeEvent{{Introduced: "0"}}}
+	for _, f := range fixed {
+		r.Events = append(r.Events, RangeEvent{Fixed: f})
+	}
+	return &Entry{
+		ID:       id,
+		Modified: time.Date(2023, 1, day, 0, 0, 0, 0, time.UTC),
+		Affected: []Affected{{
+			Module: Module{Path: mod, Ecosystem: GoEcosystem},
+			Ranges: []Range{r},
+		}},
+	}
+}
+
+// writeDir writes the entries in a tree of OSV files in a new directory.
+func writeDir(t *testing.T, entries ...*Entry) string {
+	dir := t.TempDir()
+	for _, e := range entries {
+		data, err := json.Marshal(e)
+		if err != nil {
+			t.Fatal(err)
+		}
+		file := filepath.Join(dir, "sub", e.ID+".json")
+		if err := os.MkdirAll(filepath.Dir(file), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, data, 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// writeZip writes a zip file in the layout of the Go vulnerability
+// database, modified at the given day of 2023.
+func writeZip(t *testing.T, day int, entries ...*Entry) string {
+	file := filepath.Join(t.TempDir(), "vulndb.zip")
+	f, err := os.Create(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	add := func(name string, v interface{}) {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := json.NewEncoder(w).Encode(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	add("index/db.json", dbMeta{Modified: time.Date(2023, 1, day, 0, 0, 0, 0, time.UTC)})
+	add("index/modules.json", []moduleMeta{{Path: "ignored"}})
+	for _, e := range entries {
+		add("ID/"+e.ID+".json", e)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func readModules(t *testing.T, dir string) []moduleMeta {
+	data, err := os.ReadFile(filepath.Join(dir, "index", "modules.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var modules []moduleMeta
+	if err := json.Unmarshal(data, &modules); err != nil {
+		t.Fatal(err)
+	}
+	return modules
+}
+
+func TestImportSnapshotDir(t *testing.T) {
+	src := writeDir(t,
+		entry("GO-2023-0002", 5, "golang.org/amod", "1.0.4", "1.0.10"),
+		entry("GO-2023-0001", 3, "golang.org/amod", "1.0.2"),
+		entry("GO-2023-0003", 4, "golang.org/bmod"),
+	)
+	dir := filepath.Join(t.TempDir(), "vulndb")
+	s, err := ImportSnapshot(dir, src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := s.Version(), "2023-01-05T00:00:00Z"; got != want {
+		t.Errorf("Version() = %s, want %s", got, want)
+	}
+	if runtime.GOOS != "windows" {
+		if got, want := s.URI(), "file://"+dir; got != want {
+			t.Errorf("URI() = %s, want %s", got, want)
+		}
+	}
+
+	day := func(d int) time.Time { return time.Date(2023, 1, d, 0, 0, 0, 0, time.UTC) }
+	want := []moduleMeta{
+		{Path: "golang.org/amod", Vulns: []moduleVuln{
+			{ID: "GO-2023-0001", Modified: day(3), Fixed: "1.0.2"},
+			{ID: "GO-2023-0002", Modified: day(5), Fixed: "1.0.10"},
+		}},
+		{Path: "golang.org/bmod", Vulns: []moduleVuln{
+			{ID: "GO-2023-0003", Modified: day(4)},
+		}},
+	}
+	if got := readModules(t, dir); !reflect.DeepEqual(got, want) {
+		t.Errorf("index/modules.json = %+v, want %+v", got, want)
+	}
+	for _, id := range []string{"GO-2023-0001", "GO-2023-0002", "GO-2023-0003"} {
+		if _, err := os.Stat(filepath.Join(dir, "ID", id+".json")); err != nil {
+			t.Error(err)
+		}
+	}
+
+	opened, err := OpenSnapshot(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !opened.Modified.Equal(s.Modified) {
+		t.Errorf("OpenSnapshot: modified %v, want %v", opened.Modified, s.Modified)
+	}
+}
+
+func TestImportSnapshotVersions(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "vulndb")
+	v1 := writeZip(t, 10, entry("GO-2023-0001", 3, "golang.org/amod", "1.0.2"))
+	v2 := writeZip(t, 20,
+		entry("GO-2023-0001", 3, "golang.org/amod", "1.0.2"),
+		entry("GO-2023-0002", 15, "golang.org/bmod", "0.5.0"),
+	)
+
+	s, err := ImportSnapshot(dir, v1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The modified time of a database with an index is that of the index.
+	if got, want := s.Version(), "2023-01-10T00:00:00Z"; got != want {
+		t.Errorf("Version() = %s, want %s", got, want)
+	}
+	// The index of the zip file is recomputed.
+	if got := readModules(t, dir); len(got) != 1 || got[0].Path != "golang.org/amod" {
+		t.Errorf("index/modules.json = %+v, want golang.org/amod only", got)
+	}
+
+	// Importing the same version is a no-op.
+	if s, err := ImportSnapshot(dir, v1); err != nil || s.Version() != "2023-01-10T00:00:00Z" {
+		t.Errorf("reimporting: got %v, %v", s, err)
+	}
+
+	// A newer version replaces the snapshot.
+	s, err = ImportSnapshot(dir, v2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := s.Version(), "2023-01-20T00:00:00Z"; got != want {
+		t.Errorf("Version() = %s, want %s", got, want)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "ID", "GO-2023-0002.json")); err != nil {
+		t.Error(err)
+	}
+
+	// An older version is an error, and leaves the snapshot unchanged.
+	if _, err := ImportSnapshot(dir, v1); err == nil || !strings.Contains(err.Error(), "older") {
+		t.Errorf("importing an older version: got error %v, want one about it being older", err)
+	}
+	if s, err := OpenSnapshot(dir); err != nil || s.Version() != "2023-01-20T00:00:00Z" {
+		t.Errorf("after importing an older version: got %v, %v", s, err)
+	}
+
+	// The temporary directories are removed.
+	entries, err := os.ReadDir(filepath.Dir(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("got %d files next to the snapshot, want none", len(entries)-1)
+	}
+}
+
+func TestImportSnapshotErrors(t *testing.T) {
+	src := writeDir(t, entry("GO-2023-0001", 3, "golang.org/amod"))
+
+	// A directory that is not a snapshot is not replaced.
+	notSnapshot := t.TempDir()
+	if _, err := ImportSnapshot(notSnapshot, src); err == nil {
+		t.Error("importing into a directory that is not a snapshot succeeded")
+	}
+
+	// Invalid sources.
+	empty := t.TempDir()
+	dup := writeDir(t, entry("GO-2023-0001", 3, "golang.org/amod"))
+	if err := os.WriteFile(filepath.Join(dup, "copy.json"), mustMarshal(t, entry("GO-2023-0001", 3, "golang.org/amod")), 0666); err != nil {
+		t.Fatal(err)
+	}
+	badID := writeDir(t, entry("../GO-2023-0001", 3, "golang.org/amod"))
+	notZip := filepath.Join(t.TempDir(), "vulndb.zip")
+	if err := os.WriteFile(notZip, []byte("not a zip file"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	for name, src := range map[string]string{
+		"empty":     empty,
+		"duplicate": dup,
+		"bad id":    badID,
+		"not zip":   notZip,
+		"missing":   filepath.Join(empty, "missing"),
+	} {
+		dir := filepath.Join(t.TempDir(), "vulndb")
+		if _, err := ImportSnapshot(dir, src); err == nil {
+			t.Errorf("%s: importing succeeded", name)
+		}
+		if _, err := os.Stat(dir); !os.IsNotExist(err) {
+			t.Errorf("%s: snapshot created after error", name)
+		}
+	}
+}
+
+func mustMarshal(t *testing.T, v interface{}) []byte {
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
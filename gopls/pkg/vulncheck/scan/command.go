@@ -61,7 +61,8 @@ func RunGovulncheck(ctx context.Context, pattern string, snapshot source.Snapsho
 	if dir != "" {
 		vulncheckargs = append(vulncheckargs, "-C", dir)
 	}
-	if db := getEnv(snapshot, "GOVULNDB"); db != "" {
+	db, modified := database(snapshot)
+	if db != "" {
 		vulncheckargs = append(vulncheckargs, "-db", db)
 	}
 	vulncheckargs = append(vulncheckargs, pattern)
@@ -115,18 +116,24 @@ func RunGovulncheck(ctx context.Context, pattern string, snapshot source.Snapsho
 		}
 		return x.Trace[0].Package < y.Trace[0].Package
 	})
+	if handler.dbModified != nil {
+		modified = *handler.dbModified
+	}
 	result := &vulncheck.Result{
-		Mode:     vulncheck.ModeGovulncheck,
-		AsOf:     time.Now(),
-		Entries:  handler.osvs,
-		Findings: findings,
+		Mode:       vulncheck.ModeGovulncheck,
+		AsOf:       time.Now(),
+		Entries:    handler.osvs,
+		Findings:   findings,
+		DB:         db,
+		DBModified: modified,
 	}
 	return result, nil
 }
 
 type govulncheckHandler struct {
-	logger io.Writer // forward progress reports to logger.
-	err    error
+	logger     io.Writer // forward progress reports to logger.
+	err        error
+	dbModified *time.Time // last modified time of the database, if reported
 
 	osvs     map[string]*osv.Entry
 	findings []*govulncheck.Finding
@@ -148,6 +155,7 @@ func (h *govulncheckHandler) Config(config *govulncheck.Config) error {
 		dbInfo := fmt.Sprintf("DB: %v", config.DB)
 		if config.DBLastModified != nil {
 			dbInfo += fmt.Sprintf(" (DB updated: %v)", config.DBLastModified.String())
+			h.dbModified = config.DBLastModified
 		}
 		fmt.Fprintln(h.logger, dbInfo)
 	}
@@ -298,7 +306,7 @@ func VulnerablePackages(ctx context.Context, snapshot source.Snapshot) (*vulnche
 	}
 
 	// GOVULNDB may point the test db URI.
-	db := getEnv(snapshot, "GOVULNDB")
+	db, modified := database(snapshot)
 
 	var group errgroup.Group
 	group.SetLimit(10) // limit govulncheck api runs
@@ -383,9 +391,11 @@ func VulnerablePackages(ctx context.Context, snapshot source.Snapshot) (*vulnche
 		return x.Trace[0].Package < y.Trace[0].Package
 	})
 	ret := &vulncheck.Result{
-		Entries:  osvs,
-		Findings: findings,
-		Mode:     vulncheck.ModeImports,
+		Entries:    osvs,
+		Findings:   findings,
+		Mode:       vulncheck.ModeImports,
+		DB:         db,
+		DBModified: modified,
 	}
 	return ret, nil
 }
@@ -0,0 +1,58 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.18
+// +build go1.18
+
+package scan
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/tools/gopls/pkg/lsp/protocol"
+	"golang.org/x/tools/gopls/pkg/lsp/source"
+	"golang.org/x/tools/gopls/pkg/vulncheck/osv"
+)
+
+// DefaultDBDir returns the directory of the vulnerability database
+// snapshot that 'gopls vulndb import' imports by default, and that
+// vulncheck uses unless GOVULNDB is set.
+//
+// It is not in the gopls subdirectory of the user cache directory, as
+// the file cache deletes the files it does not use from there.
+func DefaultDBDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "gopls-vulndb"), nil
+}
+
+// database returns the URL of the vulnerability database of vulncheck
+// for the snapshot, and its last modified time, if it is a local
+// snapshot.
+//
+// The database is GOVULNDB, if set, or else the snapshot in
+// DefaultDBDir, if any, or else "", which stands for the default
+// database at https://vuln.go.dev.
+func database(snapshot source.Snapshot) (string, time.Time) {
+	if db := getEnv(snapshot, "GOVULNDB"); db != "" {
+		var modified time.Time
+		if strings.HasPrefix(db, "file://") {
+			if s, err := osv.OpenSnapshot(protocol.DocumentURI(db).Path()); err == nil {
+				modified = s.Modified
+			}
+		}
+		return db, modified
+	}
+	if dir, err := DefaultDBDir(); err == nil {
+		if s, err := osv.OpenSnapshot(dir); err == nil {
+			return s.URI(), s.Modified
+		}
+	}
+	return "", time.Time{}
+}
@@ -7,6 +7,7 @@
 package vulncheck
 
 import (
+	"fmt"
 	"time"
 
 	gvc "golang.org/x/tools/gopls/pkg/vulncheck/govulncheck"
@@ -36,6 +37,39 @@ type Result struct {
 	// AsOf describes when this Result was computed using govulncheck.
 	// It is valid only with the govulncheck analysis mode.
 	AsOf time.Time `json:",omitempty"`
+
+	// DB is the URL of the vulnerability database used for the analysis,
+	// or "" for the default database, and DBModified its last modified
+	// time, if known.
+	DB         string    `json:",omitempty"`
+	DBModified time.Time `json:",omitempty"`
+}
+
+// MaxDBAge is the age of a vulnerability database beyond which gopls
+// warns that it is stale, as snapshots imported by 'gopls vulndb import'
+// may be.
+//
+// Mutable for testing.
+var MaxDBAge = 7 * 24 * time.Hour
+
+// StaleDBWarning returns a warning that the vulnerability database db,
+// last modified at the given time, is stale at time now, or "" if it is
+// not, or if its last modified time is unknown (zero).
+func StaleDBWarning(db string, modified, now time.Time) string {
+	if modified.IsZero() {
+		return ""
+	}
+	age := now.Sub(modified)
+	if age <= MaxDBAge {
+		return ""
+	}
+	if db == "" {
+		db = "The vulnerability database"
+	} else {
+		db = "The vulnerability database " + db
+	}
+	return fmt.Sprintf("%s was last modified on %s, %d days ago: vulnerabilities published since are not reported. Update it with 'gopls vulndb import'.",
+		db, modified.UTC().Format("2006-01-02"), int(age/(24*time.Hour)))
 }
 
 type AnalysisMode string
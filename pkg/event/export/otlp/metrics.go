@@ -0,0 +1,148 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package otlp
+
+import (
+	"time"
+
+	"golang.org/x/tools/pkg/event/export/metric"
+	"golang.org/x/tools/pkg/event/export/otlp/wire"
+	"golang.org/x/tools/pkg/event/label"
+)
+
+// convertMetric returns the OTLP metric for data, whose cumulative
+// values are measured from start. It returns nil for unknown types
+// of data.
+func convertMetric(data metric.Data, start time.Time) *wire.Metric {
+	switch d := data.(type) {
+	case *metric.Int64Data:
+		points := make([]*wire.NumberDataPoint, len(d.Rows))
+		for i, v := range d.Rows {
+			asInt := wire.Int64(v)
+			points[i] = numberDataPoint(d.Groups(), i, d.IsGauge, start, d.EndTime)
+			points[i].AsInt = &asInt
+		}
+		return scalarMetric(d.Info, d.IsGauge, points)
+
+	case *metric.Float64Data:
+		points := make([]*wire.NumberDataPoint, len(d.Rows))
+		for i, v := range d.Rows {
+			asDouble := v
+			points[i] = numberDataPoint(d.Groups(), i, d.IsGauge, start, d.EndTime)
+			points[i].AsDouble = &asDouble
+		}
+		return scalarMetric(d.Info, d.IsGauge, points)
+
+	case *metric.HistogramInt64Data:
+		bounds := make([]float64, len(d.Info.Buckets))
+		for i, b := range d.Info.Buckets {
+			bounds[i] = float64(b)
+		}
+		points := make([]*wire.HistogramDataPoint, len(d.Rows))
+		for i, row := range d.Rows {
+			sum, min, max := float64(row.Sum), float64(row.Min), float64(row.Max)
+			points[i] = &wire.HistogramDataPoint{
+				Attributes:        groupAttributes(d.Groups(), i),
+				StartTimeUnixNano: convertTimestamp(start),
+				TimeUnixNano:      convertTimestamp(d.EndTime),
+				Count:             wire.Uint64(row.Count),
+				Sum:               &sum,
+				BucketCounts:      bucketCounts(row.Values, row.Count),
+				ExplicitBounds:    bounds,
+				Min:               &min,
+				Max:               &max,
+			}
+		}
+		return &wire.Metric{
+			Name:        d.Info.Name,
+			Description: d.Info.Description,
+			Histogram: &wire.Histogram{
+				DataPoints:             points,
+				AggregationTemporality: wire.AggregationTemporalityCumulative,
+			},
+		}
+
+	case *metric.HistogramFloat64Data:
+		points := make([]*wire.HistogramDataPoint, len(d.Rows))
+		for i, row := range d.Rows {
+			sum, min, max := row.Sum, row.Min, row.Max
+			points[i] = &wire.HistogramDataPoint{
+				Attributes:        groupAttributes(d.Groups(), i),
+				StartTimeUnixNano: convertTimestamp(start),
+				TimeUnixNano:      convertTimestamp(d.EndTime),
+				Count:             wire.Uint64(row.Count),
+				Sum:               &sum,
+				BucketCounts:      bucketCounts(row.Values, row.Count),
+				ExplicitBounds:    d.Info.Buckets,
+				Min:               &min,
+				Max:               &max,
+			}
+		}
+		return &wire.Metric{
+			Name:        d.Info.Name,
+			Description: d.Info.Description,
+			Histogram: &wire.Histogram{
+				DataPoints:             points,
+				AggregationTemporality: wire.AggregationTemporalityCumulative,
+			},
+		}
+	}
+	return nil
+}
+
+// scalarMetric returns the gauge, or monotonic cumulative sum, metric
+// with the given points.
+func scalarMetric(info *metric.Scalar, isGauge bool, points []*wire.NumberDataPoint) *wire.Metric {
+	m := &wire.Metric{
+		Name:        info.Name,
+		Description: info.Description,
+	}
+	if isGauge {
+		m.Gauge = &wire.Gauge{DataPoints: points}
+	} else {
+		m.Sum = &wire.Sum{
+			DataPoints:             points,
+			AggregationTemporality: wire.AggregationTemporalityCumulative,
+			IsMonotonic:            true,
+		}
+	}
+	return m
+}
+
+// numberDataPoint returns the data point, without a value, for row i of
+// a scalar metric. Only sums have a start time.
+func numberDataPoint(groups [][]label.Label, i int, isGauge bool, start, end time.Time) *wire.NumberDataPoint {
+	point := &wire.NumberDataPoint{
+		Attributes:   groupAttributes(groups, i),
+		TimeUnixNano: convertTimestamp(end),
+	}
+	if !isGauge {
+		point.StartTimeUnixNano = convertTimestamp(start)
+	}
+	return point
+}
+
+// groupAttributes returns the attributes of the valid labels of group i.
+func groupAttributes(groups [][]label.Label, i int) []wire.KeyValue {
+	if i >= len(groups) {
+		return nil
+	}
+	return convertAttributes(label.NewList(groups[i]...), 0)
+}
+
+// bucketCounts converts the cumulative counts of the values of a
+// histogram row that are at most each bound into the per-bucket counts
+// of OTLP, including the final bucket of the values beyond the last
+// bound.
+func bucketCounts(cumulative []int64, count int64) []wire.Uint64 {
+	counts := make([]wire.Uint64, len(cumulative)+1)
+	prev := int64(0)
+	for i, c := range cumulative {
+		counts[i] = wire.Uint64(c - prev)
+		prev = c
+	}
+	counts[len(cumulative)] = wire.Uint64(count - prev)
+	return counts
+}
@@ -0,0 +1,343 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package otlp exports spans and metrics to an OpenTelemetry collector,
+// using the OTLP/HTTP protocol with JSON encoding.
+//
+// Spans are exported as OpenTelemetry traces, with the labels of their
+// start events as attributes and their log and label events as span
+// events. Metrics are exported as cumulative sums, gauges and
+// histograms. Both are batched, and sent periodically.
+package otlp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"golang.org/x/tools/pkg/event"
+	"golang.org/x/tools/pkg/event/core"
+	"golang.org/x/tools/pkg/event/export"
+	"golang.org/x/tools/pkg/event/export/metric"
+	"golang.org/x/tools/pkg/event/export/otlp/wire"
+	"golang.org/x/tools/pkg/event/label"
+)
+
+// DefaultAddress is the address of a collector running locally with
+// the default OTLP/HTTP port.
+const DefaultAddress = "http://localhost:4318"
+
+const (
+	tracesPath  = "/v1/traces"
+	metricsPath = "/v1/metrics"
+
+	scopeName = "golang.org/x/tools/pkg/event"
+)
+
+type Config struct {
+	Start   time.Time
+	Host    string
+	Process uint32
+	Client  *http.Client
+	Service string
+
+	// Address is the base URL of the collector, such as
+	// DefaultAddress. The traces and metrics are posted to the
+	// /v1/traces and /v1/metrics paths beneath it.
+	Address string
+
+	// Headers are added to every request, for example to
+	// authenticate with the collector.
+	Headers map[string]string
+
+	// Rate is the interval between exports.
+	Rate time.Duration
+
+	// MaxBatch is the maximum number of spans, or of metrics,
+	// sent in a single request.
+	MaxBatch int
+
+	// MaxQueue is the maximum number of spans held between exports.
+	// Once it is reached, further spans are dropped until the next
+	// export.
+	MaxQueue int
+
+	// MaxRetries is the number of times a request that failed
+	// with a transient error is retried, and RetryDelay the delay
+	// before the first retry, which doubles for each following one.
+	MaxRetries int
+	RetryDelay time.Duration
+}
+
+type Exporter struct {
+	config Config
+	done   chan struct{}
+
+	mu      sync.Mutex
+	spans   []*export.Span
+	metrics map[string]metric.Data // latest data of updated metrics, by handle
+	order   []string               // handles of metrics, in order of first update
+	dropped int                    // spans dropped since the last export
+
+	sendMu sync.Mutex // held while exporting
+	closed bool
+}
+
+// Connect creates a process specific exporter that uploads its
+// telemetry to the collector at the address of config. It returns nil
+// if the address is empty or "off".
+//
+// The exporter uploads periodically until it is closed.
+func Connect(config *Config) *Exporter {
+	if config == nil || config.Address == "" || config.Address == "off" {
+		return nil
+	}
+	resolved := *config
+	if resolved.Host == "" {
+		hostname, _ := os.Hostname()
+		resolved.Host = hostname
+	}
+	if resolved.Process == 0 {
+		resolved.Process = uint32(os.Getpid())
+	}
+	if resolved.Client == nil {
+		resolved.Client = http.DefaultClient
+	}
+	if resolved.Service == "" {
+		resolved.Service = filepath.Base(os.Args[0])
+	}
+	if resolved.Start.IsZero() {
+		resolved.Start = time.Now()
+	}
+	if resolved.Rate == 0 {
+		resolved.Rate = 2 * time.Second
+	}
+	if resolved.MaxBatch == 0 {
+		resolved.MaxBatch = 512
+	}
+	if resolved.MaxQueue == 0 {
+		resolved.MaxQueue = 4 * resolved.MaxBatch
+	}
+	if resolved.MaxRetries == 0 {
+		resolved.MaxRetries = 3
+	}
+	if resolved.RetryDelay == 0 {
+		resolved.RetryDelay = 500 * time.Millisecond
+	}
+	exporter := &Exporter{
+		config:  resolved,
+		done:    make(chan struct{}),
+		metrics: make(map[string]metric.Data),
+	}
+	go func() {
+		ticker := time.NewTicker(exporter.config.Rate)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				exporter.Flush()
+			case <-exporter.done:
+				return
+			}
+		}
+	}()
+	return exporter
+}
+
+func (e *Exporter) ProcessEvent(ctx context.Context, ev core.Event, lm label.Map) context.Context {
+	switch {
+	case event.IsEnd(ev):
+		span := export.GetSpan(ctx)
+		if span == nil {
+			break
+		}
+		e.mu.Lock()
+		if len(e.spans) < e.config.MaxQueue {
+			e.spans = append(e.spans, span)
+		} else {
+			e.dropped++
+		}
+		e.mu.Unlock()
+	case event.IsMetric(ev):
+		data := metric.Entries.Get(lm).([]metric.Data)
+		e.mu.Lock()
+		for _, d := range data {
+			// Metric data is cumulative, so only the latest
+			// update of each metric needs to be exported.
+			if _, ok := e.metrics[d.Handle()]; !ok {
+				e.order = append(e.order, d.Handle())
+			}
+			e.metrics[d.Handle()] = d
+		}
+		e.mu.Unlock()
+	}
+	return ctx
+}
+
+// Flush exports the spans that ended, and the metrics that were updated,
+// since the previous export.
+func (e *Exporter) Flush() {
+	e.sendMu.Lock()
+	defer e.sendMu.Unlock()
+	if e.closed {
+		return
+	}
+	e.flush()
+}
+
+// Close stops the periodic export, and exports any remaining telemetry.
+func (e *Exporter) Close() {
+	e.sendMu.Lock()
+	defer e.sendMu.Unlock()
+	if e.closed {
+		return
+	}
+	e.closed = true
+	close(e.done)
+	e.flush()
+}
+
+// flush is Flush, with sendMu held.
+func (e *Exporter) flush() {
+	e.mu.Lock()
+	spans := e.spans
+	e.spans = nil
+	dropped := e.dropped
+	e.dropped = 0
+	var metrics []metric.Data
+	for _, handle := range e.order {
+		metrics = append(metrics, e.metrics[handle])
+	}
+	e.metrics = make(map[string]metric.Data)
+	e.order = nil
+	e.mu.Unlock()
+
+	if dropped > 0 {
+		errorInExport("otlp dropped %d spans, exceeding the queue limit of %d", dropped, e.config.MaxQueue)
+	}
+	resource := e.config.buildResource()
+	scope := &wire.InstrumentationScope{Name: scopeName}
+	for len(spans) > 0 {
+		n := min(len(spans), e.config.MaxBatch)
+		batch := make([]*wire.Span, n)
+		for i, s := range spans[:n] {
+			batch[i] = convertSpan(s)
+		}
+		spans = spans[n:]
+		e.send(tracesPath, &wire.ExportTraceServiceRequest{
+			ResourceSpans: []*wire.ResourceSpans{{
+				Resource:   resource,
+				ScopeSpans: []*wire.ScopeSpans{{Scope: scope, Spans: batch}},
+			}},
+		})
+	}
+	for len(metrics) > 0 {
+		n := min(len(metrics), e.config.MaxBatch)
+		var batch []*wire.Metric
+		for _, m := range metrics[:n] {
+			if converted := convertMetric(m, e.config.Start); converted != nil {
+				batch = append(batch, converted)
+			}
+		}
+		metrics = metrics[n:]
+		if len(batch) == 0 {
+			continue
+		}
+		e.send(metricsPath, &wire.ExportMetricsServiceRequest{
+			ResourceMetrics: []*wire.ResourceMetrics{{
+				Resource:     resource,
+				ScopeMetrics: []*wire.ScopeMetrics{{Scope: scope, Metrics: batch}},
+			}},
+		})
+	}
+}
+
+func (cfg *Config) buildResource() *wire.Resource {
+	return &wire.Resource{
+		Attributes: []wire.KeyValue{
+			{Key: "service.name", Value: wire.StringValue(cfg.Service)},
+			{Key: "host.name", Value: wire.StringValue(cfg.Host)},
+			{Key: "process.pid", Value: wire.IntValue(int64(cfg.Process))},
+			{Key: "telemetry.sdk.language", Value: wire.StringValue("go")},
+			{Key: "telemetry.sdk.name", Value: wire.StringValue("x/tools")},
+		},
+	}
+}
+
+// send posts message to the endpoint of the collector, retrying with
+// exponential backoff while it fails with a transient error.
+func (e *Exporter) send(endpoint string, message interface{}) {
+	blob, err := json.Marshal(message)
+	if err != nil {
+		errorInExport("otlp failed to marshal message for %v: %v", endpoint, err)
+		return
+	}
+	uri := e.config.Address + endpoint
+	delay := e.config.RetryDelay
+	for attempt := 0; ; attempt++ {
+		retry, err := e.post(uri, blob)
+		if err == nil {
+			return
+		}
+		if !retry || attempt == e.config.MaxRetries {
+			errorInExport("otlp failed to send message to %v: %v", uri, err)
+			return
+		}
+		select {
+		case <-time.After(delay):
+		case <-e.done:
+			// Don't delay the final export on Close.
+		}
+		delay *= 2
+	}
+}
+
+// post makes a single attempt at posting blob to uri, reporting
+// whether a failure is transient, and may be retried.
+func (e *Exporter) post(uri string, blob []byte) (retry bool, _ error) {
+	req, err := http.NewRequest("POST", uri, bytes.NewReader(blob))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.config.Headers {
+		req.Header.Set(k, v)
+	}
+	res, err := e.config.Client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	io.Copy(io.Discard, res.Body) // allow reuse of the connection
+	res.Body.Close()
+	switch res.StatusCode {
+	case http.StatusOK, http.StatusAccepted:
+		return false, nil
+	case http.StatusTooManyRequests,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true, fmt.Errorf("%s", res.Status)
+	default:
+		return false, fmt.Errorf("%s", res.Status)
+	}
+}
+
+func errorInExport(message string, args ...interface{}) {
+	// This function is useful when debugging the exporter, but in general we
+	// want to just drop any export
+}
+
+func min(x, y int) int {
+	if x < y {
+		return x
+	}
+	return y
+}
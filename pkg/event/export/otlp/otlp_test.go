@@ -0,0 +1,423 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package otlp_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/tools/pkg/event"
+	"golang.org/x/tools/pkg/event/core"
+	"golang.org/x/tools/pkg/event/export"
+	"golang.org/x/tools/pkg/event/export/metric"
+	"golang.org/x/tools/pkg/event/export/otlp"
+	"golang.org/x/tools/pkg/event/export/otlp/wire"
+	"golang.org/x/tools/pkg/event/keys"
+	"golang.org/x/tools/pkg/event/label"
+)
+
+const testResourceStr = `"resource":{
+	"attributes":[
+		{"key":"service.name","value":{"stringValue":"otlp-tests"}},
+		{"key":"host.name","value":{"stringValue":"tester"}},
+		{"key":"process.pid","value":{"intValue":"1"}},
+		{"key":"telemetry.sdk.language","value":{"stringValue":"go"}},
+		{"key":"telemetry.sdk.name","value":{"stringValue":"x/tools"}}
+	]
+},`
+
+const testScopeStr = `"scope":{"name":"golang.org/x/tools/pkg/event"},`
+
+var (
+	keyMethod = keys.NewString("method", "a metric grouping key")
+	keyCount  = keys.NewInt("count", "A test int key")
+	keyRatio  = keys.NewFloat64("ratio", "A test float64 key")
+	keyRetry  = keys.NewBoolean("retry", "A test boolean key")
+
+	requests  = keys.NewInt64("requests", "Number of requests")
+	openFiles = keys.NewInt64("open_files", "Number of open files")
+	latencyMs = keys.NewFloat64("latency", "The latency in milliseconds")
+
+	metricRequests = metric.Scalar{
+		Name:        "requests",
+		Description: "The number of requests",
+		Keys:        []label.Key{keyMethod},
+	}
+
+	metricOpenFiles = metric.Scalar{
+		Name:        "open_files",
+		Description: "The number of open files",
+	}
+
+	metricLatency = metric.HistogramFloat64{
+		Name:        "latency_ms",
+		Description: "The latency of requests in milliseconds",
+		Keys:        []label.Key{keyMethod},
+		Buckets:     []float64{10, 100},
+	}
+)
+
+// A collector is a stand-in for an OTLP/HTTP collector, which records
+// the requests it receives.
+type collector struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	failures []int               // statuses of the responses to the first requests
+	received map[string][][]byte // bodies of the accepted requests, by path
+	attempts int
+}
+
+func newCollector(t *testing.T) *collector {
+	c := &collector{received: make(map[string][][]byte)}
+	c.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if got := req.Header.Get("Content-Type"); got != "application/json" {
+			t.Errorf("Content-Type = %q, want application/json", got)
+		}
+		if got := req.Header.Get("Authorization"); got != "Bearer token" {
+			t.Errorf("Authorization = %q, want the configured header", got)
+		}
+		data, err := io.ReadAll(req.Body)
+		if err != nil {
+			t.Error(err)
+		}
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.attempts++
+		if len(c.failures) > 0 {
+			status := c.failures[0]
+			c.failures = c.failures[1:]
+			w.WriteHeader(status)
+			return
+		}
+		c.received[req.URL.Path] = append(c.received[req.URL.Path], data)
+	}))
+	t.Cleanup(c.Close)
+	return c
+}
+
+// reset sets the statuses of the responses to the next requests, and
+// resets the count of attempts.
+func (c *collector) reset(failures ...int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.failures = failures
+	c.attempts = 0
+}
+
+func (c *collector) attemptCount() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.attempts
+}
+
+func (c *collector) get(path string) [][]byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	data := c.received[path]
+	delete(c.received, path)
+	return data
+}
+
+// registerExporter installs an exporter to the collector, with
+// deterministic times and span IDs.
+func registerExporter(t *testing.T, c *collector, maxBatch int) *otlp.Exporter {
+	cfg := otlp.Config{
+		Host:       "tester",
+		Process:    1,
+		Service:    "otlp-tests",
+		Address:    c.URL,
+		Headers:    map[string]string{"Authorization": "Bearer token"},
+		Rate:       time.Hour, // only export on Flush
+		MaxBatch:   maxBatch,
+		RetryDelay: time.Millisecond,
+	}
+	cfg.Start, _ = time.Parse(time.RFC3339Nano, "1970-01-01T00:00:10Z")
+	exporter := otlp.Connect(&cfg)
+	t.Cleanup(exporter.Close)
+
+	metrics := metric.Config{}
+	metricRequests.SumInt64(&metrics, requests)
+	metricOpenFiles.LatestInt64(&metrics, openFiles)
+	metricLatency.Record(&metrics, latencyMs)
+
+	e := exporter.ProcessEvent
+	e = metrics.Exporter(e)
+	e = spanFixer(e)
+	e = export.Spans(e)
+	e = export.Labels(e)
+	e = timeFixer(e)
+	event.SetExporter(e)
+	t.Cleanup(func() { event.SetExporter(nil) })
+	return exporter
+}
+
+func timeFixer(output event.Exporter) event.Exporter {
+	start, _ := time.Parse(time.RFC3339Nano, "1970-01-01T00:00:30Z")
+	at, _ := time.Parse(time.RFC3339Nano, "1970-01-01T00:00:40Z")
+	end, _ := time.Parse(time.RFC3339Nano, "1970-01-01T00:00:50Z")
+	return func(ctx context.Context, ev core.Event, lm label.Map) context.Context {
+		switch {
+		case event.IsStart(ev):
+			ev = core.CloneEvent(ev, start)
+		case event.IsEnd(ev):
+			ev = core.CloneEvent(ev, end)
+		default:
+			ev = core.CloneEvent(ev, at)
+		}
+		return output(ctx, ev, lm)
+	}
+}
+
+// spanFixer numbers the spans from 1, in the order they start, within
+// a single trace.
+func spanFixer(output event.Exporter) event.Exporter {
+	var next byte
+	return func(ctx context.Context, ev core.Event, lm label.Map) context.Context {
+		if event.IsStart(ev) {
+			span := export.GetSpan(ctx)
+			next++
+			span.ID = export.SpanContext{
+				TraceID: export.TraceID{15: 1},
+				SpanID:  export.SpanID{7: next},
+			}
+			if span.ParentID.IsValid() {
+				span.ParentID = export.SpanID{7: next - 1}
+			}
+		}
+		return output(ctx, ev, lm)
+	}
+}
+
+func checkJSON(t *testing.T, got, want []byte) {
+	t.Helper()
+	// compare the compact form, to allow for formatting differences
+	g := &bytes.Buffer{}
+	if err := json.Compact(g, got); err != nil {
+		t.Fatal(err)
+	}
+	w := &bytes.Buffer{}
+	if err := json.Compact(w, want); err != nil {
+		t.Fatal(err)
+	}
+	if g.String() != w.String() {
+		t.Fatalf("Got:\n%s\nWant:\n%s", g, w)
+	}
+}
+
+func TestTrace(t *testing.T) {
+	c := newCollector(t)
+	exporter := registerExporter(t, c, 0)
+
+	ctx := context.Background()
+	ctx, done := event.Start(ctx, "parent", keyMethod.Of("textDocument/hover"), keyRetry.Of(true))
+	ctx2, done2 := event.Start(ctx, "child", keyCount.Of(3), keyRatio.Of(0.5))
+	event.Log(ctx2, "cache miss", keyCount.Of(4))
+	event.Error(ctx2, "", errors.New("no package"))
+	done2()
+	done()
+	exporter.Flush()
+
+	got := c.get("/v1/traces")
+	if len(got) != 1 {
+		t.Fatalf("received %d trace requests, want 1", len(got))
+	}
+	checkJSON(t, got[0], []byte(`{"resourceSpans":[{`+testResourceStr+`"scopeSpans":[{`+testScopeStr+`"spans":[
+	{
+		"traceId":"00000000000000000000000000000001",
+		"spanId":"0000000000000002",
+		"parentSpanId":"0000000000000001",
+		"name":"child",
+		"kind":1,
+		"startTimeUnixNano":"30000000000",
+		"endTimeUnixNano":"50000000000",
+		"attributes":[
+			{"key":"count","value":{"intValue":"3"}},
+			{"key":"ratio","value":{"doubleValue":0.5}}
+		],
+		"events":[
+			{
+				"timeUnixNano":"40000000000",
+				"name":"cache miss",
+				"attributes":[{"key":"count","value":{"intValue":"4"}}]
+			},
+			{
+				"timeUnixNano":"40000000000",
+				"name":"event",
+				"attributes":[{"key":"error","value":{"stringValue":"no package"}}]
+			}
+		],
+		"status":{"message":"no package","code":2}
+	},
+	{
+		"traceId":"00000000000000000000000000000001",
+		"spanId":"0000000000000001",
+		"name":"parent",
+		"kind":1,
+		"startTimeUnixNano":"30000000000",
+		"endTimeUnixNano":"50000000000",
+		"attributes":[
+			{"key":"method","value":{"stringValue":"textDocument/hover"}},
+			{"key":"retry","value":{"boolValue":true}}
+		]
+	}
+	]}]}]}`))
+}
+
+func TestMetrics(t *testing.T) {
+	c := newCollector(t)
+	exporter := registerExporter(t, c, 0)
+
+	ctx := event.Label(context.Background(), keyMethod.Of("hover"))
+	event.Metric(ctx, requests.Of(1))
+	event.Metric(ctx, requests.Of(2))
+	event.Metric(ctx, latencyMs.Of(5))
+	event.Metric(ctx, latencyMs.Of(50))
+	event.Metric(ctx, latencyMs.Of(500))
+	event.Metric(context.Background(), openFiles.Of(7))
+	exporter.Flush()
+
+	got := c.get("/v1/metrics")
+	if len(got) != 1 {
+		t.Fatalf("received %d metrics requests, want 1", len(got))
+	}
+	checkJSON(t, got[0], []byte(`{"resourceMetrics":[{`+testResourceStr+`"scopeMetrics":[{`+testScopeStr+`"metrics":[
+	{
+		"name":"requests",
+		"description":"The number of requests",
+		"sum":{
+			"dataPoints":[{
+				"attributes":[{"key":"method","value":{"stringValue":"hover"}}],
+				"startTimeUnixNano":"10000000000",
+				"timeUnixNano":"40000000000",
+				"asInt":"3"
+			}],
+			"aggregationTemporality":2,
+			"isMonotonic":true
+		}
+	},
+	{
+		"name":"latency_ms",
+		"description":"The latency of requests in milliseconds",
+		"histogram":{
+			"dataPoints":[{
+				"attributes":[{"key":"method","value":{"stringValue":"hover"}}],
+				"startTimeUnixNano":"10000000000",
+				"timeUnixNano":"40000000000",
+				"count":"3",
+				"sum":555,
+				"bucketCounts":["1","1","1"],
+				"explicitBounds":[10,100],
+				"min":5,
+				"max":500
+			}],
+			"aggregationTemporality":2
+		}
+	},
+	{
+		"name":"open_files",
+		"description":"The number of open files",
+		"gauge":{
+			"dataPoints":[{
+				"timeUnixNano":"40000000000",
+				"asInt":"7"
+			}]
+		}
+	}
+	]}]}]}`))
+
+	// Only metrics updated since the previous export are exported.
+	event.Metric(ctx, requests.Of(1))
+	exporter.Flush()
+	got = c.get("/v1/metrics")
+	if len(got) != 1 {
+		t.Fatalf("received %d metrics requests, want 1", len(got))
+	}
+	var req wire.ExportMetricsServiceRequest
+	if err := json.Unmarshal(got[0], &req); err != nil {
+		t.Fatal(err)
+	}
+	metrics := req.ResourceMetrics[0].ScopeMetrics[0].Metrics
+	if len(metrics) != 1 || metrics[0].Name != "requests" || *metrics[0].Sum.DataPoints[0].AsInt != 4 {
+		t.Errorf("second export = %s, want the cumulative requests sum of 4", got[0])
+	}
+}
+
+func TestBatchingAndRetry(t *testing.T) {
+	c := newCollector(t)
+	exporter := registerExporter(t, c, 2)
+
+	spans := func(n int) {
+		for i := 0; i < n; i++ {
+			_, done := event.Start(context.Background(), "span")
+			done()
+		}
+	}
+	countSpans := func(t *testing.T, got [][]byte) []int {
+		var counts []int
+		for _, data := range got {
+			var req wire.ExportTraceServiceRequest
+			if err := json.Unmarshal(data, &req); err != nil {
+				t.Fatal(err)
+			}
+			counts = append(counts, len(req.ResourceSpans[0].ScopeSpans[0].Spans))
+		}
+		return counts
+	}
+
+	// Spans are sent in batches of at most MaxBatch, and transient
+	// failures are retried.
+	c.reset(http.StatusServiceUnavailable, http.StatusTooManyRequests)
+	spans(5)
+	exporter.Flush()
+	if got, want := countSpans(t, c.get("/v1/traces")), []int{2, 2, 1}; !equalInts(got, want) {
+		t.Errorf("batches = %v, want %v", got, want)
+	}
+	if got := c.attemptCount(); got != 5 {
+		t.Errorf("attempts = %d, want 5", got)
+	}
+
+	// Other failures are not retried.
+	c.reset(http.StatusBadRequest)
+	spans(1)
+	exporter.Flush()
+	if got := c.get("/v1/traces"); len(got) != 0 {
+		t.Errorf("received %d requests after a permanent failure, want 0", len(got))
+	}
+	if got := c.attemptCount(); got != 1 {
+		t.Errorf("attempts = %d, want 1", got)
+	}
+
+	// Retries stop after MaxRetries.
+	c.reset(502, 502, 502, 502, 502)
+	spans(1)
+	exporter.Flush()
+	if got := c.get("/v1/traces"); len(got) != 0 {
+		t.Errorf("received %d requests after repeated failures, want 0", len(got))
+	}
+	if got := c.attemptCount(); got != 4 {
+		t.Errorf("attempts = %d, want 4", got)
+	}
+}
+
+func equalInts(x, y []int) bool {
+	if len(x) != len(y) {
+		return false
+	}
+	for i := range x {
+		if x[i] != y[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,120 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package otlp
+
+import (
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"golang.org/x/tools/pkg/event/core"
+	"golang.org/x/tools/pkg/event/export"
+	"golang.org/x/tools/pkg/event/export/otlp/wire"
+	"golang.org/x/tools/pkg/event/keys"
+	"golang.org/x/tools/pkg/event/label"
+)
+
+func convertTimestamp(t time.Time) wire.Uint64 {
+	if t.IsZero() {
+		return 0
+	}
+	return wire.Uint64(t.UnixNano())
+}
+
+func convertSpan(span *export.Span) *wire.Span {
+	result := &wire.Span{
+		TraceID:           hex.EncodeToString(span.ID.TraceID[:]),
+		SpanID:            hex.EncodeToString(span.ID.SpanID[:]),
+		Name:              span.Name,
+		Kind:              wire.SpanKindInternal,
+		StartTimeUnixNano: convertTimestamp(span.Start().At()),
+		EndTimeUnixNano:   convertTimestamp(span.Finish().At()),
+		Attributes:        convertAttributes(span.Start(), 1),
+	}
+	if span.ParentID.IsValid() {
+		result.ParentSpanID = hex.EncodeToString(span.ParentID[:])
+	}
+	for _, ev := range span.Events() {
+		result.Events = append(result.Events, convertEvent(ev))
+		// A span that logged an error failed.
+		if err := keys.Err.Get(ev); err != nil && result.Status == nil {
+			result.Status = &wire.Status{Code: wire.StatusCodeError, Message: err.Error()}
+		}
+	}
+	return result
+}
+
+// convertAttributes returns the attributes for the valid labels of list,
+// starting at index.
+func convertAttributes(list label.List, index int) []wire.KeyValue {
+	var attributes []wire.KeyValue
+	for ; list.Valid(index); index++ {
+		l := list.Label(index)
+		if !l.Valid() || l.Key() == keys.Label {
+			continue
+		}
+		attributes = append(attributes, wire.KeyValue{
+			Key:   l.Key().Name(),
+			Value: convertAttribute(l),
+		})
+	}
+	return attributes
+}
+
+func convertAttribute(l label.Label) wire.AnyValue {
+	switch key := l.Key().(type) {
+	case *keys.Int:
+		return wire.IntValue(int64(key.From(l)))
+	case *keys.Int8:
+		return wire.IntValue(int64(key.From(l)))
+	case *keys.Int16:
+		return wire.IntValue(int64(key.From(l)))
+	case *keys.Int32:
+		return wire.IntValue(int64(key.From(l)))
+	case *keys.Int64:
+		return wire.IntValue(key.From(l))
+	case *keys.UInt:
+		return wire.IntValue(int64(key.From(l)))
+	case *keys.UInt8:
+		return wire.IntValue(int64(key.From(l)))
+	case *keys.UInt16:
+		return wire.IntValue(int64(key.From(l)))
+	case *keys.UInt32:
+		return wire.IntValue(int64(key.From(l)))
+	case *keys.UInt64:
+		return wire.IntValue(int64(key.From(l)))
+	case *keys.Float32:
+		return wire.DoubleValue(float64(key.From(l)))
+	case *keys.Float64:
+		return wire.DoubleValue(key.From(l))
+	case *keys.Boolean:
+		return wire.BoolValue(key.From(l))
+	case *keys.String:
+		return wire.StringValue(key.From(l))
+	case *keys.Error:
+		return wire.StringValue(key.From(l).Error())
+	case *keys.Value:
+		return wire.StringValue(fmt.Sprint(key.From(l)))
+	default:
+		return wire.StringValue(fmt.Sprintf("%T", key))
+	}
+}
+
+// convertEvent converts a log or label event of a span. Its message,
+// if any, is the name of the span event.
+func convertEvent(ev core.Event) *wire.Event {
+	name, index := "", 0
+	if l := ev.Label(0); l.Key() == keys.Msg {
+		name, index = keys.Msg.From(l), 1
+	}
+	if name == "" {
+		name = "event"
+	}
+	return &wire.Event{
+		TimeUnixNano: convertTimestamp(ev.At()),
+		Name:         name,
+		Attributes:   convertAttributes(ev, index),
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package wire defines the JSON encoding of the OpenTelemetry protocol
+// (OTLP) messages that are sent to a collector over HTTP.
+//
+// Only the fields needed to export traces and metrics are defined.
+// The encoding follows the protobuf JSON mapping as specified by
+// https://opentelemetry.io/docs/specs/otlp/#json-protobuf-encoding:
+// field names are lowerCamelCase, 64-bit integers are encoded as
+// decimal strings, and trace and span IDs as hexadecimal strings.
+package wire
+
+// This file holds common OTLP types
+
+import (
+	"encoding/json"
+	"strconv"
+)
+
+type Resource struct {
+	Attributes []KeyValue `json:"attributes,omitempty"`
+}
+
+type InstrumentationScope struct {
+	Name    string `json:"name,omitempty"`
+	Version string `json:"version,omitempty"`
+}
+
+type KeyValue struct {
+	Key   string   `json:"key"`
+	Value AnyValue `json:"value"`
+}
+
+// An AnyValue holds exactly one of its fields.
+type AnyValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	IntValue    *Int64   `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+func StringValue(v string) AnyValue  { return AnyValue{StringValue: &v} }
+func BoolValue(v bool) AnyValue      { return AnyValue{BoolValue: &v} }
+func IntValue(v int64) AnyValue      { i := Int64(v); return AnyValue{IntValue: &i} }
+func DoubleValue(v float64) AnyValue { return AnyValue{DoubleValue: &v} }
+
+// Int64 is an int64 encoded as a decimal string.
+type Int64 int64
+
+func (i Int64) MarshalJSON() ([]byte, error) {
+	return json.Marshal(strconv.FormatInt(int64(i), 10))
+}
+
+func (i *Int64) UnmarshalJSON(data []byte) error {
+	return unmarshalInt(data, func(s string) error {
+		v, err := strconv.ParseInt(s, 10, 64)
+		*i = Int64(v)
+		return err
+	})
+}
+
+// Uint64 is a uint64 encoded as a decimal string.
+type Uint64 uint64
+
+func (u Uint64) MarshalJSON() ([]byte, error) {
+	return json.Marshal(strconv.FormatUint(uint64(u), 10))
+}
+
+func (u *Uint64) UnmarshalJSON(data []byte) error {
+	return unmarshalInt(data, func(s string) error {
+		v, err := strconv.ParseUint(s, 10, 64)
+		*u = Uint64(v)
+		return err
+	})
+}
+
+// unmarshalInt calls parse with the decimal digits of data, which may
+// be a JSON string or number.
+func unmarshalInt(data []byte, parse func(string) error) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		s = string(data)
+	}
+	return parse(s)
+}
@@ -0,0 +1,60 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package wire
+
+type ExportTraceServiceRequest struct {
+	ResourceSpans []*ResourceSpans `json:"resourceSpans"`
+}
+
+type ResourceSpans struct {
+	Resource   *Resource     `json:"resource,omitempty"`
+	ScopeSpans []*ScopeSpans `json:"scopeSpans"`
+}
+
+type ScopeSpans struct {
+	Scope *InstrumentationScope `json:"scope,omitempty"`
+	Spans []*Span               `json:"spans"`
+}
+
+type Span struct {
+	TraceID           string     `json:"traceId"`
+	SpanID            string     `json:"spanId"`
+	ParentSpanID      string     `json:"parentSpanId,omitempty"`
+	Name              string     `json:"name"`
+	Kind              SpanKind   `json:"kind,omitempty"`
+	StartTimeUnixNano Uint64     `json:"startTimeUnixNano"`
+	EndTimeUnixNano   Uint64     `json:"endTimeUnixNano"`
+	Attributes        []KeyValue `json:"attributes,omitempty"`
+	Events            []*Event   `json:"events,omitempty"`
+	Status            *Status    `json:"status,omitempty"`
+}
+
+type SpanKind int32
+
+const (
+	SpanKindUnspecified SpanKind = 0
+	SpanKindInternal    SpanKind = 1
+	SpanKindServer      SpanKind = 2
+	SpanKindClient      SpanKind = 3
+)
+
+type Event struct {
+	TimeUnixNano Uint64     `json:"timeUnixNano"`
+	Name         string     `json:"name"`
+	Attributes   []KeyValue `json:"attributes,omitempty"`
+}
+
+type Status struct {
+	Message string     `json:"message,omitempty"`
+	Code    StatusCode `json:"code,omitempty"`
+}
+
+type StatusCode int32
+
+const (
+	StatusCodeUnset StatusCode = 0
+	StatusCodeOK    StatusCode = 1
+	StatusCodeError StatusCode = 2
+)
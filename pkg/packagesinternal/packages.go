@@ -20,3 +20,19 @@ var ForTest int    // must be set as a LoadMode to call GetForTest
 
 var SetModFlag = func(config interface{}, value string) {}
 var SetModFile = func(config interface{}, value string) {}
+
+// DriverExtensions holds the optional driver protocol extensions declared by
+// an external GOPACKAGESDRIVER in its response.
+type DriverExtensions struct {
+	// Incremental reports that the driver answers "file=" queries with only
+	// the packages affected by those files.
+	Incremental bool
+
+	// FileOwners maps file names, including non-Go build inputs, to the IDs
+	// of the packages that depend on them.
+	FileOwners map[string][]string
+}
+
+// SetDriverExtensions arranges for packages.Load with the given config to
+// record into ext the extensions declared by an external driver.
+var SetDriverExtensions = func(config interface{}, ext *DriverExtensions) {}